@@ -3,12 +3,12 @@ module github.com/drinkthere/go-aster
 go 1.21
 
 require (
+	github.com/gorilla/websocket v1.5.3
 	github.com/json-iterator/go v1.1.12
 	golang.org/x/crypto v0.19.0
 )
 
 require (
-	github.com/gorilla/websocket v1.5.3 // indirect
 	github.com/juju/ratelimit v1.0.2 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
@@ -0,0 +1,267 @@
+package astertest
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// spotBalance is a spot asset balance
+type spotBalance struct {
+	free   float64
+	locked float64
+}
+
+// position is a futures position
+type position struct {
+	symbol     string
+	side       string
+	amount     float64 // negative when short
+	entryPrice float64
+	updateTime int64
+}
+
+// accountTrade is a fill of an account order
+type accountTrade struct {
+	id              int64
+	symbol          string
+	orderID         int64
+	price           float64
+	qty             float64
+	commission      float64
+	commissionAsset string
+	time            int64
+	isBuyer         bool
+	isMaker         bool
+}
+
+// account is the state of one API key
+type account struct {
+	apiKey    string
+	secretKey string
+
+	spot       map[string]*spotBalance
+	spotAssets []string // in creation order
+	spotTrades map[string][]*accountTrade
+
+	wallet      map[string]float64
+	positions   map[string]*position // by symbol|positionSide
+	leverage    map[string]int
+	marginTypes map[string]string
+}
+
+// listenKey is a user data stream of an account on one market
+type listenKey struct {
+	key    string
+	acct   *account
+	market Market
+}
+
+// newAccount creates an empty account
+func newAccount(apiKey, secretKey string) *account {
+	return &account{
+		apiKey:      apiKey,
+		secretKey:   secretKey,
+		spot:        map[string]*spotBalance{},
+		spotTrades:  map[string][]*accountTrade{},
+		wallet:      map[string]float64{},
+		positions:   map[string]*position{},
+		leverage:    map[string]int{},
+		marginTypes: map[string]string{},
+	}
+}
+
+// spotBalance returns the balance of an asset, creating it if needed
+func (a *account) spotBalance(asset string) *spotBalance {
+	b, ok := a.spot[asset]
+	if !ok {
+		b = &spotBalance{}
+		a.spot[asset] = b
+		a.spotAssets = append(a.spotAssets, asset)
+	}
+	return b
+}
+
+// position returns a position, creating it if needed
+func (a *account) position(symbol, side string) *position {
+	if side == "" {
+		side = "BOTH"
+	}
+	key := symbol + "|" + side
+	p, ok := a.positions[key]
+	if !ok {
+		p = &position{symbol: symbol, side: side}
+		a.positions[key] = p
+	}
+	return p
+}
+
+// symbolLeverage returns the leverage of a symbol, 20 by default
+func (a *account) symbolLeverage(symbol string) int {
+	if l, ok := a.leverage[symbol]; ok {
+		return l
+	}
+	return 20
+}
+
+// symbolMarginType returns the margin type of a symbol, CROSS by default
+func (a *account) symbolMarginType(symbol string) string {
+	if t, ok := a.marginTypes[symbol]; ok {
+		return t
+	}
+	return "CROSS"
+}
+
+// request is an authenticated request with its query and form parameters
+type request struct {
+	acct   *account
+	params url.Values
+}
+
+// get returns a parameter
+func (r *request) get(key string) string {
+	return r.params.Get(key)
+}
+
+// int64 returns an integer parameter, 0 if absent or malformed
+func (r *request) int64(key string) int64 {
+	v, _ := strconv.ParseInt(r.params.Get(key), 10, 64)
+	return v
+}
+
+// float returns a decimal parameter, 0 if absent or malformed
+func (r *request) float(key string) float64 {
+	return parseFloat(r.params.Get(key))
+}
+
+// bool returns a boolean parameter
+func (r *request) bool(key string) bool {
+	return r.params.Get(key) == "true"
+}
+
+// authenticate parses the parameters of r and checks its API key, and its
+// signature and timestamp when signed. It must be called with s.mu held.
+func (s *Server) authenticate(r *http.Request, sec secType) (*request, *apiError) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, errMandatoryParam
+	}
+	params, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return nil, errMandatoryParam
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, errMandatoryParam
+	}
+	for k, vs := range form {
+		params[k] = vs
+	}
+	req := &request{params: params}
+	if sec == secNone {
+		return req, nil
+	}
+
+	acct, ok := s.accounts[r.Header.Get("X-MBX-APIKEY")]
+	if !ok {
+		return nil, errInvalidAPIKey
+	}
+	req.acct = acct
+	if sec == secAPIKey {
+		return req, nil
+	}
+
+	// The client signs the query string followed by the body and appends
+	// the signature as the last query parameter
+	raw := r.URL.RawQuery
+	i := strings.LastIndex(raw, "signature=")
+	if i < 0 {
+		return nil, errInvalidSignature
+	}
+	payload := strings.TrimSuffix(raw[:i], "&") + string(body)
+	expected := common.HMACSignature(payload, acct.secretKey)
+	if !hmac.Equal([]byte(expected), []byte(params.Get("signature"))) {
+		return nil, errInvalidSignature
+	}
+
+	ts, err := strconv.ParseInt(params.Get("timestamp"), 10, 64)
+	if err != nil {
+		return nil, errMandatoryParam
+	}
+	recvWindow := s.recvWindow
+	if rw := req.int64("recvWindow"); rw > 0 {
+		recvWindow = rw
+	}
+	now := s.nowMs()
+	if ts > now+1000 || now-ts > recvWindow {
+		return nil, errTimestamp
+	}
+	return req, nil
+}
+
+// secType is the security type of an endpoint
+type secType int
+
+const (
+	secNone secType = iota
+	secAPIKey
+	secSigned
+)
+
+// startUserStream returns the listen key of an account on a market,
+// creating it if needed
+func (s *Server) startUserStream(acct *account, market Market) string {
+	for key, lk := range s.listenKeys {
+		if lk.acct == acct && lk.market == market {
+			return key
+		}
+	}
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	key := hex.EncodeToString(buf)
+	s.listenKeys[key] = &listenKey{key: key, acct: acct, market: market}
+	return key
+}
+
+// userStreamKey returns the listen key of a keepalive or close request,
+// defaulting to the account key when the parameter is absent
+func (s *Server) userStreamKey(req *request, market Market) (string, *apiError) {
+	key := req.get("listenKey")
+	if key == "" {
+		for k, lk := range s.listenKeys {
+			if lk.acct == req.acct && lk.market == market {
+				key = k
+			}
+		}
+	}
+	lk, ok := s.listenKeys[key]
+	if !ok || lk.acct != req.acct || lk.market != market {
+		return "", errNoListenKey
+	}
+	return key, nil
+}
+
+// keepaliveUserStream extends a listen key
+func (s *Server) keepaliveUserStream(req *request, market Market) (interface{}, *apiError) {
+	if _, e := s.userStreamKey(req, market); e != nil {
+		return nil, e
+	}
+	return struct{}{}, nil
+}
+
+// closeUserStream deletes a listen key
+func (s *Server) closeUserStream(req *request, market Market) (interface{}, *apiError) {
+	key, e := s.userStreamKey(req, market)
+	if e != nil {
+		return nil, e
+	}
+	delete(s.listenKeys, key)
+	return struct{}{}, nil
+}
@@ -0,0 +1,420 @@
+package astertest
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// order is a resting or finished order. Orders of the server liquidity have
+// no account.
+type order struct {
+	id            int64
+	clientOrderID string
+	acct          *account
+	symbol        string
+	side          common.SideType
+	orderType     common.OrderType
+	timeInForce   common.TimeInForceType
+	price         float64
+	stopPrice     float64
+	origQty       float64
+	quoteOrderQty float64 // spot market orders sized in the quote asset
+	executedQty   float64
+	cumQuote      float64
+	status        common.OrderStatusType
+	reduceOnly    bool
+	positionSide  string
+	time          int64
+	updateTime    int64
+	seq           int64   // time priority
+	locked        float64 // spot funds still locked by the order
+}
+
+// remaining returns the quantity left to fill
+func (o *order) remaining() float64 {
+	return o.origQty - o.executedQty
+}
+
+// isOpen reports whether the order can still fill
+func (o *order) isOpen() bool {
+	return o.status == common.OrderStatusTypeNew || o.status == common.OrderStatusTypePartiallyFilled
+}
+
+// avgPrice returns the average fill price
+func (o *order) avgPrice() float64 {
+	if o.executedQty == 0 {
+		return 0
+	}
+	return o.cumQuote / o.executedQty
+}
+
+// fill is one match between an incoming order and a resting order
+type fill struct {
+	tradeID int64
+	maker   *order
+	taker   *order
+	price   float64
+	qty     float64
+	time    int64
+}
+
+// trade is a public trade of a symbol
+type trade struct {
+	id         int64
+	price      float64
+	qty        float64
+	time       int64
+	buyerMaker bool
+}
+
+// level is an aggregated price level
+type level struct {
+	price float64
+	qty   float64
+}
+
+// book holds the resting orders of a symbol in price-time priority
+type book struct {
+	bids     []*order // highest price first, then oldest
+	asks     []*order // lowest price first, then oldest
+	updateID int64
+}
+
+// exchange is the order books, orders and trades of one market
+type exchange struct {
+	market      Market
+	books       map[string]*book
+	orders      map[string][]*order // account orders by symbol
+	trades      map[string][]*trade
+	markPrices  map[string]float64
+	makerRate   float64
+	takerRate   float64
+	nextOrderID int64
+	nextTradeID int64
+	seq         int64
+}
+
+// newExchange creates an empty market
+func newExchange(market Market) *exchange {
+	return &exchange{
+		market:     market,
+		books:      map[string]*book{},
+		orders:     map[string][]*order{},
+		trades:     map[string][]*trade{},
+		markPrices: map[string]float64{},
+	}
+}
+
+// book returns the book of a symbol, creating it if needed
+func (x *exchange) book(symbol string) *book {
+	b, ok := x.books[symbol]
+	if !ok {
+		b = &book{}
+		x.books[symbol] = b
+	}
+	return b
+}
+
+// crosses reports whether o trades against a resting order at price
+func crosses(o *order, price float64) bool {
+	if o.orderType == common.OrderTypeMarket {
+		return true
+	}
+	if o.side == common.SideTypeBuy {
+		return o.price >= price
+	}
+	return o.price <= price
+}
+
+// opposite returns the resting orders o trades against
+func (b *book) opposite(o *order) []*order {
+	if o.side == common.SideTypeBuy {
+		return b.asks
+	}
+	return b.bids
+}
+
+// wouldTake reports whether o would immediately trade
+func (x *exchange) wouldTake(o *order) bool {
+	side := x.book(o.symbol).opposite(o)
+	return len(side) > 0 && crosses(o, side[0].price)
+}
+
+// fillable returns the quantity o could fill immediately
+func (x *exchange) fillable(o *order) float64 {
+	var qty float64
+	for _, m := range x.book(o.symbol).opposite(o) {
+		if !crosses(o, m.price) {
+			break
+		}
+		qty += m.remaining()
+	}
+	return qty
+}
+
+// register assigns an id to a new order and fills in defaults
+func (x *exchange) register(o *order, now int64) {
+	x.nextOrderID++
+	x.seq++
+	o.id = x.nextOrderID
+	o.seq = x.seq
+	if o.clientOrderID == "" {
+		o.clientOrderID = "astertest" + strconv.FormatInt(o.id, 10)
+	}
+	if o.timeInForce == "" && o.orderType != common.OrderTypeMarket {
+		o.timeInForce = common.TimeInForceTypeGTC
+	}
+	o.time = now
+	o.updateTime = now
+	o.status = common.OrderStatusTypeNew
+	if o.acct != nil {
+		x.orders[o.symbol] = append(x.orders[o.symbol], o)
+	}
+}
+
+// execute matches a registered order and rests the remainder according to
+// its time in force. onFill is called after each fill is applied to both
+// orders. It returns the fills in order.
+func (x *exchange) execute(o *order, now int64, onFill func(fill)) []fill {
+	var fills []fill
+	switch {
+	case o.orderType == common.OrderTypeMarket:
+		fills = x.match(o, now, onFill)
+		if o.quoteOrderQty > 0 {
+			o.origQty = o.executedQty
+		}
+		if o.status != common.OrderStatusTypeFilled {
+			o.status = common.OrderStatusTypeExpired
+		}
+		return fills
+	case o.orderType == common.OrderTypeLimitMaker || o.timeInForce == common.TimeInForceTypeGTX:
+		if x.wouldTake(o) {
+			o.status = common.OrderStatusTypeExpired
+			return nil
+		}
+	case o.timeInForce == common.TimeInForceTypeFOK:
+		if x.fillable(o) < o.origQty {
+			o.status = common.OrderStatusTypeExpired
+			return nil
+		}
+		fills = x.match(o, now, onFill)
+	case o.timeInForce == common.TimeInForceTypeIOC:
+		fills = x.match(o, now, onFill)
+		if o.remaining() > 0 {
+			o.status = common.OrderStatusTypeExpired
+		}
+		return fills
+	default:
+		fills = x.match(o, now, onFill)
+	}
+	if o.remaining() > 0 {
+		x.rest(o)
+	}
+	return fills
+}
+
+// match trades o against the opposite side of the book
+func (x *exchange) match(o *order, now int64, onFill func(fill)) []fill {
+	b := x.book(o.symbol)
+	var fills []fill
+	for {
+		side := b.opposite(o)
+		if len(side) == 0 || !crosses(o, side[0].price) {
+			break
+		}
+		m := side[0]
+		qty := m.remaining()
+		if o.quoteOrderQty > 0 {
+			qty = minFloat(qty, (o.quoteOrderQty-o.cumQuote)/m.price)
+		} else {
+			qty = minFloat(qty, o.remaining())
+		}
+		if qty <= 1e-12 {
+			break
+		}
+		x.nextTradeID++
+		f := fill{tradeID: x.nextTradeID, maker: m, taker: o, price: m.price, qty: qty, time: now}
+		fills = append(fills, f)
+		x.trades[o.symbol] = append(x.trades[o.symbol], &trade{
+			id:         f.tradeID,
+			price:      f.price,
+			qty:        f.qty,
+			time:       now,
+			buyerMaker: m.side == common.SideTypeBuy,
+		})
+		for _, p := range []*order{m, o} {
+			p.executedQty += qty
+			p.cumQuote += qty * m.price
+			p.updateTime = now
+			p.status = common.OrderStatusTypePartiallyFilled
+			done := p.remaining() <= 1e-12
+			if p.quoteOrderQty > 0 {
+				done = p.quoteOrderQty-p.cumQuote <= 1e-8
+			}
+			if done {
+				p.status = common.OrderStatusTypeFilled
+			}
+		}
+		if m.status == common.OrderStatusTypeFilled {
+			b.remove(m)
+		}
+		if onFill != nil {
+			onFill(f)
+		}
+		if o.status == common.OrderStatusTypeFilled {
+			break
+		}
+	}
+	return fills
+}
+
+// rest adds o to the book behind the orders at the same price
+func (x *exchange) rest(o *order) {
+	b := x.book(o.symbol)
+	if o.side == common.SideTypeBuy {
+		i := sort.Search(len(b.bids), func(i int) bool { return b.bids[i].price < o.price })
+		b.bids = append(b.bids, nil)
+		copy(b.bids[i+1:], b.bids[i:])
+		b.bids[i] = o
+		return
+	}
+	i := sort.Search(len(b.asks), func(i int) bool { return b.asks[i].price > o.price })
+	b.asks = append(b.asks, nil)
+	copy(b.asks[i+1:], b.asks[i:])
+	b.asks[i] = o
+}
+
+// remove takes o out of the book
+func (b *book) remove(o *order) {
+	for _, side := range []*[]*order{&b.bids, &b.asks} {
+		for i, r := range *side {
+			if r == o {
+				*side = append((*side)[:i], (*side)[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// removeHouseOrders removes the server liquidity of a symbol
+func (x *exchange) removeHouseOrders(symbol string) {
+	b := x.book(symbol)
+	keep := func(orders []*order) []*order {
+		res := orders[:0]
+		for _, o := range orders {
+			if o.acct != nil {
+				res = append(res, o)
+			}
+		}
+		return res
+	}
+	b.bids = keep(b.bids)
+	b.asks = keep(b.asks)
+}
+
+// find returns an account order by id or client order id
+func (x *exchange) find(acct *account, symbol string, orderID int64, clientOrderID string) *order {
+	for _, o := range x.orders[symbol] {
+		if o.acct != acct {
+			continue
+		}
+		if (orderID > 0 && o.id == orderID) || (orderID <= 0 && clientOrderID != "" && o.clientOrderID == clientOrderID) {
+			return o
+		}
+	}
+	return nil
+}
+
+// cancel removes an open order from the book
+func (x *exchange) cancel(o *order, now int64) {
+	x.book(o.symbol).remove(o)
+	o.status = common.OrderStatusTypeCanceled
+	o.updateTime = now
+}
+
+// accountOrders returns the orders of an account on a symbol, or on all
+// symbols if symbol is ""
+func (x *exchange) accountOrders(acct *account, symbol string, openOnly bool) []*order {
+	var res []*order
+	for sym, orders := range x.orders {
+		if symbol != "" && sym != symbol {
+			continue
+		}
+		for _, o := range orders {
+			if o.acct == acct && (!openOnly || o.isOpen()) {
+				res = append(res, o)
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].id < res[j].id })
+	return res
+}
+
+// levels returns the aggregated levels of one side of the book
+func levels(orders []*order) []level {
+	var res []level
+	for _, o := range orders {
+		if n := len(res); n > 0 && res[n-1].price == o.price {
+			res[n-1].qty += o.remaining()
+			continue
+		}
+		res = append(res, level{price: o.price, qty: o.remaining()})
+	}
+	return res
+}
+
+// formatLevels formats up to limit levels as [price, quantity] pairs
+func formatLevels(levels []level, limit int) [][]string {
+	if limit > 0 && len(levels) > limit {
+		levels = levels[:limit]
+	}
+	res := make([][]string, 0, len(levels))
+	for _, l := range levels {
+		res = append(res, []string{formatFloat(l.price), formatFloat(l.qty)})
+	}
+	return res
+}
+
+// lastPrice returns the last trade price, or the mid price without trades
+func (x *exchange) lastPrice(symbol string) float64 {
+	if trades := x.trades[symbol]; len(trades) > 0 {
+		return trades[len(trades)-1].price
+	}
+	b := x.book(symbol)
+	switch {
+	case len(b.bids) > 0 && len(b.asks) > 0:
+		return (b.bids[0].price + b.asks[0].price) / 2
+	case len(b.bids) > 0:
+		return b.bids[0].price
+	case len(b.asks) > 0:
+		return b.asks[0].price
+	}
+	return 0
+}
+
+// markPrice returns the configured mark price or the last price
+func (x *exchange) markPrice(symbol string) float64 {
+	if p, ok := x.markPrices[symbol]; ok {
+		return p
+	}
+	return x.lastPrice(symbol)
+}
+
+// symbols returns the symbols with a book, sorted
+func (x *exchange) symbols() []string {
+	res := make([]string, 0, len(x.books))
+	for s := range x.books {
+		res = append(res, s)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,811 @@
+package astertest
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/drinkthere/go-aster/v2/common"
+	"github.com/drinkthere/go-aster/v2/futures"
+)
+
+// route is a REST endpoint handler
+type route struct {
+	sec secType
+	fn  func(s *Server, req *request) (interface{}, *apiError)
+}
+
+// futuresRoutes are the /fapi endpoints by "METHOD path"
+var futuresRoutes = map[string]route{
+	"GET /fapi/v1/ping":              {secNone, func(s *Server, req *request) (interface{}, *apiError) { return struct{}{}, nil }},
+	"GET /fapi/v1/time":              {secNone, (*Server).serverTime},
+	"GET /fapi/v1/exchangeInfo":      {secNone, (*Server).futuresExchangeInfo},
+	"GET /fapi/v1/depth":             {secNone, (*Server).futuresDepth},
+	"GET /fapi/v1/trades":            {secNone, (*Server).futuresTrades},
+	"GET /fapi/v1/aggTrades":         {secNone, (*Server).futuresAggTrades},
+	"GET /fapi/v1/ticker/bookTicker": {secNone, (*Server).futuresBookTicker},
+	"GET /fapi/v1/ticker/price":      {secNone, (*Server).futuresPrice},
+	"GET /fapi/v1/premiumIndex":      {secNone, (*Server).futuresPremiumIndex},
+	"POST /fapi/v1/order":            {secSigned, (*Server).futuresNewOrder},
+	"GET /fapi/v1/order":             {secSigned, (*Server).futuresGetOrder},
+	"DELETE /fapi/v1/order":          {secSigned, (*Server).futuresCancelOrder},
+	"DELETE /fapi/v1/allOpenOrders":  {secSigned, (*Server).futuresCancelAll},
+	"GET /fapi/v1/openOrders":        {secSigned, (*Server).futuresOpenOrders},
+	"GET /fapi/v1/allOrders":         {secSigned, (*Server).futuresAllOrders},
+	"GET /fapi/v2/account":           {secSigned, (*Server).futuresAccount},
+	"GET /fapi/v2/balance":           {secSigned, (*Server).futuresBalance},
+	"GET /fapi/v2/positionRisk":      {secSigned, (*Server).futuresPositionRisk},
+	"POST /fapi/v1/leverage":         {secSigned, (*Server).futuresLeverage},
+	"POST /fapi/v1/marginType":       {secSigned, (*Server).futuresMarginType},
+	"GET /fapi/v1/commissionRate":    {secSigned, (*Server).futuresCommissionRate},
+	"POST /fapi/v1/listenKey":        {secSigned, (*Server).futuresStartUserStream},
+	"PUT /fapi/v1/listenKey":         {secSigned, (*Server).futuresKeepaliveUserStream},
+	"DELETE /fapi/v1/listenKey":      {secSigned, (*Server).futuresCloseUserStream},
+}
+
+// serveFutures serves the /fapi endpoints
+func (s *Server) serveFutures(w http.ResponseWriter, r *http.Request) {
+	s.serveRoute(w, r, futuresRoutes)
+}
+
+// serveRoute authenticates a request and runs its handler under s.mu
+func (s *Server) serveRoute(w http.ResponseWriter, r *http.Request, routes map[string]route) {
+	rt, ok := routes[r.Method+" "+r.URL.Path]
+	if !ok {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req, e := s.authenticate(r, rt.sec)
+	if e != nil {
+		writeError(w, http.StatusBadRequest, e)
+		return
+	}
+	res, e := rt.fn(s, req)
+	if e != nil {
+		writeError(w, http.StatusBadRequest, e)
+		return
+	}
+	writeJSON(w, res)
+}
+
+// serverTime serves the server time of both markets
+func (s *Server) serverTime(req *request) (interface{}, *apiError) {
+	return map[string]int64{"serverTime": s.nowMs()}, nil
+}
+
+// listedSymbols returns the listed symbols sorted by name
+func (s *Server) listedSymbols() []Symbol {
+	res := make([]Symbol, 0, len(s.symbols))
+	for _, sym := range s.symbols {
+		res = append(res, sym)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Symbol < res[j].Symbol })
+	return res
+}
+
+func (s *Server) futuresExchangeInfo(req *request) (interface{}, *apiError) {
+	info := &futures.ExchangeInfo{Timezone: "UTC", ServerTime: s.nowMs(), FuturesType: "U_MARGINED"}
+	for _, sym := range s.listedSymbols() {
+		info.Symbols = append(info.Symbols, futures.Symbol{
+			Symbol:            sym.Symbol,
+			Pair:              sym.Symbol,
+			ContractType:      futures.ContractTypePerpetual,
+			Status:            futures.SymbolStatusTypeTrading,
+			BaseAsset:         sym.BaseAsset,
+			QuoteAsset:        sym.QuoteAsset,
+			MarginAsset:       sym.QuoteAsset,
+			PricePrecision:    8,
+			QuantityPrecision: 8,
+			OrderTypes:        []common.OrderType{common.OrderTypeLimit, common.OrderTypeMarket},
+			TimeInForce: []common.TimeInForceType{
+				common.TimeInForceTypeGTC, common.TimeInForceTypeIOC, common.TimeInForceTypeFOK, common.TimeInForceTypeGTX,
+			},
+		})
+	}
+	return info, nil
+}
+
+// limit returns the limit parameter, def if absent
+func (r *request) limit(def int) int {
+	if l := int(r.int64("limit")); l > 0 {
+		return l
+	}
+	return def
+}
+
+// marketSymbol returns the symbol parameter if it is listed
+func (s *Server) marketSymbol(req *request) (string, *apiError) {
+	symbol := req.get("symbol")
+	if symbol == "" {
+		return "", errMandatoryParam
+	}
+	if _, ok := s.symbol(symbol); !ok {
+		return "", errInvalidSymbol
+	}
+	return symbol, nil
+}
+
+func (s *Server) futuresDepth(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	b := s.markets[MarketFutures].book(symbol)
+	now := s.nowMs()
+	return &futures.DepthResponse{
+		LastUpdateID:    b.updateID,
+		EventTime:       now,
+		TransactionTime: now,
+		Bids:            formatLevels(levels(b.bids), req.limit(500)),
+		Asks:            formatLevels(levels(b.asks), req.limit(500)),
+	}, nil
+}
+
+// recentTrades returns the last limit trades of a symbol
+func (x *exchange) recentTrades(symbol string, limit int) []*trade {
+	trades := x.trades[symbol]
+	if len(trades) > limit {
+		trades = trades[len(trades)-limit:]
+	}
+	return trades
+}
+
+// filterTrades returns the trades of a symbol matching fromId, startTime,
+// endTime and limit
+func (x *exchange) filterTrades(symbol string, req *request) []*trade {
+	fromID, start, end := req.int64("fromId"), req.int64("startTime"), req.int64("endTime")
+	limit := req.limit(500)
+	var res []*trade
+	for _, t := range x.trades[symbol] {
+		if (fromID > 0 && t.id < fromID) || (start > 0 && t.time < start) || (end > 0 && t.time > end) {
+			continue
+		}
+		res = append(res, t)
+		if len(res) == limit {
+			break
+		}
+	}
+	if fromID == 0 && start == 0 && end == 0 {
+		return x.recentTrades(symbol, limit)
+	}
+	return res
+}
+
+func (s *Server) futuresTrades(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	res := make([]*futures.Trade, 0)
+	for _, t := range s.markets[MarketFutures].recentTrades(symbol, req.limit(500)) {
+		res = append(res, &futures.Trade{
+			ID:           t.id,
+			Price:        formatFloat(t.price),
+			Quantity:     formatFloat(t.qty),
+			QuoteQty:     formatFloat(t.price * t.qty),
+			Time:         t.time,
+			IsBuyerMaker: t.buyerMaker,
+		})
+	}
+	return res, nil
+}
+
+func (s *Server) futuresAggTrades(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	res := make([]*futures.AggTrade, 0)
+	for _, t := range s.markets[MarketFutures].filterTrades(symbol, req) {
+		res = append(res, &futures.AggTrade{
+			AggTradeID:   t.id,
+			Price:        formatFloat(t.price),
+			Quantity:     formatFloat(t.qty),
+			FirstTradeID: t.id,
+			LastTradeID:  t.id,
+			Time:         t.time,
+			IsBuyerMaker: t.buyerMaker,
+		})
+	}
+	return res, nil
+}
+
+// tickerSymbols returns the symbol parameter, or all symbols with a book
+func (s *Server) tickerSymbols(x *exchange, req *request) ([]string, bool, *apiError) {
+	if req.get("symbol") == "" {
+		return x.symbols(), false, nil
+	}
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, false, e
+	}
+	return []string{symbol}, true, nil
+}
+
+func (s *Server) futuresBookTicker(req *request) (interface{}, *apiError) {
+	x := s.markets[MarketFutures]
+	symbols, single, e := s.tickerSymbols(x, req)
+	if e != nil {
+		return nil, e
+	}
+	res := make([]*futures.BookTicker, 0, len(symbols))
+	for _, symbol := range symbols {
+		b := x.book(symbol)
+		bid, ask := top(levels(b.bids)), top(levels(b.asks))
+		res = append(res, &futures.BookTicker{
+			Symbol:   symbol,
+			BidPrice: formatFloat(bid.price),
+			BidQty:   formatFloat(bid.qty),
+			AskPrice: formatFloat(ask.price),
+			AskQty:   formatFloat(ask.qty),
+			Time:     s.nowMs(),
+		})
+	}
+	if single {
+		return res[0], nil
+	}
+	return res, nil
+}
+
+func (s *Server) futuresPrice(req *request) (interface{}, *apiError) {
+	x := s.markets[MarketFutures]
+	symbols, single, e := s.tickerSymbols(x, req)
+	if e != nil {
+		return nil, e
+	}
+	res := make([]*futures.SymbolPrice, 0, len(symbols))
+	for _, symbol := range symbols {
+		res = append(res, &futures.SymbolPrice{Symbol: symbol, Price: formatFloat(x.lastPrice(symbol)), Time: s.nowMs()})
+	}
+	if single {
+		return res[0], nil
+	}
+	return res, nil
+}
+
+func (s *Server) futuresPremiumIndex(req *request) (interface{}, *apiError) {
+	x := s.markets[MarketFutures]
+	symbols, single, e := s.tickerSymbols(x, req)
+	if e != nil {
+		return nil, e
+	}
+	res := make([]*futures.MarkPrice, 0, len(symbols))
+	for _, symbol := range symbols {
+		mark := formatFloat(x.markPrice(symbol))
+		res = append(res, &futures.MarkPrice{
+			Symbol:               symbol,
+			MarkPrice:            mark,
+			IndexPrice:           mark,
+			EstimatedSettlePrice: mark,
+			LastFundingRate:      "0",
+			NextFundingTime:      nextFundingTime(s.nowMs()),
+			InterestRate:         "0",
+			Time:                 s.nowMs(),
+		})
+	}
+	if single {
+		return res[0], nil
+	}
+	return res, nil
+}
+
+// toFuturesOrder converts an order to the REST format
+func toFuturesOrder(o *order) *futures.Order {
+	return &futures.Order{
+		AvgPrice:      formatFloat(o.avgPrice()),
+		ClientOrderID: o.clientOrderID,
+		CumQuote:      formatFloat(o.cumQuote),
+		ExecutedQty:   formatFloat(o.executedQty),
+		OrderID:       o.id,
+		OrigQty:       formatFloat(o.origQty),
+		OrigType:      o.orderType,
+		Price:         formatFloat(o.price),
+		ReduceOnly:    o.reduceOnly,
+		Side:          o.side,
+		PositionSide:  futures.PositionSideType(o.positionSide),
+		Status:        o.status,
+		StopPrice:     formatFloat(o.stopPrice),
+		Symbol:        o.symbol,
+		Time:          o.time,
+		TimeInForce:   o.timeInForce,
+		Type:          o.orderType,
+		UpdateTime:    o.updateTime,
+		WorkingType:   futures.WorkingTypeContractPrice,
+	}
+}
+
+// referencePrice returns the price used to size the margin of an order
+func (x *exchange) referencePrice(o *order) float64 {
+	if o.orderType != common.OrderTypeMarket {
+		return o.price
+	}
+	if side := x.book(o.symbol).opposite(o); len(side) > 0 {
+		return side[0].price
+	}
+	return x.lastPrice(o.symbol)
+}
+
+// unrealizedProfit returns the unrealized profit of a position
+func (x *exchange) unrealizedProfit(p *position) float64 {
+	if p.amount == 0 {
+		return 0
+	}
+	return (x.markPrice(p.symbol) - p.entryPrice) * p.amount
+}
+
+// futuresMargin returns the wallet balance, unrealized profit, position
+// margin and open order margin of an account in a margin asset
+func (s *Server) futuresMargin(acct *account, asset string) (wallet, unrealized, positionMargin, orderMargin float64) {
+	x := s.markets[MarketFutures]
+	wallet = acct.wallet[asset]
+	for _, p := range acct.positions {
+		if sym, ok := s.symbols[p.symbol]; !ok || sym.QuoteAsset != asset {
+			continue
+		}
+		unrealized += x.unrealizedProfit(p)
+		positionMargin += math.Abs(p.amount) * p.entryPrice / float64(acct.symbolLeverage(p.symbol))
+	}
+	for _, o := range x.accountOrders(acct, "", true) {
+		if sym, ok := s.symbols[o.symbol]; !ok || sym.QuoteAsset != asset || o.reduceOnly {
+			continue
+		}
+		orderMargin += o.remaining() * o.price / float64(acct.symbolLeverage(o.symbol))
+	}
+	return wallet, unrealized, positionMargin, orderMargin
+}
+
+// futuresAvailable returns the balance available for new orders
+func (s *Server) futuresAvailable(acct *account, asset string) float64 {
+	wallet, unrealized, positionMargin, orderMargin := s.futuresMargin(acct, asset)
+	return wallet + unrealized - positionMargin - orderMargin
+}
+
+func (s *Server) futuresNewOrder(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	x := s.markets[MarketFutures]
+	o := &order{
+		acct:          req.acct,
+		clientOrderID: req.get("newClientOrderId"),
+		symbol:        symbol,
+		side:          common.SideType(req.get("side")),
+		orderType:     common.OrderType(req.get("type")),
+		timeInForce:   common.TimeInForceType(req.get("timeInForce")),
+		price:         req.float("price"),
+		origQty:       req.float("quantity"),
+		reduceOnly:    req.bool("reduceOnly"),
+		positionSide:  req.get("positionSide"),
+	}
+	if o.positionSide == "" {
+		o.positionSide = string(futures.PositionSideTypeBoth)
+	}
+	if e := validateOrder(o); e != nil {
+		return nil, e
+	}
+	if o.orderType != common.OrderTypeLimit && o.orderType != common.OrderTypeMarket {
+		return nil, errInvalidOrderType
+	}
+	if o.clientOrderID != "" {
+		if prev := x.find(o.acct, symbol, 0, o.clientOrderID); prev != nil && prev.isOpen() {
+			return nil, errDuplicateClientID
+		}
+	}
+
+	p := o.acct.position(symbol, o.positionSide)
+	reduces := (o.side == common.SideTypeSell && p.amount > 0) || (o.side == common.SideTypeBuy && p.amount < 0)
+	if o.reduceOnly {
+		if !reduces {
+			return nil, errReduceOnly
+		}
+		o.origQty = math.Min(o.origQty, math.Abs(p.amount))
+	}
+	if !reduces {
+		sym, _ := s.symbol(symbol)
+		required := o.origQty * x.referencePrice(o) / float64(o.acct.symbolLeverage(symbol))
+		if required > s.futuresAvailable(o.acct, sym.QuoteAsset)+1e-9 {
+			return nil, errMargin
+		}
+	}
+
+	s.updateBook(x, symbol, func() { s.submit(x, o) })
+	return toFuturesOrder(o), nil
+}
+
+// validateOrder checks the fields common to both markets
+func validateOrder(o *order) *apiError {
+	if o.side != common.SideTypeBuy && o.side != common.SideTypeSell {
+		return errInvalidSide
+	}
+	if o.orderType == "" {
+		return errMandatoryParam
+	}
+	if o.origQty <= 0 && o.quoteOrderQty <= 0 {
+		return errInvalidQty
+	}
+	if o.orderType != common.OrderTypeMarket && o.price <= 0 {
+		return errInvalidPrice
+	}
+	return nil
+}
+
+// findOrder returns the order identified by orderId or origClientOrderId
+func (s *Server) findOrder(x *exchange, req *request) (*order, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	orderID, clientID := req.int64("orderId"), req.get("origClientOrderId")
+	if orderID <= 0 && clientID == "" {
+		return nil, errMandatoryParam
+	}
+	o := x.find(req.acct, symbol, orderID, clientID)
+	if o == nil {
+		return nil, errNoSuchOrder
+	}
+	return o, nil
+}
+
+func (s *Server) futuresGetOrder(req *request) (interface{}, *apiError) {
+	o, e := s.findOrder(s.markets[MarketFutures], req)
+	if e != nil {
+		return nil, e
+	}
+	return toFuturesOrder(o), nil
+}
+
+func (s *Server) futuresCancelOrder(req *request) (interface{}, *apiError) {
+	x := s.markets[MarketFutures]
+	o, e := s.findOrder(x, req)
+	if e != nil {
+		if e == errNoSuchOrder {
+			e = errUnknownOrder
+		}
+		return nil, e
+	}
+	if !o.isOpen() {
+		return nil, errUnknownOrder
+	}
+	s.cancelOrder(x, o)
+	return toFuturesOrder(o), nil
+}
+
+func (s *Server) futuresCancelAll(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	x := s.markets[MarketFutures]
+	for _, o := range x.accountOrders(req.acct, symbol, true) {
+		s.cancelOrder(x, o)
+	}
+	return map[string]interface{}{"code": 200, "msg": "The operation of cancel all open order is done."}, nil
+}
+
+func (s *Server) futuresOpenOrders(req *request) (interface{}, *apiError) {
+	symbol := req.get("symbol")
+	if symbol != "" {
+		if _, ok := s.symbol(symbol); !ok {
+			return nil, errInvalidSymbol
+		}
+	}
+	res := make([]*futures.Order, 0)
+	for _, o := range s.markets[MarketFutures].accountOrders(req.acct, symbol, true) {
+		res = append(res, toFuturesOrder(o))
+	}
+	return res, nil
+}
+
+// filterOrders returns the orders matching orderId, startTime, endTime and
+// limit
+func filterOrders(orders []*order, req *request) []*order {
+	orderID, start, end := req.int64("orderId"), req.int64("startTime"), req.int64("endTime")
+	limit := req.limit(500)
+	var res []*order
+	for _, o := range orders {
+		if (orderID > 0 && o.id < orderID) || (start > 0 && o.time < start) || (end > 0 && o.time > end) {
+			continue
+		}
+		res = append(res, o)
+	}
+	if len(res) > limit {
+		if orderID > 0 {
+			res = res[:limit]
+		} else {
+			res = res[len(res)-limit:]
+		}
+	}
+	return res
+}
+
+func (s *Server) futuresAllOrders(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	res := make([]*futures.Order, 0)
+	for _, o := range filterOrders(s.markets[MarketFutures].accountOrders(req.acct, symbol, false), req) {
+		res = append(res, toFuturesOrder(o))
+	}
+	return res, nil
+}
+
+// futuresBalances returns the wallet of an account in the REST format
+func (s *Server) futuresBalances(acct *account) []futures.Balance {
+	assets := make([]string, 0, len(acct.wallet))
+	for asset := range acct.wallet {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+	res := make([]futures.Balance, 0, len(assets))
+	for _, asset := range assets {
+		wallet, unrealized, _, _ := s.futuresMargin(acct, asset)
+		available := formatFloat(s.futuresAvailable(acct, asset))
+		res = append(res, futures.Balance{
+			AccountAlias:       "astertest",
+			Asset:              asset,
+			Balance:            formatFloat(wallet),
+			CrossWalletBalance: formatFloat(wallet),
+			CrossUnPnl:         formatFloat(unrealized),
+			AvailableBalance:   available,
+			MaxWithdrawAmount:  available,
+			MarginAvailable:    true,
+			UpdateTime:         s.nowMs(),
+		})
+	}
+	return res
+}
+
+// futuresPositions returns the positions of an account on a symbol, or on
+// all symbols if symbol is "", in the REST format
+func (s *Server) futuresPositions(acct *account, symbol string) []futures.PositionRisk {
+	x := s.markets[MarketFutures]
+	keys := make([]string, 0, len(acct.positions))
+	for key, p := range acct.positions {
+		if symbol == "" || p.symbol == symbol {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	res := make([]futures.PositionRisk, 0, len(keys))
+	for _, key := range keys {
+		p := acct.positions[key]
+		leverage := acct.symbolLeverage(p.symbol)
+		margin := math.Abs(p.amount) * p.entryPrice / float64(leverage)
+		res = append(res, futures.PositionRisk{
+			Symbol:                p.symbol,
+			PositionSide:          futures.PositionSideType(p.side),
+			PositionAmt:           formatFloat(p.amount),
+			EntryPrice:            formatFloat(p.entryPrice),
+			MarkPrice:             formatFloat(x.markPrice(p.symbol)),
+			UnRealizedProfit:      formatFloat(x.unrealizedProfit(p)),
+			LiquidationPrice:      "0",
+			Leverage:              formatFloat(float64(leverage)),
+			MaxNotionalValue:      "1000000",
+			MarginType:            futures.MarginType(strings.ToLower(acct.symbolMarginType(p.symbol))),
+			IsolatedMargin:        "0",
+			IsAutoAddMargin:       "false",
+			PositionInitialMargin: formatFloat(margin),
+			PositionMaintMargin:   "0",
+			IsolatedWallet:        "0",
+			UpdateTime:            p.updateTime,
+		})
+	}
+	return res
+}
+
+func (s *Server) futuresAccount(req *request) (interface{}, *apiError) {
+	assets := s.futuresBalances(req.acct)
+	acc := &futures.Account{
+		Assets:      assets,
+		Positions:   s.futuresPositions(req.acct, ""),
+		CanTrade:    true,
+		CanWithdraw: true,
+		CanDeposit:  true,
+		UpdateTime:  s.nowMs(),
+	}
+	var wallet, unrealized, positionMargin, orderMargin, available float64
+	for _, b := range assets {
+		w, u, pm, om := s.futuresMargin(req.acct, b.Asset)
+		wallet, unrealized, positionMargin, orderMargin = wallet+w, unrealized+u, positionMargin+pm, orderMargin+om
+		available += parseFloat(b.AvailableBalance)
+	}
+	acc.TotalInitialMargin = formatFloat(positionMargin + orderMargin)
+	acc.TotalMaintMargin = "0"
+	acc.TotalWalletBalance = formatFloat(wallet)
+	acc.TotalUnrealizedProfit = formatFloat(unrealized)
+	acc.TotalMarginBalance = formatFloat(wallet + unrealized)
+	acc.TotalPositionInitialMargin = formatFloat(positionMargin)
+	acc.TotalOpenOrderInitialMargin = formatFloat(orderMargin)
+	acc.TotalCrossWalletBalance = formatFloat(wallet)
+	acc.TotalCrossUnPnl = formatFloat(unrealized)
+	acc.AvailableBalance = formatFloat(available)
+	acc.MaxWithdrawAmount = formatFloat(available)
+	return acc, nil
+}
+
+func (s *Server) futuresBalance(req *request) (interface{}, *apiError) {
+	return s.futuresBalances(req.acct), nil
+}
+
+func (s *Server) futuresPositionRisk(req *request) (interface{}, *apiError) {
+	return s.futuresPositions(req.acct, req.get("symbol")), nil
+}
+
+func (s *Server) futuresLeverage(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	leverage := int(req.int64("leverage"))
+	if leverage < 1 || leverage > 125 {
+		return nil, &apiError{http.StatusBadRequest, -4028, "Leverage is not valid."}
+	}
+	req.acct.leverage[symbol] = leverage
+	now := s.nowMs()
+	s.publishUser(MarketFutures, req.acct, map[string]interface{}{
+		"e":  "ACCOUNT_CONFIG_UPDATE",
+		"E":  now,
+		"T":  now,
+		"ac": map[string]interface{}{"s": symbol, "l": leverage},
+	})
+	return &futures.SymbolLeverage{Symbol: symbol, Leverage: leverage, MaxNotionalValue: "1000000"}, nil
+}
+
+func (s *Server) futuresMarginType(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	var marginType string
+	switch req.get("marginType") {
+	case "ISOLATED":
+		marginType = "ISOLATED"
+	case "CROSS", "CROSSED":
+		marginType = "CROSS"
+	default:
+		return nil, errMandatoryParam
+	}
+	if req.acct.symbolMarginType(symbol) == marginType {
+		return nil, errMarginType
+	}
+	req.acct.marginTypes[symbol] = marginType
+	return map[string]interface{}{"code": 200, "msg": "success"}, nil
+}
+
+func (s *Server) futuresCommissionRate(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	x := s.markets[MarketFutures]
+	return &futures.CommissionRate{
+		Symbol:              symbol,
+		MakerCommissionRate: formatFloat(x.makerRate),
+		TakerCommissionRate: formatFloat(x.takerRate),
+	}, nil
+}
+
+func (s *Server) futuresStartUserStream(req *request) (interface{}, *apiError) {
+	return map[string]string{"listenKey": s.startUserStream(req.acct, MarketFutures)}, nil
+}
+
+func (s *Server) futuresKeepaliveUserStream(req *request) (interface{}, *apiError) {
+	return s.keepaliveUserStream(req, MarketFutures)
+}
+
+func (s *Server) futuresCloseUserStream(req *request) (interface{}, *apiError) {
+	return s.closeUserStream(req, MarketFutures)
+}
+
+// settleFutures applies a fill to the position and wallet of a futures
+// order and publishes the order and account updates
+func (s *Server) settleFutures(x *exchange, o *order, f fill) {
+	acct := o.acct
+	sym, _ := s.symbol(o.symbol)
+	commission := f.price * f.qty * x.commission(o, f)
+	p := acct.position(o.symbol, o.positionSide)
+	delta := f.qty
+	if o.side == common.SideTypeSell {
+		delta = -delta
+	}
+	var realized float64
+	if p.amount != 0 && (p.amount > 0) != (delta > 0) {
+		closed := math.Min(math.Abs(p.amount), math.Abs(delta))
+		realized = closed * (f.price - p.entryPrice)
+		if p.amount < 0 {
+			realized = -realized
+		}
+	}
+	amount := p.amount + delta
+	if math.Abs(amount) < 1e-12 {
+		amount = 0
+	}
+	switch {
+	case amount == 0:
+		p.entryPrice = 0
+	case p.amount == 0 || (p.amount > 0) != (amount > 0):
+		p.entryPrice = f.price
+	case math.Abs(amount) > math.Abs(p.amount):
+		p.entryPrice = (p.entryPrice*math.Abs(p.amount) + f.price*math.Abs(delta)) / math.Abs(amount)
+	}
+	p.amount = amount
+	p.updateTime = f.time
+	acct.wallet[sym.QuoteAsset] += realized - commission
+
+	s.publishUser(MarketFutures, acct, s.futuresOrderEvent(x, o, "TRADE", &f, realized))
+	now := s.nowMs()
+	wallet := formatFloat(acct.wallet[sym.QuoteAsset])
+	s.publishUser(MarketFutures, acct, map[string]interface{}{
+		"e": "ACCOUNT_UPDATE",
+		"E": now,
+		"T": now,
+		"a": map[string]interface{}{
+			"m": "ORDER",
+			"B": []map[string]interface{}{
+				{"a": sym.QuoteAsset, "wb": wallet, "cw": wallet, "bc": "0"},
+			},
+			"P": []map[string]interface{}{{
+				"s":  p.symbol,
+				"pa": formatFloat(p.amount),
+				"ep": formatFloat(p.entryPrice),
+				"cr": formatFloat(realized),
+				"up": formatFloat(x.unrealizedProfit(p)),
+				"mt": strings.ToLower(acct.symbolMarginType(p.symbol)),
+				"iw": "0",
+				"ps": p.side,
+			}},
+		},
+	})
+}
+
+// futuresOrderEvent returns an ORDER_TRADE_UPDATE event. f and realized
+// describe the fill of TRADE updates.
+func (s *Server) futuresOrderEvent(x *exchange, o *order, execType string, f *fill, realized float64) map[string]interface{} {
+	now := s.nowMs()
+	ev := map[string]interface{}{
+		"s":  o.symbol,
+		"c":  o.clientOrderID,
+		"S":  o.side,
+		"o":  o.orderType,
+		"f":  o.timeInForce,
+		"q":  formatFloat(o.origQty),
+		"p":  formatFloat(o.price),
+		"ap": formatFloat(o.avgPrice()),
+		"sp": formatFloat(o.stopPrice),
+		"x":  execType,
+		"X":  o.status,
+		"i":  o.id,
+		"l":  "0",
+		"z":  formatFloat(o.executedQty),
+		"L":  "0",
+		"n":  "0",
+		"N":  "",
+		"T":  o.updateTime,
+		"t":  int64(0),
+		"b":  "0",
+		"a":  "0",
+		"m":  false,
+		"R":  o.reduceOnly,
+		"wt": futures.WorkingTypeContractPrice,
+		"ot": o.orderType,
+		"ps": o.positionSide,
+		"cp": false,
+		"rp": formatFloat(realized),
+	}
+	if f != nil {
+		sym, _ := s.symbol(o.symbol)
+		ev["l"] = formatFloat(f.qty)
+		ev["L"] = formatFloat(f.price)
+		ev["n"] = formatFloat(f.price * f.qty * x.commission(o, *f))
+		ev["N"] = sym.QuoteAsset
+		ev["t"] = f.tradeID
+		ev["m"] = o == f.maker
+		ev["T"] = f.time
+	}
+	return map[string]interface{}{"e": "ORDER_TRADE_UPDATE", "E": now, "T": now, "o": ev}
+}
@@ -0,0 +1,306 @@
+package astertest
+
+import (
+	"sort"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// submit registers and executes an order, publishing its user data events
+// and settling its fills. It must be called inside updateBook.
+func (s *Server) submit(x *exchange, o *order) []fill {
+	now := s.nowMs()
+	x.register(o, now)
+	if o.acct != nil {
+		s.publishOrder(x, o, "NEW", nil)
+	}
+	fills := x.execute(o, now, func(f fill) { s.settleFill(x, f) })
+	if o.acct != nil && o.status == common.OrderStatusTypeExpired {
+		s.publishOrder(x, o, "EXPIRED", nil)
+	}
+	return fills
+}
+
+// settleFill publishes the public trade of a fill and settles the accounts
+// of both orders
+func (s *Server) settleFill(x *exchange, f fill) {
+	s.publishTrade(x, f)
+	for _, o := range []*order{f.maker, f.taker} {
+		if o.acct == nil {
+			continue
+		}
+		if x.market == MarketFutures {
+			s.settleFutures(x, o, f)
+		} else {
+			s.settleSpot(x, o, f)
+		}
+	}
+}
+
+// commission returns the commission rate paid by o on f
+func (x *exchange) commission(o *order, f fill) float64 {
+	if o == f.maker {
+		return x.makerRate
+	}
+	return x.takerRate
+}
+
+// cancelOrder cancels an open account order and publishes the change
+func (s *Server) cancelOrder(x *exchange, o *order) {
+	s.updateBook(x, o.symbol, func() {
+		x.cancel(o, s.nowMs())
+	})
+	if x.market == MarketSpot {
+		s.releaseSpot(o)
+	}
+	s.publishOrder(x, o, "CANCELED", nil)
+	if x.market == MarketSpot {
+		s.publishSpotBalances(o)
+	}
+}
+
+// updateBook runs fn, which changes the book of a symbol, and publishes the
+// resulting depth and bookTicker updates
+func (s *Server) updateBook(x *exchange, symbol string, fn func()) {
+	b := x.book(symbol)
+	beforeBids, beforeAsks := levels(b.bids), levels(b.asks)
+	fn()
+	bids, asks := levels(b.bids), levels(b.asks)
+	bidDiff := diffLevels(beforeBids, bids, true)
+	askDiff := diffLevels(beforeAsks, asks, false)
+	if len(bidDiff) == 0 && len(askDiff) == 0 {
+		return
+	}
+	prev := b.updateID
+	b.updateID++
+	s.publishDepth(x, symbol, prev, bidDiff, askDiff)
+	if topChanged(beforeBids, bids) || topChanged(beforeAsks, asks) {
+		s.publishBookTicker(x, symbol)
+	}
+}
+
+// diffLevels returns the levels of after that differ from before, with
+// removed levels at quantity 0, sorted best first
+func diffLevels(before, after []level, bids bool) []level {
+	old := make(map[float64]float64, len(before))
+	for _, l := range before {
+		old[l.price] = l.qty
+	}
+	var res []level
+	for _, l := range after {
+		if q, ok := old[l.price]; !ok || q != l.qty {
+			res = append(res, l)
+		}
+		delete(old, l.price)
+	}
+	for p := range old {
+		res = append(res, level{price: p})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if bids {
+			return res[i].price > res[j].price
+		}
+		return res[i].price < res[j].price
+	})
+	return res
+}
+
+// topChanged reports whether the best level changed
+func topChanged(before, after []level) bool {
+	if len(before) == 0 || len(after) == 0 {
+		return len(before) != len(after)
+	}
+	return before[0] != after[0]
+}
+
+// top returns the best level of a side, zero if empty
+func top(levels []level) level {
+	if len(levels) == 0 {
+		return level{}
+	}
+	return levels[0]
+}
+
+// publishDepth pushes a diff to the depth streams and the new book to the
+// partial depth streams
+func (s *Server) publishDepth(x *exchange, symbol string, prev int64, bids, asks []level) {
+	now := s.nowMs()
+	u := x.book(symbol).updateID
+	s.hub.publish(x.market, kindDepth, symbol, func(streamSpec) interface{} {
+		ev := map[string]interface{}{
+			"e": "depthUpdate",
+			"E": now,
+			"s": symbol,
+			"U": prev + 1,
+			"u": u,
+			"b": formatLevels(bids, 0),
+			"a": formatLevels(asks, 0),
+		}
+		if x.market == MarketFutures {
+			ev["T"] = now
+			ev["pu"] = prev
+		}
+		return ev
+	})
+	s.hub.publish(x.market, kindPartialDepth, symbol, func(spec streamSpec) interface{} {
+		return s.partialDepthEvent(x, symbol, spec.levels)
+	})
+}
+
+// partialDepthEvent returns the top levels of a book in the partial depth
+// stream format
+func (s *Server) partialDepthEvent(x *exchange, symbol string, limit int) interface{} {
+	b := x.book(symbol)
+	bids := formatLevels(levels(b.bids), limit)
+	asks := formatLevels(levels(b.asks), limit)
+	if x.market == MarketSpot {
+		return map[string]interface{}{"lastUpdateId": b.updateID, "bids": bids, "asks": asks}
+	}
+	now := s.nowMs()
+	prev := b.updateID - 1
+	if prev < 0 {
+		prev = 0
+	}
+	return map[string]interface{}{
+		"e":  "depthUpdate",
+		"E":  now,
+		"T":  now,
+		"s":  symbol,
+		"U":  b.updateID,
+		"u":  b.updateID,
+		"pu": prev,
+		"b":  bids,
+		"a":  asks,
+	}
+}
+
+// bookTickerEvent returns the top of a book in the bookTicker stream format
+func (s *Server) bookTickerEvent(x *exchange, symbol string) map[string]interface{} {
+	b := x.book(symbol)
+	bid, ask := top(levels(b.bids)), top(levels(b.asks))
+	ev := map[string]interface{}{
+		"u": b.updateID,
+		"s": symbol,
+		"b": formatFloat(bid.price),
+		"B": formatFloat(bid.qty),
+		"a": formatFloat(ask.price),
+		"A": formatFloat(ask.qty),
+	}
+	if x.market == MarketFutures {
+		now := s.nowMs()
+		ev["e"] = "bookTicker"
+		ev["E"] = now
+		ev["T"] = now
+	}
+	return ev
+}
+
+// publishBookTicker pushes the top of a book to the bookTicker streams
+func (s *Server) publishBookTicker(x *exchange, symbol string) {
+	ev := s.bookTickerEvent(x, symbol)
+	build := func(streamSpec) interface{} { return ev }
+	s.hub.publish(x.market, kindBookTicker, symbol, build)
+	s.hub.publish(x.market, kindAllBookTicker, "", build)
+}
+
+// publishTrade pushes a fill to the aggTrade and trade streams
+func (s *Server) publishTrade(x *exchange, f fill) {
+	now := s.nowMs()
+	symbol := f.taker.symbol
+	buyerMaker := f.maker.side == common.SideTypeBuy
+	s.hub.publish(x.market, kindAggTrade, symbol, func(streamSpec) interface{} {
+		ev := map[string]interface{}{
+			"e": "aggTrade",
+			"E": now,
+			"a": f.tradeID,
+			"s": symbol,
+			"p": formatFloat(f.price),
+			"q": formatFloat(f.qty),
+			"f": f.tradeID,
+			"l": f.tradeID,
+			"T": f.time,
+			"m": buyerMaker,
+		}
+		if x.market == MarketSpot {
+			ev["M"] = true
+		}
+		return ev
+	})
+	s.hub.publish(x.market, kindTrade, symbol, func(streamSpec) interface{} {
+		buyer, seller := f.taker, f.maker
+		if buyerMaker {
+			buyer, seller = f.maker, f.taker
+		}
+		return map[string]interface{}{
+			"e": "trade",
+			"E": now,
+			"s": symbol,
+			"t": f.tradeID,
+			"p": formatFloat(f.price),
+			"q": formatFloat(f.qty),
+			"b": buyer.id,
+			"a": seller.id,
+			"T": f.time,
+			"m": buyerMaker,
+			"M": true,
+		}
+	})
+}
+
+// markPriceEvent returns the futures mark price of a symbol in the
+// markPrice stream format
+func (s *Server) markPriceEvent(symbol string) map[string]interface{} {
+	x := s.markets[MarketFutures]
+	mark := formatFloat(x.markPrice(symbol))
+	return map[string]interface{}{
+		"e": "markPriceUpdate",
+		"E": s.nowMs(),
+		"s": symbol,
+		"p": mark,
+		"i": mark,
+		"P": mark,
+		"r": "0",
+		"T": nextFundingTime(s.now().UnixMilli()),
+	}
+}
+
+// publishMarkPrice pushes the mark price of a symbol to the markPrice streams
+func (s *Server) publishMarkPrice(symbol string) {
+	ev := s.markPriceEvent(symbol)
+	s.hub.publish(MarketFutures, kindMarkPrice, symbol, func(streamSpec) interface{} { return ev })
+	s.hub.publish(MarketFutures, kindAllMarkPrice, "", func(streamSpec) interface{} {
+		x := s.markets[MarketFutures]
+		all := make([]map[string]interface{}, 0, len(x.markPrices))
+		for _, sym := range x.symbols() {
+			if _, ok := x.markPrices[sym]; ok {
+				all = append(all, s.markPriceEvent(sym))
+			}
+		}
+		return all
+	})
+}
+
+// nextFundingTime returns the next 8 hour funding boundary
+func nextFundingTime(now int64) int64 {
+	const period = 8 * 3600 * 1000
+	return (now/period + 1) * period
+}
+
+// publishUser pushes an event to the user data streams of an account
+func (s *Server) publishUser(market Market, acct *account, ev interface{}) {
+	for key, lk := range s.listenKeys {
+		if lk.acct == acct && lk.market == market {
+			s.hub.publish(market, kindUser, key, func(streamSpec) interface{} { return ev })
+		}
+	}
+}
+
+// publishOrder pushes an order update to the user data streams of its
+// account. f is the fill that caused the update, if any.
+func (s *Server) publishOrder(x *exchange, o *order, execType string, f *fill) {
+	if x.market == MarketFutures {
+		s.publishUser(x.market, o.acct, s.futuresOrderEvent(x, o, execType, f, 0))
+	} else {
+		s.publishUser(x.market, o.acct, s.spotOrderEvent(x, o, execType, f))
+	}
+}
@@ -0,0 +1,323 @@
+// Package astertest provides a local Aster exchange for end-to-end tests.
+//
+// The server speaks the REST and websocket wire format of the spot (/api/v3)
+// and futures (/fapi) APIs. It verifies HMAC signatures, keeps balances and
+// positions per account, matches orders with price-time priority and pushes
+// depth, bookTicker, trade and user data events.
+//
+//	srv := astertest.NewServer()
+//	defer srv.Close()
+//	srv.AddAccount("key", "secret")
+//	srv.SetFuturesBalance("key", "USDT", "10000")
+//	srv.SetDepth(astertest.MarketFutures, "BTCUSDT", bids, asks)
+//
+//	client := futures.NewClient("key", "secret", aster.WithBaseURL(srv.URL()))
+//	aster.WsFuturesDepthServe("BTCUSDT", handler, errHandler,
+//		aster.WithStreamEndpoint(srv.FuturesWsURL()))
+package astertest
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	aster "github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// Market selects the spot or the futures exchange of the server
+type Market int
+
+const (
+	MarketSpot Market = iota
+	MarketFutures
+)
+
+// Symbol is a listed trading pair
+type Symbol struct {
+	Symbol     string
+	BaseAsset  string
+	QuoteAsset string
+}
+
+// Server is a local Aster exchange. All methods are safe for concurrent use.
+type Server struct {
+	srv *httptest.Server
+
+	mu         sync.Mutex
+	now        func() time.Time
+	recvWindow int64
+	symbols    map[string]Symbol
+	accounts   map[string]*account // by API key
+	listenKeys map[string]*listenKey
+	markets    [2]*exchange
+
+	hub *hub
+}
+
+// NewServer starts a server on a random local port
+func NewServer() *Server {
+	s := &Server{
+		now:        time.Now,
+		recvWindow: 5000,
+		symbols:    map[string]Symbol{},
+		accounts:   map[string]*account{},
+		listenKeys: map[string]*listenKey{},
+		markets:    [2]*exchange{newExchange(MarketSpot), newExchange(MarketFutures)},
+	}
+	s.hub = newHub(s)
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close disconnects all streams and shuts the server down
+func (s *Server) Close() {
+	s.hub.closeAll()
+	s.srv.Close()
+}
+
+// URL returns the REST base URL, to be passed to aster.WithBaseURL
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// SpotWsURL returns the spot websocket base URL, to be passed to
+// aster.WithStreamEndpoint
+func (s *Server) SpotWsURL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http") + "/spot"
+}
+
+// FuturesWsURL returns the futures websocket base URL, to be passed to
+// aster.WithStreamEndpoint
+func (s *Server) FuturesWsURL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http") + "/futures"
+}
+
+// AddSymbol lists a symbol. Symbols ending in USDT are listed automatically
+// the first time they are used.
+func (s *Server) AddSymbol(symbol Symbol) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.symbols[symbol.Symbol] = symbol
+}
+
+// symbol returns a listed symbol
+func (s *Server) symbol(name string) (Symbol, bool) {
+	sym, ok := s.symbols[name]
+	if !ok && len(name) > 4 && strings.HasSuffix(name, "USDT") {
+		sym = Symbol{Symbol: name, BaseAsset: strings.TrimSuffix(name, "USDT"), QuoteAsset: "USDT"}
+		s.symbols[name] = sym
+		ok = true
+	}
+	return sym, ok
+}
+
+// AddAccount creates an account authenticated by an API key and an HMAC
+// secret key
+func (s *Server) AddAccount(apiKey, secretKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[apiKey] = newAccount(apiKey, secretKey)
+}
+
+// SetSpotBalance sets the free spot balance of an asset
+func (s *Server) SetSpotBalance(apiKey, asset, free string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.accounts[apiKey]; ok {
+		a.spotBalance(asset).free = parseFloat(free)
+	}
+}
+
+// SetFuturesBalance sets the futures wallet balance of an asset
+func (s *Server) SetFuturesBalance(apiKey, asset, wallet string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.accounts[apiKey]; ok {
+		a.wallet[asset] = parseFloat(wallet)
+	}
+}
+
+// SetCommission sets the maker and taker commission rates of a market,
+// e.g. "0.0002" and "0.0004". Rates default to 0.
+func (s *Server) SetCommission(market Market, maker, taker string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	x := s.markets[market]
+	x.makerRate = parseFloat(maker)
+	x.takerRate = parseFloat(taker)
+}
+
+// SetRecvWindow sets the default recvWindow in milliseconds used when a
+// signed request does not send one
+func (s *Server) SetRecvWindow(ms int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recvWindow = ms
+}
+
+// SetDepth replaces the liquidity provided by the server itself on a symbol
+// with resting orders at the given [price, quantity] levels. Account orders
+// crossed by the new levels are filled.
+func (s *Server) SetDepth(market Market, symbol string, bids, asks [][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.symbol(symbol); !ok {
+		return
+	}
+	x := s.markets[market]
+	s.updateBook(x, symbol, func() {
+		x.removeHouseOrders(symbol)
+		for _, lv := range asks {
+			s.placeHouseOrder(x, symbol, common.SideTypeSell, lv)
+		}
+		for _, lv := range bids {
+			s.placeHouseOrder(x, symbol, common.SideTypeBuy, lv)
+		}
+	})
+}
+
+// placeHouseOrder places a server liquidity order and settles its fills
+func (s *Server) placeHouseOrder(x *exchange, symbol string, side common.SideType, lv []string) {
+	if len(lv) < 2 {
+		return
+	}
+	o := &order{
+		symbol:      symbol,
+		side:        side,
+		orderType:   common.OrderTypeLimit,
+		timeInForce: common.TimeInForceTypeGTC,
+		price:       parseFloat(lv[0]),
+		origQty:     parseFloat(lv[1]),
+	}
+	if o.price <= 0 || o.origQty <= 0 {
+		return
+	}
+	s.submit(x, o)
+}
+
+// SetMarkPrice sets the futures mark price of a symbol and pushes it to the
+// markPrice streams. Without a mark price, the last trade price is used.
+func (s *Server) SetMarkPrice(symbol, markPrice string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	x := s.markets[MarketFutures]
+	x.markPrices[symbol] = parseFloat(markPrice)
+	s.publishMarkPrice(symbol)
+}
+
+// ExpireListenKey invalidates a listen key and sends listenKeyExpired on its
+// user data streams
+func (s *Server) ExpireListenKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lk, ok := s.listenKeys[key]
+	if !ok {
+		return
+	}
+	delete(s.listenKeys, key)
+	now := s.nowMs()
+	ev := map[string]interface{}{"e": "listenKeyExpired", "E": now, "listenKey": key}
+	s.hub.publish(lk.market, kindUser, key, func(streamSpec) interface{} { return ev })
+}
+
+// CloseStreams drops every websocket connection, e.g. to test reconnects
+func (s *Server) CloseStreams() {
+	s.hub.closeAll()
+}
+
+// nowMs returns the server time in milliseconds
+func (s *Server) nowMs() int64 {
+	return s.now().UnixMilli()
+}
+
+// serveHTTP routes REST and websocket requests
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/spot/"):
+		s.hub.serveWs(w, r, MarketSpot, strings.TrimPrefix(r.URL.Path, "/spot"))
+	case strings.HasPrefix(r.URL.Path, "/futures/"):
+		s.hub.serveWs(w, r, MarketFutures, strings.TrimPrefix(r.URL.Path, "/futures"))
+	case strings.HasPrefix(r.URL.Path, "/fapi/"):
+		s.serveFutures(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/"):
+		s.serveSpot(w, r)
+	default:
+		writeError(w, http.StatusNotFound, errNotFound)
+	}
+}
+
+// apiError is an error response in the exchange format
+type apiError struct {
+	status int
+	code   int
+	msg    string
+}
+
+// Error codes returned by the server, matching the exchange
+var (
+	errNotFound          = &apiError{http.StatusNotFound, -1000, "Unknown endpoint."}
+	errMandatoryParam    = &apiError{http.StatusBadRequest, -1102, "Mandatory parameter was not sent, was empty/null, or malformed."}
+	errInvalidSymbol     = &apiError{http.StatusBadRequest, -1121, "Invalid symbol."}
+	errInvalidSignature  = &apiError{http.StatusBadRequest, -1022, "Signature for this request is not valid."}
+	errTimestamp         = &apiError{http.StatusBadRequest, -1021, "Timestamp for this request is outside of the recvWindow."}
+	errInvalidAPIKey     = &apiError{http.StatusUnauthorized, -2015, "Invalid API-key, IP, or permissions for action."}
+	errInvalidOrderType  = &apiError{http.StatusBadRequest, -1116, "Invalid orderType."}
+	errInvalidSide       = &apiError{http.StatusBadRequest, -1117, "Invalid side."}
+	errInvalidQty        = &apiError{http.StatusBadRequest, -4003, "Quantity less than or equal to zero."}
+	errInvalidPrice      = &apiError{http.StatusBadRequest, -4014, "Price less than or equal to zero."}
+	errNoSuchOrder       = &apiError{http.StatusBadRequest, -2013, "Order does not exist."}
+	errUnknownOrder      = &apiError{http.StatusBadRequest, -2011, "Unknown order sent."}
+	errDuplicateClientID = &apiError{http.StatusBadRequest, -4015, "Client order id is not valid."}
+	errInsufficient      = &apiError{http.StatusBadRequest, -2010, "Account has insufficient balance for requested action."}
+	errMargin            = &apiError{http.StatusBadRequest, -2019, "Margin is insufficient."}
+	errReduceOnly        = &apiError{http.StatusBadRequest, -2022, "ReduceOnly Order is rejected."}
+	errNoListenKey       = &apiError{http.StatusBadRequest, -1125, "This listenKey does not exist."}
+	errMarginType        = &apiError{http.StatusBadRequest, -4046, "No need to change margin type."}
+	errLimitMaker        = &apiError{http.StatusBadRequest, -2010, "Order would immediately match and take."}
+)
+
+// writeJSON writes a 200 response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := aster.JSON.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// writeError writes an error response decoded by the SDK as *common.APIError
+func writeError(w http.ResponseWriter, status int, e *apiError) {
+	if e.status != 0 {
+		status = e.status
+	}
+	data, _ := aster.JSON.Marshal(common.APIError{Code: e.code, Message: e.msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// parseFloat parses a decimal, treating malformed input as 0
+func parseFloat(s string) float64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// formatFloat formats a decimal the way the exchange does, rounded to 8
+// places to hide float artifacts
+func formatFloat(f float64) string {
+	f = math.Round(f*1e8) / 1e8
+	if f == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
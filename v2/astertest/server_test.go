@@ -0,0 +1,159 @@
+package astertest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	aster "github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/astertest"
+	"github.com/drinkthere/go-aster/v2/common"
+	"github.com/drinkthere/go-aster/v2/futures"
+)
+
+var ctx = context.Background()
+
+// newTestServer starts a server with a funded futures account "key" and a
+// BTCUSDT book of 99 bid, 101 ask
+func newTestServer(t *testing.T) *astertest.Server {
+	srv := astertest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.AddAccount("key", "secret")
+	srv.SetFuturesBalance("key", "USDT", "10000")
+	srv.SetDepth(astertest.MarketFutures, "BTCUSDT", [][]string{{"99", "5"}}, [][]string{{"101", "5"}})
+	return srv
+}
+
+// receive waits for a value on ch
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out")
+	}
+	panic("unreachable")
+}
+
+func TestSignedOrderFills(t *testing.T) {
+	srv := newTestServer(t)
+	client := futures.NewClient("key", "secret", aster.WithBaseURL(srv.URL()))
+
+	order, err := client.NewCreateOrderService().Symbol("BTCUSDT").Side(common.SideTypeBuy).
+		Type(common.OrderTypeMarket).Quantity("2").Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != common.OrderStatusTypeFilled || order.ExecutedQty != "2" {
+		t.Errorf("got order %s with %s executed, want FILLED with 2", order.Status, order.ExecutedQty)
+	}
+
+	positions, err := client.NewGetPositionRiskService().Symbol("BTCUSDT").Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 1 || positions[0].PositionAmt != "2" || positions[0].EntryPrice != "101" {
+		t.Errorf("got positions %+v, want 2 BTCUSDT at 101", positions)
+	}
+}
+
+func TestBadSignatureRejected(t *testing.T) {
+	srv := newTestServer(t)
+	client := futures.NewClient("key", "wrong secret", aster.WithBaseURL(srv.URL()))
+
+	_, err := client.NewCreateOrderService().Symbol("BTCUSDT").Side(common.SideTypeBuy).
+		Type(common.OrderTypeMarket).Quantity("1").Do(ctx)
+	var apiErr *common.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != -1022 {
+		t.Fatalf("got error %v, want API error -1022", err)
+	}
+	positions, err := client.NewGetPositionRiskService().Do(ctx)
+	if err == nil && len(positions) > 0 {
+		t.Errorf("a rejected order opened positions %+v", positions)
+	}
+}
+
+func TestUserDataPushes(t *testing.T) {
+	srv := newTestServer(t)
+	client := futures.NewClient("key", "secret", aster.WithBaseURL(srv.URL()))
+
+	orders := make(chan *aster.WsFuturesOrderUpdate, 10)
+	accounts := make(chan *aster.WsFuturesAccountUpdate, 10)
+	s := aster.NewFutures("key", "secret", aster.WithBaseURL(srv.URL())).NewUserStream(func(e *aster.WsFuturesUserDataEvent) {
+		switch {
+		case e.OrderUpdate != nil:
+			orders <- e.OrderUpdate
+		case e.AccountUpdate != nil:
+			accounts <- e.AccountUpdate
+		}
+	}, func(err error) { t.Error(err) })
+	s.Options = []aster.StreamOption{aster.WithStreamEndpoint(srv.FuturesWsURL())}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	if _, err := client.NewCreateOrderService().Symbol("BTCUSDT").Side(common.SideTypeSell).
+		Type(common.OrderTypeMarket).Quantity("1").Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if o := receive(t, orders); o.ExecutionType != common.ExecutionTypeNew {
+		t.Errorf("got execution %s, want NEW", o.ExecutionType)
+	}
+	if o := receive(t, orders); o.ExecutionType != common.ExecutionTypeTrade || o.OrderStatus != common.OrderStatusTypeFilled {
+		t.Errorf("got execution %s with status %s, want a TRADE that FILLED", o.ExecutionType, o.OrderStatus)
+	}
+	update := receive(t, accounts).UpdateData
+	if update.Reason != aster.AccountUpdateReasonOrder {
+		t.Errorf("got account update reason %s, want ORDER", update.Reason)
+	}
+	if len(update.Balances) != 1 || update.Balances[0].Asset != "USDT" || update.Balances[0].WalletBalance != "10000" {
+		t.Errorf("got balances %+v, want a USDT wallet of 10000", update.Balances)
+	}
+	if len(update.Positions) != 1 || update.Positions[0].Amount != "-1" || update.Positions[0].EntryPrice != "99" {
+		t.Errorf("got positions %+v, want -1 BTCUSDT at 99", update.Positions)
+	}
+}
+
+func TestMarketPushes(t *testing.T) {
+	srv := newTestServer(t)
+	endpoint := aster.WithStreamEndpoint(srv.FuturesWsURL())
+	errHandler := func(err error) { t.Error(err) }
+
+	depths := make(chan *aster.WsDepthEvent, 10)
+	doneC, stopC, err := aster.WsFuturesDepthServe("BTCUSDT", func(e *aster.WsDepthEvent) { depths <- e }, errHandler,
+		endpoint, aster.WithStreamReconnect(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { close(stopC); <-doneC }()
+
+	tickers := make(chan *aster.WsBookTickerEvent, 10)
+	doneC2, stopC2, err := aster.WsFuturesBookTickerServe("BTCUSDT", func(e *aster.WsBookTickerEvent) { tickers <- e }, errHandler,
+		endpoint, aster.WithStreamReconnect(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { close(stopC2); <-doneC2 }()
+
+	srv.SetDepth(astertest.MarketFutures, "BTCUSDT", [][]string{{"100", "3"}}, [][]string{{"101", "5"}})
+
+	depth := receive(t, depths)
+	if depth.Symbol != "BTCUSDT" || len(depth.Bids) == 0 {
+		t.Fatalf("got depth %+v, want BTCUSDT bids", depth)
+	}
+	bids := map[string]string{}
+	for _, bid := range depth.Bids {
+		bids[bid.Price] = bid.Quantity
+	}
+	if bids["100"] != "3" || bids["99"] != "0" {
+		t.Errorf("got bids %+v, want 100 added and 99 removed", depth.Bids)
+	}
+	ticker := receive(t, tickers)
+	if ticker.BestBidPrice != "100" || ticker.BestBidQty != "3" || ticker.BestAskPrice != "101" {
+		t.Errorf("got book ticker %+v, want 100x3 / 101", ticker)
+	}
+}
@@ -0,0 +1,629 @@
+package astertest
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/drinkthere/go-aster/v2/common"
+
+	aster "github.com/drinkthere/go-aster/v2"
+)
+
+// spotRoutes are the /api endpoints by "METHOD path"
+var spotRoutes = map[string]route{
+	"GET /api/v3/ping":              {secNone, func(s *Server, req *request) (interface{}, *apiError) { return struct{}{}, nil }},
+	"GET /api/v3/time":              {secNone, (*Server).serverTime},
+	"GET /api/v3/exchangeInfo":      {secNone, (*Server).spotExchangeInfo},
+	"GET /api/v3/depth":             {secNone, (*Server).spotDepth},
+	"GET /api/v3/trades":            {secNone, (*Server).spotTrades},
+	"GET /api/v3/aggTrades":         {secNone, (*Server).spotAggTrades},
+	"GET /api/v3/ticker/bookTicker": {secNone, (*Server).spotBookTicker},
+	"GET /api/v3/ticker/price":      {secNone, (*Server).spotPrice},
+	"POST /api/v3/order":            {secSigned, (*Server).spotNewOrder},
+	"GET /api/v3/order":             {secSigned, (*Server).spotGetOrder},
+	"DELETE /api/v3/order":          {secSigned, (*Server).spotCancelOrder},
+	"DELETE /api/v3/openOrders":     {secSigned, (*Server).spotCancelAll},
+	"GET /api/v3/openOrders":        {secSigned, (*Server).spotOpenOrders},
+	"GET /api/v3/allOrders":         {secSigned, (*Server).spotAllOrders},
+	"GET /api/v3/account":           {secSigned, (*Server).spotAccount},
+	"GET /api/v3/myTrades":          {secSigned, (*Server).spotMyTrades},
+	"POST /api/v3/userDataStream":   {secAPIKey, (*Server).spotStartUserStream},
+	"PUT /api/v3/userDataStream":    {secAPIKey, (*Server).spotKeepaliveUserStream},
+	"DELETE /api/v3/userDataStream": {secAPIKey, (*Server).spotCloseUserStream},
+}
+
+// serveSpot serves the /api endpoints
+func (s *Server) serveSpot(w http.ResponseWriter, r *http.Request) {
+	s.serveRoute(w, r, spotRoutes)
+}
+
+func (s *Server) spotExchangeInfo(req *request) (interface{}, *apiError) {
+	info := &aster.SpotExchangeInfo{Timezone: "UTC", ServerTime: s.nowMs()}
+	for _, sym := range s.listedSymbols() {
+		info.Symbols = append(info.Symbols, aster.SpotSymbol{
+			Symbol:               sym.Symbol,
+			Status:               "TRADING",
+			BaseAsset:            sym.BaseAsset,
+			BaseAssetPrecision:   8,
+			QuoteAsset:           sym.QuoteAsset,
+			QuotePrecision:       8,
+			QuoteAssetPrecision:  8,
+			OrderTypes:           []common.OrderType{common.OrderTypeLimit, common.OrderTypeLimitMaker, common.OrderTypeMarket},
+			IsSpotTradingAllowed: true,
+			Permissions:          []string{"SPOT"},
+		})
+	}
+	return info, nil
+}
+
+func (s *Server) spotDepth(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	b := s.markets[MarketSpot].book(symbol)
+	return &aster.SpotDepthResponse{
+		LastUpdateID: b.updateID,
+		Bids:         formatLevels(levels(b.bids), req.limit(100)),
+		Asks:         formatLevels(levels(b.asks), req.limit(100)),
+	}, nil
+}
+
+func (s *Server) spotTrades(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	res := make([]*aster.SpotMarketTrade, 0)
+	for _, t := range s.markets[MarketSpot].recentTrades(symbol, req.limit(500)) {
+		res = append(res, &aster.SpotMarketTrade{
+			ID:           t.id,
+			Price:        formatFloat(t.price),
+			Quantity:     formatFloat(t.qty),
+			QuoteQty:     formatFloat(t.price * t.qty),
+			Time:         t.time,
+			IsBuyerMaker: t.buyerMaker,
+			IsBestMatch:  true,
+		})
+	}
+	return res, nil
+}
+
+func (s *Server) spotAggTrades(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	res := make([]*aster.SpotAggTrade, 0)
+	for _, t := range s.markets[MarketSpot].filterTrades(symbol, req) {
+		res = append(res, &aster.SpotAggTrade{
+			TradeID:      t.id,
+			Price:        formatFloat(t.price),
+			Quantity:     formatFloat(t.qty),
+			FirstTradeID: t.id,
+			LastTradeID:  t.id,
+			Time:         t.time,
+			IsBuyerMaker: t.buyerMaker,
+			IsBestMatch:  true,
+		})
+	}
+	return res, nil
+}
+
+func (s *Server) spotBookTicker(req *request) (interface{}, *apiError) {
+	x := s.markets[MarketSpot]
+	symbols, single, e := s.tickerSymbols(x, req)
+	if e != nil {
+		return nil, e
+	}
+	res := make([]*aster.SpotBookTicker, 0, len(symbols))
+	for _, symbol := range symbols {
+		b := x.book(symbol)
+		bid, ask := top(levels(b.bids)), top(levels(b.asks))
+		res = append(res, &aster.SpotBookTicker{
+			Symbol:   symbol,
+			BidPrice: formatFloat(bid.price),
+			BidQty:   formatFloat(bid.qty),
+			AskPrice: formatFloat(ask.price),
+			AskQty:   formatFloat(ask.qty),
+		})
+	}
+	if single {
+		return res[0], nil
+	}
+	return res, nil
+}
+
+func (s *Server) spotPrice(req *request) (interface{}, *apiError) {
+	x := s.markets[MarketSpot]
+	symbols, single, e := s.tickerSymbols(x, req)
+	if e != nil {
+		return nil, e
+	}
+	res := make([]*aster.SpotSymbolPrice, 0, len(symbols))
+	for _, symbol := range symbols {
+		res = append(res, &aster.SpotSymbolPrice{Symbol: symbol, Price: formatFloat(x.lastPrice(symbol))})
+	}
+	if single {
+		return res[0], nil
+	}
+	return res, nil
+}
+
+// marketCost estimates the quote cost of a market buy sized in the base asset
+func (x *exchange) marketCost(o *order) float64 {
+	var cost float64
+	qty := o.origQty
+	for _, m := range x.book(o.symbol).opposite(o) {
+		q := math.Min(qty, m.remaining())
+		cost += q * m.price
+		if qty -= q; qty <= 0 {
+			break
+		}
+	}
+	return cost
+}
+
+// marketQty estimates the base quantity of a market sell sized in the quote
+// asset
+func (x *exchange) marketQty(o *order) float64 {
+	var qty float64
+	quote := o.quoteOrderQty
+	for _, m := range x.book(o.symbol).opposite(o) {
+		q := math.Min(quote/m.price, m.remaining())
+		qty += q
+		if quote -= q * m.price; quote <= 1e-12 {
+			break
+		}
+	}
+	return qty
+}
+
+func (s *Server) spotNewOrder(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	x := s.markets[MarketSpot]
+	o := &order{
+		acct:          req.acct,
+		clientOrderID: req.get("newClientOrderId"),
+		symbol:        symbol,
+		side:          common.SideType(req.get("side")),
+		orderType:     common.OrderType(req.get("type")),
+		timeInForce:   common.TimeInForceType(req.get("timeInForce")),
+		price:         req.float("price"),
+		origQty:       req.float("quantity"),
+		quoteOrderQty: req.float("quoteOrderQty"),
+	}
+	if e := validateOrder(o); e != nil {
+		return nil, e
+	}
+	switch o.orderType {
+	case common.OrderTypeLimit, common.OrderTypeLimitMaker:
+		if o.quoteOrderQty > 0 || o.origQty <= 0 {
+			return nil, errInvalidQty
+		}
+	case common.OrderTypeMarket:
+		if o.origQty > 0 {
+			o.quoteOrderQty = 0
+		}
+	default:
+		return nil, errInvalidOrderType
+	}
+	if o.orderType == common.OrderTypeLimitMaker && x.wouldTake(o) {
+		return nil, errLimitMaker
+	}
+	if o.clientOrderID != "" {
+		if prev := x.find(o.acct, symbol, 0, o.clientOrderID); prev != nil && prev.isOpen() {
+			return nil, errDuplicateClientID
+		}
+	}
+
+	sym, _ := s.symbol(symbol)
+	base, quote := o.acct.spotBalance(sym.BaseAsset), o.acct.spotBalance(sym.QuoteAsset)
+	switch {
+	case o.orderType == common.OrderTypeMarket && o.side == common.SideTypeBuy:
+		cost := o.quoteOrderQty
+		if cost == 0 {
+			cost = x.marketCost(o)
+		}
+		if cost > quote.free+1e-12 {
+			return nil, errInsufficient
+		}
+	case o.orderType == common.OrderTypeMarket:
+		qty := o.origQty
+		if qty == 0 {
+			qty = x.marketQty(o)
+		}
+		if qty > base.free+1e-12 {
+			return nil, errInsufficient
+		}
+	case o.side == common.SideTypeBuy:
+		o.locked = o.price * o.origQty
+		if o.locked > quote.free+1e-12 {
+			return nil, errInsufficient
+		}
+		quote.free -= o.locked
+		quote.locked += o.locked
+	default:
+		o.locked = o.origQty
+		if o.locked > base.free+1e-12 {
+			return nil, errInsufficient
+		}
+		base.free -= o.locked
+		base.locked += o.locked
+	}
+
+	var fills []fill
+	s.updateBook(x, symbol, func() { fills = s.submit(x, o) })
+	if !o.isOpen() {
+		s.releaseSpot(o)
+	}
+	s.publishSpotBalances(o)
+
+	res := &aster.CreateSpotOrderResponse{
+		Symbol:             o.symbol,
+		OrderID:            o.id,
+		OrderListID:        -1,
+		ClientOrderID:      o.clientOrderID,
+		TransactTime:       o.time,
+		Price:              formatFloat(o.price),
+		OrigQty:            formatFloat(o.origQty),
+		ExecutedQty:        formatFloat(o.executedQty),
+		CumulativeQuoteQty: formatFloat(o.cumQuote),
+		Status:             o.status,
+		TimeInForce:        o.timeInForce,
+		Type:               o.orderType,
+		Side:               o.side,
+		Fills:              []aster.SpotFill{},
+	}
+	for _, f := range fills {
+		commission, asset := s.spotCommission(x, o, f)
+		res.Fills = append(res.Fills, aster.SpotFill{
+			Price:           formatFloat(f.price),
+			Qty:             formatFloat(f.qty),
+			Commission:      formatFloat(commission),
+			CommissionAsset: asset,
+			TradeId:         f.tradeID,
+		})
+	}
+	return res, nil
+}
+
+// toSpotOrder converts an order to the REST format
+func toSpotOrder(o *order) *aster.SpotOrder {
+	return &aster.SpotOrder{
+		Symbol:             o.symbol,
+		OrderID:            o.id,
+		OrderListID:        -1,
+		ClientOrderID:      o.clientOrderID,
+		Price:              formatFloat(o.price),
+		OrigQty:            formatFloat(o.origQty),
+		ExecutedQty:        formatFloat(o.executedQty),
+		CumulativeQuoteQty: formatFloat(o.cumQuote),
+		Status:             o.status,
+		TimeInForce:        o.timeInForce,
+		Type:               o.orderType,
+		Side:               o.side,
+		StopPrice:          formatFloat(o.stopPrice),
+		IcebergQty:         "0",
+		Time:               o.time,
+		UpdateTime:         o.updateTime,
+		IsWorking:          true,
+		OrigQuoteOrderQty:  formatFloat(o.quoteOrderQty),
+	}
+}
+
+// toCancelSpotOrderResponse converts a canceled order to the REST format
+func toCancelSpotOrderResponse(o *order) *aster.CancelSpotOrderResponse {
+	return &aster.CancelSpotOrderResponse{
+		Symbol:             o.symbol,
+		OrigClientOrderID:  o.clientOrderID,
+		OrderID:            o.id,
+		OrderListID:        -1,
+		ClientOrderID:      o.clientOrderID,
+		Price:              formatFloat(o.price),
+		OrigQty:            formatFloat(o.origQty),
+		ExecutedQty:        formatFloat(o.executedQty),
+		CumulativeQuoteQty: formatFloat(o.cumQuote),
+		Status:             o.status,
+		TimeInForce:        o.timeInForce,
+		Type:               o.orderType,
+		Side:               o.side,
+	}
+}
+
+func (s *Server) spotGetOrder(req *request) (interface{}, *apiError) {
+	o, e := s.findOrder(s.markets[MarketSpot], req)
+	if e != nil {
+		return nil, e
+	}
+	return toSpotOrder(o), nil
+}
+
+func (s *Server) spotCancelOrder(req *request) (interface{}, *apiError) {
+	x := s.markets[MarketSpot]
+	o, e := s.findOrder(x, req)
+	if e != nil {
+		if e == errNoSuchOrder {
+			e = errUnknownOrder
+		}
+		return nil, e
+	}
+	if !o.isOpen() {
+		return nil, errUnknownOrder
+	}
+	s.cancelOrder(x, o)
+	return toCancelSpotOrderResponse(o), nil
+}
+
+func (s *Server) spotCancelAll(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	x := s.markets[MarketSpot]
+	res := make([]*aster.CancelSpotOrderResponse, 0)
+	for _, o := range x.accountOrders(req.acct, symbol, true) {
+		s.cancelOrder(x, o)
+		res = append(res, toCancelSpotOrderResponse(o))
+	}
+	return res, nil
+}
+
+func (s *Server) spotOpenOrders(req *request) (interface{}, *apiError) {
+	symbol := req.get("symbol")
+	if symbol != "" {
+		if _, ok := s.symbol(symbol); !ok {
+			return nil, errInvalidSymbol
+		}
+	}
+	res := make([]*aster.SpotOrder, 0)
+	for _, o := range s.markets[MarketSpot].accountOrders(req.acct, symbol, true) {
+		res = append(res, toSpotOrder(o))
+	}
+	return res, nil
+}
+
+func (s *Server) spotAllOrders(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	res := make([]*aster.SpotOrder, 0)
+	for _, o := range filterOrders(s.markets[MarketSpot].accountOrders(req.acct, symbol, false), req) {
+		res = append(res, toSpotOrder(o))
+	}
+	return res, nil
+}
+
+func (s *Server) spotAccount(req *request) (interface{}, *apiError) {
+	x := s.markets[MarketSpot]
+	acc := &aster.SpotAccount{
+		MakerCommission: int64(math.Round(x.makerRate * 10000)),
+		TakerCommission: int64(math.Round(x.takerRate * 10000)),
+		CanTrade:        true,
+		CanWithdraw:     true,
+		CanDeposit:      true,
+		UpdateTime:      s.nowMs(),
+		AccountType:     "SPOT",
+		Balances:        []aster.SpotBalance{},
+		Permissions:     []string{"SPOT"},
+	}
+	for _, asset := range req.acct.spotAssets {
+		b := req.acct.spot[asset]
+		acc.Balances = append(acc.Balances, aster.SpotBalance{
+			Asset:  asset,
+			Free:   formatFloat(b.free),
+			Locked: formatFloat(b.locked),
+		})
+	}
+	return acc, nil
+}
+
+func (s *Server) spotMyTrades(req *request) (interface{}, *apiError) {
+	symbol, e := s.marketSymbol(req)
+	if e != nil {
+		return nil, e
+	}
+	orderID, fromID := req.int64("orderId"), req.int64("fromId")
+	start, end := req.int64("startTime"), req.int64("endTime")
+	limit := req.limit(500)
+	res := make([]*aster.SpotTrade, 0)
+	for _, t := range req.acct.spotTrades[symbol] {
+		if (orderID > 0 && t.orderID != orderID) || (fromID > 0 && t.id < fromID) ||
+			(start > 0 && t.time < start) || (end > 0 && t.time > end) {
+			continue
+		}
+		res = append(res, &aster.SpotTrade{
+			Symbol:          symbol,
+			Id:              t.id,
+			OrderId:         t.orderID,
+			OrderListId:     -1,
+			Price:           formatFloat(t.price),
+			Qty:             formatFloat(t.qty),
+			QuoteQty:        formatFloat(t.price * t.qty),
+			Commission:      formatFloat(t.commission),
+			CommissionAsset: t.commissionAsset,
+			Time:            t.time,
+			IsBuyer:         t.isBuyer,
+			IsMaker:         t.isMaker,
+			IsBestMatch:     true,
+		})
+	}
+	if len(res) > limit {
+		if fromID > 0 {
+			res = res[:limit]
+		} else {
+			res = res[len(res)-limit:]
+		}
+	}
+	return res, nil
+}
+
+func (s *Server) spotStartUserStream(req *request) (interface{}, *apiError) {
+	return map[string]string{"listenKey": s.startUserStream(req.acct, MarketSpot)}, nil
+}
+
+func (s *Server) spotKeepaliveUserStream(req *request) (interface{}, *apiError) {
+	return s.keepaliveUserStream(req, MarketSpot)
+}
+
+func (s *Server) spotCloseUserStream(req *request) (interface{}, *apiError) {
+	return s.closeUserStream(req, MarketSpot)
+}
+
+// spotCommission returns the commission paid by o on f and its asset, which
+// is the asset received
+func (s *Server) spotCommission(x *exchange, o *order, f fill) (float64, string) {
+	sym, _ := s.symbol(o.symbol)
+	rate := x.commission(o, f)
+	if o.side == common.SideTypeBuy {
+		return f.qty * rate, sym.BaseAsset
+	}
+	return f.price * f.qty * rate, sym.QuoteAsset
+}
+
+// settleSpot applies a fill to the balances of a spot order and publishes
+// the order update. Balances of the incoming order are published once it
+// is done.
+func (s *Server) settleSpot(x *exchange, o *order, f fill) {
+	acct := o.acct
+	sym, _ := s.symbol(o.symbol)
+	base, quote := acct.spotBalance(sym.BaseAsset), acct.spotBalance(sym.QuoteAsset)
+	quoteQty := f.price * f.qty
+	commission, asset := s.spotCommission(x, o, f)
+	if o.side == common.SideTypeBuy {
+		if o.locked > 0 {
+			release := math.Min(o.locked, o.price*f.qty)
+			o.locked -= release
+			quote.locked -= release
+			quote.free += release
+		}
+		quote.free -= quoteQty
+		base.free += f.qty - commission
+	} else {
+		if o.locked > 0 {
+			release := math.Min(o.locked, f.qty)
+			o.locked -= release
+			base.locked -= release
+			base.free += release
+		}
+		base.free -= f.qty
+		quote.free += quoteQty - commission
+	}
+	acct.spotTrades[o.symbol] = append(acct.spotTrades[o.symbol], &accountTrade{
+		id:              f.tradeID,
+		symbol:          o.symbol,
+		orderID:         o.id,
+		price:           f.price,
+		qty:             f.qty,
+		commission:      commission,
+		commissionAsset: asset,
+		time:            f.time,
+		isBuyer:         o.side == common.SideTypeBuy,
+		isMaker:         o == f.maker,
+	})
+
+	s.publishOrder(x, o, "TRADE", &f)
+	if o == f.maker {
+		if !o.isOpen() {
+			s.releaseSpot(o)
+		}
+		s.publishSpotBalances(o)
+	}
+}
+
+// releaseSpot unlocks the funds still held by a spot order
+func (s *Server) releaseSpot(o *order) {
+	if o.acct == nil || o.locked == 0 {
+		return
+	}
+	sym, _ := s.symbol(o.symbol)
+	asset := sym.BaseAsset
+	if o.side == common.SideTypeBuy {
+		asset = sym.QuoteAsset
+	}
+	b := o.acct.spotBalance(asset)
+	b.locked -= o.locked
+	b.free += o.locked
+	o.locked = 0
+}
+
+// publishSpotBalances pushes the balances of the symbol assets of a spot
+// order to the user data streams of its account
+func (s *Server) publishSpotBalances(o *order) {
+	sym, _ := s.symbol(o.symbol)
+	now := s.nowMs()
+	var balances []map[string]interface{}
+	for _, asset := range []string{sym.BaseAsset, sym.QuoteAsset} {
+		b := o.acct.spotBalance(asset)
+		balances = append(balances, map[string]interface{}{
+			"a": asset,
+			"f": formatFloat(b.free),
+			"l": formatFloat(b.locked),
+		})
+	}
+	s.publishUser(MarketSpot, o.acct, map[string]interface{}{
+		"e": "outboundAccountPosition",
+		"E": now,
+		"u": now,
+		"B": balances,
+	})
+}
+
+// spotOrderEvent returns an executionReport event. f is the fill of TRADE
+// updates.
+func (s *Server) spotOrderEvent(x *exchange, o *order, execType string, f *fill) map[string]interface{} {
+	ev := map[string]interface{}{
+		"e": "executionReport",
+		"E": s.nowMs(),
+		"s": o.symbol,
+		"c": o.clientOrderID,
+		"S": o.side,
+		"o": o.orderType,
+		"f": o.timeInForce,
+		"q": formatFloat(o.origQty),
+		"p": formatFloat(o.price),
+		"P": formatFloat(o.stopPrice),
+		"F": "0",
+		"g": -1,
+		"C": "",
+		"x": execType,
+		"X": o.status,
+		"r": "NONE",
+		"i": o.id,
+		"l": "0",
+		"z": formatFloat(o.executedQty),
+		"L": "0",
+		"n": "0",
+		"N": nil,
+		"T": o.updateTime,
+		"t": -1,
+		"I": o.seq,
+		"w": o.isOpen(),
+		"m": false,
+		"M": false,
+		"O": o.time,
+		"Z": formatFloat(o.cumQuote),
+		"Y": "0",
+		"Q": formatFloat(o.quoteOrderQty),
+	}
+	if execType == "CANCELED" {
+		ev["C"] = o.clientOrderID
+	}
+	if f != nil {
+		commission, asset := s.spotCommission(x, o, *f)
+		ev["l"] = formatFloat(f.qty)
+		ev["L"] = formatFloat(f.price)
+		ev["n"] = formatFloat(commission)
+		ev["N"] = asset
+		ev["T"] = f.time
+		ev["t"] = f.tradeID
+		ev["m"] = o == f.maker
+		ev["M"] = true
+		ev["Y"] = formatFloat(f.price * f.qty)
+	}
+	return ev
+}
@@ -0,0 +1,362 @@
+package astertest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	aster "github.com/drinkthere/go-aster/v2"
+)
+
+// streamSpec is a parsed stream name
+type streamSpec struct {
+	name   string
+	kind   string
+	symbol string // upper case symbol, or the listen key of user streams
+	levels int    // partial depth levels
+}
+
+// Stream kinds pushed by the server. Other valid names are accepted and
+// never pushed.
+const (
+	kindDepth         = "depth"
+	kindPartialDepth  = "partialDepth"
+	kindBookTicker    = "bookTicker"
+	kindAllBookTicker = "allBookTicker"
+	kindAggTrade      = "aggTrade"
+	kindTrade         = "trade"
+	kindMarkPrice     = "markPrice"
+	kindAllMarkPrice  = "allMarkPrice"
+	kindUser          = "user"
+	kindOther         = "other"
+)
+
+// parseStream parses a stream name such as "btcusdt@depth5@100ms"
+func parseStream(name string) streamSpec {
+	spec := streamSpec{name: name, kind: kindOther}
+	parts := strings.Split(name, "@")
+	if len(parts) == 1 && !strings.HasPrefix(name, "!") {
+		spec.kind = kindUser
+		spec.symbol = name
+		return spec
+	}
+	switch {
+	case parts[0] == "!bookTicker":
+		spec.kind = kindAllBookTicker
+		return spec
+	case parts[0] == "!markPrice":
+		spec.kind = kindAllMarkPrice
+		return spec
+	case strings.HasPrefix(parts[0], "!"):
+		return spec
+	}
+	spec.symbol = strings.ToUpper(parts[0])
+	switch stream := parts[1]; {
+	case stream == "depth":
+		spec.kind = kindDepth
+	case strings.HasPrefix(stream, "depth"):
+		n, err := strconv.Atoi(strings.TrimPrefix(stream, "depth"))
+		if err == nil && n > 0 {
+			spec.kind = kindPartialDepth
+			spec.levels = n
+		}
+	case stream == "bookTicker":
+		spec.kind = kindBookTicker
+	case stream == "aggTrade":
+		spec.kind = kindAggTrade
+	case stream == "trade":
+		spec.kind = kindTrade
+	case stream == "markPrice":
+		spec.kind = kindMarkPrice
+	}
+	return spec
+}
+
+// hub holds the websocket connections of the server
+type hub struct {
+	s        *Server
+	upgrader websocket.Upgrader
+
+	mu    sync.Mutex
+	conns map[*wsConn]struct{}
+}
+
+// wsConn is one websocket connection and its subscriptions
+type wsConn struct {
+	ws       *websocket.Conn
+	market   Market
+	combined bool
+	send     chan []byte
+	done     chan struct{}
+	once     sync.Once
+	streams  map[string]streamSpec // guarded by hub.mu
+}
+
+// newHub creates an empty hub
+func newHub(s *Server) *hub {
+	return &hub{
+		s:        s,
+		upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+		conns:    map[*wsConn]struct{}{},
+	}
+}
+
+// serveWs upgrades a raw (/ws/<stream>) or combined (/stream?streams=)
+// stream request
+func (h *hub) serveWs(w http.ResponseWriter, r *http.Request, market Market, path string) {
+	var names []string
+	combined := false
+	switch {
+	case path == "/stream":
+		combined = true
+		if q := r.URL.Query().Get("streams"); q != "" {
+			names = strings.Split(q, "/")
+		}
+	case path == "/ws":
+	case strings.HasPrefix(path, "/ws/"):
+		names = strings.Split(strings.TrimPrefix(path, "/ws/"), "/")
+	default:
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+
+	streams := map[string]streamSpec{}
+	var specs []streamSpec
+	for _, name := range names {
+		spec := parseStream(name)
+		if spec.kind == kindUser && !h.s.validListenKey(market, spec.symbol) {
+			writeError(w, http.StatusBadRequest, errNoListenKey)
+			return
+		}
+		streams[name] = spec
+		specs = append(specs, spec)
+	}
+
+	// Hold the hub during the handshake, so that no event published once
+	// the client is connected misses the connection
+	h.mu.Lock()
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		h.mu.Unlock()
+		return
+	}
+	c := &wsConn{
+		ws:       ws,
+		market:   market,
+		combined: combined,
+		send:     make(chan []byte, 1024),
+		done:     make(chan struct{}),
+		streams:  streams,
+	}
+	h.conns[c] = struct{}{}
+	h.mu.Unlock()
+
+	go c.writeLoop()
+	go h.readLoop(c)
+	for _, spec := range specs {
+		h.s.sendInitial(c, spec)
+	}
+}
+
+// validListenKey reports whether key is an active listen key of market
+func (s *Server) validListenKey(market Market, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lk, ok := s.listenKeys[key]
+	return ok && lk.market == market
+}
+
+// writeLoop writes queued messages until the connection closes
+func (c *wsConn) writeLoop() {
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.ws.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// close closes the connection once
+func (c *wsConn) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.ws.Close()
+	})
+}
+
+// enqueue queues a message, dropping the connection if it is too slow
+func (c *wsConn) enqueue(msg []byte) {
+	select {
+	case c.send <- msg:
+	case <-c.done:
+	default:
+		c.close()
+	}
+}
+
+// wsRequest is a subscription management request
+type wsRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	ID     interface{}   `json:"id"`
+}
+
+// readLoop serves SUBSCRIBE, UNSUBSCRIBE and LIST_SUBSCRIPTIONS requests
+func (h *hub) readLoop(c *wsConn) {
+	defer func() {
+		h.mu.Lock()
+		delete(h.conns, c)
+		h.mu.Unlock()
+		c.close()
+	}()
+	for {
+		_, msg, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req wsRequest
+		if err := aster.JSON.Unmarshal(msg, &req); err != nil {
+			h.reply(c, map[string]interface{}{"code": 2, "msg": "Invalid JSON: " + err.Error(), "id": nil})
+			continue
+		}
+		var names []string
+		for _, p := range req.Params {
+			if name, ok := p.(string); ok {
+				names = append(names, name)
+			}
+		}
+		switch req.Method {
+		case "SUBSCRIBE":
+			var added []streamSpec
+			h.mu.Lock()
+			for _, name := range names {
+				if _, ok := c.streams[name]; !ok {
+					spec := parseStream(name)
+					c.streams[name] = spec
+					added = append(added, spec)
+				}
+			}
+			h.mu.Unlock()
+			h.reply(c, map[string]interface{}{"result": nil, "id": req.ID})
+			for _, spec := range added {
+				h.s.sendInitial(c, spec)
+			}
+		case "UNSUBSCRIBE":
+			h.mu.Lock()
+			for _, name := range names {
+				delete(c.streams, name)
+			}
+			h.mu.Unlock()
+			h.reply(c, map[string]interface{}{"result": nil, "id": req.ID})
+		case "LIST_SUBSCRIPTIONS":
+			h.mu.Lock()
+			list := make([]string, 0, len(c.streams))
+			for name := range c.streams {
+				list = append(list, name)
+			}
+			h.mu.Unlock()
+			h.reply(c, map[string]interface{}{"result": list, "id": req.ID})
+		default:
+			h.reply(c, map[string]interface{}{"code": 2, "msg": "Invalid request: unknown method " + req.Method, "id": req.ID})
+		}
+	}
+}
+
+// reply sends a response to a subscription request
+func (h *hub) reply(c *wsConn, v interface{}) {
+	data, err := aster.JSON.Marshal(v)
+	if err == nil {
+		c.enqueue(data)
+	}
+}
+
+// publish sends an event to the connections of market subscribed to a
+// stream of the given kind and symbol. build returns the payload of a
+// matching stream.
+func (h *hub) publish(market Market, kind, symbol string, build func(spec streamSpec) interface{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cache := map[string][]byte{}
+	for c := range h.conns {
+		if c.market != market {
+			continue
+		}
+		for name, spec := range c.streams {
+			if spec.kind != kind || spec.symbol != symbol {
+				continue
+			}
+			key := name
+			if c.combined {
+				key = "combined:" + name
+			}
+			msg, ok := cache[key]
+			if !ok {
+				var v interface{} = build(spec)
+				if c.combined {
+					v = map[string]interface{}{"stream": name, "data": v}
+				}
+				data, err := aster.JSON.Marshal(v)
+				if err != nil {
+					continue
+				}
+				msg = data
+				cache[key] = msg
+			}
+			c.enqueue(msg)
+		}
+	}
+}
+
+// closeAll drops every connection
+func (h *hub) closeAll() {
+	h.mu.Lock()
+	conns := make([]*wsConn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.mu.Unlock()
+	for _, c := range conns {
+		c.close()
+	}
+}
+
+// sendInitial pushes the current state to a new partial depth or mark
+// price subscription
+func (s *Server) sendInitial(c *wsConn, spec streamSpec) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var v interface{}
+	switch spec.kind {
+	case kindPartialDepth:
+		x := s.markets[c.market]
+		if _, ok := x.books[spec.symbol]; !ok {
+			return
+		}
+		v = s.partialDepthEvent(x, spec.symbol, spec.levels)
+	case kindMarkPrice:
+		if c.market != MarketFutures {
+			return
+		}
+		if _, ok := s.markets[MarketFutures].books[spec.symbol]; !ok {
+			return
+		}
+		v = s.markPriceEvent(spec.symbol)
+	default:
+		return
+	}
+	if c.combined {
+		v = map[string]interface{}{"stream": spec.name, "data": v}
+	}
+	data, err := aster.JSON.Marshal(v)
+	if err == nil {
+		c.enqueue(data)
+	}
+}
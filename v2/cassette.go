@@ -0,0 +1,217 @@
+package aster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Placeholders written to cassettes in place of volatile or secret values
+const (
+	cassetteRedacted  = "REDACTED"
+	cassetteTimestamp = "TIMESTAMP"
+)
+
+// cassetteNormalized maps the parameters that change between runs or hold
+// credentials to the placeholder stored in their place
+var cassetteNormalized = map[string]string{
+	"timestamp":     cassetteTimestamp,
+	"nonce":         cassetteTimestamp,
+	"signature":     cassetteRedacted,
+	"userAddress":   cassetteRedacted,
+	"signerAddress": cassetteRedacted,
+}
+
+// Cassette is a recorded sequence of API calls
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request and its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request with its signature, timestamps and keys
+// redacted
+type RecordedRequest struct {
+	Method   string     `json:"method"`
+	Endpoint string     `json:"endpoint"`
+	Params   url.Values `json:"params"` // query and form parameters
+}
+
+// RecordedResponse is a response status and body
+type RecordedResponse struct {
+	StatusCode int             `json:"statusCode"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// key returns the value requests are matched on, with the parameters sorted
+// by name and repeated values in request order
+func (r RecordedRequest) key() string {
+	return r.Method + " " + r.Endpoint + "?" + r.Params.Encode()
+}
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cassette := new(Cassette)
+	if err := JSON.Unmarshal(data, cassette); err != nil {
+		return nil, fmt.Errorf("aster: invalid cassette %s: %w", path, err)
+	}
+	return cassette, nil
+}
+
+// Save writes the cassette to a file
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// recordRequest returns the normalized form of req and restores its body
+func recordRequest(req *http.Request) (RecordedRequest, error) {
+	params := url.Values{}
+	for k, vs := range req.URL.Query() {
+		params[k] = vs
+	}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return RecordedRequest{}, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return RecordedRequest{}, err
+		}
+		for k, vs := range form {
+			params[k] = append(params[k], vs...)
+		}
+	}
+	for k, vs := range params {
+		if placeholder, ok := cassetteNormalized[k]; ok {
+			for i := range vs {
+				vs[i] = placeholder
+			}
+		}
+	}
+	return RecordedRequest{Method: req.Method, Endpoint: req.URL.Path, Params: params}, nil
+}
+
+// recordBody returns a response body as stored in a cassette. Bodies that
+// are not JSON are stored as JSON strings.
+func recordBody(data []byte) json.RawMessage {
+	if JSON.Valid(data) {
+		return append(json.RawMessage(nil), data...)
+	}
+	quoted, _ := JSON.Marshal(string(data))
+	return quoted
+}
+
+// replayBody reverses recordBody
+func replayBody(raw json.RawMessage) []byte {
+	var s string
+	if len(raw) > 0 && raw[0] == '"' && JSON.Unmarshal(raw, &s) == nil {
+		return []byte(s)
+	}
+	return raw
+}
+
+// WithRecorder records every API call of the client to a cassette file,
+// rewriting the file after each call. Signatures, timestamps, nonces and
+// Web3 addresses are redacted and the API key header is not stored.
+func WithRecorder(path string) ClientOption {
+	return func(c *BaseClient) {
+		var mu sync.Mutex
+		cassette := &Cassette{}
+		c.do = func(req *http.Request) (*http.Response, error) {
+			recorded, err := recordRequest(req)
+			if err != nil {
+				return nil, err
+			}
+			res, err := c.HTTPClient.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			data, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			res.Body = io.NopCloser(bytes.NewReader(data))
+
+			mu.Lock()
+			defer mu.Unlock()
+			cassette.Interactions = append(cassette.Interactions, Interaction{
+				Request:  recorded,
+				Response: RecordedResponse{StatusCode: res.StatusCode, Body: recordBody(data)},
+			})
+			if err := cassette.Save(path); err != nil {
+				return nil, err
+			}
+			return res, nil
+		}
+	}
+}
+
+// WithReplayer serves API calls from a cassette file recorded by
+// WithRecorder instead of the network. Requests match on method, endpoint
+// and parameters. Repeated requests are served in recorded order, and a
+// request beyond the recorded ones fails like an unrecorded one. Signed
+// requests still need credentials, which can be any value.
+func WithReplayer(path string) ClientOption {
+	return func(c *BaseClient) {
+		var mu sync.Mutex
+		cassette, loadErr := LoadCassette(path)
+		queues := map[string][]RecordedResponse{}
+		if loadErr == nil {
+			for _, it := range cassette.Interactions {
+				k := it.Request.key()
+				queues[k] = append(queues[k], it.Response)
+			}
+		}
+		c.do = func(req *http.Request) (*http.Response, error) {
+			if loadErr != nil {
+				return nil, loadErr
+			}
+			recorded, err := recordRequest(req)
+			if err != nil {
+				return nil, err
+			}
+			k := recorded.key()
+			mu.Lock()
+			queue := queues[k]
+			if len(queue) == 0 {
+				mu.Unlock()
+				return nil, fmt.Errorf("aster: no recorded response for %s", k)
+			}
+			resp := queue[0]
+			queues[k] = queue[1:]
+			mu.Unlock()
+
+			body := replayBody(resp.Body)
+			return &http.Response{
+				Status:        strconv.Itoa(resp.StatusCode) + " " + http.StatusText(resp.StatusCode),
+				StatusCode:    resp.StatusCode,
+				Header:        http.Header{"Content-Type": []string{"application/json"}},
+				Body:          io.NopCloser(bytes.NewReader(body)),
+				ContentLength: int64(len(body)),
+				Request:       req,
+			}, nil
+		}
+	}
+}
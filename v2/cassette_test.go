@@ -0,0 +1,149 @@
+package aster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+func TestCassetteRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/depth":
+			fmt.Fprintf(w, `{"lastUpdateId":%s,"bids":[["1.5","2"]],"asks":[]}`, r.URL.Query().Get("limit"))
+		case "/api/v3/order":
+			if err := r.ParseForm(); err != nil || r.PostForm.Get("symbol") != "BTCUSDT" {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			fmt.Fprint(w, `{"symbol":"BTCUSDT","orderId":7,"status":"NEW"}`)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, "<html>bad gateway</html>")
+		}
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	ctx := context.Background()
+	calls := func(client *SpotClient) (depths []*SpotDepthResponse, order *CreateSpotOrderResponse, pingErr error, err error) {
+		for _, limit := range []int{5, 5, 10} {
+			depth, err := client.NewDepthService().Symbol("BTCUSDT").Limit(limit).Do(ctx)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			depths = append(depths, depth)
+		}
+		order, err = client.NewCreateOrderService().Symbol("BTCUSDT").Side(common.SideTypeBuy).
+			Type(common.OrderTypeMarket).Quantity("1").Do(ctx)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return depths, order, client.NewPingService().Do(ctx), nil
+	}
+
+	recorded, order, pingErr, err := calls(NewSpot("key", "secret", WithBaseURL(srv.URL), WithRecorder(path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"key", "secret"} {
+		if strings.Contains(string(data), `"`+secret+`"`) {
+			t.Errorf("cassette contains %q", secret)
+		}
+	}
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(cassette.Interactions); n != 5 {
+		t.Fatalf("recorded %d interactions, want 5", n)
+	}
+	params := cassette.Interactions[3].Request.Params
+	if params.Get("timestamp") != cassetteTimestamp || params.Get("signature") != cassetteRedacted || params.Get("side") != "BUY" {
+		t.Errorf("recorded order params %v", params)
+	}
+
+	replayer := NewSpot("any", "any", WithReplayer(path))
+	replayed, replayedOrder, replayedPingErr, err := calls(replayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range recorded {
+		if replayed[i].LastUpdateID != recorded[i].LastUpdateID || replayed[i].Bids[0][0] != "1.5" {
+			t.Errorf("replayed depth %+v, recorded %+v", replayed[i], recorded[i])
+		}
+	}
+	if replayedOrder.OrderID != order.OrderID || replayedOrder.Status != order.Status {
+		t.Errorf("replayed order %+v, recorded %+v", replayedOrder, order)
+	}
+	if pingErr == nil || replayedPingErr == nil || replayedPingErr.Error() != pingErr.Error() {
+		t.Errorf("replayed ping error %v, recorded %v", replayedPingErr, pingErr)
+	}
+
+	// every recorded response is served once
+	if _, err := replayer.NewDepthService().Symbol("BTCUSDT").Limit(10).Do(ctx); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("got %v from an exhausted request", err)
+	}
+	if _, err := replayer.NewDepthService().Symbol("ETHUSDT").Do(ctx); err == nil {
+		t.Errorf("replayed an unrecorded request")
+	}
+}
+
+func TestSpotServiceFixtures(t *testing.T) {
+	client := NewSpot("key", "secret", WithReplayer(filepath.Join("testdata", "spot_services.json")))
+	ctx := context.Background()
+
+	serverTime, err := client.NewServerTimeService().Do(ctx)
+	if err != nil || serverTime != 1700000000123 {
+		t.Errorf("server time %d, %v", serverTime, err)
+	}
+	depth, err := client.NewDepthService().Symbol("BTCUSDT").Limit(5).Do(ctx)
+	if err != nil || depth.LastUpdateID != 4009002170 || len(depth.Bids) != 2 || depth.Asks[1][0] != "42000.50" {
+		t.Errorf("depth %+v, %v", depth, err)
+	}
+	aggTrades, err := client.NewAggTradesService().Symbol("BTCUSDT").FromID(26129).Limit(2).Do(ctx)
+	if err != nil || len(aggTrades) != 2 || aggTrades[0].LastTradeID != 27783 || !aggTrades[0].IsBuyerMaker || aggTrades[1].Price != "42000.20" {
+		t.Errorf("aggregate trades %+v, %v", aggTrades, err)
+	}
+	klines, err := client.NewKlinesService().Symbol("BTCUSDT").Interval(common.Interval1m).Limit(2).Do(ctx)
+	if err != nil || len(klines) != 2 || klines[0].CloseTime != 1699999979999 || klines[0].TradeNum != 310 || klines[1].Close != "42000.10" {
+		t.Errorf("klines %+v, %v", klines, err)
+	}
+	prices, err := client.NewListPricesService().Symbol("BTCUSDT").Do(ctx)
+	if err != nil || len(prices) != 1 || prices[0].Price != "42000.10" {
+		t.Errorf("prices %+v, %v", prices, err)
+	}
+	tickers, err := client.NewListBookTickersService().Do(ctx)
+	if err != nil || len(tickers) != 2 || tickers[1].Symbol != "ETHUSDT" || tickers[0].AskQty != "0.700" {
+		t.Errorf("book tickers %+v, %v", tickers, err)
+	}
+	account, err := client.NewGetAccountService().Do(ctx)
+	if err != nil || !account.CanTrade || len(account.Balances) != 2 || account.Balances[0].Locked != "0.10000000" {
+		t.Errorf("account %+v, %v", account, err)
+	}
+	order, err := client.NewCreateOrderService().Symbol("BTCUSDT").Side(common.SideTypeBuy).Type(common.OrderTypeLimit).
+		TimeInForce(common.TimeInForceTypeGTC).Quantity("0.01").Price("41000").NewClientOrderID("fixture-1").Do(ctx)
+	if err != nil || order.OrderID != 28 || order.Status != common.OrderStatusTypeNew || order.ClientOrderID != "fixture-1" {
+		t.Errorf("order %+v, %v", order, err)
+	}
+	_, err = client.NewCancelOrderService().Symbol("BTCUSDT").OrderID(29).Do(ctx)
+	var apiErr *common.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != -2011 {
+		t.Errorf("cancel returned %v, want API error -2011", err)
+	}
+	listenKey, err := client.NewStartUserStreamService().Do(ctx)
+	if err != nil || len(listenKey) != 64 {
+		t.Errorf("listen key %q, %v", listenKey, err)
+	}
+}
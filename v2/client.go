@@ -311,13 +311,16 @@ func (c *BaseClient) callAPI(ctx context.Context, r *request, opts ...RequestOpt
 	}
 
 	if res.StatusCode >= http.StatusBadRequest {
-		apiErr := new(common.APIError)
+		apiErr := &common.APIError{StatusCode: res.StatusCode}
 		e := JSON.Unmarshal(data, apiErr)
 		if e != nil {
 			if c.Debug {
 				c.Logger.Printf("Failed to unmarshal error: %s", e)
 			}
-			return nil, fmt.Errorf("request failed with status %d: %s", res.StatusCode, string(data))
+			return nil, &common.APIError{
+				Message:    fmt.Sprintf("request failed with status %d: %s", res.StatusCode, string(data)),
+				StatusCode: res.StatusCode,
+			}
 		}
 		return nil, apiErr
 	}
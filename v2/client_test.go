@@ -0,0 +1,53 @@
+package aster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+func TestCallAPIStatusCode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v3/userDataStream" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":-1125,"msg":"This listenKey does not exist."}`))
+	}))
+	defer srv.Close()
+	client := NewSpot("key", "secret", WithBaseURL(srv.URL))
+	ctx := context.Background()
+
+	err := client.NewKeepaliveUserStreamService().ListenKey("k").Do(ctx)
+	var apiErr *common.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("got %v, want an API error with status 404", err)
+	}
+	err = (&FuturesClient{BaseClient: client.BaseClient}).NewKeepaliveUserStreamService().ListenKey("k").Do(ctx)
+	if !errors.As(err, &apiErr) || apiErr.Code != -1125 || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("got %v, want API error -1125 with status 400", err)
+	}
+}
+
+func TestIsListenKeyGone(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		gone bool
+	}{
+		{&common.APIError{Code: -1125, StatusCode: http.StatusBadRequest}, true},
+		{&common.APIError{StatusCode: http.StatusNotFound}, true},
+		{fmt.Errorf("keepalive: %w", &common.APIError{Code: -1125}), true},
+		{&common.APIError{Code: -1022, StatusCode: http.StatusBadRequest}, false},
+		{errors.New("request failed with status 404"), false},
+	} {
+		if got := isListenKeyGone(tt.err); got != tt.gone {
+			t.Errorf("isListenKeyGone(%v) = %v, want %v", tt.err, got, tt.gone)
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package common
 
+import "time"
+
 // Common types shared between spot and futures
 
 // APIError represents an error response from the API
 type APIError struct {
-	Code    int    `json:"code"`
-	Message string `json:"msg"`
+	Code       int    `json:"code"`
+	Message    string `json:"msg"`
+	StatusCode int    `json:"-"` // HTTP status of the response
 }
 
 // Error returns the error message
@@ -67,6 +70,21 @@ const (
 	OrderStatusTypeExpired         OrderStatusType = "EXPIRED"
 )
 
+// Execution type of an order update
+type ExecutionType string
+
+const (
+	ExecutionTypeNew             ExecutionType = "NEW"
+	ExecutionTypeCanceled        ExecutionType = "CANCELED"
+	ExecutionTypeReplaced        ExecutionType = "REPLACED"
+	ExecutionTypeRejected        ExecutionType = "REJECTED"
+	ExecutionTypeTrade           ExecutionType = "TRADE"
+	ExecutionTypeExpired         ExecutionType = "EXPIRED"
+	ExecutionTypeCalculated      ExecutionType = "CALCULATED"
+	ExecutionTypeAmendment       ExecutionType = "AMENDMENT"
+	ExecutionTypeTradePrevention ExecutionType = "TRADE_PREVENTION"
+)
+
 // Time in force
 type TimeInForceType string
 
@@ -132,3 +150,39 @@ const (
 	Interval1w  Interval = "1w"
 	Interval1M  Interval = "1M"
 )
+
+// Duration returns the length of the interval. It returns 0 for Interval1M
+// since calendar months have no fixed length.
+func (i Interval) Duration() time.Duration {
+	switch i {
+	case Interval1m:
+		return time.Minute
+	case Interval3m:
+		return 3 * time.Minute
+	case Interval5m:
+		return 5 * time.Minute
+	case Interval15m:
+		return 15 * time.Minute
+	case Interval30m:
+		return 30 * time.Minute
+	case Interval1h:
+		return time.Hour
+	case Interval2h:
+		return 2 * time.Hour
+	case Interval4h:
+		return 4 * time.Hour
+	case Interval6h:
+		return 6 * time.Hour
+	case Interval8h:
+		return 8 * time.Hour
+	case Interval12h:
+		return 12 * time.Hour
+	case Interval1d:
+		return 24 * time.Hour
+	case Interval3d:
+		return 3 * 24 * time.Hour
+	case Interval1w:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
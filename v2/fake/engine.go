@@ -0,0 +1,599 @@
+package fake
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// Error codes returned by the fake clients, matching the exchange
+const (
+	codeMandatoryParam       = -1102
+	codeInvalidOrderType     = -1116
+	codeUnknownOrder         = -2011
+	codeNoSuchOrder          = -2013
+	codeWouldTrigger         = -2021
+	codeInvalidQty           = -4003
+	codeInvalidPrice         = -4014
+	codeDuplicateClientID    = -4015
+	codeNoNeedToChangeMargin = -4046
+)
+
+// workingTypeMarkPrice triggers a futures order on the mark price
+const workingTypeMarkPrice = "MARK_PRICE"
+
+// trigger describes a conditional order type: the type it executes as once
+// the trigger price crosses its stop price, and whether it triggers on a
+// rise (buy stops, sell take profits) or a fall
+type trigger struct {
+	execType   common.OrderType
+	takeProfit bool
+}
+
+// Conditional order types of each market. The same name can execute
+// differently, e.g. TAKE_PROFIT is a market order on spot and a limit
+// order on futures.
+var (
+	spotTriggers = map[common.OrderType]trigger{
+		common.OrderTypeStopLoss:        {execType: common.OrderTypeMarket},
+		common.OrderTypeStopLossLimit:   {execType: common.OrderTypeLimit},
+		common.OrderTypeTakeProfit:      {execType: common.OrderTypeMarket, takeProfit: true},
+		common.OrderTypeTakeProfitLimit: {execType: common.OrderTypeLimit, takeProfit: true},
+	}
+	futuresTriggers = map[common.OrderType]trigger{
+		"STOP":               {execType: common.OrderTypeLimit},
+		"STOP_MARKET":        {execType: common.OrderTypeMarket},
+		"TAKE_PROFIT":        {execType: common.OrderTypeLimit, takeProfit: true},
+		"TAKE_PROFIT_MARKET": {execType: common.OrderTypeMarket, takeProfit: true},
+	}
+)
+
+// newAPIError returns an API error as callAPI would
+func newAPIError(code int, msg string) *common.APIError {
+	return &common.APIError{Code: code, Message: msg}
+}
+
+// level is a price level of the book
+type level struct {
+	price float64
+	qty   float64
+}
+
+// book is the external liquidity of a symbol. Resting fake orders are kept
+// out of it and fill when the book moves through their price.
+type book struct {
+	bids         []level // best (highest) first
+	asks         []level // best (lowest) first
+	lastUpdateID int64
+	// last is the price conditional orders trigger on: the latest fill, or
+	// the mid price after the book was last set
+	last float64
+}
+
+// order is the market-agnostic state of a fake order
+type order struct {
+	id            int64
+	clientOrderID string
+	symbol        string
+	side          common.SideType
+	orderType     common.OrderType
+	timeInForce   common.TimeInForceType
+	price         float64
+	stopPrice     float64
+	origQty       float64
+	executedQty   float64
+	cumQuote      float64
+	status        common.OrderStatusType
+	reduceOnly    bool
+	positionSide  string
+	workingType   string
+	time          int64
+	updateTime    int64
+
+	// execType is the type the order executes as, "" while a conditional
+	// order waits for its trigger
+	execType common.OrderType
+}
+
+// avgPrice returns the average fill price
+func (o *order) avgPrice() float64 {
+	if o.executedQty == 0 {
+		return 0
+	}
+	return o.cumQuote / o.executedQty
+}
+
+// isOpen reports whether the order can still fill
+func (o *order) isOpen() bool {
+	return o.status == common.OrderStatusTypeNew || o.status == common.OrderStatusTypePartiallyFilled
+}
+
+// trade is a fill of a fake order
+type trade struct {
+	id      int64
+	orderID int64
+	symbol  string
+	side    common.SideType
+	price   float64
+	qty     float64
+	time    int64
+	isMaker bool
+}
+
+// engine is the in-memory order book and order lifecycle shared by the
+// spot and futures fakes
+type engine struct {
+	mu          sync.Mutex
+	nextOrderID int64
+	nextTradeID int64
+	books       map[string]*book
+	orders      map[string][]*order
+	trades      map[string][]*trade
+	triggers    map[common.OrderType]trigger
+	now         func() time.Time
+	onFill      func(o *order, t *trade)
+	// markPrice returns the mark price of a symbol, 0 when unknown, for
+	// orders triggered on it
+	markPrice func(symbol string) float64
+}
+
+// newEngine creates an empty engine with the conditional order types of a
+// market
+func newEngine(triggers map[common.OrderType]trigger) *engine {
+	return &engine{
+		books:    map[string]*book{},
+		orders:   map[string][]*order{},
+		trades:   map[string][]*trade{},
+		triggers: triggers,
+		now:      time.Now,
+	}
+}
+
+// nowMs returns the engine time in milliseconds
+func (e *engine) nowMs() int64 {
+	return e.now().UnixMilli()
+}
+
+// parseLevels converts [price, qty] string pairs to levels, dropping
+// malformed entries and zero quantities
+func parseLevels(in [][]string) []level {
+	levels := make([]level, 0, len(in))
+	for _, l := range in {
+		if len(l) < 2 {
+			continue
+		}
+		price, err1 := strconv.ParseFloat(l[0], 64)
+		qty, err2 := strconv.ParseFloat(l[1], 64)
+		if err1 != nil || err2 != nil || qty <= 0 {
+			continue
+		}
+		levels = append(levels, level{price: price, qty: qty})
+	}
+	return levels
+}
+
+// formatLevels converts levels back to [price, qty] string pairs
+func formatLevels(levels []level, limit int) [][]string {
+	if limit > 0 && len(levels) > limit {
+		levels = levels[:limit]
+	}
+	out := make([][]string, 0, len(levels))
+	for _, l := range levels {
+		out = append(out, []string{formatFloat(l.price), formatFloat(l.qty)})
+	}
+	return out
+}
+
+// formatFloat formats a number the way the exchange returns decimals
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// parseFloat parses a decimal string, treating "" as 0
+func parseFloat(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// getBook returns the book of a symbol, creating it if needed
+func (e *engine) getBook(symbol string) *book {
+	b, ok := e.books[symbol]
+	if !ok {
+		b = &book{}
+		e.books[symbol] = b
+	}
+	return b
+}
+
+// setBook replaces the book of a symbol and fills resting orders that the
+// new prices cross
+func (e *engine) setBook(symbol string, bids, asks [][]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	b := e.getBook(symbol)
+	b.bids = parseLevels(bids)
+	b.asks = parseLevels(asks)
+	sort.Slice(b.bids, func(i, j int) bool { return b.bids[i].price > b.bids[j].price })
+	sort.Slice(b.asks, func(i, j int) bool { return b.asks[i].price < b.asks[j].price })
+	b.lastUpdateID++
+	b.last = 0
+	if bid, ask := e.bestBidAsk(symbol); bid.price > 0 && ask.price > 0 {
+		b.last = (bid.price + ask.price) / 2
+	}
+	for _, o := range e.orders[symbol] {
+		if o.isOpen() && o.execType != "" {
+			e.match(o, b, true)
+		}
+	}
+	e.trigger(symbol)
+}
+
+// bestBidAsk returns the top of the book, with zero values for empty sides
+func (e *engine) bestBidAsk(symbol string) (bid, ask level) {
+	b := e.getBook(symbol)
+	if len(b.bids) > 0 {
+		bid = b.bids[0]
+	}
+	if len(b.asks) > 0 {
+		ask = b.asks[0]
+	}
+	return bid, ask
+}
+
+// crosses reports whether a limit order at price trades against a level
+func crosses(side common.SideType, price float64, l level) bool {
+	if side == common.SideTypeBuy {
+		return l.price <= price
+	}
+	return l.price >= price
+}
+
+// fillable returns the quantity available to an order without filling it
+func (e *engine) fillable(o *order, b *book) float64 {
+	levels := b.asks
+	if o.side == common.SideTypeSell {
+		levels = b.bids
+	}
+	total := 0.0
+	for _, l := range levels {
+		if o.execType != common.OrderTypeMarket && !crosses(o.side, o.price, l) {
+			break
+		}
+		total += l.qty
+	}
+	return total
+}
+
+// match fills o against the book. Resting orders fill at their own price
+// as makers; incoming orders take liquidity level by level.
+func (e *engine) match(o *order, b *book, resting bool) {
+	levels := &b.asks
+	if o.side == common.SideTypeSell {
+		levels = &b.bids
+	}
+	for len(*levels) > 0 && o.executedQty < o.origQty {
+		l := &(*levels)[0]
+		if o.execType != common.OrderTypeMarket && !crosses(o.side, o.price, *l) {
+			break
+		}
+		qty := o.origQty - o.executedQty
+		if l.qty < qty {
+			qty = l.qty
+		}
+		price := l.price
+		if resting {
+			price = o.price
+		}
+		e.fill(o, price, qty, resting)
+		l.qty -= qty
+		if l.qty <= 0 {
+			*levels = (*levels)[1:]
+		}
+	}
+}
+
+// fill applies one fill to an order
+func (e *engine) fill(o *order, price, qty float64, isMaker bool) {
+	e.nextTradeID++
+	o.executedQty += qty
+	o.cumQuote += price * qty
+	o.updateTime = e.nowMs()
+	if o.executedQty >= o.origQty {
+		o.status = common.OrderStatusTypeFilled
+	} else {
+		o.status = common.OrderStatusTypePartiallyFilled
+	}
+	t := &trade{
+		id:      e.nextTradeID,
+		orderID: o.id,
+		symbol:  o.symbol,
+		side:    o.side,
+		price:   price,
+		qty:     qty,
+		time:    o.updateTime,
+		isMaker: isMaker,
+	}
+	e.trades[o.symbol] = append(e.trades[o.symbol], t)
+	e.getBook(o.symbol).last = price
+	if e.onFill != nil {
+		e.onFill(o, t)
+	}
+}
+
+// place validates, matches and stores a new order. Conditional orders
+// rest without matching until their trigger price crosses the stop price.
+func (e *engine) place(o *order) error {
+	if o.symbol == "" || o.side == "" || o.orderType == "" {
+		return newAPIError(codeMandatoryParam, "Mandatory parameter was not sent, was empty/null, or malformed.")
+	}
+	trig, conditional := e.triggers[o.orderType]
+	o.execType = o.orderType
+	switch {
+	case conditional:
+		if o.stopPrice <= 0 {
+			return newAPIError(codeMandatoryParam, "Mandatory parameter 'stopPrice' was not sent, was empty/null, or malformed.")
+		}
+		o.execType = ""
+	case o.orderType != common.OrderTypeLimit && o.orderType != common.OrderTypeMarket && o.orderType != common.OrderTypeLimitMaker:
+		return newAPIError(codeInvalidOrderType, "Invalid orderType.")
+	}
+	if o.origQty <= 0 {
+		return newAPIError(codeInvalidQty, "Quantity less than zero.")
+	}
+	isLimit := o.orderType != common.OrderTypeMarket
+	if conditional {
+		isLimit = trig.execType == common.OrderTypeLimit
+	}
+	if isLimit && o.price <= 0 {
+		return newAPIError(codeInvalidPrice, "Price less than or equal to zero.")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, existing := range e.orders[o.symbol] {
+		if o.clientOrderID != "" && existing.clientOrderID == o.clientOrderID && existing.isOpen() {
+			return newAPIError(codeDuplicateClientID, "Client order id is not valid.")
+		}
+	}
+	if conditional && e.triggered(o, trig) {
+		return newAPIError(codeWouldTrigger, "Order would immediately trigger.")
+	}
+	e.nextOrderID++
+	o.id = e.nextOrderID
+	if o.clientOrderID == "" {
+		o.clientOrderID = "fake" + strconv.FormatInt(o.id, 10)
+	}
+	if o.timeInForce == "" && isLimit && o.orderType != common.OrderTypeLimitMaker {
+		o.timeInForce = common.TimeInForceTypeGTC
+	}
+	o.time = e.nowMs()
+	o.updateTime = o.time
+	o.status = common.OrderStatusTypeNew
+	e.orders[o.symbol] = append(e.orders[o.symbol], o)
+	if conditional {
+		return nil
+	}
+	e.execute(o)
+	e.trigger(o.symbol)
+	return nil
+}
+
+// execute matches a new or just triggered order as its execution type
+func (e *engine) execute(o *order) {
+	b := e.getBook(o.symbol)
+	switch {
+	case o.execType == common.OrderTypeMarket:
+		e.match(o, b, false)
+		if o.executedQty < o.origQty {
+			o.status = common.OrderStatusTypeExpired
+		}
+	case o.orderType == common.OrderTypeLimitMaker || o.timeInForce == common.TimeInForceTypeGTX:
+		if e.fillable(o, b) > 0 {
+			o.status = common.OrderStatusTypeExpired
+		}
+	case o.timeInForce == common.TimeInForceTypeFOK:
+		if e.fillable(o, b) < o.origQty {
+			o.status = common.OrderStatusTypeExpired
+		} else {
+			e.match(o, b, false)
+		}
+	case o.timeInForce == common.TimeInForceTypeIOC:
+		e.match(o, b, false)
+		if o.executedQty < o.origQty {
+			o.status = common.OrderStatusTypeExpired
+		}
+	default:
+		e.match(o, b, false)
+	}
+}
+
+// trigger executes the waiting conditional orders of a symbol whose stop
+// price has been crossed. Their fills move the last price, which can
+// trigger more orders.
+func (e *engine) trigger(symbol string) {
+	for fired := true; fired; {
+		fired = false
+		for _, o := range e.orders[symbol] {
+			if !o.isOpen() || o.execType != "" {
+				continue
+			}
+			trig := e.triggers[o.orderType]
+			if !e.triggered(o, trig) {
+				continue
+			}
+			o.execType = trig.execType
+			o.updateTime = e.nowMs()
+			e.execute(o)
+			fired = true
+		}
+	}
+}
+
+// triggered reports whether the trigger price of a conditional order has
+// crossed its stop price. Nothing triggers without a price.
+func (e *engine) triggered(o *order, trig trigger) bool {
+	price := e.getBook(o.symbol).last
+	if o.workingType == workingTypeMarkPrice && e.markPrice != nil {
+		if mark := e.markPrice(o.symbol); mark > 0 {
+			price = mark
+		}
+	}
+	if price <= 0 {
+		return false
+	}
+	rises := (o.side == common.SideTypeBuy) != trig.takeProfit
+	if rises {
+		return price >= o.stopPrice
+	}
+	return price <= o.stopPrice
+}
+
+// find returns an order by id or client order id
+func (e *engine) find(symbol string, orderID int64, clientOrderID string) *order {
+	for _, o := range e.orders[symbol] {
+		if (orderID > 0 && o.id == orderID) || (orderID <= 0 && clientOrderID != "" && o.clientOrderID == clientOrderID) {
+			return o
+		}
+	}
+	return nil
+}
+
+// get returns a copy of an order
+func (e *engine) get(symbol string, orderID int64, clientOrderID string) (order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	o := e.find(symbol, orderID, clientOrderID)
+	if o == nil {
+		return order{}, newAPIError(codeNoSuchOrder, "Order does not exist.")
+	}
+	return *o, nil
+}
+
+// cancel cancels an open order and returns a copy of it
+func (e *engine) cancel(symbol string, orderID int64, clientOrderID string) (order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	o := e.find(symbol, orderID, clientOrderID)
+	if o == nil || !o.isOpen() {
+		return order{}, newAPIError(codeUnknownOrder, "Unknown order sent.")
+	}
+	o.status = common.OrderStatusTypeCanceled
+	o.updateTime = e.nowMs()
+	return *o, nil
+}
+
+// cancelAll cancels all open orders of a symbol and returns copies of them
+func (e *engine) cancelAll(symbol string) []order {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var canceled []order
+	for _, o := range e.orders[symbol] {
+		if o.isOpen() {
+			o.status = common.OrderStatusTypeCanceled
+			o.updateTime = e.nowMs()
+			canceled = append(canceled, *o)
+		}
+	}
+	return canceled
+}
+
+// list returns copies of the orders of a symbol ("" for all symbols)
+func (e *engine) list(symbol string, openOnly bool) []order {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var symbols []string
+	if symbol != "" {
+		symbols = []string{symbol}
+	} else {
+		for s := range e.orders {
+			symbols = append(symbols, s)
+		}
+		sort.Strings(symbols)
+	}
+	var out []order
+	for _, s := range symbols {
+		for _, o := range e.orders[s] {
+			if !openOnly || o.isOpen() {
+				out = append(out, *o)
+			}
+		}
+	}
+	return out
+}
+
+// filterOrders applies the id, time and limit filters of an order listing
+func filterOrders(orders []order, orderID, startTime, endTime int64, limit int) []order {
+	var out []order
+	for _, o := range orders {
+		if orderID > 0 && o.id < orderID {
+			continue
+		}
+		if startTime > 0 && o.time < startTime {
+			continue
+		}
+		if endTime > 0 && o.time > endTime {
+			continue
+		}
+		out = append(out, o)
+	}
+	if limit > 0 && len(out) > limit {
+		out = out[len(out)-limit:]
+	}
+	return out
+}
+
+// recentTrades returns copies of the latest fills of a symbol
+func (e *engine) recentTrades(symbol string, limit int) []trade {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	trades := e.trades[symbol]
+	if limit > 0 && len(trades) > limit {
+		trades = trades[len(trades)-limit:]
+	}
+	out := make([]trade, 0, len(trades))
+	for _, t := range trades {
+		out = append(out, *t)
+	}
+	return out
+}
+
+// lastPrice returns the last fill price, falling back to the mid price
+func (e *engine) lastPrice(symbol string) float64 {
+	if trades := e.trades[symbol]; len(trades) > 0 {
+		return trades[len(trades)-1].price
+	}
+	bid, ask := e.bestBidAsk(symbol)
+	switch {
+	case bid.price > 0 && ask.price > 0:
+		return (bid.price + ask.price) / 2
+	case bid.price > 0:
+		return bid.price
+	}
+	return ask.price
+}
+
+// sortedKeys returns the keys of a map in order, so that listings do not
+// depend on map order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// symbols returns the symbols that have a book, sorted
+func (e *engine) symbols() []string {
+	var symbols []string
+	for s := range e.books {
+		symbols = append(symbols, s)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
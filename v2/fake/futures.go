@@ -0,0 +1,649 @@
+package fake
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"github.com/drinkthere/go-aster/v2/common"
+	"github.com/drinkthere/go-aster/v2/futures"
+)
+
+// FuturesClient is an in-memory implementation of futures.MarketDataAPI,
+// futures.TradingAPI and futures.AccountAPI. Orders match against the book
+// set with SetDepth, and fills update positions.
+type FuturesClient struct {
+	recorder
+	e *engine
+
+	mu           sync.Mutex
+	serverTime   int64
+	exchangeInfo *futures.ExchangeInfo
+	klines       map[string][]*futures.Kline
+	aggTrades    map[string][]*futures.AggTrade
+	markPrices   map[string]*futures.MarkPrice
+	fundingRates map[string][]*futures.FundingRate
+	stats        map[string]*futures.PriceChangeStats
+	balances     []futures.Balance
+	positions    map[string]*futures.PositionRisk
+	leverage     map[string]int
+	marginTypes  map[string]futures.MarginType
+	commission   map[string]*futures.CommissionRate
+}
+
+var (
+	_ futures.MarketDataAPI = (*FuturesClient)(nil)
+	_ futures.TradingAPI    = (*FuturesClient)(nil)
+	_ futures.AccountAPI    = (*FuturesClient)(nil)
+)
+
+// NewFuturesClient creates an empty fake futures client
+func NewFuturesClient() *FuturesClient {
+	f := &FuturesClient{
+		e:            newEngine(futuresTriggers),
+		exchangeInfo: &futures.ExchangeInfo{},
+		klines:       map[string][]*futures.Kline{},
+		aggTrades:    map[string][]*futures.AggTrade{},
+		markPrices:   map[string]*futures.MarkPrice{},
+		fundingRates: map[string][]*futures.FundingRate{},
+		stats:        map[string]*futures.PriceChangeStats{},
+		positions:    map[string]*futures.PositionRisk{},
+		leverage:     map[string]int{},
+		marginTypes:  map[string]futures.MarginType{},
+		commission:   map[string]*futures.CommissionRate{},
+	}
+	f.e.onFill = f.applyFill
+	f.e.markPrice = f.markPrice
+	return f
+}
+
+// SetServerTime fixes the time returned by ServerTime. 0 means the local clock.
+func (f *FuturesClient) SetServerTime(ms int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.serverTime = ms
+}
+
+// SetExchangeInfo sets the response of ExchangeInfo
+func (f *FuturesClient) SetExchangeInfo(info *futures.ExchangeInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.exchangeInfo = info
+}
+
+// SetDepth replaces the book of a symbol. Resting orders crossed by the new
+// prices fill at their limit price.
+func (f *FuturesClient) SetDepth(symbol string, bids, asks [][]string) {
+	f.e.setBook(symbol, bids, asks)
+}
+
+// SetKlines sets the klines served for a symbol and interval
+func (f *FuturesClient) SetKlines(symbol string, interval common.Interval, klines []*futures.Kline) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.klines[symbol+"|"+string(interval)] = klines
+}
+
+// SetAggTrades sets the aggregate trades served for a symbol
+func (f *FuturesClient) SetAggTrades(symbol string, trades []*futures.AggTrade) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.aggTrades[symbol] = trades
+}
+
+// SetMarkPrice sets the mark price of a symbol. Conditional orders with
+// the MARK_PRICE working type trigger on it.
+func (f *FuturesClient) SetMarkPrice(markPrice *futures.MarkPrice) {
+	f.mu.Lock()
+	mp := *markPrice
+	f.markPrices[markPrice.Symbol] = &mp
+	f.mu.Unlock()
+
+	f.e.mu.Lock()
+	defer f.e.mu.Unlock()
+	f.e.trigger(markPrice.Symbol)
+}
+
+// markPrice returns the mark price of a symbol, 0 when it is not set
+func (f *FuturesClient) markPrice(symbol string) float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if mp, ok := f.markPrices[symbol]; ok {
+		price, _ := parseFloat(mp.MarkPrice)
+		return price
+	}
+	return 0
+}
+
+// SetFundingRates sets the funding rate history of a symbol
+func (f *FuturesClient) SetFundingRates(symbol string, rates []*futures.FundingRate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fundingRates[symbol] = rates
+}
+
+// SetPriceChangeStats sets the 24hr statistics of a symbol
+func (f *FuturesClient) SetPriceChangeStats(stats *futures.PriceChangeStats) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st := *stats
+	f.stats[stats.Symbol] = &st
+}
+
+// SetBalances sets the account balances
+func (f *FuturesClient) SetBalances(balances []futures.Balance) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.balances = balances
+}
+
+// SetPosition sets a position. Fills keep updating it afterwards.
+func (f *FuturesClient) SetPosition(position futures.PositionRisk) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if position.PositionSide == "" {
+		position.PositionSide = futures.PositionSideTypeBoth
+	}
+	f.positions[position.Symbol+"|"+string(position.PositionSide)] = &position
+}
+
+// SetCommissionRate sets the commission rate of a symbol
+func (f *FuturesClient) SetCommissionRate(rate *futures.CommissionRate) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commission[rate.Symbol] = rate
+}
+
+// applyFill updates the position of a filled order
+func (f *FuturesClient) applyFill(o *order, t *trade) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	side := o.positionSide
+	if side == "" {
+		side = string(futures.PositionSideTypeBoth)
+	}
+	key := o.symbol + "|" + side
+	p, ok := f.positions[key]
+	if !ok {
+		p = &futures.PositionRisk{
+			Symbol:       o.symbol,
+			PositionSide: futures.PositionSideType(side),
+			PositionAmt:  "0",
+			EntryPrice:   "0",
+			MarginType:   futures.MarginTypeCross,
+		}
+		f.positions[key] = p
+	}
+	amt, _ := parseFloat(p.PositionAmt)
+	entry, _ := parseFloat(p.EntryPrice)
+	delta := t.qty
+	if t.side == common.SideTypeSell {
+		delta = -delta
+	}
+	newAmt := amt + delta
+	switch {
+	case newAmt == 0:
+		entry = 0
+	case amt == 0 || (amt > 0) != (newAmt > 0):
+		entry = t.price
+	case math.Abs(newAmt) > math.Abs(amt):
+		entry = (entry*math.Abs(amt) + t.price*math.Abs(delta)) / math.Abs(newAmt)
+	}
+	p.PositionAmt = formatFloat(newAmt)
+	p.EntryPrice = formatFloat(entry)
+	p.MarkPrice = formatFloat(t.price)
+	p.UnRealizedProfit = "0"
+	p.UpdateTime = t.time
+	if lev, ok := f.leverage[o.symbol]; ok {
+		p.Leverage = formatFloat(float64(lev))
+	}
+}
+
+// toFuturesOrder converts an engine order to the REST type
+func toFuturesOrder(o order) *futures.Order {
+	return &futures.Order{
+		AvgPrice:      formatFloat(o.avgPrice()),
+		ClientOrderID: o.clientOrderID,
+		CumQuote:      formatFloat(o.cumQuote),
+		ExecutedQty:   formatFloat(o.executedQty),
+		OrderID:       o.id,
+		OrigQty:       formatFloat(o.origQty),
+		OrigType:      o.orderType,
+		Price:         formatFloat(o.price),
+		ReduceOnly:    o.reduceOnly,
+		Side:          o.side,
+		PositionSide:  futures.PositionSideType(o.positionSide),
+		Status:        o.status,
+		StopPrice:     formatFloat(o.stopPrice),
+		Symbol:        o.symbol,
+		Time:          o.time,
+		TimeInForce:   o.timeInForce,
+		Type:          o.orderType,
+		UpdateTime:    o.updateTime,
+		WorkingType:   futures.WorkingType(o.workingType),
+	}
+}
+
+// Ping tests connectivity
+func (f *FuturesClient) Ping(ctx context.Context) error {
+	return f.record("Ping")
+}
+
+// ServerTime returns the configured server time
+func (f *FuturesClient) ServerTime(ctx context.Context) (int64, error) {
+	if err := f.record("ServerTime"); err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.serverTime > 0 {
+		return f.serverTime, nil
+	}
+	return f.e.nowMs(), nil
+}
+
+// ExchangeInfo returns the configured exchange info
+func (f *FuturesClient) ExchangeInfo(ctx context.Context) (*futures.ExchangeInfo, error) {
+	if err := f.record("ExchangeInfo"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.exchangeInfo, nil
+}
+
+// Depth returns the book of a symbol
+func (f *FuturesClient) Depth(ctx context.Context, symbol string, limit int) (*futures.DepthResponse, error) {
+	if err := f.record("Depth", symbol, limit); err != nil {
+		return nil, err
+	}
+	f.e.mu.Lock()
+	defer f.e.mu.Unlock()
+	b := f.e.getBook(symbol)
+	now := f.e.nowMs()
+	return &futures.DepthResponse{
+		LastUpdateID:    b.lastUpdateID,
+		EventTime:       now,
+		TransactionTime: now,
+		Bids:            formatLevels(b.bids, limit),
+		Asks:            formatLevels(b.asks, limit),
+	}, nil
+}
+
+// RecentTrades returns the fills of fake orders
+func (f *FuturesClient) RecentTrades(ctx context.Context, symbol string, limit int) ([]*futures.Trade, error) {
+	if err := f.record("RecentTrades", symbol, limit); err != nil {
+		return nil, err
+	}
+	trades := f.e.recentTrades(symbol, limit)
+	res := make([]*futures.Trade, 0, len(trades))
+	for _, t := range trades {
+		res = append(res, &futures.Trade{
+			ID:           t.id,
+			Price:        formatFloat(t.price),
+			Quantity:     formatFloat(t.qty),
+			QuoteQty:     formatFloat(t.price * t.qty),
+			Time:         t.time,
+			IsBuyerMaker: (t.side == common.SideTypeBuy) == t.isMaker,
+		})
+	}
+	return res, nil
+}
+
+// AggTrades returns the configured aggregate trades matching params
+func (f *FuturesClient) AggTrades(ctx context.Context, params futures.AggTradesParams) ([]*futures.AggTrade, error) {
+	if err := f.record("AggTrades", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	limit := params.Limit
+	if limit <= 0 {
+		limit = 500
+	}
+	res := make([]*futures.AggTrade, 0)
+	for _, t := range f.aggTrades[params.Symbol] {
+		if params.FromID > 0 && t.AggTradeID < params.FromID {
+			continue
+		}
+		if params.StartTime > 0 && t.Time < params.StartTime {
+			continue
+		}
+		if params.EndTime > 0 && t.Time > params.EndTime {
+			continue
+		}
+		res = append(res, t)
+		if len(res) == limit {
+			break
+		}
+	}
+	return res, nil
+}
+
+// Klines returns the configured klines matching params
+func (f *FuturesClient) Klines(ctx context.Context, params futures.KlinesParams) ([]*futures.Kline, error) {
+	if err := f.record("Klines", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	limit := params.Limit
+	if limit <= 0 {
+		limit = 500
+	}
+	res := make([]*futures.Kline, 0)
+	for _, k := range f.klines[params.Symbol+"|"+string(params.Interval)] {
+		if params.StartTime > 0 && k.OpenTime < params.StartTime {
+			continue
+		}
+		if params.EndTime > 0 && k.OpenTime > params.EndTime {
+			continue
+		}
+		res = append(res, k)
+		if len(res) == limit {
+			break
+		}
+	}
+	return res, nil
+}
+
+// MarkPrices returns copies of the configured mark prices, sorted by symbol
+func (f *FuturesClient) MarkPrices(ctx context.Context, symbol string) ([]*futures.MarkPrice, error) {
+	if err := f.record("MarkPrices", symbol); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	res := make([]*futures.MarkPrice, 0)
+	for _, s := range sortedKeys(f.markPrices) {
+		if symbol == "" || s == symbol {
+			mp := *f.markPrices[s]
+			res = append(res, &mp)
+		}
+	}
+	return res, nil
+}
+
+// FundingRates returns the configured funding rates matching params
+func (f *FuturesClient) FundingRates(ctx context.Context, params futures.FundingRateParams) ([]*futures.FundingRate, error) {
+	if err := f.record("FundingRates", params); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	limit := params.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	res := make([]*futures.FundingRate, 0)
+	for _, r := range f.fundingRates[params.Symbol] {
+		if params.StartTime > 0 && r.FundingTime < params.StartTime {
+			continue
+		}
+		if params.EndTime > 0 && r.FundingTime > params.EndTime {
+			continue
+		}
+		res = append(res, r)
+		if len(res) == limit {
+			break
+		}
+	}
+	return res, nil
+}
+
+// PriceChangeStats returns copies of the configured 24hr statistics,
+// sorted by symbol
+func (f *FuturesClient) PriceChangeStats(ctx context.Context, symbol string) ([]*futures.PriceChangeStats, error) {
+	if err := f.record("PriceChangeStats", symbol); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	res := make([]*futures.PriceChangeStats, 0)
+	for _, s := range sortedKeys(f.stats) {
+		if symbol == "" || s == symbol {
+			st := *f.stats[s]
+			res = append(res, &st)
+		}
+	}
+	return res, nil
+}
+
+// Prices returns the last fill price, or the mid price, of each book
+func (f *FuturesClient) Prices(ctx context.Context, symbol string) ([]*futures.SymbolPrice, error) {
+	if err := f.record("Prices", symbol); err != nil {
+		return nil, err
+	}
+	f.e.mu.Lock()
+	defer f.e.mu.Unlock()
+	res := make([]*futures.SymbolPrice, 0)
+	for _, s := range f.e.symbols() {
+		if symbol == "" || s == symbol {
+			res = append(res, &futures.SymbolPrice{Symbol: s, Price: formatFloat(f.e.lastPrice(s)), Time: f.e.nowMs()})
+		}
+	}
+	return res, nil
+}
+
+// BookTickers returns the top of each book
+func (f *FuturesClient) BookTickers(ctx context.Context, symbol string) ([]*futures.BookTicker, error) {
+	if err := f.record("BookTickers", symbol); err != nil {
+		return nil, err
+	}
+	f.e.mu.Lock()
+	defer f.e.mu.Unlock()
+	res := make([]*futures.BookTicker, 0)
+	for _, s := range f.e.symbols() {
+		if symbol != "" && s != symbol {
+			continue
+		}
+		bid, ask := f.e.bestBidAsk(s)
+		res = append(res, &futures.BookTicker{
+			Symbol:   s,
+			BidPrice: formatFloat(bid.price),
+			BidQty:   formatFloat(bid.qty),
+			AskPrice: formatFloat(ask.price),
+			AskQty:   formatFloat(ask.qty),
+			Time:     f.e.nowMs(),
+		})
+	}
+	return res, nil
+}
+
+// CreateOrder places an order against the fake book
+func (f *FuturesClient) CreateOrder(ctx context.Context, params futures.CreateOrderParams) (*futures.Order, error) {
+	if err := f.record("CreateOrder", params); err != nil {
+		return nil, err
+	}
+	qty, err := parseFloat(params.Quantity)
+	if err != nil {
+		return nil, newAPIError(codeInvalidQty, err.Error())
+	}
+	price, err := parseFloat(params.Price)
+	if err != nil {
+		return nil, newAPIError(codeInvalidPrice, err.Error())
+	}
+	stopPrice, err := parseFloat(params.StopPrice)
+	if err != nil {
+		return nil, newAPIError(codeInvalidPrice, err.Error())
+	}
+	o := &order{
+		clientOrderID: params.NewClientOrderID,
+		symbol:        params.Symbol,
+		side:          params.Side,
+		orderType:     params.Type,
+		timeInForce:   params.TimeInForce,
+		price:         price,
+		stopPrice:     stopPrice,
+		origQty:       qty,
+		reduceOnly:    params.ReduceOnly,
+		positionSide:  string(params.PositionSide),
+		workingType:   string(params.WorkingType),
+	}
+	if o.workingType == "" {
+		o.workingType = string(futures.WorkingTypeContractPrice)
+	}
+	if o.positionSide == "" {
+		o.positionSide = string(futures.PositionSideTypeBoth)
+	}
+	if err = f.e.place(o); err != nil {
+		return nil, err
+	}
+	res, _ := f.e.get(o.symbol, o.id, "")
+	return toFuturesOrder(res), nil
+}
+
+// GetOrder returns an order
+func (f *FuturesClient) GetOrder(ctx context.Context, query futures.OrderQuery) (*futures.Order, error) {
+	if err := f.record("GetOrder", query); err != nil {
+		return nil, err
+	}
+	o, err := f.e.get(query.Symbol, query.OrderID, query.OrigClientOrderID)
+	if err != nil {
+		return nil, err
+	}
+	return toFuturesOrder(o), nil
+}
+
+// CancelOrder cancels an open order
+func (f *FuturesClient) CancelOrder(ctx context.Context, query futures.OrderQuery) (*futures.Order, error) {
+	if err := f.record("CancelOrder", query); err != nil {
+		return nil, err
+	}
+	o, err := f.e.cancel(query.Symbol, query.OrderID, query.OrigClientOrderID)
+	if err != nil {
+		return nil, err
+	}
+	return toFuturesOrder(o), nil
+}
+
+// CancelAllOpenOrders cancels all open orders of a symbol
+func (f *FuturesClient) CancelAllOpenOrders(ctx context.Context, symbol string) error {
+	if err := f.record("CancelAllOpenOrders", symbol); err != nil {
+		return err
+	}
+	f.e.cancelAll(symbol)
+	return nil
+}
+
+// OpenOrders returns open orders
+func (f *FuturesClient) OpenOrders(ctx context.Context, symbol string) ([]*futures.Order, error) {
+	if err := f.record("OpenOrders", symbol); err != nil {
+		return nil, err
+	}
+	res := make([]*futures.Order, 0)
+	for _, o := range f.e.list(symbol, true) {
+		res = append(res, toFuturesOrder(o))
+	}
+	return res, nil
+}
+
+// AllOrders returns all orders of a symbol matching params
+func (f *FuturesClient) AllOrders(ctx context.Context, params futures.ListOrdersParams) ([]*futures.Order, error) {
+	if err := f.record("AllOrders", params); err != nil {
+		return nil, err
+	}
+	orders := filterOrders(f.e.list(params.Symbol, false), params.OrderID, params.StartTime, params.EndTime, params.Limit)
+	res := make([]*futures.Order, 0, len(orders))
+	for _, o := range orders {
+		res = append(res, toFuturesOrder(o))
+	}
+	return res, nil
+}
+
+// Account returns the configured balances and the tracked positions
+func (f *FuturesClient) Account(ctx context.Context) (*futures.Account, error) {
+	if err := f.record("Account"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &futures.Account{
+		Assets:      append([]futures.Balance(nil), f.balances...),
+		Positions:   f.positionList(""),
+		CanTrade:    true,
+		CanWithdraw: true,
+		CanDeposit:  true,
+		UpdateTime:  f.e.now().UnixMilli(),
+	}, nil
+}
+
+// Balances returns the configured balances
+func (f *FuturesClient) Balances(ctx context.Context) ([]futures.Balance, error) {
+	if err := f.record("Balances"); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]futures.Balance{}, f.balances...), nil
+}
+
+// PositionRisk returns the tracked positions
+func (f *FuturesClient) PositionRisk(ctx context.Context, symbol string) ([]futures.PositionRisk, error) {
+	if err := f.record("PositionRisk", symbol); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.positionList(symbol), nil
+}
+
+// positionList returns copies of the positions of a symbol ("" for all),
+// sorted by symbol and position side
+func (f *FuturesClient) positionList(symbol string) []futures.PositionRisk {
+	res := make([]futures.PositionRisk, 0, len(f.positions))
+	for _, key := range sortedKeys(f.positions) {
+		if p := f.positions[key]; symbol == "" || p.Symbol == symbol {
+			res = append(res, *p)
+		}
+	}
+	return res
+}
+
+// ChangeLeverage records the leverage of a symbol
+func (f *FuturesClient) ChangeLeverage(ctx context.Context, symbol string, leverage int) (*futures.SymbolLeverage, error) {
+	if err := f.record("ChangeLeverage", symbol, leverage); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.leverage[symbol] = leverage
+	for _, p := range f.positions {
+		if p.Symbol == symbol {
+			p.Leverage = formatFloat(float64(leverage))
+		}
+	}
+	return &futures.SymbolLeverage{Symbol: symbol, Leverage: leverage}, nil
+}
+
+// ChangeMarginType records the margin type of a symbol
+func (f *FuturesClient) ChangeMarginType(ctx context.Context, symbol string, marginType futures.MarginType) error {
+	if err := f.record("ChangeMarginType", symbol, marginType); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.marginTypes[symbol] == marginType {
+		return newAPIError(codeNoNeedToChangeMargin, "No need to change margin type.")
+	}
+	f.marginTypes[symbol] = marginType
+	for _, p := range f.positions {
+		if p.Symbol == symbol {
+			p.MarginType = marginType
+		}
+	}
+	return nil
+}
+
+// CommissionRate returns the configured commission rate of a symbol
+func (f *FuturesClient) CommissionRate(ctx context.Context, symbol string) (*futures.CommissionRate, error) {
+	if err := f.record("CommissionRate", symbol); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if rate, ok := f.commission[symbol]; ok {
+		return rate, nil
+	}
+	return &futures.CommissionRate{Symbol: symbol, MakerCommissionRate: "0", TakerCommissionRate: "0"}, nil
+}
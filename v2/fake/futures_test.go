@@ -0,0 +1,300 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/drinkthere/go-aster/v2/common"
+	"github.com/drinkthere/go-aster/v2/futures"
+)
+
+var ctx = context.Background()
+
+// newTestFutures creates a fake with a BTCUSDT book of 100 bid and 101 ask
+func newTestFutures() *FuturesClient {
+	f := NewFuturesClient()
+	f.SetDepth("BTCUSDT", [][]string{{"100", "1"}, {"99", "2"}}, [][]string{{"101", "1"}, {"102", "2"}})
+	return f
+}
+
+// checkAPIError checks that err is an API error with code
+func checkAPIError(t *testing.T, err error, code int) {
+	t.Helper()
+	var apiErr *common.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != code {
+		t.Errorf("got %v, want API error %d", err, code)
+	}
+}
+
+func TestFuturesPlaceAndMatch(t *testing.T) {
+	f := newTestFutures()
+
+	// a market buy takes both ask levels
+	o, err := f.CreateOrder(ctx, futures.CreateOrderParams{
+		Symbol: "BTCUSDT", Side: common.SideTypeBuy, Type: common.OrderTypeMarket, Quantity: "2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != common.OrderStatusTypeFilled || o.AvgPrice != "101.5" {
+		t.Errorf("market order %+v, want filled at 101.5", o)
+	}
+	positions, _ := f.PositionRisk(ctx, "BTCUSDT")
+	if len(positions) != 1 || positions[0].PositionAmt != "2" || positions[0].EntryPrice != "101.5" {
+		t.Errorf("positions %+v, want 2 at 101.5", positions)
+	}
+
+	// a limit sell below the bid partially fills and rests
+	o, err = f.CreateOrder(ctx, futures.CreateOrderParams{
+		Symbol: "BTCUSDT", Side: common.SideTypeSell, Type: common.OrderTypeLimit, Quantity: "2", Price: "100",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Status != common.OrderStatusTypePartiallyFilled || o.ExecutedQty != "1" || o.TimeInForce != common.TimeInForceTypeGTC {
+		t.Errorf("limit order %+v, want partially filled", o)
+	}
+
+	// the resting order fills as maker when the book moves through it
+	f.SetDepth("BTCUSDT", [][]string{{"100.5", "3"}}, [][]string{{"101", "1"}})
+	o, err = f.GetOrder(ctx, futures.OrderQuery{Symbol: "BTCUSDT", OrderID: o.OrderID})
+	if err != nil || o.Status != common.OrderStatusTypeFilled || o.AvgPrice != "100" {
+		t.Errorf("resting order %+v, %v, want filled at 100", o, err)
+	}
+}
+
+func TestFuturesTimeInForce(t *testing.T) {
+	for _, tt := range []struct {
+		tif    common.TimeInForceType
+		qty    string
+		status common.OrderStatusType
+		filled string
+	}{
+		{common.TimeInForceTypeIOC, "2", common.OrderStatusTypeExpired, "1"},
+		{common.TimeInForceTypeFOK, "2", common.OrderStatusTypeExpired, "0"},
+		{common.TimeInForceTypeFOK, "1", common.OrderStatusTypeFilled, "1"},
+		{common.TimeInForceTypeGTX, "1", common.OrderStatusTypeExpired, "0"},
+	} {
+		f := newTestFutures()
+		o, err := f.CreateOrder(ctx, futures.CreateOrderParams{
+			Symbol: "BTCUSDT", Side: common.SideTypeBuy, Type: common.OrderTypeLimit,
+			TimeInForce: tt.tif, Quantity: tt.qty, Price: "101",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.Status != tt.status || o.ExecutedQty != tt.filled {
+			t.Errorf("%s order of %s: got %s with %s filled, want %s with %s", tt.tif, tt.qty, o.Status, o.ExecutedQty, tt.status, tt.filled)
+		}
+	}
+}
+
+func TestFuturesRejectsOrders(t *testing.T) {
+	f := newTestFutures()
+	for _, tt := range []struct {
+		params futures.CreateOrderParams
+		code   int
+	}{
+		{futures.CreateOrderParams{Symbol: "BTCUSDT", Side: common.SideTypeBuy, Quantity: "1"}, codeMandatoryParam},
+		{futures.CreateOrderParams{Symbol: "BTCUSDT", Side: common.SideTypeBuy, Type: common.OrderTypeMarket, Quantity: "0"}, codeInvalidQty},
+		{futures.CreateOrderParams{Symbol: "BTCUSDT", Side: common.SideTypeBuy, Type: common.OrderTypeLimit, Quantity: "1"}, codeInvalidPrice},
+		{futures.CreateOrderParams{Symbol: "BTCUSDT", Side: common.SideTypeBuy, Type: "STOP_MARKET", Quantity: "1"}, codeMandatoryParam},
+		{futures.CreateOrderParams{Symbol: "BTCUSDT", Side: common.SideTypeBuy, Type: "STOP", Quantity: "1", StopPrice: "105"}, codeInvalidPrice},
+		{futures.CreateOrderParams{Symbol: "BTCUSDT", Side: common.SideTypeSell, Type: "TRAILING_STOP_MARKET", Quantity: "1", CallbackRate: "1"}, codeInvalidOrderType},
+		// the last price, the mid price of 100.5, is above the stop already
+		{futures.CreateOrderParams{Symbol: "BTCUSDT", Side: common.SideTypeBuy, Type: "STOP_MARKET", Quantity: "1", StopPrice: "100"}, codeWouldTrigger},
+	} {
+		_, err := f.CreateOrder(ctx, tt.params)
+		checkAPIError(t, err, tt.code)
+	}
+
+	params := futures.CreateOrderParams{
+		Symbol: "BTCUSDT", Side: common.SideTypeBuy, Type: common.OrderTypeLimit,
+		Quantity: "1", Price: "90", NewClientOrderID: "dup",
+	}
+	if _, err := f.CreateOrder(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	_, err := f.CreateOrder(ctx, params)
+	checkAPIError(t, err, codeDuplicateClientID)
+
+	if orders, _ := f.AllOrders(ctx, futures.ListOrdersParams{Symbol: "BTCUSDT"}); len(orders) != 1 {
+		t.Errorf("got %d orders, want only the accepted one", len(orders))
+	}
+}
+
+func TestFuturesConditionalOrders(t *testing.T) {
+	f := newTestFutures()
+	stop, err := f.CreateOrder(ctx, futures.CreateOrderParams{
+		Symbol: "BTCUSDT", Side: common.SideTypeSell, Type: "STOP_MARKET", Quantity: "1", StopPrice: "95",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	takeProfit, err := f.CreateOrder(ctx, futures.CreateOrderParams{
+		Symbol: "BTCUSDT", Side: common.SideTypeSell, Type: "TAKE_PROFIT", Quantity: "1", Price: "109", StopPrice: "110",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	markStop, err := f.CreateOrder(ctx, futures.CreateOrderParams{
+		Symbol: "BTCUSDT", Side: common.SideTypeBuy, Type: "STOP_MARKET", Quantity: "1", StopPrice: "120",
+		WorkingType: futures.WorkingTypeMarkPrice,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := func(id int64) *futures.Order {
+		t.Helper()
+		o, err := f.GetOrder(ctx, futures.OrderQuery{Symbol: "BTCUSDT", OrderID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o
+	}
+	if o := status(stop.OrderID); o.Status != common.OrderStatusTypeNew || o.ExecutedQty != "0" {
+		t.Fatalf("stop order %+v filled before its trigger", o)
+	}
+
+	// the book falls through the stop, which sells at the bid
+	f.SetDepth("BTCUSDT", [][]string{{"94", "5"}}, [][]string{{"95", "5"}})
+	if o := status(stop.OrderID); o.Status != common.OrderStatusTypeFilled || o.AvgPrice != "94" || o.Type != "STOP_MARKET" {
+		t.Errorf("stop order %+v, want filled at 94", o)
+	}
+	if o := status(takeProfit.OrderID); o.Status != common.OrderStatusTypeNew {
+		t.Errorf("take profit %+v triggered on a fall", o)
+	}
+
+	// the book rises through the take profit, which then rests as a limit
+	// order until the bid reaches its price
+	f.SetDepth("BTCUSDT", [][]string{{"108", "5"}}, [][]string{{"112", "5"}})
+	if o := status(takeProfit.OrderID); o.Status != common.OrderStatusTypeNew || o.ExecutedQty != "0" {
+		t.Errorf("take profit %+v filled below its price", o)
+	}
+	f.SetDepth("BTCUSDT", [][]string{{"109.5", "5"}}, [][]string{{"112", "5"}})
+	if o := status(takeProfit.OrderID); o.Status != common.OrderStatusTypeFilled || o.AvgPrice != "109" {
+		t.Errorf("take profit %+v, want filled at 109", o)
+	}
+
+	// the mark price stop ignores the last price
+	if o := status(markStop.OrderID); o.Status != common.OrderStatusTypeNew {
+		t.Fatalf("mark price stop %+v triggered", o)
+	}
+	f.SetMarkPrice(&futures.MarkPrice{Symbol: "BTCUSDT", MarkPrice: "121"})
+	if o := status(markStop.OrderID); o.Status != common.OrderStatusTypeFilled || o.AvgPrice != "112" ||
+		o.WorkingType != futures.WorkingTypeMarkPrice {
+		t.Errorf("mark price stop %+v, want filled at 112", o)
+	}
+}
+
+func TestFuturesCancel(t *testing.T) {
+	f := newTestFutures()
+	var ids []int64
+	for _, price := range []string{"90", "91"} {
+		o, err := f.CreateOrder(ctx, futures.CreateOrderParams{
+			Symbol: "BTCUSDT", Side: common.SideTypeBuy, Type: common.OrderTypeLimit, Quantity: "1", Price: price,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, o.OrderID)
+	}
+	o, err := f.CancelOrder(ctx, futures.OrderQuery{Symbol: "BTCUSDT", OrderID: ids[0]})
+	if err != nil || o.Status != common.OrderStatusTypeCanceled {
+		t.Errorf("cancel %+v, %v", o, err)
+	}
+	_, err = f.CancelOrder(ctx, futures.OrderQuery{Symbol: "BTCUSDT", OrderID: ids[0]})
+	checkAPIError(t, err, codeUnknownOrder)
+	_, err = f.GetOrder(ctx, futures.OrderQuery{Symbol: "BTCUSDT", OrderID: 99})
+	checkAPIError(t, err, codeNoSuchOrder)
+
+	if err := f.CancelAllOpenOrders(ctx, "BTCUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	if open, _ := f.OpenOrders(ctx, "BTCUSDT"); len(open) != 0 {
+		t.Errorf("got open orders %+v after cancelling all", open)
+	}
+	// canceled orders do not fill
+	f.SetDepth("BTCUSDT", [][]string{{"100", "1"}}, [][]string{{"85", "5"}})
+	if positions, _ := f.PositionRisk(ctx, "BTCUSDT"); len(positions) != 0 {
+		t.Errorf("canceled orders filled: %+v", positions)
+	}
+}
+
+func TestFuturesMarginType(t *testing.T) {
+	f := newTestFutures()
+	if err := f.ChangeMarginType(ctx, "BTCUSDT", futures.MarginTypeIsolated); err != nil {
+		t.Fatal(err)
+	}
+	checkAPIError(t, f.ChangeMarginType(ctx, "BTCUSDT", futures.MarginTypeIsolated), codeNoNeedToChangeMargin)
+}
+
+func TestFuturesListingsAreSortedCopies(t *testing.T) {
+	f := newTestFutures()
+	symbols := []string{"SOLUSDT", "BTCUSDT", "ETHUSDT", "ADAUSDT"}
+	for _, s := range symbols {
+		f.SetMarkPrice(&futures.MarkPrice{Symbol: s, MarkPrice: "1"})
+		f.SetPriceChangeStats(&futures.PriceChangeStats{Symbol: s, LastPrice: "1"})
+		f.SetPosition(futures.PositionRisk{Symbol: s, PositionAmt: "1"})
+	}
+	want := []string{"ADAUSDT", "BTCUSDT", "ETHUSDT", "SOLUSDT"}
+	for i := 0; i < 5; i++ {
+		marks, _ := f.MarkPrices(ctx, "")
+		stats, _ := f.PriceChangeStats(ctx, "")
+		positions, _ := f.PositionRisk(ctx, "")
+		for j, s := range want {
+			if marks[j].Symbol != s || stats[j].Symbol != s || positions[j].Symbol != s {
+				t.Fatalf("listings are not sorted by symbol: %s, %s, %s at %d", marks[j].Symbol, stats[j].Symbol, positions[j].Symbol, j)
+			}
+		}
+	}
+
+	marks, _ := f.MarkPrices(ctx, "BTCUSDT")
+	marks[0].MarkPrice = "2"
+	stats, _ := f.PriceChangeStats(ctx, "BTCUSDT")
+	stats[0].LastPrice = "2"
+	marks, _ = f.MarkPrices(ctx, "BTCUSDT")
+	stats, _ = f.PriceChangeStats(ctx, "BTCUSDT")
+	if marks[0].MarkPrice != "1" || stats[0].LastPrice != "1" {
+		t.Errorf("callers changed the state of the fake")
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	f := newTestFutures()
+	injected := &common.APIError{Code: -1001, Message: "disconnected"}
+	f.InjectError("CancelOrder", injected, 2)
+	query := futures.OrderQuery{Symbol: "BTCUSDT", OrderID: 1}
+	for i := 0; i < 2; i++ {
+		if _, err := f.CancelOrder(ctx, query); err != injected {
+			t.Errorf("call %d returned %v, want the injected error", i, err)
+		}
+	}
+	// the injection is used up
+	_, err := f.CancelOrder(ctx, query)
+	checkAPIError(t, err, codeUnknownOrder)
+
+	f.InjectError("Balances", injected, 0)
+	for i := 0; i < 3; i++ {
+		if _, err := f.Balances(ctx); err != injected {
+			t.Errorf("Balances returned %v, want the injected error until cleared", err)
+		}
+	}
+	f.ClearErrors()
+	if _, err := f.Balances(ctx); err != nil {
+		t.Errorf("Balances returned %v after ClearErrors", err)
+	}
+
+	calls := f.CallsTo("CancelOrder")
+	if len(calls) != 3 || calls[0].Args[0] != query || calls[0].Time.IsZero() {
+		t.Errorf("got CancelOrder calls %+v", calls)
+	}
+	if all := f.Calls(); len(all) != 7 || all[3].Method != "Balances" {
+		t.Errorf("got calls %+v", all)
+	}
+	f.ResetCalls()
+	if all := f.Calls(); len(all) != 0 {
+		t.Errorf("got calls %+v after ResetCalls", all)
+	}
+}
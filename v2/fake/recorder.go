@@ -0,0 +1,91 @@
+package fake
+
+import (
+	"sync"
+	"time"
+)
+
+// Call records one call made to a fake client. Method is the name of the
+// interface method, e.g. "CreateOrder", and Args are its arguments without
+// the context.
+type Call struct {
+	Method string
+	Args   []interface{}
+	Time   time.Time
+}
+
+// injectedError is an error returned by the next calls to a method
+type injectedError struct {
+	err   error
+	times int // <= 0 means until cleared
+}
+
+// recorder records calls and serves injected errors
+type recorder struct {
+	mu     sync.Mutex
+	calls  []Call
+	errors map[string]*injectedError
+}
+
+// record records a call and returns the error injected for the method, if any
+func (r *recorder) record(method string, args ...interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, Call{Method: method, Args: args, Time: time.Now()})
+	ie, ok := r.errors[method]
+	if !ok {
+		return nil
+	}
+	if ie.times > 0 {
+		ie.times--
+		if ie.times == 0 {
+			delete(r.errors, method)
+		}
+	}
+	return ie.err
+}
+
+// InjectError makes the next times calls to method return err. A times
+// value <= 0 keeps returning err until ClearErrors is called.
+func (r *recorder) InjectError(method string, err error, times int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.errors == nil {
+		r.errors = map[string]*injectedError{}
+	}
+	r.errors[method] = &injectedError{err: err, times: times}
+}
+
+// ClearErrors removes all injected errors
+func (r *recorder) ClearErrors() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = nil
+}
+
+// Calls returns all recorded calls in order
+func (r *recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// CallsTo returns the recorded calls to method in order
+func (r *recorder) CallsTo(method string) []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var calls []Call
+	for _, c := range r.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// ResetCalls forgets all recorded calls
+func (r *recorder) ResetCalls() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+}
@@ -0,0 +1,573 @@
+package fake
+
+import (
+	"context"
+	"sync"
+
+	aster "github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// SpotClient is an in-memory implementation of aster.SpotMarketDataAPI,
+// aster.SpotTradingAPI and aster.SpotAccountAPI. Orders match against the
+// book set with SetDepth. Fills move balances when the symbol is listed in
+// the exchange info set with SetExchangeInfo.
+type SpotClient struct {
+	recorder
+	e *engine
+
+	mu           sync.Mutex
+	serverTime   int64
+	exchangeInfo *aster.SpotExchangeInfo
+	klines       map[string][]*aster.SpotKline
+	aggTrades    map[string][]*aster.SpotAggTrade
+	stats        map[string]*aster.SpotPriceChangeStats
+	balances     map[string]*aster.SpotBalance
+	assets       []string // balance order
+	trades       map[string][]*aster.SpotTrade
+}
+
+var (
+	_ aster.SpotMarketDataAPI = (*SpotClient)(nil)
+	_ aster.SpotTradingAPI    = (*SpotClient)(nil)
+	_ aster.SpotAccountAPI    = (*SpotClient)(nil)
+)
+
+// NewSpotClient creates an empty fake spot client
+func NewSpotClient() *SpotClient {
+	s := &SpotClient{
+		e:            newEngine(spotTriggers),
+		exchangeInfo: &aster.SpotExchangeInfo{},
+		klines:       map[string][]*aster.SpotKline{},
+		aggTrades:    map[string][]*aster.SpotAggTrade{},
+		stats:        map[string]*aster.SpotPriceChangeStats{},
+		balances:     map[string]*aster.SpotBalance{},
+		trades:       map[string][]*aster.SpotTrade{},
+	}
+	s.e.onFill = s.applyFill
+	return s
+}
+
+// SetServerTime fixes the time returned by ServerTime. 0 means the local clock.
+func (s *SpotClient) SetServerTime(ms int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.serverTime = ms
+}
+
+// SetExchangeInfo sets the response of ExchangeInfo
+func (s *SpotClient) SetExchangeInfo(info *aster.SpotExchangeInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exchangeInfo = info
+}
+
+// SetDepth replaces the book of a symbol. Resting orders crossed by the new
+// prices fill at their limit price.
+func (s *SpotClient) SetDepth(symbol string, bids, asks [][]string) {
+	s.e.setBook(symbol, bids, asks)
+}
+
+// SetKlines sets the klines served for a symbol and interval
+func (s *SpotClient) SetKlines(symbol string, interval common.Interval, klines []*aster.SpotKline) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.klines[symbol+"|"+string(interval)] = klines
+}
+
+// SetAggTrades sets the aggregate trades served for a symbol
+func (s *SpotClient) SetAggTrades(symbol string, trades []*aster.SpotAggTrade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aggTrades[symbol] = trades
+}
+
+// SetPriceChangeStats sets the 24hr statistics of a symbol
+func (s *SpotClient) SetPriceChangeStats(stats *aster.SpotPriceChangeStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := *stats
+	s.stats[stats.Symbol] = &st
+}
+
+// SetBalance sets the free and locked amounts of an asset
+func (s *SpotClient) SetBalance(asset, free, locked string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.balance(asset)
+	b.Free = free
+	b.Locked = locked
+}
+
+// balance returns the balance of an asset, creating it if needed
+func (s *SpotClient) balance(asset string) *aster.SpotBalance {
+	b, ok := s.balances[asset]
+	if !ok {
+		b = &aster.SpotBalance{Asset: asset, Free: "0", Locked: "0"}
+		s.balances[asset] = b
+		s.assets = append(s.assets, asset)
+	}
+	return b
+}
+
+// applyFill records the account trade and moves balances
+func (s *SpotClient) applyFill(o *order, t *trade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trades[o.symbol] = append(s.trades[o.symbol], &aster.SpotTrade{
+		Symbol:          o.symbol,
+		Id:              t.id,
+		OrderId:         o.id,
+		OrderListId:     -1,
+		Price:           formatFloat(t.price),
+		Qty:             formatFloat(t.qty),
+		QuoteQty:        formatFloat(t.price * t.qty),
+		Commission:      "0",
+		CommissionAsset: "",
+		Time:            t.time,
+		IsBuyer:         t.side == common.SideTypeBuy,
+		IsMaker:         t.isMaker,
+		IsBestMatch:     true,
+	})
+	for _, sym := range s.exchangeInfo.Symbols {
+		if sym.Symbol != o.symbol {
+			continue
+		}
+		base, quote := t.qty, t.price*t.qty
+		if t.side == common.SideTypeSell {
+			base, quote = -base, -quote
+		}
+		s.adjust(sym.BaseAsset, base)
+		s.adjust(sym.QuoteAsset, -quote)
+	}
+}
+
+// adjust adds delta to the free amount of an asset
+func (s *SpotClient) adjust(asset string, delta float64) {
+	b := s.balance(asset)
+	free, _ := parseFloat(b.Free)
+	b.Free = formatFloat(free + delta)
+}
+
+// fills returns the fills of an order
+func (s *SpotClient) fills(symbol string, orderID int64) []aster.SpotFill {
+	s.e.mu.Lock()
+	defer s.e.mu.Unlock()
+	res := make([]aster.SpotFill, 0)
+	for _, t := range s.e.trades[symbol] {
+		if t.orderID == orderID {
+			res = append(res, aster.SpotFill{
+				Price:      formatFloat(t.price),
+				Qty:        formatFloat(t.qty),
+				Commission: "0",
+				TradeId:    t.id,
+			})
+		}
+	}
+	return res
+}
+
+// toSpotOrder converts an engine order to the REST type
+func toSpotOrder(o order) *aster.SpotOrder {
+	return &aster.SpotOrder{
+		Symbol:             o.symbol,
+		OrderID:            o.id,
+		OrderListID:        -1,
+		ClientOrderID:      o.clientOrderID,
+		Price:              formatFloat(o.price),
+		OrigQty:            formatFloat(o.origQty),
+		ExecutedQty:        formatFloat(o.executedQty),
+		CumulativeQuoteQty: formatFloat(o.cumQuote),
+		Status:             o.status,
+		TimeInForce:        o.timeInForce,
+		Type:               o.orderType,
+		Side:               o.side,
+		StopPrice:          formatFloat(o.stopPrice),
+		IcebergQty:         "0",
+		Time:               o.time,
+		UpdateTime:         o.updateTime,
+		IsWorking:          true,
+		OrigQuoteOrderQty:  "0",
+	}
+}
+
+// toCancelSpotOrderResponse converts a canceled engine order to the REST type
+func toCancelSpotOrderResponse(o order) *aster.CancelSpotOrderResponse {
+	return &aster.CancelSpotOrderResponse{
+		Symbol:             o.symbol,
+		OrigClientOrderID:  o.clientOrderID,
+		OrderID:            o.id,
+		OrderListID:        -1,
+		ClientOrderID:      o.clientOrderID,
+		Price:              formatFloat(o.price),
+		OrigQty:            formatFloat(o.origQty),
+		ExecutedQty:        formatFloat(o.executedQty),
+		CumulativeQuoteQty: formatFloat(o.cumQuote),
+		Status:             o.status,
+		TimeInForce:        o.timeInForce,
+		Type:               o.orderType,
+		Side:               o.side,
+	}
+}
+
+// Ping tests connectivity
+func (s *SpotClient) Ping(ctx context.Context) error {
+	return s.record("Ping")
+}
+
+// ServerTime returns the configured server time
+func (s *SpotClient) ServerTime(ctx context.Context) (int64, error) {
+	if err := s.record("ServerTime"); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.serverTime > 0 {
+		return s.serverTime, nil
+	}
+	return s.e.nowMs(), nil
+}
+
+// ExchangeInfo returns the configured exchange info
+func (s *SpotClient) ExchangeInfo(ctx context.Context) (*aster.SpotExchangeInfo, error) {
+	if err := s.record("ExchangeInfo"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exchangeInfo, nil
+}
+
+// Depth returns the book of a symbol
+func (s *SpotClient) Depth(ctx context.Context, symbol string, limit int) (*aster.SpotDepthResponse, error) {
+	if err := s.record("Depth", symbol, limit); err != nil {
+		return nil, err
+	}
+	s.e.mu.Lock()
+	defer s.e.mu.Unlock()
+	b := s.e.getBook(symbol)
+	return &aster.SpotDepthResponse{
+		LastUpdateID: b.lastUpdateID,
+		Bids:         formatLevels(b.bids, limit),
+		Asks:         formatLevels(b.asks, limit),
+	}, nil
+}
+
+// RecentTrades returns the fills of fake orders
+func (s *SpotClient) RecentTrades(ctx context.Context, symbol string, limit int) ([]*aster.SpotMarketTrade, error) {
+	if err := s.record("RecentTrades", symbol, limit); err != nil {
+		return nil, err
+	}
+	trades := s.e.recentTrades(symbol, limit)
+	res := make([]*aster.SpotMarketTrade, 0, len(trades))
+	for _, t := range trades {
+		res = append(res, &aster.SpotMarketTrade{
+			ID:           t.id,
+			Price:        formatFloat(t.price),
+			Quantity:     formatFloat(t.qty),
+			QuoteQty:     formatFloat(t.price * t.qty),
+			Time:         t.time,
+			IsBuyerMaker: (t.side == common.SideTypeBuy) == t.isMaker,
+			IsBestMatch:  true,
+		})
+	}
+	return res, nil
+}
+
+// AggTrades returns the configured aggregate trades matching params
+func (s *SpotClient) AggTrades(ctx context.Context, params aster.SpotAggTradesParams) ([]*aster.SpotAggTrade, error) {
+	if err := s.record("AggTrades", params); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	limit := params.Limit
+	if limit <= 0 {
+		limit = 500
+	}
+	res := make([]*aster.SpotAggTrade, 0)
+	for _, t := range s.aggTrades[params.Symbol] {
+		if params.FromID > 0 && t.TradeID < params.FromID {
+			continue
+		}
+		if params.StartTime > 0 && t.Time < params.StartTime {
+			continue
+		}
+		if params.EndTime > 0 && t.Time > params.EndTime {
+			continue
+		}
+		res = append(res, t)
+		if len(res) == limit {
+			break
+		}
+	}
+	return res, nil
+}
+
+// Klines returns the configured klines matching params
+func (s *SpotClient) Klines(ctx context.Context, params aster.SpotKlinesParams) ([]*aster.SpotKline, error) {
+	if err := s.record("Klines", params); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	limit := params.Limit
+	if limit <= 0 {
+		limit = 500
+	}
+	res := make([]*aster.SpotKline, 0)
+	for _, k := range s.klines[params.Symbol+"|"+string(params.Interval)] {
+		if params.StartTime > 0 && k.OpenTime < params.StartTime {
+			continue
+		}
+		if params.EndTime > 0 && k.OpenTime > params.EndTime {
+			continue
+		}
+		res = append(res, k)
+		if len(res) == limit {
+			break
+		}
+	}
+	return res, nil
+}
+
+// PriceChangeStats returns copies of the configured 24hr statistics,
+// sorted by symbol
+func (s *SpotClient) PriceChangeStats(ctx context.Context, symbol string) ([]*aster.SpotPriceChangeStats, error) {
+	if err := s.record("PriceChangeStats", symbol); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]*aster.SpotPriceChangeStats, 0)
+	for _, sym := range sortedKeys(s.stats) {
+		if symbol == "" || sym == symbol {
+			st := *s.stats[sym]
+			res = append(res, &st)
+		}
+	}
+	return res, nil
+}
+
+// Prices returns the last fill price, or the mid price, of each book
+func (s *SpotClient) Prices(ctx context.Context, symbol string) ([]*aster.SpotSymbolPrice, error) {
+	if err := s.record("Prices", symbol); err != nil {
+		return nil, err
+	}
+	s.e.mu.Lock()
+	defer s.e.mu.Unlock()
+	res := make([]*aster.SpotSymbolPrice, 0)
+	for _, sym := range s.e.symbols() {
+		if symbol == "" || sym == symbol {
+			res = append(res, &aster.SpotSymbolPrice{Symbol: sym, Price: formatFloat(s.e.lastPrice(sym))})
+		}
+	}
+	return res, nil
+}
+
+// BookTickers returns the top of each book
+func (s *SpotClient) BookTickers(ctx context.Context, symbol string) ([]*aster.SpotBookTicker, error) {
+	if err := s.record("BookTickers", symbol); err != nil {
+		return nil, err
+	}
+	s.e.mu.Lock()
+	defer s.e.mu.Unlock()
+	res := make([]*aster.SpotBookTicker, 0)
+	for _, sym := range s.e.symbols() {
+		if symbol != "" && sym != symbol {
+			continue
+		}
+		bid, ask := s.e.bestBidAsk(sym)
+		res = append(res, &aster.SpotBookTicker{
+			Symbol:   sym,
+			BidPrice: formatFloat(bid.price),
+			BidQty:   formatFloat(bid.qty),
+			AskPrice: formatFloat(ask.price),
+			AskQty:   formatFloat(ask.qty),
+		})
+	}
+	return res, nil
+}
+
+// CreateOrder places an order against the fake book. A market order sized
+// by QuoteOrderQty is converted at the best opposite price.
+func (s *SpotClient) CreateOrder(ctx context.Context, params aster.CreateSpotOrderParams) (*aster.CreateSpotOrderResponse, error) {
+	if err := s.record("CreateOrder", params); err != nil {
+		return nil, err
+	}
+	qty, err := parseFloat(params.Quantity)
+	if err != nil {
+		return nil, newAPIError(codeInvalidQty, err.Error())
+	}
+	if params.Quantity == "" && params.QuoteOrderQty != "" {
+		quoteQty, err := parseFloat(params.QuoteOrderQty)
+		if err != nil {
+			return nil, newAPIError(codeInvalidQty, err.Error())
+		}
+		s.e.mu.Lock()
+		bid, ask := s.e.bestBidAsk(params.Symbol)
+		s.e.mu.Unlock()
+		ref := ask.price
+		if params.Side == common.SideTypeSell {
+			ref = bid.price
+		}
+		if ref > 0 {
+			qty = quoteQty / ref
+		}
+	}
+	price, err := parseFloat(params.Price)
+	if err != nil {
+		return nil, newAPIError(codeInvalidPrice, err.Error())
+	}
+	stopPrice, err := parseFloat(params.StopPrice)
+	if err != nil {
+		return nil, newAPIError(codeInvalidPrice, err.Error())
+	}
+	o := &order{
+		clientOrderID: params.NewClientOrderID,
+		symbol:        params.Symbol,
+		side:          params.Side,
+		orderType:     params.Type,
+		timeInForce:   params.TimeInForce,
+		price:         price,
+		stopPrice:     stopPrice,
+		origQty:       qty,
+	}
+	if err = s.e.place(o); err != nil {
+		return nil, err
+	}
+	res, _ := s.e.get(o.symbol, o.id, "")
+	return &aster.CreateSpotOrderResponse{
+		Symbol:             res.symbol,
+		OrderID:            res.id,
+		OrderListID:        -1,
+		ClientOrderID:      res.clientOrderID,
+		TransactTime:       res.time,
+		Price:              formatFloat(res.price),
+		OrigQty:            formatFloat(res.origQty),
+		ExecutedQty:        formatFloat(res.executedQty),
+		CumulativeQuoteQty: formatFloat(res.cumQuote),
+		Status:             res.status,
+		TimeInForce:        res.timeInForce,
+		Type:               res.orderType,
+		Side:               res.side,
+		Fills:              s.fills(res.symbol, res.id),
+	}, nil
+}
+
+// GetOrder returns an order
+func (s *SpotClient) GetOrder(ctx context.Context, query aster.SpotOrderQuery) (*aster.SpotOrder, error) {
+	if err := s.record("GetOrder", query); err != nil {
+		return nil, err
+	}
+	o, err := s.e.get(query.Symbol, query.OrderID, query.OrigClientOrderID)
+	if err != nil {
+		return nil, err
+	}
+	return toSpotOrder(o), nil
+}
+
+// CancelOrder cancels an open order
+func (s *SpotClient) CancelOrder(ctx context.Context, query aster.SpotOrderQuery) (*aster.CancelSpotOrderResponse, error) {
+	if err := s.record("CancelOrder", query); err != nil {
+		return nil, err
+	}
+	o, err := s.e.cancel(query.Symbol, query.OrderID, query.OrigClientOrderID)
+	if err != nil {
+		return nil, err
+	}
+	return toCancelSpotOrderResponse(o), nil
+}
+
+// CancelOpenOrders cancels all open orders of a symbol
+func (s *SpotClient) CancelOpenOrders(ctx context.Context, symbol string) ([]*aster.CancelSpotOrderResponse, error) {
+	if err := s.record("CancelOpenOrders", symbol); err != nil {
+		return nil, err
+	}
+	canceled := s.e.cancelAll(symbol)
+	res := make([]*aster.CancelSpotOrderResponse, 0, len(canceled))
+	for _, o := range canceled {
+		res = append(res, toCancelSpotOrderResponse(o))
+	}
+	return res, nil
+}
+
+// OpenOrders returns open orders
+func (s *SpotClient) OpenOrders(ctx context.Context, symbol string) ([]*aster.SpotOrder, error) {
+	if err := s.record("OpenOrders", symbol); err != nil {
+		return nil, err
+	}
+	res := make([]*aster.SpotOrder, 0)
+	for _, o := range s.e.list(symbol, true) {
+		res = append(res, toSpotOrder(o))
+	}
+	return res, nil
+}
+
+// AllOrders returns all orders of a symbol matching params
+func (s *SpotClient) AllOrders(ctx context.Context, params aster.ListSpotOrdersParams) ([]*aster.SpotOrder, error) {
+	if err := s.record("AllOrders", params); err != nil {
+		return nil, err
+	}
+	orders := filterOrders(s.e.list(params.Symbol, false), params.OrderID, params.StartTime, params.EndTime, params.Limit)
+	res := make([]*aster.SpotOrder, 0, len(orders))
+	for _, o := range orders {
+		res = append(res, toSpotOrder(o))
+	}
+	return res, nil
+}
+
+// Account returns the tracked balances
+func (s *SpotClient) Account(ctx context.Context) (*aster.SpotAccount, error) {
+	if err := s.record("Account"); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balances := make([]aster.SpotBalance, 0, len(s.assets))
+	for _, asset := range s.assets {
+		balances = append(balances, *s.balances[asset])
+	}
+	return &aster.SpotAccount{
+		CanTrade:    true,
+		CanWithdraw: true,
+		CanDeposit:  true,
+		UpdateTime:  s.e.nowMs(),
+		AccountType: "SPOT",
+		Balances:    balances,
+		Permissions: []string{"SPOT"},
+	}, nil
+}
+
+// Trades returns the fills of fake orders matching params
+func (s *SpotClient) Trades(ctx context.Context, params aster.ListSpotTradesParams) ([]*aster.SpotTrade, error) {
+	if err := s.record("Trades", params); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	limit := params.Limit
+	if limit <= 0 {
+		limit = 500
+	}
+	res := make([]*aster.SpotTrade, 0)
+	for _, t := range s.trades[params.Symbol] {
+		if params.OrderID > 0 && t.OrderId != params.OrderID {
+			continue
+		}
+		if params.FromID > 0 && t.Id < params.FromID {
+			continue
+		}
+		if params.StartTime > 0 && t.Time < params.StartTime {
+			continue
+		}
+		if params.EndTime > 0 && t.Time > params.EndTime {
+			continue
+		}
+		res = append(res, t)
+		if len(res) == limit {
+			break
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,76 @@
+package fake
+
+import (
+	"testing"
+
+	aster "github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+func TestSpotConditionalOrders(t *testing.T) {
+	s := NewSpotClient()
+	s.SetDepth("BTCUSDT", [][]string{{"100", "1"}}, [][]string{{"101", "1"}})
+
+	// TAKE_PROFIT is a market order on spot, without a price
+	tp, err := s.CreateOrder(ctx, aster.CreateSpotOrderParams{
+		Symbol: "BTCUSDT", Side: common.SideTypeSell, Type: common.OrderTypeTakeProfit, Quantity: "1", StopPrice: "105",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.CreateOrder(ctx, aster.CreateSpotOrderParams{
+		Symbol: "BTCUSDT", Side: common.SideTypeSell, Type: common.OrderTypeStopLossLimit, Quantity: "1", StopPrice: "95",
+	})
+	checkAPIError(t, err, codeInvalidPrice)
+	sl, err := s.CreateOrder(ctx, aster.CreateSpotOrderParams{
+		Symbol: "BTCUSDT", Side: common.SideTypeSell, Type: common.OrderTypeStopLossLimit, Quantity: "1", Price: "94", StopPrice: "95",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tp.Status != common.OrderStatusTypeNew || sl.Status != common.OrderStatusTypeNew {
+		t.Fatalf("conditional orders %s and %s, want NEW", tp.Status, sl.Status)
+	}
+
+	status := func(id int64) *aster.SpotOrder {
+		t.Helper()
+		o, err := s.GetOrder(ctx, aster.SpotOrderQuery{Symbol: "BTCUSDT", OrderID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o
+	}
+	s.SetDepth("BTCUSDT", [][]string{{"106", "1"}}, [][]string{{"107", "1"}})
+	if o := status(tp.OrderID); o.Status != common.OrderStatusTypeFilled || o.CumulativeQuoteQty != "106" {
+		t.Errorf("take profit %+v, want filled at 106", o)
+	}
+	if o := status(sl.OrderID); o.Status != common.OrderStatusTypeNew {
+		t.Errorf("stop loss %+v triggered on a rise", o)
+	}
+	s.SetDepth("BTCUSDT", [][]string{{"94.5", "1"}}, [][]string{{"95", "1"}})
+	if o := status(sl.OrderID); o.Status != common.OrderStatusTypeFilled || o.CumulativeQuoteQty != "94.5" {
+		t.Errorf("stop loss %+v, want filled at 94.5", o)
+	}
+}
+
+func TestSpotLimitMaker(t *testing.T) {
+	s := NewSpotClient()
+	s.SetDepth("BTCUSDT", [][]string{{"100", "1"}}, [][]string{{"101", "1"}})
+	for _, tt := range []struct {
+		price  string
+		status common.OrderStatusType
+	}{
+		{"101", common.OrderStatusTypeExpired},
+		{"100.5", common.OrderStatusTypeNew},
+	} {
+		o, err := s.CreateOrder(ctx, aster.CreateSpotOrderParams{
+			Symbol: "BTCUSDT", Side: common.SideTypeBuy, Type: common.OrderTypeLimitMaker, Quantity: "1", Price: tt.price,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.Status != tt.status || o.ExecutedQty != "0" {
+			t.Errorf("LIMIT_MAKER at %s: got %s with %s filled, want %s", tt.price, o.Status, o.ExecutedQty, tt.status)
+		}
+	}
+}
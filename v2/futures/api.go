@@ -0,0 +1,362 @@
+package futures
+
+import (
+	"context"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// MarketDataAPI is the futures market data API. A symbol of "" means all
+// symbols and a limit of 0 means the server default.
+type MarketDataAPI interface {
+	Ping(ctx context.Context) error
+	ServerTime(ctx context.Context) (int64, error)
+	ExchangeInfo(ctx context.Context) (*ExchangeInfo, error)
+	Depth(ctx context.Context, symbol string, limit int) (*DepthResponse, error)
+	RecentTrades(ctx context.Context, symbol string, limit int) ([]*Trade, error)
+	AggTrades(ctx context.Context, params AggTradesParams) ([]*AggTrade, error)
+	Klines(ctx context.Context, params KlinesParams) ([]*Kline, error)
+	MarkPrices(ctx context.Context, symbol string) ([]*MarkPrice, error)
+	FundingRates(ctx context.Context, params FundingRateParams) ([]*FundingRate, error)
+	PriceChangeStats(ctx context.Context, symbol string) ([]*PriceChangeStats, error)
+	Prices(ctx context.Context, symbol string) ([]*SymbolPrice, error)
+	BookTickers(ctx context.Context, symbol string) ([]*BookTicker, error)
+}
+
+// TradingAPI is the futures trading API
+type TradingAPI interface {
+	CreateOrder(ctx context.Context, params CreateOrderParams) (*Order, error)
+	GetOrder(ctx context.Context, query OrderQuery) (*Order, error)
+	CancelOrder(ctx context.Context, query OrderQuery) (*Order, error)
+	CancelAllOpenOrders(ctx context.Context, symbol string) error
+	OpenOrders(ctx context.Context, symbol string) ([]*Order, error)
+	AllOrders(ctx context.Context, params ListOrdersParams) ([]*Order, error)
+}
+
+// AccountAPI is the futures account API
+type AccountAPI interface {
+	Account(ctx context.Context) (*Account, error)
+	Balances(ctx context.Context) ([]Balance, error)
+	PositionRisk(ctx context.Context, symbol string) ([]PositionRisk, error)
+	ChangeLeverage(ctx context.Context, symbol string, leverage int) (*SymbolLeverage, error)
+	ChangeMarginType(ctx context.Context, symbol string, marginType MarginType) error
+	CommissionRate(ctx context.Context, symbol string) (*CommissionRate, error)
+}
+
+var (
+	_ MarketDataAPI = (*Client)(nil)
+	_ TradingAPI    = (*Client)(nil)
+	_ AccountAPI    = (*Client)(nil)
+)
+
+// AggTradesParams are the parameters of AggTrades. Zero values are omitted.
+type AggTradesParams struct {
+	Symbol    string
+	FromID    int64
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
+
+// KlinesParams are the parameters of Klines. Zero values are omitted.
+type KlinesParams struct {
+	Symbol    string
+	Interval  common.Interval
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
+
+// FundingRateParams are the parameters of FundingRates. Zero values are omitted.
+type FundingRateParams struct {
+	Symbol    string
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
+
+// CreateOrderParams are the parameters of CreateOrder. Zero values are omitted.
+type CreateOrderParams struct {
+	Symbol           string
+	Side             common.SideType
+	PositionSide     PositionSideType
+	Type             common.OrderType
+	TimeInForce      common.TimeInForceType
+	Quantity         string
+	Price            string
+	ReduceOnly       bool
+	NewClientOrderID string
+	StopPrice        string
+	ClosePosition    bool
+	ActivationPrice  string
+	CallbackRate     string
+	WorkingType      WorkingType
+	PriceProtect     bool
+	NewOrderRespType common.NewOrderRespType
+}
+
+// OrderQuery identifies an order by OrderID or OrigClientOrderID
+type OrderQuery struct {
+	Symbol            string
+	OrderID           int64
+	OrigClientOrderID string
+}
+
+// ListOrdersParams are the parameters of AllOrders. Zero values are omitted.
+type ListOrdersParams struct {
+	Symbol    string
+	OrderID   int64
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
+
+// Ping tests connectivity
+func (c *Client) Ping(ctx context.Context) error {
+	return c.NewPingService().Do(ctx)
+}
+
+// ServerTime returns the server time
+func (c *Client) ServerTime(ctx context.Context) (int64, error) {
+	return c.NewServerTimeService().Do(ctx)
+}
+
+// ExchangeInfo returns the exchange info
+func (c *Client) ExchangeInfo(ctx context.Context) (*ExchangeInfo, error) {
+	return c.NewExchangeInfoService().Do(ctx)
+}
+
+// Depth returns the order book of a symbol
+func (c *Client) Depth(ctx context.Context, symbol string, limit int) (*DepthResponse, error) {
+	s := c.NewDepthService().Symbol(symbol)
+	if limit > 0 {
+		s.Limit(limit)
+	}
+	return s.Do(ctx)
+}
+
+// RecentTrades returns the recent trades of a symbol
+func (c *Client) RecentTrades(ctx context.Context, symbol string, limit int) ([]*Trade, error) {
+	s := c.NewRecentTradesListService().Symbol(symbol)
+	if limit > 0 {
+		s.Limit(limit)
+	}
+	return s.Do(ctx)
+}
+
+// AggTrades returns aggregate trades
+func (c *Client) AggTrades(ctx context.Context, params AggTradesParams) ([]*AggTrade, error) {
+	s := c.NewAggTradesService().Symbol(params.Symbol)
+	if params.FromID > 0 {
+		s.FromID(params.FromID)
+	}
+	if params.StartTime > 0 {
+		s.StartTime(params.StartTime)
+	}
+	if params.EndTime > 0 {
+		s.EndTime(params.EndTime)
+	}
+	if params.Limit > 0 {
+		s.Limit(params.Limit)
+	}
+	return s.Do(ctx)
+}
+
+// Klines returns klines
+func (c *Client) Klines(ctx context.Context, params KlinesParams) ([]*Kline, error) {
+	s := c.NewKlinesService().Symbol(params.Symbol).Interval(params.Interval)
+	if params.StartTime > 0 {
+		s.StartTime(params.StartTime)
+	}
+	if params.EndTime > 0 {
+		s.EndTime(params.EndTime)
+	}
+	if params.Limit > 0 {
+		s.Limit(params.Limit)
+	}
+	return s.Do(ctx)
+}
+
+// MarkPrices returns mark prices and funding rates
+func (c *Client) MarkPrices(ctx context.Context, symbol string) ([]*MarkPrice, error) {
+	s := c.NewMarkPriceService()
+	if symbol != "" {
+		s.Symbol(symbol)
+	}
+	return s.Do(ctx)
+}
+
+// FundingRates returns the funding rate history
+func (c *Client) FundingRates(ctx context.Context, params FundingRateParams) ([]*FundingRate, error) {
+	s := c.NewFundingRateService().Symbol(params.Symbol)
+	if params.StartTime > 0 {
+		s.StartTime(params.StartTime)
+	}
+	if params.EndTime > 0 {
+		s.EndTime(params.EndTime)
+	}
+	if params.Limit > 0 {
+		s.Limit(params.Limit)
+	}
+	return s.Do(ctx)
+}
+
+// PriceChangeStats returns 24hr price change statistics
+func (c *Client) PriceChangeStats(ctx context.Context, symbol string) ([]*PriceChangeStats, error) {
+	s := c.NewListPriceChangeStatsService()
+	if symbol != "" {
+		s.Symbol(symbol)
+	}
+	return s.Do(ctx)
+}
+
+// Prices returns the latest prices
+func (c *Client) Prices(ctx context.Context, symbol string) ([]*SymbolPrice, error) {
+	s := c.NewListPricesService()
+	if symbol != "" {
+		s.Symbol(symbol)
+	}
+	return s.Do(ctx)
+}
+
+// BookTickers returns the best price/qty on the order book
+func (c *Client) BookTickers(ctx context.Context, symbol string) ([]*BookTicker, error) {
+	s := c.NewListBookTickersService()
+	if symbol != "" {
+		s.Symbol(symbol)
+	}
+	return s.Do(ctx)
+}
+
+// CreateOrder places an order
+func (c *Client) CreateOrder(ctx context.Context, params CreateOrderParams) (*Order, error) {
+	s := c.NewCreateOrderService().Symbol(params.Symbol).Side(params.Side).
+		Type(params.Type).Quantity(params.Quantity)
+	if params.PositionSide != "" {
+		s.PositionSide(params.PositionSide)
+	}
+	if params.TimeInForce != "" {
+		s.TimeInForce(params.TimeInForce)
+	}
+	if params.Price != "" {
+		s.Price(params.Price)
+	}
+	if params.ReduceOnly {
+		s.ReduceOnly(true)
+	}
+	if params.NewClientOrderID != "" {
+		s.NewClientOrderID(params.NewClientOrderID)
+	}
+	if params.StopPrice != "" {
+		s.StopPrice(params.StopPrice)
+	}
+	if params.ClosePosition {
+		s.ClosePosition(true)
+	}
+	if params.ActivationPrice != "" {
+		s.ActivationPrice(params.ActivationPrice)
+	}
+	if params.CallbackRate != "" {
+		s.CallbackRate(params.CallbackRate)
+	}
+	if params.WorkingType != "" {
+		s.WorkingType(params.WorkingType)
+	}
+	if params.PriceProtect {
+		s.PriceProtect(true)
+	}
+	if params.NewOrderRespType != "" {
+		s.NewOrderRespType(params.NewOrderRespType)
+	}
+	return s.Do(ctx)
+}
+
+// GetOrder returns an order
+func (c *Client) GetOrder(ctx context.Context, query OrderQuery) (*Order, error) {
+	s := c.NewGetOrderService().Symbol(query.Symbol)
+	if query.OrderID > 0 {
+		s.OrderID(query.OrderID)
+	}
+	if query.OrigClientOrderID != "" {
+		s.OrigClientOrderID(query.OrigClientOrderID)
+	}
+	return s.Do(ctx)
+}
+
+// CancelOrder cancels an order
+func (c *Client) CancelOrder(ctx context.Context, query OrderQuery) (*Order, error) {
+	s := c.NewCancelOrderService().Symbol(query.Symbol)
+	if query.OrderID > 0 {
+		s.OrderID(query.OrderID)
+	}
+	if query.OrigClientOrderID != "" {
+		s.OrigClientOrderID(query.OrigClientOrderID)
+	}
+	return s.Do(ctx)
+}
+
+// CancelAllOpenOrders cancels all open orders of a symbol
+func (c *Client) CancelAllOpenOrders(ctx context.Context, symbol string) error {
+	return c.NewCancelAllOpenOrdersService().Symbol(symbol).Do(ctx)
+}
+
+// OpenOrders returns open orders
+func (c *Client) OpenOrders(ctx context.Context, symbol string) ([]*Order, error) {
+	s := c.NewListOpenOrdersService()
+	if symbol != "" {
+		s.Symbol(symbol)
+	}
+	return s.Do(ctx)
+}
+
+// AllOrders returns all orders of a symbol
+func (c *Client) AllOrders(ctx context.Context, params ListOrdersParams) ([]*Order, error) {
+	s := c.NewListOrdersService().Symbol(params.Symbol)
+	if params.OrderID > 0 {
+		s.OrderID(params.OrderID)
+	}
+	if params.StartTime > 0 {
+		s.StartTime(params.StartTime)
+	}
+	if params.EndTime > 0 {
+		s.EndTime(params.EndTime)
+	}
+	if params.Limit > 0 {
+		s.Limit(params.Limit)
+	}
+	return s.Do(ctx)
+}
+
+// Account returns the account info
+func (c *Client) Account(ctx context.Context) (*Account, error) {
+	return c.NewGetAccountService().Do(ctx)
+}
+
+// Balances returns the account balances
+func (c *Client) Balances(ctx context.Context) ([]Balance, error) {
+	return c.NewGetBalanceService().Do(ctx)
+}
+
+// PositionRisk returns position risk
+func (c *Client) PositionRisk(ctx context.Context, symbol string) ([]PositionRisk, error) {
+	s := c.NewGetPositionRiskService()
+	if symbol != "" {
+		s.Symbol(symbol)
+	}
+	return s.Do(ctx)
+}
+
+// ChangeLeverage changes the leverage of a symbol
+func (c *Client) ChangeLeverage(ctx context.Context, symbol string, leverage int) (*SymbolLeverage, error) {
+	return c.NewChangeLeverageService().Symbol(symbol).Leverage(leverage).Do(ctx)
+}
+
+// ChangeMarginType changes the margin type of a symbol
+func (c *Client) ChangeMarginType(ctx context.Context, symbol string, marginType MarginType) error {
+	return c.NewChangeMarginTypeService().Symbol(symbol).MarginType(marginType).Do(ctx)
+}
+
+// CommissionRate returns the commission rate of a symbol
+func (c *Client) CommissionRate(ctx context.Context, symbol string) (*CommissionRate, error) {
+	return c.NewCommissionRateService().Symbol(symbol).Do(ctx)
+}
@@ -0,0 +1,42 @@
+package futures
+
+import (
+	"context"
+
+	"github.com/drinkthere/go-aster/v2"
+)
+
+// NewAggTradeBackfillHandler wraps handler so that missed aggregate trades
+// are fetched with the aggregate trades service, see
+// aster.NewFuturesAggTradeBackfillHandler
+func (c *Client) NewAggTradeBackfillHandler(handler aster.WsFuturesAggTradeHandler, errHandler aster.ErrHandler) aster.WsFuturesAggTradeHandler {
+	return aster.NewFuturesAggTradeBackfillHandler(c.FetchAggTrades, handler, errHandler)
+}
+
+// WsAggTradeServeWithBackfill serves the aggregate trade stream of symbol,
+// backfilling missed trades, see NewAggTradeBackfillHandler
+func (c *Client) WsAggTradeServeWithBackfill(symbol string, handler aster.WsFuturesAggTradeHandler, errHandler aster.ErrHandler, opts ...aster.StreamOption) (doneC, stopC chan struct{}, err error) {
+	return c.WsAggTradeServe(symbol, c.NewAggTradeBackfillHandler(handler, errHandler), errHandler, opts...)
+}
+
+// FetchAggTrades is an aster.AggTradeFetcher on top of the aggregate trades
+// service
+func (c *Client) FetchAggTrades(ctx context.Context, symbol string, fromID int64, limit int) ([]*aster.SpotAggTrade, error) {
+	trades, err := c.NewAggTradesService().Symbol(symbol).FromID(fromID).Limit(limit).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*aster.SpotAggTrade, 0, len(trades))
+	for _, t := range trades {
+		res = append(res, &aster.SpotAggTrade{
+			TradeID:      t.AggTradeID,
+			Price:        t.Price,
+			Quantity:     t.Quantity,
+			FirstTradeID: t.FirstTradeID,
+			LastTradeID:  t.LastTradeID,
+			Time:         t.Time,
+			IsBuyerMaker: t.IsBuyerMaker,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,308 @@
+package futures
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2"
+)
+
+// ErrInvalidBarSpec is returned by NewBarAggregator for a spec without a
+// usable interval or threshold
+var ErrInvalidBarSpec = errors.New("futures: invalid bar spec")
+
+// BarType selects when a bar closes
+type BarType int
+
+const (
+	// BarTime closes bars at multiples of the interval since the epoch
+	BarTime BarType = iota
+	// BarTick closes bars after a number of trades
+	BarTick
+	// BarVolume closes bars once their base volume reaches the threshold
+	BarVolume
+	// BarDollar closes bars once their quote volume reaches the threshold
+	BarDollar
+)
+
+// BarSpec defines the bars of a BarAggregator
+type BarSpec struct {
+	Type      BarType
+	Interval  time.Duration // of BarTime
+	Threshold float64       // of BarTick, BarVolume and BarDollar
+}
+
+// TimeBars returns the spec of time aligned bars, e.g. 10s or 2m bars
+func TimeBars(interval time.Duration) BarSpec {
+	return BarSpec{Type: BarTime, Interval: interval}
+}
+
+// TickBars returns the spec of bars of n trades
+func TickBars(n int) BarSpec {
+	return BarSpec{Type: BarTick, Threshold: float64(n)}
+}
+
+// VolumeBars returns the spec of bars of a base volume
+func VolumeBars(volume float64) BarSpec {
+	return BarSpec{Type: BarVolume, Threshold: volume}
+}
+
+// DollarBars returns the spec of bars of a quote volume
+func DollarBars(quoteVolume float64) BarSpec {
+	return BarSpec{Type: BarDollar, Threshold: quoteVolume}
+}
+
+// Bar is an aggregated bar. Its Kline has the same fields as the klines of
+// the exchange intervals, so bars can go wherever klines go.
+type Bar struct {
+	Symbol string
+	Kline
+	VWAP         string
+	FirstTradeID int64
+	LastTradeID  int64
+}
+
+// BarTrade is a trade fed to a BarAggregator
+type BarTrade struct {
+	ID           int64
+	Time         int64 // ms
+	Price        float64
+	Quantity     float64
+	IsBuyerMaker bool
+	Count        int64 // trades aggregated, e.g. by an aggregate trade; 1 when 0
+}
+
+// BarConfig configures a BarAggregator
+type BarConfig struct {
+	Spec BarSpec
+	// OnBar receives the bars of every symbol as they close. Calls are
+	// serialized.
+	OnBar func(b *Bar)
+	// ErrHandler receives trades that cannot be parsed
+	ErrHandler aster.ErrHandler
+}
+
+// BarAggregator builds bars per symbol from trades, e.g. aggregate trade
+// streams or recorded trades. Time bars close when a trade of a later
+// interval arrives or on Flush, so intervals without trades produce no bar.
+// All methods are safe for concurrent use.
+type BarAggregator struct {
+	cfg BarConfig
+
+	mu   sync.Mutex
+	open map[string]*barState
+}
+
+// barState is the bar in progress of a symbol
+type barState struct {
+	start, end  int64 // interval of a time bar, ms
+	firstTime   int64
+	lastTime    int64
+	firstID     int64
+	lastID      int64
+	open, close float64
+	high, low   float64
+	volume      float64
+	quoteVolume float64
+	takerBase   float64
+	takerQuote  float64
+	trades      int64
+}
+
+// NewBarAggregator creates a bar aggregator. Time bars need an interval of
+// at least 1ms and activity bars a positive threshold.
+func NewBarAggregator(cfg BarConfig) (*BarAggregator, error) {
+	if err := cfg.Spec.validate(); err != nil {
+		return nil, err
+	}
+	return &BarAggregator{cfg: cfg, open: map[string]*barState{}}, nil
+}
+
+// validate checks that the spec defines bars
+func (s BarSpec) validate() error {
+	switch s.Type {
+	case BarTime:
+		if s.Interval < time.Millisecond {
+			return fmt.Errorf("%w: time bars of %s", ErrInvalidBarSpec, s.Interval)
+		}
+	case BarTick, BarVolume, BarDollar:
+		if !(s.Threshold > 0) || math.IsInf(s.Threshold, 1) {
+			return fmt.Errorf("%w: threshold %v", ErrInvalidBarSpec, s.Threshold)
+		}
+	default:
+		return fmt.Errorf("%w: type %d", ErrInvalidBarSpec, s.Type)
+	}
+	return nil
+}
+
+// HandleAggTrade adds a futures aggregate trade event
+func (a *BarAggregator) HandleAggTrade(ev *aster.WsFuturesAggTradeEvent) {
+	a.addEvent(ev.Symbol, ev.AggregateTradeID, ev.TradeTime, ev.Price, ev.Quantity, ev.IsBuyerMaker, ev.LastBreakdownTradeID-ev.FirstBreakdownTradeID+1)
+}
+
+// HandleSpotAggTrade adds a spot aggregate trade event
+func (a *BarAggregator) HandleSpotAggTrade(ev *aster.WsSpotAggTradeEvent) {
+	a.addEvent(ev.Symbol, ev.AggregateTradeID, ev.TradeTime, ev.Price, ev.Quantity, ev.IsBuyerMaker, ev.LastBreakdownTradeID-ev.FirstBreakdownTradeID+1)
+}
+
+// AddAggTrade adds an aggregate trade of symbol fetched through REST
+func (a *BarAggregator) AddAggTrade(symbol string, t *AggTrade) {
+	a.addEvent(symbol, t.AggTradeID, t.Time, t.Price, t.Quantity, t.IsBuyerMaker, t.LastTradeID-t.FirstTradeID+1)
+}
+
+// Add adds a trade of symbol. Trades of a symbol must be added in time
+// order.
+func (a *BarAggregator) Add(symbol string, t BarTrade) {
+	symbol = strings.ToUpper(symbol)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	b := a.open[symbol]
+	if b != nil && a.cfg.Spec.Type == BarTime && t.Time >= b.end {
+		a.close(symbol, b)
+		b = nil
+	}
+	if b == nil {
+		b = &barState{firstTime: t.Time, firstID: t.ID, open: t.Price, high: t.Price, low: t.Price}
+		if a.cfg.Spec.Type == BarTime {
+			interval := a.cfg.Spec.Interval.Milliseconds()
+			b.start = t.Time - t.Time%interval
+			b.end = b.start + interval
+		}
+		a.open[symbol] = b
+	}
+	b.add(t)
+	if a.full(b) {
+		a.close(symbol, b)
+	}
+}
+
+// Flush closes the time bars whose interval ended before now, for symbols
+// that stopped trading. Call it periodically, e.g. every second.
+func (a *BarAggregator) Flush(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cfg.Spec.Type != BarTime {
+		return
+	}
+	ms := now.UnixMilli()
+	for symbol, b := range a.open {
+		if ms >= b.end {
+			a.close(symbol, b)
+		}
+	}
+}
+
+// Current returns the bar in progress of symbol
+func (a *BarAggregator) Current(symbol string) (*Bar, bool) {
+	symbol = strings.ToUpper(symbol)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	b := a.open[symbol]
+	if b == nil {
+		return nil, false
+	}
+	return b.bar(symbol), true
+}
+
+// addEvent parses and adds an aggregate trade of count trades
+func (a *BarAggregator) addEvent(symbol string, id, tradeTime int64, price, quantity string, isBuyerMaker bool, count int64) {
+	p, err := strconv.ParseFloat(price, 64)
+	if err == nil {
+		var q float64
+		if q, err = strconv.ParseFloat(quantity, 64); err == nil {
+			a.Add(symbol, BarTrade{ID: id, Time: tradeTime, Price: p, Quantity: q, IsBuyerMaker: isBuyerMaker, Count: count})
+			return
+		}
+	}
+	if a.cfg.ErrHandler != nil {
+		a.cfg.ErrHandler(err)
+	}
+}
+
+// full reports whether an activity bar reached its threshold
+func (a *BarAggregator) full(b *barState) bool {
+	switch a.cfg.Spec.Type {
+	case BarTick:
+		return float64(b.trades) >= a.cfg.Spec.Threshold
+	case BarVolume:
+		return b.volume >= a.cfg.Spec.Threshold
+	case BarDollar:
+		return b.quoteVolume >= a.cfg.Spec.Threshold
+	}
+	return false
+}
+
+// close emits the bar of symbol and starts a new one with the next trade
+func (a *BarAggregator) close(symbol string, b *barState) {
+	delete(a.open, symbol)
+	if a.cfg.OnBar != nil {
+		a.cfg.OnBar(b.bar(symbol))
+	}
+}
+
+// add adds a trade to the bar
+func (b *barState) add(t BarTrade) {
+	if t.Price > b.high {
+		b.high = t.Price
+	}
+	if t.Price < b.low {
+		b.low = t.Price
+	}
+	b.close = t.Price
+	b.lastTime = t.Time
+	b.lastID = t.ID
+	quote := t.Price * t.Quantity
+	b.volume += t.Quantity
+	b.quoteVolume += quote
+	if !t.IsBuyerMaker {
+		b.takerBase += t.Quantity
+		b.takerQuote += quote
+	}
+	if t.Count > 0 {
+		b.trades += t.Count
+	} else {
+		b.trades++
+	}
+}
+
+// bar returns the bar of symbol. Time bars span their interval, activity
+// bars their first and last trade.
+func (b *barState) bar(symbol string) *Bar {
+	openTime, closeTime := b.firstTime, b.lastTime
+	if b.end > 0 {
+		openTime, closeTime = b.start, b.end-1
+	}
+	var vwap float64
+	if b.volume > 0 {
+		vwap = b.quoteVolume / b.volume
+	}
+	return &Bar{
+		Symbol: symbol,
+		Kline: Kline{
+			OpenTime:                 openTime,
+			Open:                     formatBarFloat(b.open),
+			High:                     formatBarFloat(b.high),
+			Low:                      formatBarFloat(b.low),
+			Close:                    formatBarFloat(b.close),
+			Volume:                   formatBarFloat(b.volume),
+			CloseTime:                closeTime,
+			QuoteVolume:              formatBarFloat(b.quoteVolume),
+			TradeNum:                 b.trades,
+			TakerBuyBaseAssetVolume:  formatBarFloat(b.takerBase),
+			TakerBuyQuoteAssetVolume: formatBarFloat(b.takerQuote),
+		},
+		VWAP:         formatBarFloat(vwap),
+		FirstTradeID: b.firstID,
+		LastTradeID:  b.lastID,
+	}
+}
+
+// formatBarFloat formats a bar value with the fewest digits that round trip
+func formatBarFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
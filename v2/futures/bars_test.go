@@ -0,0 +1,127 @@
+package futures
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2"
+)
+
+func newTestAggregator(t *testing.T, spec BarSpec) (*BarAggregator, *[]*Bar) {
+	t.Helper()
+	var bars []*Bar
+	agg, err := NewBarAggregator(BarConfig{Spec: spec, OnBar: func(b *Bar) { bars = append(bars, b) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return agg, &bars
+}
+
+func TestNewBarAggregatorValidatesSpec(t *testing.T) {
+	for _, spec := range []BarSpec{
+		TimeBars(0),
+		TimeBars(time.Microsecond),
+		TimeBars(-time.Second),
+		TickBars(0),
+		VolumeBars(-1),
+		DollarBars(math.NaN()),
+		DollarBars(math.Inf(1)),
+		{Type: BarType(42), Threshold: 1},
+	} {
+		if _, err := NewBarAggregator(BarConfig{Spec: spec}); !errors.Is(err, ErrInvalidBarSpec) {
+			t.Errorf("spec %+v: got %v, want ErrInvalidBarSpec", spec, err)
+		}
+	}
+	if _, err := NewBarAggregator(BarConfig{Spec: TimeBars(time.Millisecond)}); err != nil {
+		t.Errorf("1ms bars: %v", err)
+	}
+}
+
+func TestTimeBars(t *testing.T) {
+	agg, bars := newTestAggregator(t, TimeBars(10*time.Second))
+	agg.Add("btcusdt", BarTrade{ID: 1, Time: 10_500, Price: 100, Quantity: 1})
+	agg.Add("BTCUSDT", BarTrade{ID: 2, Time: 12_000, Price: 110, Quantity: 1, IsBuyerMaker: true})
+	agg.Add("BTCUSDT", BarTrade{ID: 3, Time: 19_999, Price: 90, Quantity: 2})
+	agg.Add("BTCUSDT", BarTrade{ID: 4, Time: 35_000, Price: 95, Quantity: 1})
+	if len(*bars) != 1 {
+		t.Fatalf("got %d bars, want 1", len(*bars))
+	}
+	b := (*bars)[0]
+	if b.Symbol != "BTCUSDT" || b.OpenTime != 10_000 || b.CloseTime != 19_999 {
+		t.Errorf("bar spans %s %d to %d", b.Symbol, b.OpenTime, b.CloseTime)
+	}
+	if b.Open != "100" || b.High != "110" || b.Low != "90" || b.Close != "90" || b.Volume != "4" {
+		t.Errorf("got OHLCV %s %s %s %s %s", b.Open, b.High, b.Low, b.Close, b.Volume)
+	}
+	if b.QuoteVolume != "390" || b.VWAP != "97.5" || b.TakerBuyBaseAssetVolume != "3" || b.TakerBuyQuoteAssetVolume != "280" {
+		t.Errorf("got quote %s vwap %s taker %s %s", b.QuoteVolume, b.VWAP, b.TakerBuyBaseAssetVolume, b.TakerBuyQuoteAssetVolume)
+	}
+	if b.TradeNum != 3 || b.FirstTradeID != 1 || b.LastTradeID != 3 {
+		t.Errorf("got %d trades, ids %d to %d", b.TradeNum, b.FirstTradeID, b.LastTradeID)
+	}
+
+	agg.Flush(time.UnixMilli(39_999))
+	if len(*bars) != 1 {
+		t.Fatalf("flushed a bar in progress")
+	}
+	agg.Flush(time.UnixMilli(40_000))
+	if len(*bars) != 2 || (*bars)[1].OpenTime != 30_000 {
+		t.Fatalf("got bars %+v after Flush", *bars)
+	}
+	if _, ok := agg.Current("BTCUSDT"); ok {
+		t.Errorf("bar in progress after Flush")
+	}
+}
+
+func TestTickBarsCountAggregatedTrades(t *testing.T) {
+	agg, bars := newTestAggregator(t, TickBars(10))
+	trade := func(id, first, last int64) *aster.WsFuturesAggTradeEvent {
+		return &aster.WsFuturesAggTradeEvent{Symbol: "BTCUSDT", AggregateTradeID: id, TradeTime: id, Price: "100", Quantity: "1", FirstBreakdownTradeID: first, LastBreakdownTradeID: last}
+	}
+	agg.HandleAggTrade(trade(1, 100, 103)) // 4 trades
+	agg.HandleAggTrade(trade(2, 104, 104)) // 1 trade
+	if b, ok := agg.Current("BTCUSDT"); !ok || b.TradeNum != 5 {
+		t.Fatalf("got bar in progress %+v", b)
+	}
+	agg.HandleAggTrade(trade(3, 105, 109)) // 5 trades
+	if len(*bars) != 1 || (*bars)[0].TradeNum != 10 || (*bars)[0].LastTradeID != 3 {
+		t.Fatalf("got bars %+v", *bars)
+	}
+	agg.AddAggTrade("BTCUSDT", &AggTrade{AggTradeID: 4, Time: 4, Price: "1", Quantity: "1", FirstTradeID: 110, LastTradeID: 111})
+	if b, _ := agg.Current("BTCUSDT"); b.TradeNum != 2 {
+		t.Errorf("REST aggregate trade counted %d trades, want 2", b.TradeNum)
+	}
+}
+
+func TestVolumeAndDollarBars(t *testing.T) {
+	volume, volumeBars := newTestAggregator(t, VolumeBars(3))
+	dollar, dollarBars := newTestAggregator(t, DollarBars(1000))
+	for i, q := range []float64{1, 1, 2, 1} {
+		trade := BarTrade{ID: int64(i + 1), Time: int64(i), Price: 200, Quantity: q}
+		volume.Add("ETHUSDT", trade)
+		dollar.Add("ETHUSDT", trade)
+	}
+	if len(*volumeBars) != 1 || (*volumeBars)[0].Volume != "4" || (*volumeBars)[0].OpenTime != 0 || (*volumeBars)[0].CloseTime != 2 {
+		t.Errorf("got volume bars %+v", *volumeBars)
+	}
+	if len(*dollarBars) != 1 || (*dollarBars)[0].QuoteVolume != "1000" || (*dollarBars)[0].LastTradeID != 4 {
+		t.Errorf("got dollar bars %+v", *dollarBars)
+	}
+}
+
+func TestBarAggregatorReportsBadTrades(t *testing.T) {
+	var errs []error
+	agg, err := NewBarAggregator(BarConfig{Spec: TickBars(1), ErrHandler: func(err error) { errs = append(errs, err) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg.HandleAggTrade(&aster.WsFuturesAggTradeEvent{Symbol: "BTCUSDT", Price: "x", Quantity: "1"})
+	if len(errs) != 1 {
+		t.Errorf("got errors %v", errs)
+	}
+	if _, ok := agg.Current("BTCUSDT"); ok {
+		t.Errorf("bad trade opened a bar")
+	}
+}
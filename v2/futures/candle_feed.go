@@ -0,0 +1,344 @@
+package futures
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// ErrCandleFeedClosed is returned by CandleFeed.Run when the kline stream
+// ends before its context is done
+var ErrCandleFeedClosed = errors.New("futures: kline stream closed")
+
+// klinesPageLimit is the maximum limit of the klines endpoint
+const klinesPageLimit = 1500
+
+// Candle is a kline of a symbol and interval
+type Candle struct {
+	Symbol   string
+	Interval common.Interval
+	Kline
+	Final      bool // the candle is closed and will not change
+	Backfilled bool // the candle was fetched through REST
+}
+
+// CandleGapError reports final candles that could not be backfilled, from
+// open time From up to but excluding open time To
+type CandleGapError struct {
+	Symbol   string
+	Interval common.Interval
+	From     int64
+	To       int64
+	Err      error
+}
+
+func (e *CandleGapError) Error() string {
+	return fmt.Sprintf("futures: %s %s candles from %d to %d missed: %v", e.Symbol, e.Interval, e.From, e.To, e.Err)
+}
+
+func (e *CandleGapError) Unwrap() error {
+	return e.Err
+}
+
+// CandleFeedConfig configures a CandleFeed
+type CandleFeedConfig struct {
+	Symbols   []string
+	Intervals []common.Interval
+	// API fetches the candles missed while the stream was down
+	API MarketDataAPI
+
+	// Partial also emits the updates of the candle in progress
+	Partial bool
+	// StartTime backfills the final candles from this open time in ms
+	// before the first streamed candle of each symbol and interval
+	StartTime int64
+
+	// OnCandle receives the candles of every symbol and interval, final
+	// candles in open time order without gaps. Calls are serialized.
+	OnCandle func(c *Candle)
+
+	// ErrHandler receives stream errors and CandleGapErrors
+	ErrHandler aster.ErrHandler
+
+	// LocalAddress and Options configure the connection of Run, see
+	// aster.StreamClient
+	LocalAddress string
+	Options      []aster.StreamOption
+}
+
+// candleKey identifies the candle sequence of a symbol and interval
+type candleKey struct {
+	symbol   string
+	interval common.Interval
+}
+
+// candleSeries is the state of the candle sequence of a symbol and interval
+type candleSeries struct {
+	last    int64 // open time of the last final candle
+	started bool  // last is set
+	filled  int64 // open time up to which missed candles were fetched
+	filling bool
+	pending []*aster.WsFuturesKlineEvent // events held while filling
+}
+
+// CandleFeed turns kline streams into ordered sequences of final candles.
+// Candles missed while the stream was down are fetched through the klines
+// endpoint and emitted, flagged as Backfilled, before the streamed candle
+// that revealed the gap. The fetch runs on its own goroutine, holding the
+// later events of the sequence until it ends, so OnCandle may run there.
+// All methods are safe for concurrent use.
+//
+//	feed := futures.NewCandleFeed(futures.CandleFeedConfig{
+//		Symbols:   []string{"BTCUSDT"},
+//		Intervals: []common.Interval{common.Interval1m},
+//		API:       client,
+//		OnCandle:  func(c *futures.Candle) { fmt.Println(c.Close) },
+//	})
+//	defer feed.Close()
+//	go feed.Run(ctx)
+type CandleFeed struct {
+	cfg    CandleFeedConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex // serializes OnCandle
+	series map[candleKey]*candleSeries
+}
+
+// NewCandleFeed creates a candle feed. Kline events are fed by Run or by
+// Handle.
+func NewCandleFeed(cfg CandleFeedConfig) *CandleFeed {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CandleFeed{
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+		series: map[candleKey]*candleSeries{},
+	}
+}
+
+// Run streams the klines of the configured symbols and intervals into the
+// feed over one connection until ctx is done. The stream reconnects
+// according to aster.WsReconnect.
+func (f *CandleFeed) Run(ctx context.Context) error {
+	client := aster.NewFuturesStreamClient(f.error)
+	client.LocalAddress = f.cfg.LocalAddress
+	client.Options = f.cfg.Options
+	if err := client.Connect(); err != nil {
+		return err
+	}
+	defer client.Close()
+	for _, interval := range f.cfg.Intervals {
+		if err := client.SubscribeFuturesKline(ctx, string(interval), f.Handle, f.cfg.Symbols...); err != nil {
+			return err
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-f.ctx.Done():
+		return nil
+	case <-client.Done():
+		return ErrCandleFeedClosed
+	}
+}
+
+// Close stops the feed. Later events are ignored.
+func (f *CandleFeed) Close() {
+	f.cancel()
+}
+
+// Handle feeds a kline event, e.g. from a StreamClient subscription. Events
+// of one symbol and interval must be passed in stream order.
+func (f *CandleFeed) Handle(ev *aster.WsFuturesKlineEvent) {
+	if f.ctx.Err() != nil {
+		return
+	}
+	key := candleKey{symbol: strings.ToUpper(ev.Symbol), interval: common.Interval(ev.Kline.Interval)}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	series := f.series[key]
+	if series == nil {
+		series = &candleSeries{}
+		f.series[key] = series
+	}
+	if series.filling {
+		held := *ev
+		series.pending = append(series.pending, &held)
+		return
+	}
+	f.handle(key, series, ev)
+}
+
+// handle emits the candle of an event, or holds the event and starts
+// fetching the candles missed before it
+func (f *CandleFeed) handle(key candleKey, series *candleSeries, ev *aster.WsFuturesKlineEvent) {
+	k := ev.Kline
+	if series.started && k.StartTime <= series.last {
+		return
+	}
+	from := k.StartTime
+	switch {
+	case series.started:
+		from = nextOpenTime(key.interval, series.last)
+	case f.cfg.StartTime > 0:
+		from = f.cfg.StartTime
+	}
+	if from < series.filled {
+		from = series.filled
+	}
+	if from < k.StartTime {
+		held := *ev
+		series.pending = append(series.pending, &held)
+		series.filling = true
+		go f.backfill(key, from, k.StartTime)
+		return
+	}
+	if !k.IsFinal && !f.cfg.Partial {
+		return
+	}
+	if k.IsFinal {
+		series.last, series.started = k.StartTime, true
+	}
+	f.emit(&Candle{
+		Symbol:   key.symbol,
+		Interval: key.interval,
+		Kline: Kline{
+			OpenTime:                 k.StartTime,
+			Open:                     k.Open,
+			High:                     k.High,
+			Low:                      k.Low,
+			Close:                    k.Close,
+			Volume:                   k.Volume,
+			CloseTime:                k.EndTime,
+			QuoteVolume:              k.QuoteVolume,
+			TradeNum:                 k.TradeNum,
+			TakerBuyBaseAssetVolume:  k.ActiveBuyVolume,
+			TakerBuyQuoteAssetVolume: k.ActiveBuyQuoteVolume,
+		},
+		Final: k.IsFinal,
+	})
+}
+
+// Last returns the last final candle open time of a symbol and interval
+func (f *CandleFeed) Last(symbol string, interval common.Interval) (int64, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	series := f.series[candleKey{symbol: strings.ToUpper(symbol), interval: interval}]
+	if series == nil || !series.started {
+		return 0, false
+	}
+	return series.last, true
+}
+
+// backfill fetches the final candles of key opened from from up to but
+// excluding to, retrying failed requests a few times, then emits them and
+// handles the events held meanwhile
+func (f *CandleFeed) backfill(key candleKey, from, to int64) {
+	var klines, page []*Kline
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-f.ctx.Done():
+				return
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+		page, from, err = f.fetch(key, from, to)
+		klines = append(klines, page...)
+		if err == nil {
+			break
+		}
+	}
+	if err == nil && from < to {
+		err = errors.New("klines endpoint returned no candles")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.ctx.Err() != nil {
+		return
+	}
+	series := f.series[key]
+	for _, k := range klines {
+		series.last, series.started = k.OpenTime, true
+		f.emit(&Candle{Symbol: key.symbol, Interval: key.interval, Kline: *k, Final: true, Backfilled: true})
+	}
+	if err != nil {
+		f.error(&CandleGapError{Symbol: key.symbol, Interval: key.interval, From: from, To: to, Err: err})
+	}
+	series.filled = to
+	series.filling = false
+	pending := series.pending
+	series.pending = nil
+	for i, ev := range pending {
+		f.handle(key, series, ev)
+		if series.filling {
+			series.pending = append(series.pending, pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// fetch returns the final candles of key opened from from up to but
+// excluding to, page by page, and the open time of the next missing candle
+func (f *CandleFeed) fetch(key candleKey, from, to int64) ([]*Kline, int64, error) {
+	var res []*Kline
+	for from < to {
+		klines, err := f.cfg.API.Klines(f.ctx, KlinesParams{
+			Symbol:    key.symbol,
+			Interval:  key.interval,
+			StartTime: from,
+			EndTime:   to - 1,
+			Limit:     klinesPageLimit,
+		})
+		if err != nil {
+			return res, from, err
+		}
+		if len(klines) == 0 {
+			return res, from, nil
+		}
+		for _, k := range klines {
+			if k.OpenTime < from || k.OpenTime >= to {
+				continue
+			}
+			res = append(res, k)
+			from = nextOpenTime(key.interval, k.OpenTime)
+		}
+		if len(klines) < klinesPageLimit {
+			return res, from, nil
+		}
+	}
+	return res, from, nil
+}
+
+// emit passes a candle to OnCandle
+func (f *CandleFeed) emit(c *Candle) {
+	if f.cfg.OnCandle != nil {
+		f.cfg.OnCandle(c)
+	}
+}
+
+// error passes an error to ErrHandler
+func (f *CandleFeed) error(err error) {
+	if f.cfg.ErrHandler != nil {
+		f.cfg.ErrHandler(err)
+	}
+}
+
+// nextOpenTime returns the open time of the candle after the one opened at
+// openTime
+func nextOpenTime(interval common.Interval, openTime int64) int64 {
+	if d := interval.Duration(); d > 0 {
+		return openTime + d.Milliseconds()
+	}
+	return time.UnixMilli(openTime).UTC().AddDate(0, 1, 0).UnixMilli()
+}
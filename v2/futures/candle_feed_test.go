@@ -0,0 +1,201 @@
+package futures
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+const minute = int64(60000)
+
+// klinesAPI serves one minute klines opened up to before, blocking until
+// release when set
+type klinesAPI struct {
+	MarketDataAPI
+	before  int64
+	err     error
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls []KlinesParams
+}
+
+func (a *klinesAPI) Klines(ctx context.Context, params KlinesParams) ([]*Kline, error) {
+	if a.release != nil {
+		<-a.release
+	}
+	a.mu.Lock()
+	a.calls = append(a.calls, params)
+	a.mu.Unlock()
+	if a.err != nil {
+		return nil, a.err
+	}
+	var klines []*Kline
+	for t := params.StartTime; t <= params.EndTime && t < a.before && len(klines) < params.Limit; t += minute {
+		klines = append(klines, &Kline{OpenTime: t, CloseTime: t + minute - 1, Close: "1"})
+	}
+	return klines, nil
+}
+
+// candleRecorder collects the candles and errors of a feed
+type candleRecorder struct {
+	mu      sync.Mutex
+	candles []*Candle
+	errs    []error
+	notify  chan struct{}
+}
+
+func newCandleRecorder() *candleRecorder {
+	return &candleRecorder{notify: make(chan struct{}, 100)}
+}
+
+func (r *candleRecorder) config(api MarketDataAPI) CandleFeedConfig {
+	return CandleFeedConfig{
+		API:      api,
+		OnCandle: r.candle,
+		ErrHandler: func(err error) {
+			r.mu.Lock()
+			r.errs = append(r.errs, err)
+			r.mu.Unlock()
+		},
+	}
+}
+
+func (r *candleRecorder) candle(c *Candle) {
+	r.mu.Lock()
+	r.candles = append(r.candles, c)
+	r.mu.Unlock()
+	r.notify <- struct{}{}
+}
+
+// wait waits for n candles and returns their open times in minutes and
+// backfill flags
+func (r *candleRecorder) wait(t *testing.T, n int) (opens []int64, backfilled []bool) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		r.mu.Lock()
+		if len(r.candles) >= n {
+			for _, c := range r.candles {
+				opens = append(opens, c.OpenTime/minute)
+				backfilled = append(backfilled, c.Backfilled)
+			}
+			r.mu.Unlock()
+			return opens, backfilled
+		}
+		r.mu.Unlock()
+		select {
+		case <-r.notify:
+		case <-deadline:
+			t.Fatalf("timed out waiting for %d candles", n)
+		}
+	}
+}
+
+func klineEvent(openMinute int64, final bool) *aster.WsFuturesKlineEvent {
+	return &aster.WsFuturesKlineEvent{
+		Event:  "kline",
+		Symbol: "BTCUSDT",
+		Kline: aster.WsFuturesKline{
+			StartTime: openMinute * minute,
+			EndTime:   (openMinute+1)*minute - 1,
+			Interval:  "1m",
+			Close:     "2",
+			IsFinal:   final,
+		},
+	}
+}
+
+func equalInt64s(got, want []int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCandleFeedBackfillsGap(t *testing.T) {
+	api := &klinesAPI{before: 100 * minute, release: make(chan struct{})}
+	r := newCandleRecorder()
+	feed := NewCandleFeed(r.config(api))
+	defer feed.Close()
+
+	feed.Handle(klineEvent(1, true))
+	feed.Handle(klineEvent(1, true)) // repeated after a reconnect
+	feed.Handle(klineEvent(4, false))
+	// the fetch is blocked, so these return only if Handle does not wait
+	feed.Handle(klineEvent(4, true))
+	feed.Handle(klineEvent(5, true))
+	close(api.release)
+
+	opens, backfilled := r.wait(t, 5)
+	if !equalInt64s(opens, []int64{1, 2, 3, 4, 5}) {
+		t.Fatalf("got candles %v, want [1 2 3 4 5]", opens)
+	}
+	for i, want := range []bool{false, true, true, false, false} {
+		if backfilled[i] != want {
+			t.Errorf("candle %d backfilled %v, want %v", opens[i], backfilled[i], want)
+		}
+	}
+	if last, ok := feed.Last("btcusdt", common.Interval1m); !ok || last != 5*minute {
+		t.Errorf("Last = %d, %v", last, ok)
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.calls) != 1 || api.calls[0].StartTime != 2*minute || api.calls[0].EndTime != 4*minute-1 {
+		t.Errorf("got klines requests %+v", api.calls)
+	}
+}
+
+func TestCandleFeedStartTime(t *testing.T) {
+	api := &klinesAPI{before: 100 * minute}
+	r := newCandleRecorder()
+	cfg := r.config(api)
+	cfg.StartTime = 7 * minute
+	cfg.Partial = true
+	feed := NewCandleFeed(cfg)
+	defer feed.Close()
+
+	feed.Handle(klineEvent(10, false))
+	feed.Handle(klineEvent(10, true))
+
+	opens, backfilled := r.wait(t, 5)
+	if !equalInt64s(opens, []int64{7, 8, 9, 10, 10}) {
+		t.Fatalf("got candles %v, want [7 8 9 10 10]", opens)
+	}
+	if !backfilled[0] || !backfilled[2] || backfilled[3] {
+		t.Errorf("got backfill flags %v", backfilled)
+	}
+}
+
+func TestCandleFeedReportsGap(t *testing.T) {
+	api := &klinesAPI{err: errors.New("unavailable")}
+	r := newCandleRecorder()
+	feed := NewCandleFeed(r.config(api))
+	defer feed.Close()
+
+	feed.Handle(klineEvent(1, true))
+	feed.Handle(klineEvent(3, true))
+	feed.Handle(klineEvent(4, true))
+
+	// the fetch is retried with a 1s and a 2s backoff
+	opens, _ := r.wait(t, 3)
+	if !equalInt64s(opens, []int64{1, 3, 4}) {
+		t.Fatalf("got candles %v, want [1 3 4]", opens)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var gap *CandleGapError
+	if len(r.errs) != 1 || !errors.As(r.errs[0], &gap) || gap.From != 2*minute || gap.To != 3*minute {
+		t.Errorf("got errors %v, want a gap of candle 2", r.errs)
+	}
+}
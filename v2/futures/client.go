@@ -0,0 +1,196 @@
+package futures
+
+import (
+	"github.com/drinkthere/go-aster/v2"
+)
+
+// Services lists the REST service constructors of the futures client
+type Services interface {
+	// Market data endpoints
+	NewPingService() *PingService
+	NewServerTimeService() *ServerTimeService
+	NewExchangeInfoService() *ExchangeInfoService
+	NewDepthService() *DepthService
+	NewRecentTradesListService() *RecentTradesListService
+	NewAggTradesService() *AggTradesService
+	NewKlinesService() *KlinesService
+	NewContinuousKlinesService() *ContinuousKlinesService
+	NewMarkPriceService() *MarkPriceService
+	NewFundingRateService() *FundingRateService
+	NewListPriceChangeStatsService() *ListPriceChangeStatsService
+	NewListPricesService() *ListPricesService
+	NewListBookTickersService() *ListBookTickersService
+
+	// Trading endpoints
+	NewCreateOrderService() *CreateOrderService
+	NewGetOrderService() *GetOrderService
+	NewCancelOrderService() *CancelOrderService
+	NewCancelAllOpenOrdersService() *CancelAllOpenOrdersService
+	NewListOpenOrdersService() *ListOpenOrdersService
+	NewListOrdersService() *ListOrdersService
+
+	// Account endpoints
+	NewGetAccountService() *GetAccountService
+	NewGetBalanceService() *GetBalanceService
+	NewGetPositionRiskService() *GetPositionRiskService
+	NewChangeLeverageService() *ChangeLeverageService
+	NewChangeMarginTypeService() *ChangeMarginTypeService
+	NewUpdatePositionMarginService() *UpdatePositionMarginService
+	NewCommissionRateService() *CommissionRateService
+
+	// User stream endpoints
+	NewStartUserStreamService() *StartUserStreamService
+	NewKeepaliveUserStreamService() *KeepaliveUserStreamService
+	NewCloseUserStreamService() *CloseUserStreamService
+}
+
+var _ Services = (*Client)(nil)
+
+// Client defines futures client. It embeds aster.FuturesClient, so the
+// websocket streams are available on it as well.
+type Client struct {
+	*aster.FuturesClient
+}
+
+// NewClient creates a new futures client (using HMAC signature)
+func NewClient(apiKey, secretKey string, opts ...aster.ClientOption) *Client {
+	return &Client{FuturesClient: aster.NewFutures(apiKey, secretKey, opts...)}
+}
+
+// NewIntranetClient creates a new futures intranet client (using HMAC signature)
+func NewIntranetClient(apiKey, secretKey string, opts ...aster.ClientOption) *Client {
+	baseClient := aster.NewFuturesIntranetClient(apiKey, secretKey, opts...)
+	return &Client{FuturesClient: &aster.FuturesClient{BaseClient: baseClient}}
+}
+
+// NewClientWithWeb3 creates a new futures client (using Web3 signature)
+func NewClientWithWeb3(userAddress, signerAddress, privateKey string, opts ...aster.ClientOption) *Client {
+	baseClient := aster.NewFuturesClientWithWeb3(userAddress, signerAddress, privateKey, opts...)
+	return &Client{FuturesClient: &aster.FuturesClient{BaseClient: baseClient}}
+}
+
+// NewIntranetClientWithWeb3 creates a new futures intranet client (using Web3 signature)
+func NewIntranetClientWithWeb3(userAddress, signerAddress, privateKey string, opts ...aster.ClientOption) *Client {
+	baseClient := aster.NewFuturesIntranetClientWithWeb3(userAddress, signerAddress, privateKey, opts...)
+	return &Client{FuturesClient: &aster.FuturesClient{BaseClient: baseClient}}
+}
+
+// Market data endpoints
+func (c *Client) NewPingService() *PingService {
+	return &PingService{C: c.BaseClient}
+}
+
+func (c *Client) NewServerTimeService() *ServerTimeService {
+	return &ServerTimeService{C: c.BaseClient}
+}
+
+func (c *Client) NewExchangeInfoService() *ExchangeInfoService {
+	return &ExchangeInfoService{C: c.BaseClient}
+}
+
+func (c *Client) NewDepthService() *DepthService {
+	return &DepthService{C: c.BaseClient}
+}
+
+func (c *Client) NewRecentTradesListService() *RecentTradesListService {
+	return &RecentTradesListService{C: c.BaseClient}
+}
+
+func (c *Client) NewAggTradesService() *AggTradesService {
+	return &AggTradesService{C: c.BaseClient}
+}
+
+func (c *Client) NewKlinesService() *KlinesService {
+	return &KlinesService{C: c.BaseClient}
+}
+
+func (c *Client) NewContinuousKlinesService() *ContinuousKlinesService {
+	return &ContinuousKlinesService{C: c.BaseClient}
+}
+
+func (c *Client) NewMarkPriceService() *MarkPriceService {
+	return &MarkPriceService{C: c.BaseClient}
+}
+
+func (c *Client) NewFundingRateService() *FundingRateService {
+	return &FundingRateService{C: c.BaseClient}
+}
+
+func (c *Client) NewListPriceChangeStatsService() *ListPriceChangeStatsService {
+	return &ListPriceChangeStatsService{C: c.BaseClient}
+}
+
+func (c *Client) NewListPricesService() *ListPricesService {
+	return &ListPricesService{C: c.BaseClient}
+}
+
+func (c *Client) NewListBookTickersService() *ListBookTickersService {
+	return &ListBookTickersService{C: c.BaseClient}
+}
+
+// Trading endpoints
+func (c *Client) NewCreateOrderService() *CreateOrderService {
+	return &CreateOrderService{C: c.BaseClient}
+}
+
+func (c *Client) NewGetOrderService() *GetOrderService {
+	return &GetOrderService{C: c.BaseClient}
+}
+
+func (c *Client) NewCancelOrderService() *CancelOrderService {
+	return &CancelOrderService{C: c.BaseClient}
+}
+
+func (c *Client) NewCancelAllOpenOrdersService() *CancelAllOpenOrdersService {
+	return &CancelAllOpenOrdersService{C: c.BaseClient}
+}
+
+func (c *Client) NewListOpenOrdersService() *ListOpenOrdersService {
+	return &ListOpenOrdersService{C: c.BaseClient}
+}
+
+func (c *Client) NewListOrdersService() *ListOrdersService {
+	return &ListOrdersService{C: c.BaseClient}
+}
+
+// Account endpoints
+func (c *Client) NewGetAccountService() *GetAccountService {
+	return &GetAccountService{C: c.BaseClient}
+}
+
+func (c *Client) NewGetBalanceService() *GetBalanceService {
+	return &GetBalanceService{C: c.BaseClient}
+}
+
+func (c *Client) NewGetPositionRiskService() *GetPositionRiskService {
+	return &GetPositionRiskService{C: c.BaseClient}
+}
+
+func (c *Client) NewChangeLeverageService() *ChangeLeverageService {
+	return &ChangeLeverageService{C: c.BaseClient}
+}
+
+func (c *Client) NewChangeMarginTypeService() *ChangeMarginTypeService {
+	return &ChangeMarginTypeService{C: c.BaseClient}
+}
+
+func (c *Client) NewUpdatePositionMarginService() *UpdatePositionMarginService {
+	return &UpdatePositionMarginService{C: c.BaseClient}
+}
+
+func (c *Client) NewCommissionRateService() *CommissionRateService {
+	return &CommissionRateService{C: c.BaseClient}
+}
+
+// User stream endpoints
+func (c *Client) NewStartUserStreamService() *StartUserStreamService {
+	return c.FuturesClient.NewStartUserStreamService()
+}
+
+func (c *Client) NewKeepaliveUserStreamService() *KeepaliveUserStreamService {
+	return c.FuturesClient.NewKeepaliveUserStreamService()
+}
+
+func (c *Client) NewCloseUserStreamService() *CloseUserStreamService {
+	return c.FuturesClient.NewCloseUserStreamService()
+}
@@ -0,0 +1,63 @@
+package futures
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+func TestServiceFixtures(t *testing.T) {
+	client := NewClient("key", "secret", aster.WithReplayer(filepath.Join("testdata", "futures_services.json")))
+	ctx := context.Background()
+
+	depth, err := client.NewDepthService().Symbol("BTCUSDT").Limit(5).Do(ctx)
+	if err != nil || depth.LastUpdateID != 1027024 || depth.TransactionTime != 1700000000120 || len(depth.Asks) != 2 {
+		t.Errorf("depth %+v, %v", depth, err)
+	}
+	klines, err := client.NewKlinesService().Symbol("BTCUSDT").Interval(common.Interval1m).
+		StartTime(1699999920000).EndTime(1700000039999).Do(ctx)
+	if err != nil || len(klines) != 2 || klines[0].TradeNum != 310 || klines[1].OpenTime != 1699999980000 || klines[1].TakerBuyQuoteAssetVolume != "71400.00" {
+		t.Errorf("klines %+v, %v", klines, err)
+	}
+	marks, err := client.NewMarkPriceService().Symbol("BTCUSDT").Do(ctx)
+	if err != nil || len(marks) != 1 || marks[0].MarkPrice != "42001.50000000" || marks[0].NextFundingTime != 1700006400000 {
+		t.Errorf("mark prices %+v, %v", marks, err)
+	}
+	rates, err := client.NewFundingRateService().Symbol("BTCUSDT").Limit(2).Do(ctx)
+	if err != nil || len(rates) != 2 || rates[1].FundingRate != "-0.00002500" {
+		t.Errorf("funding rates %+v, %v", rates, err)
+	}
+	balances, err := client.NewGetBalanceService().Do(ctx)
+	if err != nil || len(balances) != 1 || balances[0].Asset != "USDT" || !balances[0].MarginAvailable {
+		t.Errorf("balances %+v, %v", balances, err)
+	}
+	positions, err := client.NewGetPositionRiskService().Symbol("BTCUSDT").Do(ctx)
+	if err != nil || len(positions) != 1 || positions[0].PositionAmt != "0.010" || positions[0].PositionSide != PositionSideTypeBoth ||
+		!strings.EqualFold(string(positions[0].MarginType), string(MarginTypeCross)) {
+		t.Errorf("positions %+v, %v", positions, err)
+	}
+	leverage, err := client.NewChangeLeverageService().Symbol("BTCUSDT").Leverage(20).Do(ctx)
+	if err != nil || leverage.Leverage != 20 || leverage.MaxNotionalValue != "1000000" {
+		t.Errorf("leverage %+v, %v", leverage, err)
+	}
+	order, err := client.NewCreateOrderService().Symbol("BTCUSDT").Side(common.SideTypeBuy).Type(common.OrderTypeLimit).
+		TimeInForce(common.TimeInForceTypeGTC).Quantity("0.010").Price("41000").NewClientOrderID("fixture-1").Do(ctx)
+	if err != nil || order.OrderID != 22542179 || order.Status != common.OrderStatusTypeNew || order.WorkingType != WorkingTypeContractPrice {
+		t.Errorf("order %+v, %v", order, err)
+	}
+	_, err = client.NewCreateOrderService().Symbol("BTCUSDT").Side(common.SideTypeBuy).Type(common.OrderTypeMarket).
+		Quantity("1000").Do(ctx)
+	var apiErr *common.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != -2019 {
+		t.Errorf("order returned %v, want API error -2019", err)
+	}
+	listenKey, err := client.NewStartUserStreamService().Do(ctx)
+	if err != nil || len(listenKey) != 64 {
+		t.Errorf("listen key %q, %v", listenKey, err)
+	}
+}
@@ -1,68 +1,19 @@
 package futures
 
 import (
-	"context"
-	"net/http"
-
 	"github.com/drinkthere/go-aster/v2"
 )
 
-// StartUserStreamService create listen key for user stream
-type StartUserStreamService struct {
-	C *aster.BaseClient
-}
-
-// Do send request
-func (s *StartUserStreamService) Do(ctx context.Context, opts ...aster.RequestOption) (listenKey string, err error) {
-	r := aster.NewRequest(http.MethodPost, "/fapi/v1/listenKey", aster.SecTypeSigned)
-	data, err := s.C.CallAPI(ctx, r, opts...)
-	if err != nil {
-		return "", err
-	}
-	j, err := aster.NewJSON(data)
-	if err != nil {
-		return "", err
-	}
-	listenKey = (*j).Get("listenKey").ToString()
-	return listenKey, nil
-}
-
-// KeepaliveUserStreamService update listen key
-type KeepaliveUserStreamService struct {
-	C         *aster.BaseClient
-	listenKey string
-}
-
-// ListenKey set listen key
-func (s *KeepaliveUserStreamService) ListenKey(listenKey string) *KeepaliveUserStreamService {
-	s.listenKey = listenKey
-	return s
-}
+// The listen key services are implemented once in the aster package, where
+// UserStream manages its keys through them. Client returns them through the
+// embedded aster.FuturesClient.
+type (
+	// StartUserStreamService create listen key for user stream
+	StartUserStreamService = aster.StartFuturesUserStreamService
 
-// Do send request
-func (s *KeepaliveUserStreamService) Do(ctx context.Context, opts ...aster.RequestOption) (err error) {
-	r := aster.NewRequest(http.MethodPut, "/fapi/v1/listenKey", aster.SecTypeSigned)
-	r.SetFormParam("listenKey", s.listenKey)
-	_, err = s.C.CallAPI(ctx, r, opts...)
-	return err
-}
+	// KeepaliveUserStreamService update listen key
+	KeepaliveUserStreamService = aster.KeepaliveFuturesUserStreamService
 
-// CloseUserStreamService close user stream
-type CloseUserStreamService struct {
-	C         *aster.BaseClient
-	listenKey string
-}
-
-// ListenKey set listen key
-func (s *CloseUserStreamService) ListenKey(listenKey string) *CloseUserStreamService {
-	s.listenKey = listenKey
-	return s
-}
-
-// Do send request
-func (s *CloseUserStreamService) Do(ctx context.Context, opts ...aster.RequestOption) (err error) {
-	r := aster.NewRequest(http.MethodDelete, "/fapi/v1/listenKey", aster.SecTypeSigned)
-	r.SetFormParam("listenKey", s.listenKey)
-	_, err = s.C.CallAPI(ctx, r, opts...)
-	return err
-}
\ No newline at end of file
+	// CloseUserStreamService close user stream
+	CloseUserStreamService = aster.CloseFuturesUserStreamService
+)
@@ -0,0 +1,171 @@
+package futures
+
+import (
+	"context"
+
+	"github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// WsAPI places and queries futures orders over the websocket API, returning
+// the same types as the REST endpoints
+type WsAPI struct {
+	*aster.WsAPIClient
+}
+
+// ModifyOrderParams are the parameters of ModifyOrder. The order is
+// identified by OrderID or OrigClientOrderID.
+type ModifyOrderParams struct {
+	Symbol            string
+	OrderID           int64
+	OrigClientOrderID string
+	Side              common.SideType
+	Quantity          string
+	Price             string
+}
+
+// NewWsAPI creates a websocket API client for the futures client. It must
+// be connected with Connect.
+func (c *Client) NewWsAPI(errHandler aster.ErrHandler) *WsAPI {
+	return &WsAPI{WsAPIClient: aster.NewFuturesWsAPIClient(c.BaseClient, errHandler)}
+}
+
+// PlaceOrder places an order with order.place
+func (c *WsAPI) PlaceOrder(ctx context.Context, params CreateOrderParams) (*Order, error) {
+	m := aster.Params{
+		"symbol":   params.Symbol,
+		"side":     params.Side,
+		"type":     params.Type,
+		"quantity": params.Quantity,
+	}
+	if params.PositionSide != "" {
+		m["positionSide"] = params.PositionSide
+	}
+	if params.TimeInForce != "" {
+		m["timeInForce"] = params.TimeInForce
+	}
+	if params.Price != "" {
+		m["price"] = params.Price
+	}
+	if params.ReduceOnly {
+		m["reduceOnly"] = true
+	}
+	if params.NewClientOrderID != "" {
+		m["newClientOrderId"] = params.NewClientOrderID
+	}
+	if params.StopPrice != "" {
+		m["stopPrice"] = params.StopPrice
+	}
+	if params.ClosePosition {
+		m["closePosition"] = true
+	}
+	if params.ActivationPrice != "" {
+		m["activationPrice"] = params.ActivationPrice
+	}
+	if params.CallbackRate != "" {
+		m["callbackRate"] = params.CallbackRate
+	}
+	if params.WorkingType != "" {
+		m["workingType"] = params.WorkingType
+	}
+	if params.PriceProtect {
+		m["priceProtect"] = true
+	}
+	if params.NewOrderRespType != "" {
+		m["newOrderRespType"] = params.NewOrderRespType
+	}
+	res := new(Order)
+	if err := c.call(ctx, "order.place", m, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ModifyOrder changes the price and quantity of a limit order with
+// order.modify
+func (c *WsAPI) ModifyOrder(ctx context.Context, params ModifyOrderParams) (*Order, error) {
+	m := orderQueryParams(OrderQuery{
+		Symbol:            params.Symbol,
+		OrderID:           params.OrderID,
+		OrigClientOrderID: params.OrigClientOrderID,
+	})
+	m["side"] = params.Side
+	m["quantity"] = params.Quantity
+	m["price"] = params.Price
+	res := new(Order)
+	if err := c.call(ctx, "order.modify", m, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CancelOrder cancels an order with order.cancel
+func (c *WsAPI) CancelOrder(ctx context.Context, query OrderQuery) (*Order, error) {
+	res := new(Order)
+	if err := c.call(ctx, "order.cancel", orderQueryParams(query), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetOrder returns an order with order.status
+func (c *WsAPI) GetOrder(ctx context.Context, query OrderQuery) (*Order, error) {
+	res := new(Order)
+	if err := c.call(ctx, "order.status", orderQueryParams(query), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Account returns the account info with account.status
+func (c *WsAPI) Account(ctx context.Context) (*Account, error) {
+	res := new(Account)
+	if err := c.call(ctx, "account.status", aster.Params{}, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Balances returns the account balances with account.balance
+func (c *WsAPI) Balances(ctx context.Context) ([]Balance, error) {
+	var res []Balance
+	if err := c.call(ctx, "account.balance", aster.Params{}, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// PositionRisk returns position risk with account.position, of all symbols
+// when symbol is empty
+func (c *WsAPI) PositionRisk(ctx context.Context, symbol string) ([]PositionRisk, error) {
+	m := aster.Params{}
+	if symbol != "" {
+		m["symbol"] = symbol
+	}
+	var res []PositionRisk
+	if err := c.call(ctx, "account.position", m, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// call sends a signed request and decodes its result into res
+func (c *WsAPI) call(ctx context.Context, method string, m aster.Params, res interface{}) error {
+	data, err := c.Call(ctx, method, m, true)
+	if err != nil {
+		return err
+	}
+	return aster.JSON.Unmarshal(data, res)
+}
+
+// orderQueryParams returns the parameters identifying an order
+func orderQueryParams(query OrderQuery) aster.Params {
+	m := aster.Params{"symbol": query.Symbol}
+	if query.OrderID > 0 {
+		m["orderId"] = query.OrderID
+	}
+	if query.OrigClientOrderID != "" {
+		m["origClientOrderId"] = query.OrigClientOrderID
+	}
+	return m
+}
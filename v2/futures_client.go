@@ -1,6 +1,8 @@
 package aster
 
 import (
+	"time"
+
 	"github.com/drinkthere/go-aster/v2/common"
 )
 
@@ -15,60 +17,121 @@ func NewFutures(apiKey, secretKey string, opts ...ClientOption) *FuturesClient {
 	return &FuturesClient{BaseClient: baseClient}
 }
 
+// User stream endpoints
+func (c *FuturesClient) NewStartUserStreamService() *StartFuturesUserStreamService {
+	return &StartFuturesUserStreamService{c: c.BaseClient}
+}
+
+func (c *FuturesClient) NewKeepaliveUserStreamService() *KeepaliveFuturesUserStreamService {
+	return &KeepaliveFuturesUserStreamService{c: c.BaseClient}
+}
+
+func (c *FuturesClient) NewCloseUserStreamService() *CloseFuturesUserStreamService {
+	return &CloseFuturesUserStreamService{c: c.BaseClient}
+}
+
 // WebSocket streams
-func (c *FuturesClient) WsDepthServe(symbol string, handler WsDepthHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return c.WsDepthServeWithLocalAddr(symbol, handler, errHandler, c.LocalAddress)
+func (c *FuturesClient) WsDepthServe(symbol string, handler WsDepthHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesDepthServe(symbol, handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *FuturesClient) WsKlineServe(symbol string, interval common.Interval, handler WsFuturesKlineHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesKlineServe(symbol, string(interval), handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *FuturesClient) WsAggTradeServe(symbol string, handler WsFuturesAggTradeHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesAggTradeServe(symbol, handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *FuturesClient) WsBookTickerServe(symbol string, handler WsBookTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesBookTickerServe(symbol, handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *FuturesClient) WsMarkPriceServe(symbol string, handler WsFuturesMarkPriceHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesMarkPriceServe(symbol, handler, errHandler, c.streamOptions(opts)...)
 }
 
-func (c *FuturesClient) WsKlineServe(symbol string, interval common.Interval, handler WsFuturesKlineHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return c.WsKlineServeWithLocalAddr(symbol, string(interval), handler, errHandler, c.LocalAddress)
+func (c *FuturesClient) WsUserDataServe(listenKey string, handler WsFuturesUserDataHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesUserDataServe(listenKey, handler, errHandler, c.streamOptions(opts)...)
 }
 
-func (c *FuturesClient) WsAggTradeServe(symbol string, handler WsFuturesAggTradeHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return c.WsAggTradeServeWithLocalAddr(symbol, handler, errHandler, c.LocalAddress)
+func (c *FuturesClient) WsCombinedBookTickerServe(symbols []string, handler WsBookTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsCombinedFuturesBookTickerServe(symbols, handler, errHandler, c.streamOptions(opts)...)
 }
 
-func (c *FuturesClient) WsBookTickerServe(symbol string, handler WsBookTickerHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return c.WsBookTickerServeWithLocalAddr(symbol, handler, errHandler, c.LocalAddress)
+func (c *FuturesClient) WsLiquidationOrderServe(symbol string, handler WsFuturesLiquidationOrderHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesLiquidationOrderServe(symbol, handler, errHandler, c.streamOptions(opts)...)
 }
 
-func (c *FuturesClient) WsMarkPriceServe(symbol string, handler WsFuturesMarkPriceHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return c.WsMarkPriceServeWithLocalAddr(symbol, handler, errHandler, c.LocalAddress)
+func (c *FuturesClient) WsAllLiquidationOrderServe(handler WsFuturesLiquidationOrderHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesAllLiquidationOrderServe(handler, errHandler, c.streamOptions(opts)...)
 }
 
-func (c *FuturesClient) WsUserDataServe(listenKey string, handler WsFuturesUserDataHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return c.WsUserDataServeWithLocalAddr(listenKey, handler, errHandler, c.LocalAddress)
+func (c *FuturesClient) WsMiniMarketTickerServe(symbol string, handler WsFuturesMiniMarketTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesMiniMarketTickerServe(symbol, handler, errHandler, c.streamOptions(opts)...)
 }
 
-func (c *FuturesClient) WsCombinedBookTickerServe(symbols []string, handler WsBookTickerHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return WsCombinedFuturesBookTickerServeWithLocalAddr(symbols, handler, errHandler, c.LocalAddress)
+func (c *FuturesClient) WsAllMiniMarketTickerServe(handler WsFuturesAllMiniMarketTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesAllMiniMarketTickerServe(handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *FuturesClient) WsMarketTickerServe(symbol string, handler WsFuturesMarketTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesMarketTickerServe(symbol, handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *FuturesClient) WsAllMarketTickerServe(handler WsFuturesAllMarketTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesAllMarketTickerServe(handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *FuturesClient) WsContinuousKlineServe(pair, contractType string, interval common.Interval, handler WsFuturesContinuousKlineHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesContinuousKlineServe(pair, contractType, interval, handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *FuturesClient) WsIndexPriceServe(pair string, rate time.Duration, handler WsFuturesIndexPriceHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesIndexPriceServe(pair, rate, handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *FuturesClient) WsMarkPriceServeWithRate(symbol string, rate time.Duration, handler WsFuturesMarkPriceHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesMarkPriceServeWithRate(symbol, rate, handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *FuturesClient) WsAllMarkPriceServeWithRate(rate time.Duration, handler WsFuturesMarkPriceHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsFuturesAllMarkPriceServeWithRate(rate, handler, errHandler, c.streamOptions(opts)...)
 }
 
 // WebSocket streams with LocalAddress support
+
+// Deprecated: use WsDepthServe with WithStreamLocalAddress.
 func (c *FuturesClient) WsDepthServeWithLocalAddr(symbol string, handler WsDepthHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	return WsFuturesDepthServeWithLocalAddr(symbol, handler, errHandler, localAddr)
+	return WsFuturesDepthServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
+// Deprecated: use WsKlineServe with WithStreamLocalAddress.
 func (c *FuturesClient) WsKlineServeWithLocalAddr(symbol string, interval string, handler WsFuturesKlineHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	return WsFuturesKlineServeWithLocalAddr(symbol, interval, handler, errHandler, localAddr)
+	return WsFuturesKlineServe(symbol, interval, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
+// Deprecated: use WsAggTradeServe with WithStreamLocalAddress.
 func (c *FuturesClient) WsAggTradeServeWithLocalAddr(symbol string, handler WsFuturesAggTradeHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	return WsFuturesAggTradeServeWithLocalAddr(symbol, handler, errHandler, localAddr)
+	return WsFuturesAggTradeServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
+// Deprecated: use WsBookTickerServe with WithStreamLocalAddress.
 func (c *FuturesClient) WsBookTickerServeWithLocalAddr(symbol string, handler WsBookTickerHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	return WsFuturesBookTickerServeWithLocalAddr(symbol, handler, errHandler, localAddr)
+	return WsFuturesBookTickerServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
+// Deprecated: use WsMarkPriceServe with WithStreamLocalAddress.
 func (c *FuturesClient) WsMarkPriceServeWithLocalAddr(symbol string, handler WsFuturesMarkPriceHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	return WsFuturesMarkPriceServeWithLocalAddr(symbol, handler, errHandler, localAddr)
+	return WsFuturesMarkPriceServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
+// Deprecated: use WsUserDataServe with WithStreamLocalAddress.
 func (c *FuturesClient) WsUserDataServeWithLocalAddr(listenKey string, handler WsFuturesUserDataHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	return WsFuturesUserDataServeWithLocalAddr(listenKey, handler, errHandler, localAddr)
+	return WsFuturesUserDataServe(listenKey, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
+// Deprecated: use WsCombinedBookTickerServe with WithStreamLocalAddress.
 func (c *FuturesClient) WsCombinedBookTickerServeWithLocalAddr(symbols []string, handler WsBookTickerHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	return WsCombinedFuturesBookTickerServeWithLocalAddr(symbols, handler, errHandler, localAddr)
-}
\ No newline at end of file
+	return WsCombinedFuturesBookTickerServe(symbols, handler, errHandler, WithStreamLocalAddress(localAddr))
+}
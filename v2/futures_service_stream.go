@@ -0,0 +1,66 @@
+package aster
+
+import (
+	"context"
+	"net/http"
+)
+
+// StartFuturesUserStreamService create listen key for futures user stream
+type StartFuturesUserStreamService struct {
+	c *BaseClient
+}
+
+// Do send request
+func (s *StartFuturesUserStreamService) Do(ctx context.Context, opts ...RequestOption) (listenKey string, err error) {
+	r := newRequest(http.MethodPost, "/fapi/v1/listenKey", secTypeSigned)
+	data, err := s.c.callAPI(ctx, r, opts...)
+	if err != nil {
+		return "", err
+	}
+	j, err := newJSON(data)
+	if err != nil {
+		return "", err
+	}
+	listenKey = (*j).Get("listenKey").ToString()
+	return listenKey, nil
+}
+
+// KeepaliveFuturesUserStreamService update futures listen key
+type KeepaliveFuturesUserStreamService struct {
+	c         *BaseClient
+	listenKey string
+}
+
+// ListenKey set listen key
+func (s *KeepaliveFuturesUserStreamService) ListenKey(listenKey string) *KeepaliveFuturesUserStreamService {
+	s.listenKey = listenKey
+	return s
+}
+
+// Do send request
+func (s *KeepaliveFuturesUserStreamService) Do(ctx context.Context, opts ...RequestOption) (err error) {
+	r := newRequest(http.MethodPut, "/fapi/v1/listenKey", secTypeSigned)
+	r.setFormParam("listenKey", s.listenKey)
+	_, err = s.c.callAPI(ctx, r, opts...)
+	return err
+}
+
+// CloseFuturesUserStreamService close futures user stream
+type CloseFuturesUserStreamService struct {
+	c         *BaseClient
+	listenKey string
+}
+
+// ListenKey set listen key
+func (s *CloseFuturesUserStreamService) ListenKey(listenKey string) *CloseFuturesUserStreamService {
+	s.listenKey = listenKey
+	return s
+}
+
+// Do send request
+func (s *CloseFuturesUserStreamService) Do(ctx context.Context, opts ...RequestOption) (err error) {
+	r := newRequest(http.MethodDelete, "/fapi/v1/listenKey", secTypeSigned)
+	r.setFormParam("listenKey", s.listenKey)
+	_, err = s.c.callAPI(ctx, r, opts...)
+	return err
+}
@@ -0,0 +1,62 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/drinkthere/go-aster/v2"
+)
+
+// checkpoint records completed tasks so a Run can resume after a crash
+type checkpoint struct {
+	mu   sync.Mutex
+	path string
+	Done map[string]bool `json:"done"`
+}
+
+// loadCheckpoint reads the checkpoint file, or returns an empty checkpoint
+// if it does not exist yet
+func loadCheckpoint(path string) (*checkpoint, error) {
+	cp := &checkpoint{path: path, Done: map[string]bool{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = aster.JSON.Unmarshal(data, cp); err != nil {
+		return nil, err
+	}
+	if cp.Done == nil {
+		cp.Done = map[string]bool{}
+	}
+	return cp, nil
+}
+
+// isDone reports whether the task has completed
+func (cp *checkpoint) isDone(key string) bool {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.Done[key]
+}
+
+// markDone records the task as completed and persists the checkpoint
+func (cp *checkpoint) markDone(key string) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.Done[key] = true
+	data, err := aster.JSON.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(cp.path), 0o755); err != nil {
+		return err
+	}
+	tmp := cp.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cp.path)
+}
@@ -0,0 +1,298 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/common"
+	"github.com/drinkthere/go-aster/v2/futures"
+)
+
+// Request weights and page sizes of the endpoints used by the downloader
+const (
+	klinesWeight        = 10
+	aggTradesWeight     = 20
+	fundingRateWeight   = 1
+	spotKlinesLimit     = 1000
+	futuresKlinesLimit  = 1500
+	aggTradesLimit      = 1000
+	fundingRateLimit    = 1000
+	aggTradesWindow     = time.Hour
+	rateLimitedCode     = -1003
+	rateLimitedCooldown = time.Minute
+)
+
+// row is one downloaded record. key orders and deduplicates rows.
+type row struct {
+	key    int64
+	fields []string
+	value  interface{}
+}
+
+// call runs fn with weight pacing, retrying rate limit and transport errors
+func (d *Downloader) call(ctx context.Context, weight int, fn func() error) (err error) {
+	backoff := time.Second
+	for attempt := 0; attempt <= d.cfg.MaxRetries; attempt++ {
+		if err = d.limiter.wait(ctx, weight); err != nil {
+			return err
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		wait := backoff
+		var apiErr *common.APIError
+		if errors.As(err, &apiErr) {
+			if apiErr.Code != rateLimitedCode {
+				return err
+			}
+			wait = rateLimitedCooldown
+		}
+		d.cfg.Logger.Printf("request failed (attempt %d): %v, retrying in %s", attempt+1, err, wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+// fetchKlines downloads klines with open time in [from, to)
+func (d *Downloader) fetchKlines(ctx context.Context, symbol string, interval common.Interval, from, to time.Time) ([]row, error) {
+	var rows []row
+	start := from.UnixMilli()
+	end := to.UnixMilli() - 1
+	for start <= end {
+		var page []row
+		err := d.call(ctx, klinesWeight, func() error {
+			var err error
+			page, err = d.klinesPage(ctx, symbol, interval, start, end)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		rows = append(rows, page...)
+		start = page[len(page)-1].key + 1
+	}
+	return rows, nil
+}
+
+// klinesPage downloads one page of klines from the configured market
+func (d *Downloader) klinesPage(ctx context.Context, symbol string, interval common.Interval, start, end int64) ([]row, error) {
+	if d.cfg.Market == MarketSpot {
+		spot := &aster.SpotClient{BaseClient: d.c}
+		klines, err := spot.NewKlinesService().Symbol(symbol).Interval(interval).
+			StartTime(start).EndTime(end).Limit(spotKlinesLimit).Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([]row, 0, len(klines))
+		for _, k := range klines {
+			rows = append(rows, row{
+				key: k.OpenTime,
+				fields: []string{
+					strconv.FormatInt(k.OpenTime, 10), k.Open, k.High, k.Low, k.Close, k.Volume,
+					strconv.FormatInt(k.CloseTime, 10), k.QuoteAssetVolume, strconv.FormatInt(k.TradeNum, 10),
+					k.TakerBuyBaseAssetVolume, k.TakerBuyQuoteAssetVolume,
+				},
+				value: k,
+			})
+		}
+		return rows, nil
+	}
+
+	klines, err := (&futures.KlinesService{C: d.c}).Symbol(symbol).Interval(interval).
+		StartTime(start).EndTime(end).Limit(futuresKlinesLimit).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]row, 0, len(klines))
+	for _, k := range klines {
+		rows = append(rows, row{
+			key: k.OpenTime,
+			fields: []string{
+				strconv.FormatInt(k.OpenTime, 10), k.Open, k.High, k.Low, k.Close, k.Volume,
+				strconv.FormatInt(k.CloseTime, 10), k.QuoteVolume, strconv.FormatInt(k.TradeNum, 10),
+				k.TakerBuyBaseAssetVolume, k.TakerBuyQuoteAssetVolume,
+			},
+			value: k,
+		})
+	}
+	return rows, nil
+}
+
+// fetchAggTrades downloads aggregate trades with trade time in [from, to).
+// The first page is located by time, following pages by id.
+func (d *Downloader) fetchAggTrades(ctx context.Context, symbol string, from, to time.Time) ([]row, error) {
+	var rows []row
+	end := to.UnixMilli() - 1
+	var fromID *int64
+	windowStart := from.UnixMilli()
+	for {
+		var page []row
+		err := d.call(ctx, aggTradesWeight, func() error {
+			var err error
+			if fromID != nil {
+				page, err = d.aggTradesPage(ctx, symbol, fromID, 0, 0)
+			} else {
+				windowEnd := windowStart + aggTradesWindow.Milliseconds() - 1
+				if windowEnd > end {
+					windowEnd = end
+				}
+				page, err = d.aggTradesPage(ctx, symbol, nil, windowStart, windowEnd)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			if fromID != nil {
+				break
+			}
+			// Empty window: move on to the next one
+			windowStart += aggTradesWindow.Milliseconds()
+			if windowStart > end {
+				break
+			}
+			continue
+		}
+		done := false
+		for _, r := range page {
+			if tradeTime(r) > end {
+				done = true
+				break
+			}
+			rows = append(rows, r)
+		}
+		if done {
+			break
+		}
+		next := page[len(page)-1].key + 1
+		fromID = &next
+	}
+	return rows, nil
+}
+
+// tradeTime returns the trade time of an aggregate trade row
+func tradeTime(r row) int64 {
+	switch t := r.value.(type) {
+	case *aster.SpotAggTrade:
+		return t.Time
+	case *futures.AggTrade:
+		return t.Time
+	}
+	return 0
+}
+
+// aggTradesPage downloads one page of aggregate trades either from an id or
+// within a time window
+func (d *Downloader) aggTradesPage(ctx context.Context, symbol string, fromID *int64, start, end int64) ([]row, error) {
+	if d.cfg.Market == MarketSpot {
+		spot := &aster.SpotClient{BaseClient: d.c}
+		s := spot.NewAggTradesService().Symbol(symbol).Limit(aggTradesLimit)
+		if fromID != nil {
+			s.FromID(*fromID)
+		} else {
+			s.StartTime(start).EndTime(end)
+		}
+		trades, err := s.Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rows := make([]row, 0, len(trades))
+		for _, t := range trades {
+			rows = append(rows, row{
+				key: t.TradeID,
+				fields: []string{
+					strconv.FormatInt(t.TradeID, 10), t.Price, t.Quantity,
+					strconv.FormatInt(t.FirstTradeID, 10), strconv.FormatInt(t.LastTradeID, 10),
+					strconv.FormatInt(t.Time, 10), strconv.FormatBool(t.IsBuyerMaker),
+				},
+				value: t,
+			})
+		}
+		return rows, nil
+	}
+
+	s := (&futures.AggTradesService{C: d.c}).Symbol(symbol).Limit(aggTradesLimit)
+	if fromID != nil {
+		s.FromID(*fromID)
+	} else {
+		s.StartTime(start).EndTime(end)
+	}
+	trades, err := s.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]row, 0, len(trades))
+	for _, t := range trades {
+		rows = append(rows, row{
+			key: t.AggTradeID,
+			fields: []string{
+				strconv.FormatInt(t.AggTradeID, 10), t.Price, t.Quantity,
+				strconv.FormatInt(t.FirstTradeID, 10), strconv.FormatInt(t.LastTradeID, 10),
+				strconv.FormatInt(t.Time, 10), strconv.FormatBool(t.IsBuyerMaker),
+			},
+			value: t,
+		})
+	}
+	return rows, nil
+}
+
+// fetchFundingRates downloads funding rates with funding time in [from, to)
+func (d *Downloader) fetchFundingRates(ctx context.Context, symbol string, from, to time.Time) ([]row, error) {
+	var rows []row
+	start := from.UnixMilli()
+	end := to.UnixMilli() - 1
+	for start <= end {
+		var rates []*futures.FundingRate
+		err := d.call(ctx, fundingRateWeight, func() error {
+			var err error
+			rates, err = (&futures.FundingRateService{C: d.c}).Symbol(symbol).
+				StartTime(start).EndTime(end).Limit(fundingRateLimit).Do(ctx)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(rates) == 0 {
+			break
+		}
+		for _, r := range rates {
+			rows = append(rows, row{
+				key:    r.FundingTime,
+				fields: []string{r.Symbol, strconv.FormatInt(r.FundingTime, 10), r.FundingRate},
+				value:  r,
+			})
+		}
+		start = rates[len(rates)-1].FundingTime + 1
+	}
+	return rows, nil
+}
+
+// header returns the CSV header of a dataset
+func header(dataset Dataset) []string {
+	switch dataset {
+	case DatasetKlines:
+		return []string{"open_time", "open", "high", "low", "close", "volume", "close_time",
+			"quote_volume", "trade_num", "taker_buy_base_volume", "taker_buy_quote_volume"}
+	case DatasetAggTrades:
+		return []string{"agg_trade_id", "price", "quantity", "first_trade_id", "last_trade_id",
+			"time", "is_buyer_maker"}
+	case DatasetFundingRate:
+		return []string{"symbol", "funding_time", "funding_rate"}
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// Market selects the REST API family to download from
+type Market int
+
+const (
+	MarketFutures Market = iota
+	MarketSpot
+)
+
+// String returns the name of the market
+func (m Market) String() string {
+	if m == MarketSpot {
+		return "spot"
+	}
+	return "futures"
+}
+
+// Dataset represents a kind of historical market data
+type Dataset string
+
+const (
+	DatasetKlines      Dataset = "klines"
+	DatasetAggTrades   Dataset = "aggTrades"
+	DatasetFundingRate Dataset = "fundingRate"
+)
+
+// Format represents the output file format
+type Format int
+
+const (
+	FormatCSV Format = iota
+	FormatNDJSONGzip
+)
+
+// ext returns the file extension for the format
+func (f Format) ext() string {
+	if f == FormatNDJSONGzip {
+		return ".ndjson.gz"
+	}
+	return ".csv"
+}
+
+// Config configures a Downloader
+type Config struct {
+	Market          Market
+	Dir             string
+	Format          Format
+	Concurrency     int
+	WeightPerMinute int
+	MaxRetries      int
+	CheckpointPath  string
+	Logger          *log.Logger
+}
+
+// Job describes what to download: every symbol × dataset (× interval for
+// klines) over the [Start, End) date range, split into one file per UTC day
+type Job struct {
+	Symbols   []string
+	Datasets  []Dataset
+	Intervals []common.Interval
+	Start     time.Time
+	End       time.Time
+}
+
+// Gap represents a hole detected in a downloaded series. For klines and
+// funding rates From/To are open times in ms, for aggTrades they are ids.
+type Gap struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// FileReport summarizes one downloaded file
+type FileReport struct {
+	Path       string `json:"path"`
+	Rows       int    `json:"rows"`
+	Duplicates int    `json:"duplicates"`
+	Gaps       []Gap  `json:"gaps,omitempty"`
+	Skipped    bool   `json:"skipped"`
+}
+
+// Report summarizes a Run
+type Report struct {
+	Files []FileReport
+}
+
+// Downloader downloads historical market data to disk
+type Downloader struct {
+	c       *aster.BaseClient
+	cfg     Config
+	limiter *weightLimiter
+}
+
+// NewDownloader creates a new downloader
+func NewDownloader(client *aster.BaseClient, cfg Config) *Downloader {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 4
+	}
+	if cfg.WeightPerMinute <= 0 {
+		cfg.WeightPerMinute = 1200
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 5
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "."
+	}
+	if cfg.CheckpointPath == "" {
+		cfg.CheckpointPath = filepath.Join(cfg.Dir, ".checkpoint.json")
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.New(os.Stderr, "[ASTER-HISTORY] ", log.LstdFlags)
+	}
+	return &Downloader{
+		c:       client,
+		cfg:     cfg,
+		limiter: newWeightLimiter(cfg.WeightPerMinute),
+	}
+}
+
+// task is one symbol/dataset/interval/day unit of work, covering the part
+// [from, to) of the day within the job
+type task struct {
+	symbol   string
+	dataset  Dataset
+	interval common.Interval
+	day      time.Time
+	from, to time.Time
+}
+
+// name identifies the task in errors
+func (t task) name() string {
+	return fmt.Sprintf("%s/%s/%s/%s", t.symbol, t.dataset, t.interval, t.day.Format("2006-01-02"))
+}
+
+// key returns the checkpoint key of the task. It holds everything that
+// changes the file: the market, format and time range as well, so a day
+// downloaded partly or for another market is downloaded again.
+func (t task) key(m Market, f Format) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s/%d-%d", m, t.symbol, t.dataset, t.interval, f.ext()[1:], t.from.UnixMilli(), t.to.UnixMilli())
+}
+
+// path returns the output file path of the task
+func (t task) path(dir string, f Format) string {
+	name := string(t.dataset)
+	if t.dataset == DatasetKlines {
+		name = fmt.Sprintf("%s_%s", t.dataset, t.interval)
+	}
+	return filepath.Join(dir, t.symbol, name, t.day.Format("2006-01-02")+f.ext())
+}
+
+// tasks expands the job into tasks
+func (j Job) tasks() ([]task, error) {
+	if !j.End.After(j.Start) {
+		return nil, fmt.Errorf("end time must be after start time")
+	}
+	var tasks []task
+	start := j.Start.UTC().Truncate(24 * time.Hour)
+	for _, symbol := range j.Symbols {
+		for _, dataset := range j.Datasets {
+			intervals := []common.Interval{""}
+			if dataset == DatasetKlines {
+				if len(j.Intervals) == 0 {
+					return nil, fmt.Errorf("intervals are required for klines")
+				}
+				intervals = j.Intervals
+			}
+			for _, interval := range intervals {
+				for day := start; day.Before(j.End); day = day.Add(24 * time.Hour) {
+					t := task{symbol: symbol, dataset: dataset, interval: interval, day: day, from: day, to: day.Add(24 * time.Hour)}
+					if t.from.Before(j.Start) {
+						t.from = j.Start
+					}
+					if t.to.After(j.End) {
+						t.to = j.End
+					}
+					tasks = append(tasks, t)
+				}
+			}
+		}
+	}
+	return tasks, nil
+}
+
+// Run downloads the job. Days already recorded in the checkpoint file for
+// the same market, format and time range are skipped, so an interrupted Run
+// can be resumed by calling it again. Days that have not ended yet are never
+// recorded.
+func (d *Downloader) Run(ctx context.Context, job Job) (*Report, error) {
+	tasks, err := job.tasks()
+	if err != nil {
+		return nil, err
+	}
+	cp, err := loadCheckpoint(d.cfg.CheckpointPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		report   = &Report{}
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	taskC := make(chan task)
+	for i := 0; i < d.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range taskC {
+				fr, err := d.runTask(ctx, t, cp)
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("%s: %w", t.name(), err)
+						cancel()
+					}
+				} else {
+					report.Files = append(report.Files, *fr)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, t := range tasks {
+		select {
+		case taskC <- t:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(taskC)
+	wg.Wait()
+
+	sort.Slice(report.Files, func(i, j int) bool {
+		return report.Files[i].Path < report.Files[j].Path
+	})
+	if firstErr != nil {
+		return report, firstErr
+	}
+	return report, ctx.Err()
+}
+
+// runTask downloads a single day into its output file
+func (d *Downloader) runTask(ctx context.Context, t task, cp *checkpoint) (*FileReport, error) {
+	path := t.path(d.cfg.Dir, d.cfg.Format)
+	key := t.key(d.cfg.Market, d.cfg.Format)
+	if cp.isDone(key) {
+		return &FileReport{Path: path, Skipped: true}, nil
+	}
+	from, to := t.from, t.to
+
+	var rows []row
+	var err error
+	switch t.dataset {
+	case DatasetKlines:
+		rows, err = d.fetchKlines(ctx, t.symbol, t.interval, from, to)
+	case DatasetAggTrades:
+		rows, err = d.fetchAggTrades(ctx, t.symbol, from, to)
+	case DatasetFundingRate:
+		if d.cfg.Market != MarketFutures {
+			return nil, fmt.Errorf("funding rate is only available for futures")
+		}
+		rows, err = d.fetchFundingRates(ctx, t.symbol, from, to)
+	default:
+		return nil, fmt.Errorf("unknown dataset: %s", t.dataset)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, dups := dedupe(rows)
+	fr := &FileReport{
+		Path:       path,
+		Rows:       len(rows),
+		Duplicates: dups,
+		Gaps:       findGaps(rows, t.step()),
+	}
+	if err = writeFile(path, d.cfg.Format, t.dataset, rows); err != nil {
+		return nil, err
+	}
+	// the file is complete only once its range has ended
+	if !to.After(time.Now()) {
+		if err = cp.markDone(key); err != nil {
+			return nil, err
+		}
+	}
+	if len(fr.Gaps) > 0 || dups > 0 {
+		d.cfg.Logger.Printf("%s: %d rows, %d duplicates, %d gaps", path, fr.Rows, dups, len(fr.Gaps))
+	}
+	return fr, nil
+}
+
+// step returns the expected distance between consecutive keys, or 0 if the
+// series has no fixed spacing
+func (t task) step() int64 {
+	switch t.dataset {
+	case DatasetKlines:
+		return t.interval.Duration().Milliseconds()
+	case DatasetAggTrades:
+		return 1
+	}
+	return 0
+}
+
+// dedupe sorts rows by key and drops rows with a repeated key
+func dedupe(rows []row) ([]row, int) {
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].key < rows[j].key })
+	out := rows[:0]
+	dups := 0
+	for i, r := range rows {
+		if i > 0 && r.key == rows[i-1].key {
+			dups++
+			continue
+		}
+		out = append(out, r)
+	}
+	return out, dups
+}
+
+// findGaps reports missing keys in sorted, deduplicated rows
+func findGaps(rows []row, step int64) []Gap {
+	if step <= 0 {
+		return nil
+	}
+	var gaps []Gap
+	for i := 1; i < len(rows); i++ {
+		if rows[i].key-rows[i-1].key > step {
+			gaps = append(gaps, Gap{From: rows[i-1].key + step, To: rows[i].key - step})
+		}
+	}
+	return gaps
+}
@@ -0,0 +1,128 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+func TestTaskKeys(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	job := Job{Symbols: []string{"BTCUSDT"}, Datasets: []Dataset{DatasetKlines}, Intervals: []common.Interval{common.Interval1h}, Start: day.Add(6 * time.Hour), End: day.Add(36 * time.Hour)}
+	tasks, err := job.tasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("got %d tasks, want 2", len(tasks))
+	}
+	if !tasks[0].from.Equal(job.Start) || !tasks[0].to.Equal(day.Add(24*time.Hour)) || !tasks[1].to.Equal(job.End) {
+		t.Errorf("tasks cover %v-%v and %v-%v", tasks[0].from, tasks[0].to, tasks[1].from, tasks[1].to)
+	}
+
+	keys := map[string]bool{}
+	for _, m := range []Market{MarketFutures, MarketSpot} {
+		for _, f := range []Format{FormatCSV, FormatNDJSONGzip} {
+			keys[tasks[0].key(m, f)] = true
+		}
+	}
+	whole := tasks[0]
+	whole.from = day
+	keys[whole.key(MarketFutures, FormatCSV)] = true
+	if len(keys) != 5 {
+		t.Errorf("keys are not distinct: %v", keys)
+	}
+}
+
+// klinesServer serves hourly futures klines and counts the requests
+func klinesServer(t *testing.T, requests *int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/fapi/v1/klines" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(requests, 1)
+		start, _ := strconv.ParseInt(r.URL.Query().Get("startTime"), 10, 64)
+		end, _ := strconv.ParseInt(r.URL.Query().Get("endTime"), 10, 64)
+		hour := time.Hour.Milliseconds()
+		fmt.Fprint(w, "[")
+		for open, sep := (start+hour-1)/hour*hour, ""; open <= end; open, sep = open+hour, "," {
+			fmt.Fprintf(w, `%s[%d,"1","2","0.5","1.5","10",%d,"15",3,"4","6","0"]`, sep, open, open+hour-1)
+		}
+		fmt.Fprint(w, "]")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRunResumesFromCheckpoint(t *testing.T) {
+	var requests int32
+	srv := klinesServer(t, &requests)
+	client := aster.NewFuturesClient("", "", aster.WithBaseURL(srv.URL))
+	dir := t.TempDir()
+	newDownloader := func(format Format) *Downloader {
+		return NewDownloader(client, Config{Dir: dir, Format: format, Logger: log.New(io.Discard, "", 0)})
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	job := Job{Symbols: []string{"BTCUSDT"}, Datasets: []Dataset{DatasetKlines}, Intervals: []common.Interval{common.Interval1h}, Start: today.Add(-24 * time.Hour), End: today.Add(24 * time.Hour)}
+
+	report, err := newDownloader(FormatCSV).Run(context.Background(), job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 2 || report.Files[0].Rows != 24 || report.Files[0].Skipped {
+		t.Fatalf("got report %+v", report.Files)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "BTCUSDT", "klines_1h", today.Add(-24*time.Hour).Format("2006-01-02")+".csv")); err != nil {
+		t.Error(err)
+	}
+
+	// yesterday is recorded, today has not ended and is downloaded again
+	first := atomic.LoadInt32(&requests)
+	report, err = newDownloader(FormatCSV).Run(context.Background(), job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Files[0].Skipped || report.Files[1].Skipped {
+		t.Errorf("got report %+v", report.Files)
+	}
+	if n := atomic.LoadInt32(&requests) - first; n != first/2 {
+		t.Errorf("resumed run made %d requests, want %d", n, first/2)
+	}
+
+	// another format is another file
+	report, err = newDownloader(FormatNDJSONGzip).Run(context.Background(), job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Files[0].Skipped {
+		t.Errorf("skipped a day recorded for another format")
+	}
+
+	// a day recorded in part is downloaded again in full
+	partial := job
+	partial.Start, partial.End = today.Add(-48*time.Hour).Add(12*time.Hour), today.Add(-24*time.Hour)
+	if _, err = newDownloader(FormatCSV).Run(context.Background(), partial); err != nil {
+		t.Fatal(err)
+	}
+	job.Start = today.Add(-48 * time.Hour)
+	report, err = newDownloader(FormatCSV).Run(context.Background(), job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Files[0].Skipped || report.Files[0].Rows != 24 || !report.Files[1].Skipped {
+		t.Errorf("got report %+v", report.Files)
+	}
+}
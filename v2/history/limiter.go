@@ -0,0 +1,57 @@
+package history
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// weightLimiter paces requests so their total weight stays under a
+// per-minute budget. Tokens refill continuously.
+type weightLimiter struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+// newWeightLimiter creates a limiter for the given weight per minute
+func newWeightLimiter(perMinute int) *weightLimiter {
+	return &weightLimiter{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		rate:     float64(perMinute) / 60,
+		last:     time.Now(),
+	}
+}
+
+// wait blocks until weight tokens are available or ctx is done
+func (l *weightLimiter) wait(ctx context.Context, weight int) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.capacity {
+			l.tokens = l.capacity
+		}
+		l.last = now
+		need := float64(weight)
+		if need > l.capacity {
+			need = l.capacity
+		}
+		if l.tokens >= need {
+			l.tokens -= need
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((need - l.tokens) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
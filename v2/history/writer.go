@@ -0,0 +1,95 @@
+package history
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/drinkthere/go-aster/v2"
+)
+
+// writeFile writes rows to path. The file is written to a temporary name and
+// renamed on success so a crash never leaves a truncated file behind, and
+// the rename is synced before writeFile returns.
+func writeFile(path string, format Format, dataset Dataset, rows []row) (err error) {
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	bw := bufio.NewWriter(f)
+	if format == FormatNDJSONGzip {
+		err = writeNDJSONGzip(bw, rows)
+	} else {
+		err = writeCSV(bw, dataset, rows)
+	}
+	if err != nil {
+		return err
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir persists the entries of a directory, e.g. after a rename
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// writeCSV writes rows as CSV with a header line
+func writeCSV(w io.Writer, dataset Dataset, rows []row) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header(dataset)); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := cw.Write(r.fields); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeNDJSONGzip writes rows as gzip compressed newline delimited JSON
+func writeNDJSONGzip(w io.Writer, rows []row) error {
+	gw := gzip.NewWriter(w)
+	for _, r := range rows {
+		data, err := aster.JSON.Marshal(r.value)
+		if err != nil {
+			return err
+		}
+		if _, err = gw.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return gw.Close()
+}
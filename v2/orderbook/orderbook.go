@@ -0,0 +1,396 @@
+// Package orderbook maintains local order books synchronized from a REST
+// depth snapshot and the diff depth stream.
+//
+// A Book buffers diff events while it fetches the snapshot, applies the
+// buffered events that follow it and then every new event in sequence. A
+// break in the update id sequence clears the book and starts over from a new
+// snapshot.
+//
+//	book := orderbook.New(orderbook.Config{
+//		Market:   orderbook.MarketFutures,
+//		Symbol:   "BTCUSDT",
+//		Snapshot: orderbook.FuturesSnapshot(client, 1000),
+//	})
+//	defer book.Close()
+//	go book.Run(ctx)
+//
+//	bid, ok := book.BestBid()
+package orderbook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/common"
+	"github.com/drinkthere/go-aster/v2/futures"
+)
+
+// Market selects the sequencing rules of the diff stream
+type Market int
+
+const (
+	MarketFutures Market = iota
+	MarketSpot
+)
+
+// ErrStreamClosed is returned by Run when the depth stream ends before its
+// context is done
+var ErrStreamClosed = errors.New("orderbook: depth stream closed")
+
+// Snapshot is a REST depth snapshot
+type Snapshot struct {
+	LastUpdateID int64
+	Bids         [][]string
+	Asks         [][]string
+}
+
+// SnapshotFunc fetches a depth snapshot of a symbol
+type SnapshotFunc func(ctx context.Context, symbol string) (*Snapshot, error)
+
+// FuturesSnapshot fetches snapshots from the futures depth endpoint. A limit
+// of 0 means the server default.
+func FuturesSnapshot(api futures.MarketDataAPI, limit int) SnapshotFunc {
+	return func(ctx context.Context, symbol string) (*Snapshot, error) {
+		res, err := api.Depth(ctx, symbol, limit)
+		if err != nil {
+			return nil, err
+		}
+		return &Snapshot{LastUpdateID: res.LastUpdateID, Bids: res.Bids, Asks: res.Asks}, nil
+	}
+}
+
+// SpotSnapshot fetches snapshots from the spot depth endpoint. A limit of 0
+// means the server default.
+func SpotSnapshot(api aster.SpotMarketDataAPI, limit int) SnapshotFunc {
+	return func(ctx context.Context, symbol string) (*Snapshot, error) {
+		res, err := api.Depth(ctx, symbol, limit)
+		if err != nil {
+			return nil, err
+		}
+		return &Snapshot{LastUpdateID: res.LastUpdateID, Bids: res.Bids, Asks: res.Asks}, nil
+	}
+}
+
+// Config configures a Book
+type Config struct {
+	Market   Market
+	Symbol   string
+	Snapshot SnapshotFunc
+
+	// MaxBuffer caps the diff events buffered while a snapshot is fetched,
+	// the oldest being dropped first. Defaults to 1000.
+	MaxBuffer int
+
+	// OnUpdate is called after the book is synchronized and after every
+	// applied diff event. Calls are serialized.
+	OnUpdate func(b *Book)
+
+	// OnResync is called when a sequence gap clears the book
+	OnResync func(err error)
+
+	// ErrHandler receives snapshot and stream errors
+	ErrHandler aster.ErrHandler
+
+	// Options configure the depth stream of Run, e.g. WithStreamProxy
+	Options []aster.StreamOption
+}
+
+// Level is a price level of the book
+type Level struct {
+	Price    float64
+	Quantity float64
+}
+
+// GapError reports a break in the update id sequence of the diff stream
+type GapError struct {
+	Symbol   string
+	Expected int64
+	Got      int64
+}
+
+func (e *GapError) Error() string {
+	return fmt.Sprintf("orderbook: %s sequence gap: expected update id %d, got %d", e.Symbol, e.Expected, e.Got)
+}
+
+// errStale marks a diff event already contained in the book
+var errStale = errors.New("orderbook: stale event")
+
+// Book is a local order book. All methods are safe for concurrent use.
+type Book struct {
+	cfg    Config
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu           sync.RWMutex
+	bids         *side
+	asks         *side
+	lastUpdateID int64
+	updateTime   int64
+	synced       bool
+	first        bool // the next event must straddle the snapshot
+	fetching     bool
+	buffer       []*aster.WsDepthEvent
+
+	notifyMu sync.Mutex
+}
+
+// New creates a book. Diff events are fed by Run or by Handle.
+func New(cfg Config) *Book {
+	if cfg.MaxBuffer <= 0 {
+		cfg.MaxBuffer = 1000
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Book{
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+		bids:   newSide(true),
+		asks:   newSide(false),
+	}
+}
+
+// Run streams the diff events of the symbol into the book until ctx is done.
+// The stream reconnects according to aster.WsReconnect unless Options
+// override it.
+func (b *Book) Run(ctx context.Context) error {
+	var (
+		doneC, stopC chan struct{}
+		err          error
+	)
+	if b.cfg.Market == MarketSpot {
+		doneC, stopC, err = aster.WsSpotDepthServe(b.cfg.Symbol, b.Handle, b.error, b.cfg.Options...)
+	} else {
+		doneC, stopC, err = aster.WsFuturesDepthServe(b.cfg.Symbol, b.Handle, b.error, b.cfg.Options...)
+	}
+	if err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		close(stopC)
+		<-doneC
+		return ctx.Err()
+	case <-doneC:
+		return ErrStreamClosed
+	}
+}
+
+// Close stops synchronizing the book. Later events are ignored.
+func (b *Book) Close() {
+	b.cancel()
+}
+
+// Handle applies a diff event of the symbol, e.g. from a StreamClient
+// subscription. Events must be passed in stream order.
+func (b *Book) Handle(ev *aster.WsDepthEvent) {
+	if b.ctx.Err() != nil {
+		return
+	}
+	b.mu.Lock()
+	if !b.synced {
+		b.bufferEvent(ev)
+		start := !b.fetching
+		b.fetching = true
+		b.mu.Unlock()
+		if start {
+			go b.sync()
+		}
+		return
+	}
+	err := b.checkSequence(ev)
+	if errors.Is(err, errStale) {
+		b.mu.Unlock()
+		return
+	}
+	if err != nil {
+		b.reset()
+		b.bufferEvent(ev)
+		b.fetching = true
+		b.mu.Unlock()
+		if b.cfg.OnResync != nil {
+			b.cfg.OnResync(err)
+		}
+		go b.sync()
+		return
+	}
+	b.apply(ev)
+	b.mu.Unlock()
+	b.notify()
+}
+
+// Synced reports whether the book is synchronized with the stream
+func (b *Book) Synced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.synced
+}
+
+// LastUpdateID returns the update id of the last applied event or snapshot
+func (b *Book) LastUpdateID() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.lastUpdateID
+}
+
+// UpdateTime returns the event time in ms of the last applied event
+func (b *Book) UpdateTime() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.updateTime
+}
+
+// BestBid returns the highest bid
+func (b *Book) BestBid() (Level, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.bids.best()
+}
+
+// BestAsk returns the lowest ask
+func (b *Book) BestAsk() (Level, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.asks.best()
+}
+
+// Bids returns the n best bids, highest first. An n of 0 returns all bids.
+func (b *Book) Bids(n int) []Level {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.bids.top(n)
+}
+
+// Asks returns the n best asks, lowest first. An n of 0 returns all asks.
+func (b *Book) Asks(n int) []Level {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.asks.top(n)
+}
+
+// QuantityAt returns the quantity resting at a price, bids for
+// SideTypeBuy and asks for SideTypeSell
+func (b *Book) QuantityAt(sideType common.SideType, price float64) float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.side(sideType).qty[price]
+}
+
+// CumulativeQuantity returns the quantity resting at a price or better,
+// bids for SideTypeBuy and asks for SideTypeSell
+func (b *Book) CumulativeQuantity(sideType common.SideType, price float64) float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	s := b.side(sideType)
+	var total float64
+	for _, p := range s.prices {
+		if s.worse(p, price) {
+			break
+		}
+		total += s.qty[p]
+	}
+	return total
+}
+
+// side returns the bids for SideTypeBuy and the asks otherwise
+func (b *Book) side(sideType common.SideType) *side {
+	if sideType == common.SideTypeBuy {
+		return b.bids
+	}
+	return b.asks
+}
+
+// notify calls OnUpdate
+func (b *Book) notify() {
+	if b.cfg.OnUpdate == nil {
+		return
+	}
+	b.notifyMu.Lock()
+	defer b.notifyMu.Unlock()
+	b.cfg.OnUpdate(b)
+}
+
+// error reports an error to ErrHandler
+func (b *Book) error(err error) {
+	if b.cfg.ErrHandler != nil {
+		b.cfg.ErrHandler(err)
+	}
+}
+
+// side is one side of the book
+type side struct {
+	desc   bool      // bids sort highest first
+	prices []float64 // best first
+	qty    map[float64]float64
+}
+
+func newSide(desc bool) *side {
+	return &side{desc: desc, qty: map[float64]float64{}}
+}
+
+// worse reports whether price a is worse than price b
+func (s *side) worse(a, b float64) bool {
+	if s.desc {
+		return a < b
+	}
+	return a > b
+}
+
+// set sets the quantity at a price, removing the level on 0
+func (s *side) set(price, qty float64) {
+	i := sort.Search(len(s.prices), func(i int) bool { return !s.worse(price, s.prices[i]) })
+	found := i < len(s.prices) && s.prices[i] == price
+	if qty == 0 {
+		if found {
+			s.prices = append(s.prices[:i], s.prices[i+1:]...)
+			delete(s.qty, price)
+		}
+		return
+	}
+	if !found {
+		s.prices = append(s.prices, 0)
+		copy(s.prices[i+1:], s.prices[i:])
+		s.prices[i] = price
+	}
+	s.qty[price] = qty
+}
+
+func (s *side) best() (Level, bool) {
+	if len(s.prices) == 0 {
+		return Level{}, false
+	}
+	return Level{Price: s.prices[0], Quantity: s.qty[s.prices[0]]}, true
+}
+
+func (s *side) top(n int) []Level {
+	if n <= 0 || n > len(s.prices) {
+		n = len(s.prices)
+	}
+	levels := make([]Level, n)
+	for i, p := range s.prices[:n] {
+		levels[i] = Level{Price: p, Quantity: s.qty[p]}
+	}
+	return levels
+}
+
+func (s *side) reset() {
+	s.prices = nil
+	s.qty = map[float64]float64{}
+}
+
+// parseLevel parses a [price, quantity] pair
+func parseLevel(price, qty string) (float64, float64, error) {
+	p, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("orderbook: invalid price %q: %w", price, err)
+	}
+	q, err := strconv.ParseFloat(qty, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("orderbook: invalid quantity %q: %w", qty, err)
+	}
+	return p, q, nil
+}
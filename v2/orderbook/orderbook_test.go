@@ -0,0 +1,144 @@
+package orderbook
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2"
+)
+
+// testBook is a book fed by snapshots sent on snaps
+type testBook struct {
+	*Book
+	snaps   chan *Snapshot
+	updates chan int64
+	resyncs chan error
+}
+
+func newTestBook(t *testing.T, market Market) *testBook {
+	tb := &testBook{
+		snaps:   make(chan *Snapshot),
+		updates: make(chan int64, 100),
+		resyncs: make(chan error, 10),
+	}
+	tb.Book = New(Config{
+		Market: market,
+		Symbol: "BTCUSDT",
+		Snapshot: func(ctx context.Context, symbol string) (*Snapshot, error) {
+			select {
+			case snap := <-tb.snaps:
+				return snap, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		},
+		OnUpdate:   func(b *Book) { tb.updates <- b.lastUpdateID },
+		OnResync:   func(err error) { tb.resyncs <- err },
+		ErrHandler: func(err error) { t.Log(err) },
+	})
+	t.Cleanup(tb.Close)
+	return tb
+}
+
+// event returns a diff event setting the best bid to price
+func event(first, last, prev int64, price string) *aster.WsDepthEvent {
+	return &aster.WsDepthEvent{
+		Symbol:           "BTCUSDT",
+		FirstUpdateID:    first,
+		LastUpdateID:     last,
+		PrevLastUpdateID: prev,
+		Bids:             []aster.Bid{{Price: price, Quantity: "1"}},
+	}
+}
+
+func (tb *testBook) waitUpdate(t *testing.T, want int64) {
+	t.Helper()
+	select {
+	case got := <-tb.updates:
+		if got != want {
+			t.Fatalf("book at update id %d, want %d", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for update id %d", want)
+	}
+}
+
+func (tb *testBook) waitGap(t *testing.T, expected, got int64) {
+	t.Helper()
+	select {
+	case err := <-tb.resyncs:
+		var gap *GapError
+		if !errors.As(err, &gap) || gap.Expected != expected || gap.Got != got {
+			t.Fatalf("got resync %v, want a gap expecting %d got %d", err, expected, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a resync")
+	}
+}
+
+func (tb *testBook) checkBestBid(t *testing.T, want float64) {
+	t.Helper()
+	if bid, ok := tb.BestBid(); !ok || bid.Price != want {
+		t.Errorf("best bid %v, want %v", bid.Price, want)
+	}
+}
+
+func TestFuturesSequence(t *testing.T) {
+	tb := newTestBook(t, MarketFutures)
+	tb.Handle(event(90, 95, 89, "1"))    // contained in the snapshot
+	tb.Handle(event(98, 105, 95, "2"))   // U <= lastUpdateId <= u
+	tb.Handle(event(106, 110, 105, "3")) // pu is the previous u
+	tb.snaps <- &Snapshot{LastUpdateID: 100, Bids: [][]string{{"0.5", "1"}}}
+	tb.waitUpdate(t, 110)
+	tb.checkBestBid(t, 3)
+
+	tb.Handle(event(107, 110, 105, "9")) // stale
+	tb.Handle(event(111, 115, 110, "4"))
+	tb.waitUpdate(t, 115)
+	if len(tb.Bids(0)) != 4 {
+		t.Errorf("got bids %v", tb.Bids(0))
+	}
+
+	// pu does not match the last u
+	tb.Handle(event(120, 125, 118, "5"))
+	tb.waitGap(t, 115, 118)
+	if tb.Synced() || len(tb.Bids(0)) != 0 {
+		t.Errorf("book not cleared after a gap")
+	}
+	tb.Handle(event(126, 130, 125, "6"))
+	tb.snaps <- &Snapshot{LastUpdateID: 124}
+	tb.waitUpdate(t, 130)
+	if bids := tb.Bids(0); len(bids) != 2 || bids[0].Price != 6 {
+		t.Errorf("got bids %v after resync", bids)
+	}
+}
+
+func TestSpotSequence(t *testing.T) {
+	tb := newTestBook(t, MarketSpot)
+	tb.Handle(event(95, 100, 0, "1"))  // contained in the snapshot
+	tb.Handle(event(99, 103, 0, "2"))  // U <= lastUpdateId+1 <= u
+	tb.Handle(event(104, 106, 0, "3")) // U is the previous u+1
+	tb.snaps <- &Snapshot{LastUpdateID: 100}
+	tb.waitUpdate(t, 106)
+	tb.checkBestBid(t, 3)
+
+	tb.Handle(event(108, 110, 0, "4"))
+	tb.waitGap(t, 107, 108)
+	if tb.Synced() {
+		t.Errorf("book synced after a gap")
+	}
+}
+
+func TestSnapshotBehindStream(t *testing.T) {
+	tb := newTestBook(t, MarketSpot)
+	tb.Handle(event(105, 110, 0, "1"))
+	// the first buffered event does not straddle the snapshot, so another
+	// snapshot is fetched
+	tb.snaps <- &Snapshot{LastUpdateID: 100}
+	tb.Handle(event(111, 112, 0, "2"))
+	tb.snaps <- &Snapshot{LastUpdateID: 110}
+	tb.waitUpdate(t, 112)
+	tb.checkBestBid(t, 2)
+}
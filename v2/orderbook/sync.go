@@ -0,0 +1,166 @@
+package orderbook
+
+import (
+	"errors"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2"
+)
+
+// Bounds of the wait between snapshot attempts
+const (
+	minRetryDelay = 500 * time.Millisecond
+	maxRetryDelay = 30 * time.Second
+)
+
+// sync fetches snapshots until one lines up with the buffered events
+func (b *Book) sync() {
+	delay := minRetryDelay
+	for {
+		snap, err := b.cfg.Snapshot(b.ctx, b.cfg.Symbol)
+		if b.ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			b.mu.Lock()
+			err = b.load(snap)
+			if err == nil {
+				b.synced = true
+				b.fetching = false
+				b.mu.Unlock()
+				b.notify()
+				return
+			}
+			b.reset()
+			b.mu.Unlock()
+		}
+		b.error(err)
+		select {
+		case <-b.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// load replaces the book with a snapshot and applies the buffered events
+// that follow it
+func (b *Book) load(snap *Snapshot) error {
+	b.reset()
+	for _, lv := range snap.Bids {
+		if err := b.setLevel(b.bids, lv); err != nil {
+			return err
+		}
+	}
+	for _, lv := range snap.Asks {
+		if err := b.setLevel(b.asks, lv); err != nil {
+			return err
+		}
+	}
+	b.lastUpdateID = snap.LastUpdateID
+	b.first = true
+
+	buffer := b.buffer
+	b.buffer = nil
+	for _, ev := range buffer {
+		err := b.checkSequence(ev)
+		if errors.Is(err, errStale) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		b.apply(ev)
+	}
+	return nil
+}
+
+// checkSequence returns errStale for an event already contained in the book
+// and a *GapError for an event that does not follow it
+func (b *Book) checkSequence(ev *aster.WsDepthEvent) error {
+	if ev.LastUpdateID <= b.lastUpdateID {
+		return errStale
+	}
+	futures := b.cfg.Market == MarketFutures
+	switch {
+	case b.first && futures:
+		// U <= lastUpdateId <= u, or the event straight after the snapshot
+		if ev.FirstUpdateID <= b.lastUpdateID || ev.PrevLastUpdateID == b.lastUpdateID {
+			return nil
+		}
+		return &GapError{Symbol: b.cfg.Symbol, Expected: b.lastUpdateID, Got: ev.FirstUpdateID}
+	case b.first:
+		// U <= lastUpdateId+1 <= u
+		if ev.FirstUpdateID <= b.lastUpdateID+1 {
+			return nil
+		}
+		return &GapError{Symbol: b.cfg.Symbol, Expected: b.lastUpdateID + 1, Got: ev.FirstUpdateID}
+	case futures:
+		if ev.PrevLastUpdateID == b.lastUpdateID {
+			return nil
+		}
+		return &GapError{Symbol: b.cfg.Symbol, Expected: b.lastUpdateID, Got: ev.PrevLastUpdateID}
+	default:
+		if ev.FirstUpdateID == b.lastUpdateID+1 {
+			return nil
+		}
+		return &GapError{Symbol: b.cfg.Symbol, Expected: b.lastUpdateID + 1, Got: ev.FirstUpdateID}
+	}
+}
+
+// apply applies a diff event in sequence. Malformed levels are reported and
+// skipped.
+func (b *Book) apply(ev *aster.WsDepthEvent) {
+	for _, lv := range ev.Bids {
+		if err := b.setLevel(b.bids, []string{lv.Price, lv.Quantity}); err != nil {
+			b.error(err)
+		}
+	}
+	for _, lv := range ev.Asks {
+		if err := b.setLevel(b.asks, []string{lv.Price, lv.Quantity}); err != nil {
+			b.error(err)
+		}
+	}
+	b.lastUpdateID = ev.LastUpdateID
+	b.updateTime = ev.Time
+	b.first = false
+}
+
+// setLevel sets a [price, quantity] level of one side
+func (b *Book) setLevel(s *side, lv []string) error {
+	if len(lv) < 2 {
+		return errors.New("orderbook: malformed level")
+	}
+	price, qty, err := parseLevel(lv[0], lv[1])
+	if err != nil {
+		return err
+	}
+	s.set(price, qty)
+	return nil
+}
+
+// bufferEvent buffers an event until the next snapshot, dropping the oldest
+// beyond MaxBuffer
+func (b *Book) bufferEvent(ev *aster.WsDepthEvent) {
+	if len(b.buffer) >= b.cfg.MaxBuffer {
+		b.buffer = append(b.buffer[:0], b.buffer[1:]...)
+	}
+	// the event may be reused once Handle returns, see WithPooledEvents
+	cp := *ev
+	cp.Bids = append([]aster.Bid(nil), ev.Bids...)
+	cp.Asks = append([]aster.Ask(nil), ev.Asks...)
+	b.buffer = append(b.buffer, &cp)
+}
+
+// reset clears the book. Buffered events are kept.
+func (b *Book) reset() {
+	b.bids.reset()
+	b.asks.reset()
+	b.lastUpdateID = 0
+	b.updateTime = 0
+	b.synced = false
+	b.first = false
+}
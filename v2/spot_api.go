@@ -0,0 +1,308 @@
+package aster
+
+import (
+	"context"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// SpotMarketDataAPI is the spot market data API. A symbol of "" means all
+// symbols and a limit of 0 means the server default.
+type SpotMarketDataAPI interface {
+	Ping(ctx context.Context) error
+	ServerTime(ctx context.Context) (int64, error)
+	ExchangeInfo(ctx context.Context) (*SpotExchangeInfo, error)
+	Depth(ctx context.Context, symbol string, limit int) (*SpotDepthResponse, error)
+	RecentTrades(ctx context.Context, symbol string, limit int) ([]*SpotMarketTrade, error)
+	AggTrades(ctx context.Context, params SpotAggTradesParams) ([]*SpotAggTrade, error)
+	Klines(ctx context.Context, params SpotKlinesParams) ([]*SpotKline, error)
+	PriceChangeStats(ctx context.Context, symbol string) ([]*SpotPriceChangeStats, error)
+	Prices(ctx context.Context, symbol string) ([]*SpotSymbolPrice, error)
+	BookTickers(ctx context.Context, symbol string) ([]*SpotBookTicker, error)
+}
+
+// SpotTradingAPI is the spot trading API
+type SpotTradingAPI interface {
+	CreateOrder(ctx context.Context, params CreateSpotOrderParams) (*CreateSpotOrderResponse, error)
+	GetOrder(ctx context.Context, query SpotOrderQuery) (*SpotOrder, error)
+	CancelOrder(ctx context.Context, query SpotOrderQuery) (*CancelSpotOrderResponse, error)
+	CancelOpenOrders(ctx context.Context, symbol string) ([]*CancelSpotOrderResponse, error)
+	OpenOrders(ctx context.Context, symbol string) ([]*SpotOrder, error)
+	AllOrders(ctx context.Context, params ListSpotOrdersParams) ([]*SpotOrder, error)
+}
+
+// SpotAccountAPI is the spot account API
+type SpotAccountAPI interface {
+	Account(ctx context.Context) (*SpotAccount, error)
+	Trades(ctx context.Context, params ListSpotTradesParams) ([]*SpotTrade, error)
+}
+
+var (
+	_ SpotMarketDataAPI = (*SpotClient)(nil)
+	_ SpotTradingAPI    = (*SpotClient)(nil)
+	_ SpotAccountAPI    = (*SpotClient)(nil)
+)
+
+// SpotAggTradesParams are the parameters of AggTrades. Zero values are omitted.
+type SpotAggTradesParams struct {
+	Symbol    string
+	FromID    int64
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
+
+// SpotKlinesParams are the parameters of Klines. Zero values are omitted.
+type SpotKlinesParams struct {
+	Symbol    string
+	Interval  common.Interval
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
+
+// CreateSpotOrderParams are the parameters of CreateOrder. Zero values are omitted.
+type CreateSpotOrderParams struct {
+	Symbol           string
+	Side             common.SideType
+	Type             common.OrderType
+	TimeInForce      common.TimeInForceType
+	Quantity         string
+	QuoteOrderQty    string
+	Price            string
+	NewClientOrderID string
+	StopPrice        string
+	IcebergQty       string
+	NewOrderRespType common.NewOrderRespType
+}
+
+// SpotOrderQuery identifies an order by OrderID or OrigClientOrderID
+type SpotOrderQuery struct {
+	Symbol            string
+	OrderID           int64
+	OrigClientOrderID string
+}
+
+// ListSpotOrdersParams are the parameters of AllOrders. Zero values are omitted.
+type ListSpotOrdersParams struct {
+	Symbol    string
+	OrderID   int64
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
+
+// ListSpotTradesParams are the parameters of Trades. Zero values are omitted.
+type ListSpotTradesParams struct {
+	Symbol    string
+	OrderID   int64
+	StartTime int64
+	EndTime   int64
+	FromID    int64
+	Limit     int
+}
+
+// Ping tests connectivity
+func (c *SpotClient) Ping(ctx context.Context) error {
+	return c.NewPingService().Do(ctx)
+}
+
+// ServerTime returns the server time
+func (c *SpotClient) ServerTime(ctx context.Context) (int64, error) {
+	return c.NewServerTimeService().Do(ctx)
+}
+
+// ExchangeInfo returns the exchange info
+func (c *SpotClient) ExchangeInfo(ctx context.Context) (*SpotExchangeInfo, error) {
+	return c.NewExchangeInfoService().Do(ctx)
+}
+
+// Depth returns the order book of a symbol
+func (c *SpotClient) Depth(ctx context.Context, symbol string, limit int) (*SpotDepthResponse, error) {
+	s := c.NewDepthService().Symbol(symbol)
+	if limit > 0 {
+		s.Limit(limit)
+	}
+	return s.Do(ctx)
+}
+
+// RecentTrades returns the recent trades of a symbol
+func (c *SpotClient) RecentTrades(ctx context.Context, symbol string, limit int) ([]*SpotMarketTrade, error) {
+	s := c.NewRecentTradesListService().Symbol(symbol)
+	if limit > 0 {
+		s.Limit(limit)
+	}
+	return s.Do(ctx)
+}
+
+// AggTrades returns aggregate trades
+func (c *SpotClient) AggTrades(ctx context.Context, params SpotAggTradesParams) ([]*SpotAggTrade, error) {
+	s := c.NewAggTradesService().Symbol(params.Symbol)
+	if params.FromID > 0 {
+		s.FromID(params.FromID)
+	}
+	if params.StartTime > 0 {
+		s.StartTime(params.StartTime)
+	}
+	if params.EndTime > 0 {
+		s.EndTime(params.EndTime)
+	}
+	if params.Limit > 0 {
+		s.Limit(params.Limit)
+	}
+	return s.Do(ctx)
+}
+
+// Klines returns klines
+func (c *SpotClient) Klines(ctx context.Context, params SpotKlinesParams) ([]*SpotKline, error) {
+	s := c.NewKlinesService().Symbol(params.Symbol).Interval(params.Interval)
+	if params.StartTime > 0 {
+		s.StartTime(params.StartTime)
+	}
+	if params.EndTime > 0 {
+		s.EndTime(params.EndTime)
+	}
+	if params.Limit > 0 {
+		s.Limit(params.Limit)
+	}
+	return s.Do(ctx)
+}
+
+// PriceChangeStats returns 24hr price change statistics
+func (c *SpotClient) PriceChangeStats(ctx context.Context, symbol string) ([]*SpotPriceChangeStats, error) {
+	s := c.NewListPriceChangeStatsService()
+	if symbol != "" {
+		s.Symbol(symbol)
+	}
+	return s.Do(ctx)
+}
+
+// Prices returns the latest prices
+func (c *SpotClient) Prices(ctx context.Context, symbol string) ([]*SpotSymbolPrice, error) {
+	s := c.NewListPricesService()
+	if symbol != "" {
+		s.Symbol(symbol)
+	}
+	return s.Do(ctx)
+}
+
+// BookTickers returns the best price/qty on the order book
+func (c *SpotClient) BookTickers(ctx context.Context, symbol string) ([]*SpotBookTicker, error) {
+	s := c.NewListBookTickersService()
+	if symbol != "" {
+		s.Symbol(symbol)
+	}
+	return s.Do(ctx)
+}
+
+// CreateOrder places an order
+func (c *SpotClient) CreateOrder(ctx context.Context, params CreateSpotOrderParams) (*CreateSpotOrderResponse, error) {
+	s := c.NewCreateOrderService().Symbol(params.Symbol).Side(params.Side).Type(params.Type)
+	if params.TimeInForce != "" {
+		s.TimeInForce(params.TimeInForce)
+	}
+	if params.Quantity != "" {
+		s.Quantity(params.Quantity)
+	}
+	if params.QuoteOrderQty != "" {
+		s.QuoteOrderQty(params.QuoteOrderQty)
+	}
+	if params.Price != "" {
+		s.Price(params.Price)
+	}
+	if params.NewClientOrderID != "" {
+		s.NewClientOrderID(params.NewClientOrderID)
+	}
+	if params.StopPrice != "" {
+		s.StopPrice(params.StopPrice)
+	}
+	if params.IcebergQty != "" {
+		s.IcebergQty(params.IcebergQty)
+	}
+	if params.NewOrderRespType != "" {
+		s.NewOrderRespType(params.NewOrderRespType)
+	}
+	return s.Do(ctx)
+}
+
+// GetOrder returns an order
+func (c *SpotClient) GetOrder(ctx context.Context, query SpotOrderQuery) (*SpotOrder, error) {
+	s := c.NewGetOrderService().Symbol(query.Symbol)
+	if query.OrderID > 0 {
+		s.OrderID(query.OrderID)
+	}
+	if query.OrigClientOrderID != "" {
+		s.OrigClientOrderID(query.OrigClientOrderID)
+	}
+	return s.Do(ctx)
+}
+
+// CancelOrder cancels an order
+func (c *SpotClient) CancelOrder(ctx context.Context, query SpotOrderQuery) (*CancelSpotOrderResponse, error) {
+	s := c.NewCancelOrderService().Symbol(query.Symbol)
+	if query.OrderID > 0 {
+		s.OrderID(query.OrderID)
+	}
+	if query.OrigClientOrderID != "" {
+		s.OrigClientOrderID(query.OrigClientOrderID)
+	}
+	return s.Do(ctx)
+}
+
+// CancelOpenOrders cancels all open orders of a symbol
+func (c *SpotClient) CancelOpenOrders(ctx context.Context, symbol string) ([]*CancelSpotOrderResponse, error) {
+	return c.NewCancelOpenOrdersService().Symbol(symbol).Do(ctx)
+}
+
+// OpenOrders returns open orders
+func (c *SpotClient) OpenOrders(ctx context.Context, symbol string) ([]*SpotOrder, error) {
+	s := c.NewListOpenOrdersService()
+	if symbol != "" {
+		s.Symbol(symbol)
+	}
+	return s.Do(ctx)
+}
+
+// AllOrders returns all orders of a symbol
+func (c *SpotClient) AllOrders(ctx context.Context, params ListSpotOrdersParams) ([]*SpotOrder, error) {
+	s := c.NewListOrdersService().Symbol(params.Symbol)
+	if params.OrderID > 0 {
+		s.OrderID(params.OrderID)
+	}
+	if params.StartTime > 0 {
+		s.StartTime(params.StartTime)
+	}
+	if params.EndTime > 0 {
+		s.EndTime(params.EndTime)
+	}
+	if params.Limit > 0 {
+		s.Limit(params.Limit)
+	}
+	return s.Do(ctx)
+}
+
+// Account returns the account info
+func (c *SpotClient) Account(ctx context.Context) (*SpotAccount, error) {
+	return c.NewGetAccountService().Do(ctx)
+}
+
+// Trades returns the account trade history of a symbol
+func (c *SpotClient) Trades(ctx context.Context, params ListSpotTradesParams) ([]*SpotTrade, error) {
+	s := c.NewListTradesService().Symbol(params.Symbol)
+	if params.OrderID > 0 {
+		s.OrderId(params.OrderID)
+	}
+	if params.StartTime > 0 {
+		s.StartTime(params.StartTime)
+	}
+	if params.EndTime > 0 {
+		s.EndTime(params.EndTime)
+	}
+	if params.FromID > 0 {
+		s.FromId(params.FromID)
+	}
+	if params.Limit > 0 {
+		s.Limit(params.Limit)
+	}
+	return s.Do(ctx)
+}
@@ -1,9 +1,43 @@
 package aster
 
 import (
+	"time"
+
 	"github.com/drinkthere/go-aster/v2/common"
 )
 
+// SpotServices lists the REST service constructors of the spot client
+type SpotServices interface {
+	// Account and trading endpoints
+	NewCreateOrderService() *CreateSpotOrderService
+	NewGetOrderService() *GetSpotOrderService
+	NewCancelOrderService() *CancelSpotOrderService
+	NewCancelOpenOrdersService() *CancelOpenSpotOrdersService
+	NewListOpenOrdersService() *ListOpenSpotOrdersService
+	NewListOrdersService() *ListSpotOrdersService
+	NewGetAccountService() *GetSpotAccountService
+	NewListTradesService() *ListSpotTradesService
+
+	// Market data endpoints
+	NewPingService() *SpotPingService
+	NewServerTimeService() *SpotServerTimeService
+	NewExchangeInfoService() *SpotExchangeInfoService
+	NewDepthService() *SpotDepthService
+	NewAggTradesService() *SpotAggTradesService
+	NewRecentTradesListService() *SpotRecentTradesListService
+	NewKlinesService() *SpotKlinesService
+	NewListPriceChangeStatsService() *SpotListPriceChangeStatsService
+	NewListPricesService() *SpotListPricesService
+	NewListBookTickersService() *SpotListBookTickersService
+
+	// User stream endpoints
+	NewStartUserStreamService() *StartSpotUserStreamService
+	NewKeepaliveUserStreamService() *KeepaliveSpotUserStreamService
+	NewCloseUserStreamService() *CloseSpotUserStreamService
+}
+
+var _ SpotServices = (*SpotClient)(nil)
+
 // SpotClient defines spot client
 type SpotClient struct {
 	*BaseClient
@@ -103,55 +137,82 @@ func (c *SpotClient) NewCloseUserStreamService() *CloseSpotUserStreamService {
 }
 
 // WebSocket streams
-func (c *SpotClient) WsDepthServe(symbol string, handler WsDepthHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return c.WsDepthServeWithLocalAddr(symbol, handler, errHandler, c.LocalAddress)
+func (c *SpotClient) WsDepthServe(symbol string, handler WsDepthHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsSpotDepthServe(symbol, handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *SpotClient) WsKlineServe(symbol string, interval common.Interval, handler WsSpotKlineHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsSpotKlineServe(symbol, string(interval), handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *SpotClient) WsAggTradeServe(symbol string, handler WsSpotAggTradeHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsSpotAggTradeServe(symbol, handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *SpotClient) WsBookTickerServe(symbol string, handler WsBookTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsSpotBookTickerServe(symbol, handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *SpotClient) WsAllMarketsStatServe(handler WsSpotAllMarketsStatHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsSpotAllMarketsStatServe(handler, errHandler, c.streamOptions(opts)...)
 }
 
-func (c *SpotClient) WsKlineServe(symbol string, interval common.Interval, handler WsSpotKlineHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return c.WsKlineServeWithLocalAddr(symbol, string(interval), handler, errHandler, c.LocalAddress)
+func (c *SpotClient) WsPartialDepthServe(symbol string, levels int, speed time.Duration, handler WsSpotPartialDepthHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsSpotPartialDepthServeWithSpeed(symbol, levels, speed, handler, errHandler, c.streamOptions(opts)...)
 }
 
-func (c *SpotClient) WsAggTradeServe(symbol string, handler WsSpotAggTradeHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return c.WsAggTradeServeWithLocalAddr(symbol, handler, errHandler, c.LocalAddress)
+func (c *SpotClient) WsTradeServe(symbol string, handler WsSpotTradeHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsSpotTradeServe(symbol, handler, errHandler, c.streamOptions(opts)...)
 }
 
-func (c *SpotClient) WsBookTickerServe(symbol string, handler WsBookTickerHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return c.WsBookTickerServeWithLocalAddr(symbol, handler, errHandler, c.LocalAddress)
+func (c *SpotClient) WsMiniMarketTickerServe(symbol string, handler WsSpotMiniMarketTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsSpotMiniMarketTickerServe(symbol, handler, errHandler, c.streamOptions(opts)...)
 }
 
-func (c *SpotClient) WsAllMarketsStatServe(handler WsSpotAllMarketsStatHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return c.WsAllMarketsStatServeWithLocalAddr(handler, errHandler, c.LocalAddress)
+func (c *SpotClient) WsAllMiniMarketTickerServe(handler WsSpotAllMiniMarketTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsSpotAllMiniMarketTickerServe(handler, errHandler, c.streamOptions(opts)...)
 }
 
-func (c *SpotClient) WsUserDataServe(listenKey string, handler WsSpotUserDataHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return c.WsUserDataServeWithLocalAddr(listenKey, handler, errHandler, c.LocalAddress)
+func (c *SpotClient) WsMarketStatServe(symbol string, handler WsSpotMarketStatHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsSpotMarketStatServe(symbol, handler, errHandler, c.streamOptions(opts)...)
+}
+
+func (c *SpotClient) WsUserDataServe(listenKey string, handler WsSpotUserDataHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsSpotUserDataServe(listenKey, handler, errHandler, c.streamOptions(opts)...)
 }
 
 // WebSocket streams with LocalAddress support
+
+// Deprecated: use WsDepthServe with WithStreamLocalAddress.
 func (c *SpotClient) WsDepthServeWithLocalAddr(symbol string, handler WsDepthHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	return WsSpotDepthServeWithLocalAddr(symbol, handler, errHandler, localAddr)
+	return WsSpotDepthServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
+// Deprecated: use WsKlineServe with WithStreamLocalAddress.
 func (c *SpotClient) WsKlineServeWithLocalAddr(symbol string, interval string, handler WsSpotKlineHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	return WsSpotKlineServeWithLocalAddr(symbol, interval, handler, errHandler, localAddr)
+	return WsSpotKlineServe(symbol, interval, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
+// Deprecated: use WsAggTradeServe with WithStreamLocalAddress.
 func (c *SpotClient) WsAggTradeServeWithLocalAddr(symbol string, handler WsSpotAggTradeHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	return WsSpotAggTradeServeWithLocalAddr(symbol, handler, errHandler, localAddr)
+	return WsSpotAggTradeServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
+// Deprecated: use WsBookTickerServe with WithStreamLocalAddress.
 func (c *SpotClient) WsBookTickerServeWithLocalAddr(symbol string, handler WsBookTickerHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	return WsSpotBookTickerServeWithLocalAddr(symbol, handler, errHandler, localAddr)
+	return WsSpotBookTickerServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
+// Deprecated: use WsAllMarketsStatServe with WithStreamLocalAddress.
 func (c *SpotClient) WsAllMarketsStatServeWithLocalAddr(handler WsSpotAllMarketsStatHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	return WsSpotAllMarketsStatServeWithLocalAddr(handler, errHandler, localAddr)
+	return WsSpotAllMarketsStatServe(handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
+// Deprecated: use WsUserDataServe with WithStreamLocalAddress.
 func (c *SpotClient) WsUserDataServeWithLocalAddr(listenKey string, handler WsSpotUserDataHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	return WsSpotUserDataServeWithLocalAddr(listenKey, handler, errHandler, localAddr)
+	return WsSpotUserDataServe(listenKey, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
-func (c *SpotClient) WsCombinedBookTickerServe(symbols []string, handler WsBookTickerHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	return WsCombinedSpotBookTickerServeWithLocalAddr(symbols, handler, errHandler, c.LocalAddress)
-}
\ No newline at end of file
+func (c *SpotClient) WsCombinedBookTickerServe(symbols []string, handler WsBookTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsCombinedSpotBookTickerServe(symbols, handler, errHandler, c.streamOptions(opts)...)
+}
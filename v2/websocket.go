@@ -1,8 +1,15 @@
 package aster
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -16,22 +23,66 @@ type ErrHandler func(err error)
 
 // WsConfig webservice configuration
 type WsConfig struct {
-	Endpoint string
-	IP       string
-	Resolver *net.Resolver
+	Endpoint  string
+	IP        string
+	Resolver  *net.Resolver
+	Reconnect *ReconnectConfig // nil closes doneC on the first disconnect
+	Heartbeat *HeartbeatConfig // the defaults when nil
+	Recorder  *StreamRecorder  // records the received frames when set
+	Replayer  *StreamReplayer  // serves the stream from a recording when set
+
+	Proxy            func(*http.Request) (*url.URL, error) // http.ProxyFromEnvironment when nil
+	TLSConfig        *tls.Config
+	Header           http.Header   // extra handshake headers
+	DialTimeout      time.Duration // no timeout when 0
+	HandshakeTimeout time.Duration // 45s by default
+	ReadBufferSize   int           // 4096 by default
+	WriteBufferSize  int           // 4096 by default
+	ReadLimit        int64         // maximum message size, no limit when 0
+	Compression      bool          // negotiates permessage-deflate
+
+	PooledEvents bool // reuses depth, bookTicker, aggTrade and markPrice events
+
+	market   string // "spot" or "futures", set by the serve functions
+	userData bool   // the endpoint holds a listen key
+}
+
+func newWsConfig(endpoint string, opts ...StreamOption) *WsConfig {
+	cfg := &WsConfig{
+		Endpoint:  endpoint,
+		Reconnect: WsReconnect,
+		Heartbeat: WsHeartbeat,
+		Recorder:  WsRecorder,
+		Replayer:  WsReplayer,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// newMarketWsConfig returns the configuration of a stream of the spot or
+// futures market
+func newMarketWsConfig(futures bool, endpoint string, opts ...StreamOption) *WsConfig {
+	cfg := newWsConfig(endpoint, opts...)
+	cfg.market = marketName(futures)
+	return cfg
 }
 
-func newWsConfig(endpoint string) *WsConfig {
-	return &WsConfig{
-		Endpoint: endpoint,
+// marketName returns the recorded name of the spot or futures market
+func marketName(futures bool) string {
+	if futures {
+		return "futures"
 	}
+	return "spot"
 }
 
-func newWsConfigWithIP(endpoint string, localIP string) *WsConfig {
-	return &WsConfig{
-		Endpoint: endpoint,
-		IP:       localIP,
+func newWsConfigWithIP(endpoint string, localIP string, opts ...StreamOption) *WsConfig {
+	cfg := newWsConfig(endpoint, opts...)
+	if localIP != "" {
+		cfg.IP = localIP
 	}
+	return cfg
 }
 
 func (cfg *WsConfig) WithIP(ip string) {
@@ -42,87 +93,427 @@ func (cfg *WsConfig) WithResolver(resolver *net.Resolver) {
 	cfg.Resolver = resolver
 }
 
-var wsServe = func(cfg *WsConfig, handler WsHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	var Dialer websocket.Dialer
-	if cfg.IP == "" {
-		Dialer = websocket.Dialer{
-			Proxy:             http.ProxyFromEnvironment,
-			HandshakeTimeout:  45 * time.Second,
-			EnableCompression: false,
-		}
-	} else {
-		Dialer = websocket.Dialer{
-			NetDial: func(network, addr string) (net.Conn, error) {
-				localAddr, err := net.ResolveTCPAddr("tcp", cfg.IP+":0")
-				if err != nil {
-					return nil, err
-				}
-				d := net.Dialer{LocalAddr: localAddr}
-				return d.Dial(network, addr)
-			},
-			Proxy:             http.ProxyFromEnvironment,
-			HandshakeTimeout:  45 * time.Second,
-			EnableCompression: false,
+// WsReconnect enables managed streams for every Ws*Serve call started after
+// it is set. Managed streams reconnect with exponential backoff until
+// stopC is closed or the attempts run out, and doneC only closes then.
+var WsReconnect *ReconnectConfig
+
+// ErrMaxConnectionAge is reported to OnDisconnected when a managed stream
+// replaces a connection before the server's 24h limit
+var ErrMaxConnectionAge = errors.New("websocket connection reached its maximum age")
+
+// ReconnectConfig configures managed streams. The zero value uses the
+// defaults documented on each field.
+type ReconnectConfig struct {
+	InitialBackoff   time.Duration // delay before the first attempt, 500ms by default
+	MaxBackoff       time.Duration // cap of the doubling delay, 30s by default
+	Jitter           float64       // random fraction added or removed from each delay, 0.2 by default, negative disables it
+	MaxAttempts      int           // consecutive failed attempts before giving up, 0 retries forever
+	MaxConnectionAge time.Duration // age at which a connection is replaced, 23h by default
+
+	OnConnected    func(endpoint string)
+	OnDisconnected func(endpoint string, err error)
+	OnReconnecting func(endpoint string, attempt int, delay time.Duration)
+	OnGaveUp       func(endpoint string, err error)
+}
+
+// backoff returns the delay before a reconnect attempt, starting at 1
+func (rc *ReconnectConfig) backoff(attempt int) time.Duration {
+	initial, max, jitter := rc.InitialBackoff, rc.MaxBackoff, rc.Jitter
+	if initial <= 0 {
+		initial = 500 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	if jitter == 0 {
+		jitter = 0.2
+	} else if jitter < 0 {
+		jitter = 0
+	}
+	d := initial
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return time.Duration(float64(d) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
+// maxConnectionAge returns the age at which a connection is replaced
+func (rc *ReconnectConfig) maxConnectionAge() time.Duration {
+	if rc.MaxConnectionAge > 0 {
+		return rc.MaxConnectionAge
+	}
+	return 23 * time.Hour
+}
+
+// WsHeartbeat configures the heartbeat of every Ws*Serve call started
+// after it is set
+var WsHeartbeat *HeartbeatConfig
+
+// Errors reported when the heartbeat closes a connection. Managed streams
+// reconnect after them like after any other disconnect, and the watchdog
+// forces a reconnect of unmanaged streams too.
+var (
+	ErrPongTimeout = errors.New("websocket pong timeout")
+	ErrStreamStale = errors.New("websocket stream stale")
+)
+
+// HeartbeatConfig configures the keepalive pings and the stale stream
+// watchdog. The zero value uses the defaults documented on each field.
+type HeartbeatConfig struct {
+	PingInterval time.Duration // delay between client pings, 5s by default
+	PongTimeout  time.Duration // wait for a pong or server ping past the next ping, 5s by default
+
+	// MaxSilence closes a connection that stays alive but delivers no data
+	// message for that long, 0 disables the watchdog. Leave it at 0 for
+	// streams that may legitimately be quiet, like user data streams.
+	// Streams without Reconnect replace the closed connection with the
+	// default ReconnectConfig instead of ending.
+	MaxSilence time.Duration
+
+	// MaxSilenceFunc overrides MaxSilence per endpoint, e.g. a shorter
+	// threshold for the depth stream of a liquid symbol
+	MaxSilenceFunc func(endpoint string) time.Duration
+}
+
+// pingInterval returns the delay between client pings
+func (hc *HeartbeatConfig) pingInterval() time.Duration {
+	if hc != nil && hc.PingInterval > 0 {
+		return hc.PingInterval
+	}
+	return 5 * time.Second
+}
+
+// readTimeout returns how long a connection may go without a pong or a
+// server ping
+func (hc *HeartbeatConfig) readTimeout() time.Duration {
+	if hc != nil && hc.PongTimeout > 0 {
+		return hc.pingInterval() + hc.PongTimeout
+	}
+	return hc.pingInterval() + 5*time.Second
+}
+
+// maxSilence returns the watchdog threshold of an endpoint, 0 when disabled
+func (hc *HeartbeatConfig) maxSilence(endpoint string) time.Duration {
+	if hc == nil {
+		return 0
+	}
+	if hc.MaxSilenceFunc != nil {
+		return hc.MaxSilenceFunc(endpoint)
+	}
+	return hc.MaxSilence
+}
+
+// withoutWatchdog returns the config with the stale stream watchdog
+// disabled, for connections that may legitimately be quiet
+func (hc *HeartbeatConfig) withoutWatchdog() *HeartbeatConfig {
+	if hc == nil {
+		return nil
+	}
+	return &HeartbeatConfig{PingInterval: hc.PingInterval, PongTimeout: hc.PongTimeout}
+}
+
+// wsDial opens a websocket connection
+func wsDial(cfg *WsConfig) (*websocket.Conn, error) {
+	netDialer := &net.Dialer{Timeout: cfg.DialTimeout, Resolver: cfg.Resolver}
+	if cfg.IP != "" {
+		localAddr, err := net.ResolveTCPAddr("tcp", cfg.IP+":0")
+		if err != nil {
+			return nil, err
 		}
+		netDialer.LocalAddr = localAddr
+	}
+	proxy := cfg.Proxy
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+	handshakeTimeout := cfg.HandshakeTimeout
+	if handshakeTimeout <= 0 {
+		handshakeTimeout = 45 * time.Second
+	}
+	dialer := websocket.Dialer{
+		NetDialContext:    netDialer.DialContext,
+		Proxy:             proxy,
+		TLSClientConfig:   cfg.TLSConfig,
+		HandshakeTimeout:  handshakeTimeout,
+		ReadBufferSize:    cfg.ReadBufferSize,
+		WriteBufferSize:   cfg.WriteBufferSize,
+		EnableCompression: cfg.Compression,
 	}
 
-	c, _, err := Dialer.Dial(cfg.Endpoint, nil)
+	c, _, err := dialer.Dial(cfg.Endpoint, cfg.Header)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ReadLimit > 0 {
+		c.SetReadLimit(cfg.ReadLimit)
+	}
+	return c, nil
+}
+
+var wsServe = func(cfg *WsConfig, handler WsHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+	if cfg.Replayer != nil {
+		return cfg.Replayer.serve(cfg, handler)
+	}
+	c, err := wsDial(cfg)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	doneC = make(chan struct{})
 	stopC = make(chan struct{})
+	s := &wsStream{cfg: cfg, handler: handler, errHandler: errHandler, stopC: stopC}
 	go func() {
-		defer func() {
-			c.Close()
-			close(doneC)
-		}()
-		
-		c.SetReadDeadline(time.Now().Add(10 * time.Second))
-		c.SetPongHandler(func(string) error {
-			c.SetReadDeadline(time.Now().Add(10 * time.Second))
+		defer close(doneC)
+		s.run(c)
+	}()
+	return doneC, stopC, nil
+}
+
+// wsStream serves one stream over one or, when managed, successive
+// connections
+type wsStream struct {
+	cfg        *WsConfig
+	handler    WsHandler
+	errHandler ErrHandler
+	stopC      chan struct{}
+	onConnect  func(c *websocket.Conn) // called with each replacement connection before it is read
+}
+
+// stopped reports whether stopC is closed
+func (s *wsStream) stopped() bool {
+	select {
+	case <-s.stopC:
+		return true
+	default:
+		return false
+	}
+}
+
+// run serves connections until the stream stops or gives up
+func (s *wsStream) run(c *websocket.Conn) {
+	rc := s.cfg.Reconnect
+	endpoint := s.cfg.Endpoint
+	if rc != nil && rc.OnConnected != nil {
+		rc.OnConnected(endpoint)
+	}
+	for {
+		next, err := s.serveConn(c)
+		if s.stopped() {
+			if next != nil {
+				next.Close()
+			}
+			return
+		}
+		if rc == nil {
+			// The watchdog closed a connection the server still holds
+			// open, so the caller could not tell it apart from a dead
+			// stream: replace it with the default reconnect settings
+			if !errors.Is(err, ErrStreamStale) {
+				return
+			}
+			if c = s.reconnect(&ReconnectConfig{}, err); c == nil {
+				return
+			}
+			continue
+		}
+		if next != nil {
+			err = ErrMaxConnectionAge
+		}
+		if rc.OnDisconnected != nil {
+			rc.OnDisconnected(endpoint, err)
+		}
+		if next == nil {
+			if next = s.reconnect(rc, err); next == nil {
+				return
+			}
+		}
+		c = next
+		if rc.OnConnected != nil {
+			rc.OnConnected(endpoint)
+		}
+	}
+}
+
+// reconnect dials with backoff until it succeeds, the stream stops or the
+// attempts run out
+func (s *wsStream) reconnect(rc *ReconnectConfig, err error) *websocket.Conn {
+	endpoint := s.cfg.Endpoint
+	for attempt := 1; ; attempt++ {
+		if rc.MaxAttempts > 0 && attempt > rc.MaxAttempts {
+			if rc.OnGaveUp != nil {
+				rc.OnGaveUp(endpoint, err)
+			}
 			return nil
-		})
-		
-		for {
-			select {
-			case <-stopC:
+		}
+		delay := rc.backoff(attempt)
+		if rc.OnReconnecting != nil {
+			rc.OnReconnecting(endpoint, attempt, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-s.stopC:
+			timer.Stop()
+			return nil
+		}
+		var c *websocket.Conn
+		if c, err = wsDial(s.cfg); err == nil {
+			if s.onConnect != nil {
+				s.onConnect(c)
+			}
+			return c
+		}
+		if s.errHandler != nil {
+			s.errHandler(err)
+		}
+	}
+}
+
+// serveConn reads c until it fails or the stream stops. Managed streams
+// dial a replacement before c reaches its maximum age and return it as
+// next once c is closed.
+func (s *wsStream) serveConn(c *websocket.Conn) (next *websocket.Conn, err error) {
+	var (
+		mu      sync.Mutex
+		closed  bool
+		pending *websocket.Conn
+	)
+	connDone := make(chan struct{})
+	defer func() {
+		mu.Lock()
+		closed = true
+		next = pending
+		mu.Unlock()
+		close(connDone)
+		c.Close()
+	}()
+
+	if rc := s.cfg.Reconnect; rc != nil {
+		timer := time.AfterFunc(rc.maxConnectionAge(), func() {
+			nc, err := wsDial(s.cfg)
+			if err != nil {
+				// Keep the old connection, the regular reconnect takes
+				// over when the server closes it
 				return
-			default:
-				messageType, message, err := c.ReadMessage()
-				if err != nil {
-					if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-						if errHandler != nil {
-							errHandler(err)
-						}
-					}
-					return
-				}
-				
-				if messageType == websocket.TextMessage {
-					handler(message)
-				}
 			}
+			if s.onConnect != nil {
+				s.onConnect(nc)
+			}
+			mu.Lock()
+			if closed {
+				mu.Unlock()
+				nc.Close()
+				return
+			}
+			pending = nc
+			mu.Unlock()
+			c.Close()
+		})
+		defer timer.Stop()
+	}
+
+	go func() {
+		select {
+		case <-s.stopC:
+			c.Close()
+		case <-connDone:
 		}
 	}()
-	
+
+	hc := s.cfg.Heartbeat
+	readTimeout := hc.readTimeout()
+	c.SetReadDeadline(time.Now().Add(readTimeout))
+	c.SetPongHandler(func(string) error {
+		c.SetReadDeadline(time.Now().Add(readTimeout))
+		return nil
+	})
+	c.SetPingHandler(func(data string) error {
+		c.SetReadDeadline(time.Now().Add(readTimeout))
+		err := c.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(readTimeout))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		if e, ok := err.(net.Error); ok && e.Timeout() {
+			return nil
+		}
+		return err
+	})
+
 	go func() {
-		ticker := time.NewTicker(5 * time.Second)
+		ticker := time.NewTicker(hc.pingInterval())
 		defer ticker.Stop()
-		
+
 		for {
 			select {
 			case <-ticker.C:
-				err := c.WriteMessage(websocket.PingMessage, nil)
+				err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(readTimeout))
 				if err != nil {
 					return
 				}
-			case <-stopC:
+			case <-connDone:
 				return
 			}
 		}
 	}()
-	
-	return doneC, stopC, nil
-}
\ No newline at end of file
+
+	var (
+		lastData atomic.Int64
+		stale    atomic.Pointer[error]
+	)
+	lastData.Store(time.Now().UnixNano())
+	if maxSilence := hc.maxSilence(s.cfg.Endpoint); maxSilence > 0 {
+		go func() {
+			ticker := time.NewTicker(maxSilence / 4)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					silence := time.Since(time.Unix(0, lastData.Load()))
+					if silence > maxSilence {
+						err := fmt.Errorf("%w: no data for %s", ErrStreamStale, silence.Round(time.Millisecond))
+						stale.Store(&err)
+						c.Close()
+						return
+					}
+				case <-connDone:
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		messageType, message, err := c.ReadMessage()
+		if err != nil {
+			mu.Lock()
+			rotated := pending != nil
+			mu.Unlock()
+			if rotated || s.stopped() {
+				return nil, err
+			}
+			if staleErr := stale.Load(); staleErr != nil {
+				err = *staleErr
+			} else if e, ok := err.(net.Error); ok && e.Timeout() {
+				err = fmt.Errorf("%w: %v", ErrPongTimeout, err)
+			}
+			if s.errHandler != nil {
+				s.errHandler(err)
+			}
+			return nil, err
+		}
+
+		if messageType == websocket.TextMessage {
+			now := time.Now()
+			lastData.Store(now.UnixNano())
+			if s.cfg.Recorder != nil {
+				s.cfg.Recorder.record(s.cfg, message, now)
+			}
+			s.handler(message)
+		}
+	}
+}
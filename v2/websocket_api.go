@@ -0,0 +1,387 @@
+package aster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// ErrWsAPIClientClosed is returned by requests on a closed WsAPIClient
+var ErrWsAPIClientClosed = errors.New("websocket API client closed")
+
+// WsAPIClient sends requests over one persistent websocket API connection
+// and matches the responses by id. Signed requests are signed like REST
+// requests, with HMAC or Web3 depending on the client. Set the exported
+// fields before calling Connect.
+type WsAPIClient struct {
+	// RequestTimeout bounds requests whose context has no deadline,
+	// 10s by default
+	RequestTimeout time.Duration
+	// LocalAddress binds the connection to a local IP address
+	LocalAddress string
+	// Endpoint overrides the websocket API URL of the market,
+	// BaseWsAPIMainURL or BaseWsAPIFuturesURL
+	Endpoint string
+	// Options configure the connection, e.g. WithStreamProxy
+	Options []StreamOption
+
+	c          *BaseClient
+	isFutures  bool
+	errHandler ErrHandler
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	pending map[int64]chan *wsStreamResponse
+	nextID  int64
+	stopC   chan struct{}
+	doneC   chan struct{}
+}
+
+// wsAPIRequest is a websocket API request
+type wsAPIRequest struct {
+	ID     int64       `json:"id"`
+	Method string      `json:"method"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+// wsAPIResponse is a websocket API response
+type wsAPIResponse struct {
+	ID     *int64           `json:"id"`
+	Status int              `json:"status"`
+	Result json.RawMessage  `json:"result"`
+	Error  *common.APIError `json:"error"`
+}
+
+// NewSpotWsAPIClient creates a websocket API client for spot trading
+func NewSpotWsAPIClient(c *BaseClient, errHandler ErrHandler) *WsAPIClient {
+	return newWsAPIClient(c, false, errHandler)
+}
+
+// NewFuturesWsAPIClient creates a websocket API client for futures trading
+func NewFuturesWsAPIClient(c *BaseClient, errHandler ErrHandler) *WsAPIClient {
+	return newWsAPIClient(c, true, errHandler)
+}
+
+func newWsAPIClient(c *BaseClient, isFutures bool, errHandler ErrHandler) *WsAPIClient {
+	return &WsAPIClient{
+		LocalAddress: c.LocalAddress,
+		c:            c,
+		isFutures:    isFutures,
+		errHandler:   errHandler,
+		pending:      map[int64]chan *wsStreamResponse{},
+	}
+}
+
+// Connect opens the connection. With WsReconnect set, the client
+// reconnects until Close; requests in flight during a reconnect time out.
+func (c *WsAPIClient) Connect() error {
+	endpoint := BaseWsAPIMainURL
+	if c.isFutures {
+		endpoint = BaseWsAPIFuturesURL
+	}
+	if c.Endpoint != "" {
+		endpoint = c.Endpoint
+	}
+	cfg := newWsConfigWithIP(endpoint, c.LocalAddress, c.Options...)
+	// responses only follow requests, silence does not mean the stream is stale
+	cfg.Heartbeat = cfg.Heartbeat.withoutWatchdog()
+	cfg.Recorder = nil
+	conn, err := wsDial(cfg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.conn = conn
+	c.stopC = make(chan struct{})
+	c.doneC = make(chan struct{})
+	c.mu.Unlock()
+
+	s := &wsStream{
+		cfg:        cfg,
+		handler:    c.route,
+		errHandler: c.errHandler,
+		stopC:      c.stopC,
+		onConnect:  c.setConn,
+	}
+	go func() {
+		defer close(c.doneC)
+		s.run(conn)
+		c.failPending(ErrWsAPIClientClosed)
+	}()
+	return nil
+}
+
+// Close closes the connection and waits for the client to stop
+func (c *WsAPIClient) Close() {
+	c.mu.Lock()
+	stopC, doneC := c.stopC, c.doneC
+	c.mu.Unlock()
+	if stopC == nil {
+		return
+	}
+	select {
+	case <-stopC:
+	default:
+		close(stopC)
+	}
+	<-doneC
+}
+
+// Done returns a channel closed once the client stops, after Close or when
+// the connection is lost for good
+func (c *WsAPIClient) Done() <-chan struct{} {
+	return c.doneC
+}
+
+// Call sends a request and returns the result of its response. Signed
+// requests get the authentication parameters and signature added.
+func (c *WsAPIClient) Call(ctx context.Context, method string, p map[string]interface{}, signed bool) (json.RawMessage, error) {
+	var reqParams interface{} = p
+	if signed {
+		signedParams, err := c.sign(p)
+		if err != nil {
+			return nil, err
+		}
+		reqParams = signedParams
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := c.RequestTimeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	c.mu.Lock()
+	if c.doneC == nil || isClosed(c.doneC) {
+		c.mu.Unlock()
+		return nil, ErrWsAPIClientClosed
+	}
+	c.nextID++
+	id := c.nextID
+	respC := make(chan *wsStreamResponse, 1)
+	c.pending[id] = respC
+	conn := c.conn
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	data, err := json.Marshal(&wsAPIRequest{ID: id, Method: method, Params: reqParams})
+	if err != nil {
+		return nil, err
+	}
+	if c.c.Debug {
+		c.c.Logger.Printf("Websocket API request: %s", data)
+	}
+	writeDeadline, _ := ctx.Deadline()
+	c.writeMu.Lock()
+	conn.SetWriteDeadline(writeDeadline)
+	err = conn.WriteMessage(websocket.TextMessage, data)
+	c.writeMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-respC:
+		return resp.result, resp.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// sign returns the parameters of a signed request, authenticated the same
+// way as the REST requests of the client
+func (c *WsAPIClient) sign(p map[string]interface{}) (map[string]string, error) {
+	r := newRequest(http.MethodGet, "", secTypeSigned)
+	if c.c.SignatureType != common.SignatureTypeWeb3 {
+		r.setParam("apiKey", c.c.APIKey)
+	}
+	r.setParams(p)
+	if err := c.c.parseRequest(r); err != nil {
+		return nil, err
+	}
+	query := ""
+	if i := strings.IndexByte(r.fullURL, '?'); i >= 0 {
+		query = r.fullURL[i+1:]
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	signed := make(map[string]string, len(values))
+	for k, v := range values {
+		signed[k] = v[0]
+	}
+	return signed, nil
+}
+
+// setConn switches to a replacement connection
+func (c *WsAPIClient) setConn(conn *websocket.Conn) {
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+}
+
+// route hands a response to the waiter of its request
+func (c *WsAPIClient) route(message []byte) {
+	if c.c.Debug {
+		c.c.Logger.Printf("Websocket API response: %s", message)
+	}
+	var frame wsAPIResponse
+	if err := json.Unmarshal(message, &frame); err != nil {
+		if c.errHandler != nil {
+			c.errHandler(err)
+		}
+		return
+	}
+	if frame.ID == nil {
+		return
+	}
+	resp := &wsStreamResponse{result: frame.Result}
+	if frame.Error != nil {
+		frame.Error.StatusCode = frame.Status
+		resp.err = frame.Error
+	} else if frame.Status >= http.StatusBadRequest {
+		resp.err = &common.APIError{
+			Message:    fmt.Sprintf("request failed with status %d", frame.Status),
+			StatusCode: frame.Status,
+		}
+	}
+	c.mu.Lock()
+	respC := c.pending[*frame.ID]
+	c.mu.Unlock()
+	if respC != nil {
+		select {
+		case respC <- resp:
+		default:
+		}
+	}
+}
+
+// failPending ends the pending requests with err
+func (c *WsAPIClient) failPending(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, respC := range c.pending {
+		select {
+		case respC <- &wsStreamResponse{err: err}:
+		default:
+		}
+		delete(c.pending, id)
+	}
+}
+
+// SpotWsAPI places and queries spot orders over the websocket API
+type SpotWsAPI struct {
+	*WsAPIClient
+}
+
+// NewWsAPI creates a websocket API client for the spot client. It must be
+// connected with Connect.
+func (c *SpotClient) NewWsAPI(errHandler ErrHandler) *SpotWsAPI {
+	return &SpotWsAPI{WsAPIClient: NewSpotWsAPIClient(c.BaseClient, errHandler)}
+}
+
+// PlaceOrder places an order with order.place
+func (c *SpotWsAPI) PlaceOrder(ctx context.Context, p CreateSpotOrderParams) (*CreateSpotOrderResponse, error) {
+	m := params{
+		"symbol": p.Symbol,
+		"side":   p.Side,
+		"type":   p.Type,
+	}
+	if p.TimeInForce != "" {
+		m["timeInForce"] = p.TimeInForce
+	}
+	if p.Quantity != "" {
+		m["quantity"] = p.Quantity
+	}
+	if p.QuoteOrderQty != "" {
+		m["quoteOrderQty"] = p.QuoteOrderQty
+	}
+	if p.Price != "" {
+		m["price"] = p.Price
+	}
+	if p.NewClientOrderID != "" {
+		m["newClientOrderId"] = p.NewClientOrderID
+	}
+	if p.StopPrice != "" {
+		m["stopPrice"] = p.StopPrice
+	}
+	if p.IcebergQty != "" {
+		m["icebergQty"] = p.IcebergQty
+	}
+	if p.NewOrderRespType != "" {
+		m["newOrderRespType"] = p.NewOrderRespType
+	}
+	res := new(CreateSpotOrderResponse)
+	if err := c.call(ctx, "order.place", m, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CancelOrder cancels an order with order.cancel
+func (c *SpotWsAPI) CancelOrder(ctx context.Context, query SpotOrderQuery) (*CancelSpotOrderResponse, error) {
+	res := new(CancelSpotOrderResponse)
+	if err := c.call(ctx, "order.cancel", spotOrderQueryParams(query), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetOrder returns an order with order.status
+func (c *SpotWsAPI) GetOrder(ctx context.Context, query SpotOrderQuery) (*SpotOrder, error) {
+	res := new(SpotOrder)
+	if err := c.call(ctx, "order.status", spotOrderQueryParams(query), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Account returns the account info with account.status
+func (c *SpotWsAPI) Account(ctx context.Context) (*SpotAccount, error) {
+	res := new(SpotAccount)
+	if err := c.call(ctx, "account.status", params{}, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// call sends a signed request and decodes its result into res
+func (c *SpotWsAPI) call(ctx context.Context, method string, m params, res interface{}) error {
+	data, err := c.Call(ctx, method, m, true)
+	if err != nil {
+		return err
+	}
+	return JSON.Unmarshal(data, res)
+}
+
+// spotOrderQueryParams returns the parameters identifying an order
+func spotOrderQueryParams(query SpotOrderQuery) params {
+	m := params{"symbol": query.Symbol}
+	if query.OrderID > 0 {
+		m["orderId"] = query.OrderID
+	}
+	if query.OrigClientOrderID != "" {
+		m["origClientOrderId"] = query.OrigClientOrderID
+	}
+	return m
+}
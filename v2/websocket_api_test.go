@@ -0,0 +1,72 @@
+package aster
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// serveWsAPI answers ping requests with a result and others with an error
+func serveWsAPI(uris chan<- string) func(uri string, conn *websocket.Conn) {
+	return func(uri string, conn *websocket.Conn) {
+		uris <- uri
+		for {
+			var req wsAPIRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			resp := map[string]interface{}{"id": req.ID, "status": 200, "result": map[string]interface{}{}}
+			if req.Method != "ping" {
+				resp = map[string]interface{}{"id": req.ID, "status": 400, "error": map[string]interface{}{"code": -1102, "msg": "Mandatory parameter 'symbol' was not sent"}}
+			}
+			if err := conn.WriteJSON(resp); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestWsAPIClientEndpoint(t *testing.T) {
+	uris := make(chan string, 2)
+	srv := newWsTestServer(t, serveWsAPI(uris))
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http")
+	futuresURL := BaseWsAPIFuturesURL
+	t.Cleanup(func() { BaseWsAPIFuturesURL = futuresURL })
+	c := NewFuturesClient("key", "secret")
+	ctx := context.Background()
+
+	// the default endpoint is read on Connect
+	api := NewFuturesWsAPIClient(c, func(err error) {})
+	BaseWsAPIFuturesURL = wsURL + "/ws-fapi/v1"
+	if err := api.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer api.Close()
+	if uri := <-uris; uri != "/ws-fapi/v1" {
+		t.Errorf("connected to %s, want /ws-fapi/v1", uri)
+	}
+	if _, err := api.Call(ctx, "ping", nil, false); err != nil {
+		t.Fatal(err)
+	}
+	_, err := api.Call(ctx, "order.place", nil, false)
+	var apiErr *common.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != -1102 || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("got %v, want API error -1102 with status 400", err)
+	}
+
+	api = NewFuturesWsAPIClient(c, func(err error) {})
+	api.Endpoint = wsURL + "/intranet"
+	if err := api.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer api.Close()
+	if uri := <-uris; uri != "/intranet" {
+		t.Errorf("connected to %s, want the Endpoint /intranet", uri)
+	}
+}
@@ -0,0 +1,270 @@
+package aster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAggTradeGap is reported when missed aggregate trades could not be
+// backfilled
+var ErrAggTradeGap = errors.New("aster: aggregate trades missed")
+
+// AggTradeBackfillLimit caps the trades fetched to fill one gap. Gaps above
+// it are only partly filled and reported as ErrAggTradeGap.
+var AggTradeBackfillLimit = 10000
+
+// aggTradePageLimit is the maximum limit of the aggregate trades endpoints
+const aggTradePageLimit = 1000
+
+// AggTradeFetcher fetches up to limit aggregate trades of symbol from id
+// fromID, e.g. with an aggregate trades service. Futures trades have no
+// IsBestMatch.
+type AggTradeFetcher func(ctx context.Context, symbol string, fromID int64, limit int) ([]*SpotAggTrade, error)
+
+// aggTradeSequencer keeps the aggregate trades of each symbol in id order,
+// dropping repeated trades and fetching missed ones through REST on its own
+// goroutine while the live trades wait
+type aggTradeSequencer[E any] struct {
+	fetch      AggTradeFetcher
+	id         func(event *E) (symbol string, id int64)
+	backfilled func(symbol string, t *SpotAggTrade) *E
+	handler    func(event *E)
+	errHandler ErrHandler
+
+	mu         sync.Mutex
+	symbols    map[string]*aggTradeState[E]
+	queue      []*E // trades to deliver, in order
+	delivering bool // a goroutine is running the handler
+}
+
+// aggTradeState is the sequence of the trades of one symbol
+type aggTradeState[E any] struct {
+	delivered int64 // id of the last trade handled
+	received  int64 // id of the last trade handled or pending
+	filling   bool
+	pending   []*E // live trades received while filling, in id order
+}
+
+// NewSpotAggTradeBackfillHandler wraps handler so that when the aggregate
+// trade ids of a symbol jump, the missed trades are fetched through REST and
+// delivered in order, flagged as Backfilled, before the live trades. The
+// fetch runs on its own goroutine, and live trades are held until it ends,
+// so handler may also run there, never concurrently. Trades already
+// delivered, e.g. repeated after a reconnect, are dropped. The returned
+// handler works with any spot aggregate trade stream.
+func NewSpotAggTradeBackfillHandler(c *BaseClient, handler WsSpotAggTradeHandler, errHandler ErrHandler) WsSpotAggTradeHandler {
+	fetch := func(ctx context.Context, symbol string, fromID int64, limit int) ([]*SpotAggTrade, error) {
+		return (&SpotAggTradesService{c: c}).Symbol(symbol).FromID(fromID).Limit(limit).Do(ctx)
+	}
+	s := newAggTradeSequencer(fetch, func(event *WsSpotAggTradeEvent) (string, int64) {
+		return event.Symbol, event.AggregateTradeID
+	}, func(symbol string, t *SpotAggTrade) *WsSpotAggTradeEvent {
+		return &WsSpotAggTradeEvent{
+			Event:                 "aggTrade",
+			Time:                  t.Time,
+			Symbol:                symbol,
+			AggregateTradeID:      t.TradeID,
+			Price:                 t.Price,
+			Quantity:              t.Quantity,
+			FirstBreakdownTradeID: t.FirstTradeID,
+			LastBreakdownTradeID:  t.LastTradeID,
+			TradeTime:             t.Time,
+			IsBuyerMaker:          t.IsBuyerMaker,
+			Ignore:                t.IsBestMatch,
+			Backfilled:            true,
+		}
+	}, handler, errHandler)
+	return s.handle
+}
+
+// NewFuturesAggTradeBackfillHandler is NewSpotAggTradeBackfillHandler for
+// futures aggregate trade streams, fetching missed trades with fetch. The
+// futures client provides one on top of its aggregate trades service.
+func NewFuturesAggTradeBackfillHandler(fetch AggTradeFetcher, handler WsFuturesAggTradeHandler, errHandler ErrHandler) WsFuturesAggTradeHandler {
+	s := newAggTradeSequencer(fetch, func(event *WsFuturesAggTradeEvent) (string, int64) {
+		return event.Symbol, event.AggregateTradeID
+	}, func(symbol string, t *SpotAggTrade) *WsFuturesAggTradeEvent {
+		return &WsFuturesAggTradeEvent{
+			Event:                 "aggTrade",
+			Time:                  t.Time,
+			Symbol:                symbol,
+			AggregateTradeID:      t.TradeID,
+			Price:                 t.Price,
+			Quantity:              t.Quantity,
+			FirstBreakdownTradeID: t.FirstTradeID,
+			LastBreakdownTradeID:  t.LastTradeID,
+			TradeTime:             t.Time,
+			IsBuyerMaker:          t.IsBuyerMaker,
+			Backfilled:            true,
+		}
+	}, handler, errHandler)
+	return s.handle
+}
+
+// WsAggTradeServeWithBackfill serves the aggregate trade stream of symbol,
+// backfilling missed trades, see NewSpotAggTradeBackfillHandler
+func (c *SpotClient) WsAggTradeServeWithBackfill(symbol string, handler WsSpotAggTradeHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return c.WsAggTradeServe(symbol, NewSpotAggTradeBackfillHandler(c.BaseClient, handler, errHandler), errHandler, opts...)
+}
+
+func newAggTradeSequencer[E any](fetch AggTradeFetcher, id func(*E) (string, int64), backfilled func(string, *SpotAggTrade) *E, handler func(*E), errHandler ErrHandler) *aggTradeSequencer[E] {
+	return &aggTradeSequencer[E]{
+		fetch:      fetch,
+		id:         id,
+		backfilled: backfilled,
+		handler:    handler,
+		errHandler: errHandler,
+		symbols:    map[string]*aggTradeState[E]{},
+	}
+}
+
+// handle drops a live trade delivered already, holds it while the trades
+// before it are fetched, and delivers it otherwise
+func (s *aggTradeSequencer[E]) handle(event *E) {
+	symbol, id := s.id(event)
+	s.mu.Lock()
+	st := s.symbols[symbol]
+	if st == nil {
+		st = &aggTradeState[E]{delivered: id - 1, received: id - 1}
+		s.symbols[symbol] = st
+	}
+	if id <= st.received {
+		s.mu.Unlock()
+		return
+	}
+	st.received = id
+	if st.filling || id > st.delivered+1 {
+		// the event may be reused once handle returns, see WithPooledEvents
+		held := *event
+		st.pending = append(st.pending, &held)
+		if !st.filling {
+			st.filling = true
+			go s.fill(symbol, st.delivered+1, id)
+		}
+		s.mu.Unlock()
+		return
+	}
+	st.delivered = id
+	if s.delivering || len(s.queue) > 0 {
+		held := *event
+		s.queue = append(s.queue, &held)
+		s.mu.Unlock()
+		s.deliver()
+		return
+	}
+	s.delivering = true
+	s.mu.Unlock()
+	s.handler(event)
+	s.mu.Lock()
+	s.delivering = false
+	s.mu.Unlock()
+	s.deliver()
+}
+
+// fill fetches the trades of symbol from id from up to but excluding id to,
+// then delivers them and the live trades held meanwhile
+func (s *aggTradeSequencer[E]) fill(symbol string, from, to int64) {
+	trades := s.fetchRange(symbol, from, to)
+
+	s.mu.Lock()
+	st := s.symbols[symbol]
+	for _, t := range trades {
+		s.queue = append(s.queue, s.backfilled(symbol, t))
+	}
+	st.delivered = to - 1
+	for len(st.pending) > 0 {
+		event := st.pending[0]
+		_, id := s.id(event)
+		if id > st.delivered+1 {
+			go s.fill(symbol, st.delivered+1, id)
+			break
+		}
+		st.pending[0] = nil
+		st.pending = st.pending[1:]
+		st.delivered = id
+		s.queue = append(s.queue, event)
+	}
+	if len(st.pending) == 0 {
+		st.pending = nil
+		st.filling = false
+	}
+	s.mu.Unlock()
+	s.deliver()
+}
+
+// deliver runs the handler on the queued trades outside the lock, unless
+// another goroutine is delivering and will run it on them
+func (s *aggTradeSequencer[E]) deliver() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.delivering {
+		return
+	}
+	s.delivering = true
+	for len(s.queue) > 0 {
+		events := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+		for _, event := range events {
+			s.handler(event)
+		}
+		s.mu.Lock()
+	}
+	s.delivering = false
+}
+
+// fetchRange returns the trades of symbol from id from up to but excluding
+// id to, in id order, reporting those it could not fetch
+func (s *aggTradeSequencer[E]) fetchRange(symbol string, from, to int64) []*SpotAggTrade {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var trades []*SpotAggTrade
+	next := from
+	for next < to && len(trades) < AggTradeBackfillLimit {
+		limit := aggTradePageLimit
+		if remaining := AggTradeBackfillLimit - len(trades); remaining < limit {
+			limit = remaining
+		}
+		if missing := to - next; missing < int64(limit) {
+			limit = int(missing)
+		}
+		page, err := s.fetch(ctx, strings.ToUpper(symbol), next, limit)
+		if err != nil {
+			s.reportGap(symbol, next, to, err)
+			return trades
+		}
+		start := next
+		for _, t := range page {
+			if t.TradeID < next || t.TradeID >= to {
+				continue
+			}
+			trades = append(trades, t)
+			next = t.TradeID + 1
+		}
+		if len(page) < limit || next == start {
+			// the end of the trades, or a page with none in the range
+			break
+		}
+	}
+	if next < to {
+		s.reportGap(symbol, next, to, nil)
+	}
+	return trades
+}
+
+// reportGap reports the trades from id from up to but excluding id to as
+// missed
+func (s *aggTradeSequencer[E]) reportGap(symbol string, from, to int64, err error) {
+	if s.errHandler == nil {
+		return
+	}
+	if err != nil {
+		s.errHandler(fmt.Errorf("%w: %s ids %d to %d: %v", ErrAggTradeGap, symbol, from, to-1, err))
+		return
+	}
+	s.errHandler(fmt.Errorf("%w: %s ids %d to %d", ErrAggTradeGap, symbol, from, to-1))
+}
@@ -0,0 +1,260 @@
+package aster
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// backfillRecorder collects the trades delivered by a backfill handler
+type backfillRecorder struct {
+	mu     sync.Mutex
+	trades []*WsFuturesAggTradeEvent
+	errs   []error
+	notify chan struct{}
+}
+
+func newBackfillRecorder() *backfillRecorder {
+	return &backfillRecorder{notify: make(chan struct{}, 100)}
+}
+
+func (r *backfillRecorder) handle(e *WsFuturesAggTradeEvent) {
+	r.mu.Lock()
+	r.trades = append(r.trades, e)
+	r.mu.Unlock()
+	r.notify <- struct{}{}
+}
+
+func (r *backfillRecorder) error(err error) {
+	r.mu.Lock()
+	r.errs = append(r.errs, err)
+	r.mu.Unlock()
+}
+
+// wait waits for n trades in total and returns their ids and backfill flags
+func (r *backfillRecorder) wait(t *testing.T, n int) (ids []int64, backfilled []bool) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		r.mu.Lock()
+		if len(r.trades) >= n {
+			for _, e := range r.trades {
+				ids = append(ids, e.AggregateTradeID)
+				backfilled = append(backfilled, e.Backfilled)
+			}
+			r.mu.Unlock()
+			return ids, backfilled
+		}
+		r.mu.Unlock()
+		select {
+		case <-r.notify:
+		case <-deadline:
+			t.Fatalf("timed out waiting for %d trades", n)
+		}
+	}
+}
+
+func liveTrade(id int64) *WsFuturesAggTradeEvent {
+	return &WsFuturesAggTradeEvent{Event: "aggTrade", Symbol: "BTCUSDT", AggregateTradeID: id, Price: "1"}
+}
+
+// restTrades returns a fetcher serving trades with ids 1 to last
+func restTrades(last int64, calls *[]int64) AggTradeFetcher {
+	return func(ctx context.Context, symbol string, fromID int64, limit int) ([]*SpotAggTrade, error) {
+		if calls != nil {
+			*calls = append(*calls, fromID)
+		}
+		var trades []*SpotAggTrade
+		for id := fromID; id <= last && len(trades) < limit; id++ {
+			trades = append(trades, &SpotAggTrade{TradeID: id, Price: "1"})
+		}
+		return trades, nil
+	}
+}
+
+func equalIDs(got, want []int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAggTradeBackfillFillsGap(t *testing.T) {
+	release := make(chan struct{})
+	fetch := func(ctx context.Context, symbol string, fromID int64, limit int) ([]*SpotAggTrade, error) {
+		<-release
+		return restTrades(10, nil)(ctx, symbol, fromID, limit)
+	}
+	r := newBackfillRecorder()
+	handler := NewFuturesAggTradeBackfillHandler(fetch, r.handle, r.error)
+
+	handler(liveTrade(1))
+	handler(liveTrade(1)) // repeated after a reconnect
+	handler(liveTrade(4))
+	// the fetch is blocked, so these return only if the reader is not
+	held := liveTrade(5)
+	handler(held)
+	held.AggregateTradeID = 99 // a pooled event is reused once handle returns
+	handler(liveTrade(4))
+	close(release)
+
+	ids, backfilled := r.wait(t, 5)
+	if want := []int64{1, 2, 3, 4, 5}; !equalIDs(ids, want) {
+		t.Fatalf("got ids %v, want %v", ids, want)
+	}
+	for i, want := range []bool{false, true, true, false, false} {
+		if backfilled[i] != want {
+			t.Errorf("trade %d backfilled %v, want %v", ids[i], backfilled[i], want)
+		}
+	}
+
+	handler(liveTrade(6))
+	if ids, _ := r.wait(t, 6); ids[5] != 6 {
+		t.Errorf("got ids %v after the fill", ids)
+	}
+	if len(r.errs) != 0 {
+		t.Errorf("unexpected errors %v", r.errs)
+	}
+}
+
+func TestAggTradeBackfillGapWhileFilling(t *testing.T) {
+	release := make(chan struct{}, 2)
+	var mu sync.Mutex
+	var calls []int64
+	fetch := func(ctx context.Context, symbol string, fromID int64, limit int) ([]*SpotAggTrade, error) {
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		return restTrades(20, &calls)(ctx, symbol, fromID, limit)
+	}
+	r := newBackfillRecorder()
+	handler := NewFuturesAggTradeBackfillHandler(fetch, r.handle, r.error)
+
+	handler(liveTrade(1))
+	handler(liveTrade(3))
+	handler(liveTrade(6)) // held with 3, leaves a second gap
+	release <- struct{}{}
+	release <- struct{}{}
+
+	ids, backfilled := r.wait(t, 6)
+	if want := []int64{1, 2, 3, 4, 5, 6}; !equalIDs(ids, want) {
+		t.Fatalf("got ids %v, want %v", ids, want)
+	}
+	if !backfilled[1] || !backfilled[3] || !backfilled[4] || backfilled[5] {
+		t.Errorf("got backfill flags %v", backfilled)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !equalIDs(calls, []int64{2, 4}) {
+		t.Errorf("fetched from %v, want [2 4]", calls)
+	}
+}
+
+func TestAggTradeBackfillReportsGap(t *testing.T) {
+	fetchErr := errors.New("rate limited")
+	fetch := func(ctx context.Context, symbol string, fromID int64, limit int) ([]*SpotAggTrade, error) {
+		return nil, fetchErr
+	}
+	r := newBackfillRecorder()
+	handler := NewFuturesAggTradeBackfillHandler(fetch, r.handle, r.error)
+	handler(liveTrade(1))
+	handler(liveTrade(5))
+
+	if ids, _ := r.wait(t, 2); !equalIDs(ids, []int64{1, 5}) {
+		t.Fatalf("got ids %v, want [1 5]", ids)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.errs) != 1 || !errors.Is(r.errs[0], ErrAggTradeGap) {
+		t.Errorf("got errors %v, want one ErrAggTradeGap", r.errs)
+	}
+}
+
+func TestAggTradeBackfillLimit(t *testing.T) {
+	defer func(limit int) { AggTradeBackfillLimit = limit }(AggTradeBackfillLimit)
+	AggTradeBackfillLimit = 3
+	r := newBackfillRecorder()
+	handler := NewFuturesAggTradeBackfillHandler(restTrades(100, nil), r.handle, r.error)
+	handler(liveTrade(1))
+	handler(liveTrade(10))
+
+	if ids, _ := r.wait(t, 5); !equalIDs(ids, []int64{1, 2, 3, 4, 10}) {
+		t.Fatalf("got ids %v, want [1 2 3 4 10]", ids)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.errs) != 1 || !errors.Is(r.errs[0], ErrAggTradeGap) {
+		t.Errorf("got errors %v, want one ErrAggTradeGap", r.errs)
+	}
+}
+
+func TestAggTradeBackfillStalledPage(t *testing.T) {
+	var calls int
+	// a full page of trades before the range, as from a lagging replica
+	fetch := func(ctx context.Context, symbol string, fromID int64, limit int) ([]*SpotAggTrade, error) {
+		calls++
+		var trades []*SpotAggTrade
+		for i := 0; i < limit; i++ {
+			trades = append(trades, &SpotAggTrade{TradeID: fromID - 1, Price: "1"})
+		}
+		return trades, nil
+	}
+	r := newBackfillRecorder()
+	handler := NewFuturesAggTradeBackfillHandler(fetch, r.handle, r.error)
+	handler(liveTrade(1))
+	handler(liveTrade(5))
+
+	if ids, _ := r.wait(t, 2); !equalIDs(ids, []int64{1, 5}) {
+		t.Fatalf("got ids %v, want [1 5]", ids)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if calls != 1 {
+		t.Errorf("fetched %d pages, want 1", calls)
+	}
+	if len(r.errs) != 1 || !errors.Is(r.errs[0], ErrAggTradeGap) {
+		t.Errorf("got errors %v, want one ErrAggTradeGap", r.errs)
+	}
+}
+
+func TestAggTradeBackfillHandlerOutsideLock(t *testing.T) {
+	r := newBackfillRecorder()
+	blocked := make(chan struct{})
+	release := make(chan struct{})
+	handler := NewFuturesAggTradeBackfillHandler(restTrades(10, nil), func(e *WsFuturesAggTradeEvent) {
+		if e.AggregateTradeID == 2 {
+			close(blocked)
+			<-release
+		}
+		r.handle(e)
+	}, r.error)
+
+	handler(liveTrade(1))
+	handler(liveTrade(3))
+	<-blocked
+
+	// live trades are taken while the handler is busy with the backfill
+	returned := make(chan struct{})
+	go func() {
+		handler(liveTrade(4))
+		handler(liveTrade(5))
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("live trades blocked while the handler ran")
+	}
+	close(release)
+
+	if ids, _ := r.wait(t, 5); !equalIDs(ids, []int64{1, 2, 3, 4, 5}) {
+		t.Fatalf("got ids %v, want [1 2 3 4 5]", ids)
+	}
+}
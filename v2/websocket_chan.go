@@ -0,0 +1,292 @@
+package aster
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrStreamEnded is returned by EventStream.Err when the stream ended
+// before its context was done
+var ErrStreamEnded = errors.New("aster: stream ended")
+
+// OverflowPolicy selects what an EventStream does with an event when its
+// buffer is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for room, stalling the websocket reader
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest buffered event
+	OverflowDropOldest
+	// OverflowDropNewest drops the incoming event
+	OverflowDropNewest
+	// OverflowConflate keeps only the latest buffered event per key,
+	// dropping the oldest key when the buffer is full
+	OverflowConflate
+)
+
+// ChanConfig configures an EventStream
+type ChanConfig[T any] struct {
+	Buffer int // buffered events, 256 by default
+	Policy OverflowPolicy
+
+	// Key returns the conflation key of an event, e.g. its symbol. Without
+	// it OverflowConflate keeps the latest event only.
+	Key func(event T) string
+
+	// ErrHandler receives the errors of the stream
+	ErrHandler ErrHandler
+}
+
+// ServeFunc starts a stream delivering events to handler, e.g. a closure
+// around one of the Ws*Serve functions
+type ServeFunc[T any] func(handler func(event T), errHandler ErrHandler) (doneC, stopC chan struct{}, err error)
+
+// EventStream delivers the events of a stream on a channel. Events are
+// queued by the websocket reader and sent to C by a separate goroutine, so
+// a slow consumer only fills the buffer.
+type EventStream[T any] struct {
+	// C receives the events. It is closed once the stream has stopped and,
+	// unless the context is done, the buffered events have been received.
+	C <-chan T
+
+	ctx     context.Context
+	cfg     ChanConfig[T]
+	dropped atomic.Uint64
+	doneC   chan struct{}
+
+	mu     sync.Mutex
+	queue  []*queuedEvent[T]
+	keys   map[string]*queuedEvent[T] // conflation index of queue
+	ended  bool
+	readyC chan struct{}
+	spaceC chan struct{}
+}
+
+type queuedEvent[T any] struct {
+	key   string
+	event T
+}
+
+// ServeChan starts a stream and returns its events on a channel. The stream
+// is stopped when ctx is done.
+//
+//	events, err := aster.ServeChan(ctx, func(h func(*aster.WsBookTickerEvent), eh aster.ErrHandler) (chan struct{}, chan struct{}, error) {
+//		return aster.WsFuturesBookTickerServe("BTCUSDT", h, eh)
+//	}, aster.ChanConfig[*aster.WsBookTickerEvent]{Policy: aster.OverflowConflate})
+//	for e := range events.C {
+//		...
+//	}
+//
+// The Ws*Chan functions wrap the common streams. Events outlive the
+// handler, so WithPooledEvents must not be used.
+func ServeChan[T any](ctx context.Context, serve ServeFunc[T], cfg ChanConfig[T]) (*EventStream[T], error) {
+	if cfg.Buffer <= 0 {
+		cfg.Buffer = 256
+	}
+	out := make(chan T)
+	s := &EventStream[T]{
+		C:      out,
+		ctx:    ctx,
+		cfg:    cfg,
+		doneC:  make(chan struct{}),
+		keys:   map[string]*queuedEvent[T]{},
+		readyC: make(chan struct{}, 1),
+		spaceC: make(chan struct{}, 1),
+	}
+	errHandler := cfg.ErrHandler
+	if errHandler == nil {
+		errHandler = func(error) {}
+	}
+	doneC, stopC, err := serve(s.push, errHandler)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			close(stopC)
+			<-doneC
+		case <-doneC:
+		}
+		s.mu.Lock()
+		s.ended = true
+		s.mu.Unlock()
+		signal(s.readyC)
+		close(s.doneC)
+	}()
+	go s.forward(out)
+	return s, nil
+}
+
+// WsSpotBookTickerChan delivers the bookTicker events of a spot symbol on a
+// channel
+func WsSpotBookTickerChan(ctx context.Context, symbol string, cfg ChanConfig[*WsBookTickerEvent], opts ...StreamOption) (*EventStream[*WsBookTickerEvent], error) {
+	return ServeChan(ctx, func(h func(*WsBookTickerEvent), eh ErrHandler) (chan struct{}, chan struct{}, error) {
+		return WsSpotBookTickerServe(symbol, h, eh, opts...)
+	}, cfg)
+}
+
+// WsFuturesBookTickerChan delivers the bookTicker events of a futures
+// symbol on a channel
+func WsFuturesBookTickerChan(ctx context.Context, symbol string, cfg ChanConfig[*WsBookTickerEvent], opts ...StreamOption) (*EventStream[*WsBookTickerEvent], error) {
+	return ServeChan(ctx, func(h func(*WsBookTickerEvent), eh ErrHandler) (chan struct{}, chan struct{}, error) {
+		return WsFuturesBookTickerServe(symbol, h, eh, opts...)
+	}, cfg)
+}
+
+// WsSpotDepthChan delivers the diff depth events of a spot symbol on a
+// channel. Conflating or dropping them breaks the order book sequence.
+func WsSpotDepthChan(ctx context.Context, symbol string, cfg ChanConfig[*WsDepthEvent], opts ...StreamOption) (*EventStream[*WsDepthEvent], error) {
+	return ServeChan(ctx, func(h func(*WsDepthEvent), eh ErrHandler) (chan struct{}, chan struct{}, error) {
+		return WsSpotDepthServe(symbol, h, eh, opts...)
+	}, cfg)
+}
+
+// WsFuturesDepthChan delivers the diff depth events of a futures symbol on
+// a channel. Conflating or dropping them breaks the order book sequence.
+func WsFuturesDepthChan(ctx context.Context, symbol string, cfg ChanConfig[*WsDepthEvent], opts ...StreamOption) (*EventStream[*WsDepthEvent], error) {
+	return ServeChan(ctx, func(h func(*WsDepthEvent), eh ErrHandler) (chan struct{}, chan struct{}, error) {
+		return WsFuturesDepthServe(symbol, h, eh, opts...)
+	}, cfg)
+}
+
+// WsFuturesAggTradeChan delivers the aggTrade events of a futures symbol on
+// a channel
+func WsFuturesAggTradeChan(ctx context.Context, symbol string, cfg ChanConfig[*WsFuturesAggTradeEvent], opts ...StreamOption) (*EventStream[*WsFuturesAggTradeEvent], error) {
+	return ServeChan(ctx, func(h func(*WsFuturesAggTradeEvent), eh ErrHandler) (chan struct{}, chan struct{}, error) {
+		return WsFuturesAggTradeServe(symbol, h, eh, opts...)
+	}, cfg)
+}
+
+// WsFuturesAllMarkPriceChan delivers the markPrice events of all futures
+// symbols on a channel
+func WsFuturesAllMarkPriceChan(ctx context.Context, cfg ChanConfig[*WsFuturesMarkPriceEvent], opts ...StreamOption) (*EventStream[*WsFuturesMarkPriceEvent], error) {
+	return ServeChan(ctx, func(h func(*WsFuturesMarkPriceEvent), eh ErrHandler) (chan struct{}, chan struct{}, error) {
+		return WsFuturesAllMarkPriceServe(h, eh, opts...)
+	}, cfg)
+}
+
+// Dropped returns the number of events dropped or conflated so far
+func (s *EventStream[T]) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Len returns the number of buffered events
+func (s *EventStream[T]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// Done is closed once the underlying stream has stopped
+func (s *EventStream[T]) Done() <-chan struct{} {
+	return s.doneC
+}
+
+// Err returns why the stream stopped, nil while it runs
+func (s *EventStream[T]) Err() error {
+	select {
+	case <-s.doneC:
+	default:
+		return nil
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return ErrStreamEnded
+}
+
+// push queues an event according to the overflow policy. It runs on the
+// websocket reader.
+func (s *EventStream[T]) push(event T) {
+	key := ""
+	if s.cfg.Policy == OverflowConflate && s.cfg.Key != nil {
+		key = s.cfg.Key(event)
+	}
+	s.mu.Lock()
+	for s.cfg.Policy == OverflowBlock && len(s.queue) >= s.cfg.Buffer {
+		s.mu.Unlock()
+		select {
+		case <-s.spaceC:
+		case <-s.ctx.Done():
+			return
+		}
+		s.mu.Lock()
+	}
+	if s.cfg.Policy == OverflowConflate {
+		if q, ok := s.keys[key]; ok {
+			q.event = event
+			s.mu.Unlock()
+			s.dropped.Add(1)
+			return
+		}
+	}
+	if len(s.queue) >= s.cfg.Buffer {
+		s.dropped.Add(1)
+		if s.cfg.Policy == OverflowDropNewest {
+			s.mu.Unlock()
+			return
+		}
+		s.removeOldest()
+	}
+	q := &queuedEvent[T]{key: key, event: event}
+	s.queue = append(s.queue, q)
+	if s.cfg.Policy == OverflowConflate {
+		s.keys[key] = q
+	}
+	s.mu.Unlock()
+	signal(s.readyC)
+}
+
+// removeOldest removes the head of the queue. s.mu must be held.
+func (s *EventStream[T]) removeOldest() *queuedEvent[T] {
+	q := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	if s.cfg.Policy == OverflowConflate {
+		delete(s.keys, q.key)
+	}
+	return q
+}
+
+// forward sends the queued events to out until the stream has ended and
+// the queue is empty, or ctx is done
+func (s *EventStream[T]) forward(out chan<- T) {
+	defer close(out)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			ended := s.ended
+			s.mu.Unlock()
+			if ended {
+				return
+			}
+			select {
+			case <-s.readyC:
+			case <-s.ctx.Done():
+				return
+			}
+			continue
+		}
+		q := s.removeOldest()
+		s.mu.Unlock()
+		signal(s.spaceC)
+
+		select {
+		case out <- q.event:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// signal wakes up a goroutine waiting on c without blocking
+func signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
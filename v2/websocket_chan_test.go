@@ -0,0 +1,181 @@
+package aster
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// chanTestStream is a stream fed by the test through push, which ends when
+// stopped or when the test closes endC
+type chanTestStream struct {
+	push  func(string)
+	endC  chan struct{}
+	doneC chan struct{}
+}
+
+func (ts *chanTestStream) serve(handler func(string), errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+	ts.push = handler
+	stopC = make(chan struct{})
+	go func() {
+		select {
+		case <-stopC:
+		case <-ts.endC:
+		}
+		close(ts.doneC)
+	}()
+	return ts.doneC, stopC, nil
+}
+
+func newChanTestStream(t *testing.T, ctx context.Context, cfg ChanConfig[string]) (*chanTestStream, *EventStream[string]) {
+	t.Helper()
+	ts := &chanTestStream{endC: make(chan struct{}), doneC: make(chan struct{})}
+	s, err := ServeChan(ctx, ts.serve, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts, s
+}
+
+// collect receives the events of s until C is closed
+func collect(t *testing.T, s *EventStream[string]) []string {
+	t.Helper()
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e, ok := <-s.C:
+			if !ok {
+				return got
+			}
+			got = append(got, e)
+		case <-timeout:
+			t.Fatalf("C not closed, got %v", got)
+		}
+	}
+}
+
+func TestServeChanOverflow(t *testing.T) {
+	byLetter := func(e string) string { return e[:1] }
+	tests := []struct {
+		name    string
+		policy  OverflowPolicy
+		key     func(string) string
+		pushes  []string
+		want    []string
+		dropped uint64
+	}{
+		{"block", OverflowBlock, nil, []string{"a1", "b1", "c1", "d1"}, []string{"first", "a1", "b1", "c1", "d1"}, 0},
+		{"drop oldest", OverflowDropOldest, nil, []string{"a1", "b1", "c1", "d1"}, []string{"first", "c1", "d1"}, 2},
+		{"drop newest", OverflowDropNewest, nil, []string{"a1", "b1", "c1", "d1"}, []string{"first", "a1", "b1"}, 2},
+		{"conflate by key", OverflowConflate, byLetter, []string{"a1", "b1", "a2", "c1"}, []string{"first", "b1", "c1"}, 2},
+		{"conflate without key", OverflowConflate, nil, []string{"a1", "b1", "c1"}, []string{"first", "c1"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, s := newChanTestStream(t, context.Background(), ChanConfig[string]{Buffer: 2, Policy: tt.policy, Key: tt.key})
+
+			// The forwarder holds the first event until it is received,
+			// so the pushes below only fill the buffer
+			ts.push("first")
+			for deadline := time.Now().Add(5 * time.Second); s.Len() > 0; {
+				if time.Now().After(deadline) {
+					t.Fatal("first event not forwarded")
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			pushed := make(chan struct{})
+			go func() {
+				defer close(pushed)
+				for _, e := range tt.pushes {
+					ts.push(e)
+				}
+			}()
+			if tt.policy == OverflowBlock {
+				select {
+				case <-pushed:
+					t.Fatal("pushes beyond the buffer did not block")
+				case <-time.After(50 * time.Millisecond):
+				}
+			} else {
+				<-pushed
+			}
+			close(ts.endC)
+
+			if got := collect(t, s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got events %v, want %v", got, tt.want)
+			}
+			<-pushed
+			if got := s.Dropped(); got != tt.dropped {
+				t.Errorf("got %d dropped, want %d", got, tt.dropped)
+			}
+			if err := s.Err(); !errors.Is(err, ErrStreamEnded) {
+				t.Errorf("got Err %v, want ErrStreamEnded", err)
+			}
+		})
+	}
+}
+
+func TestServeChanContextShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ts, s := newChanTestStream(t, ctx, ChanConfig[string]{Buffer: 1})
+	if err := s.Err(); err != nil {
+		t.Errorf("got Err %v while running", err)
+	}
+
+	// fill the forwarder and the buffer, then block a third push
+	ts.push("a")
+	ts.push("b")
+	pushed := make(chan struct{})
+	go func() {
+		ts.push("c")
+		close(pushed)
+	}()
+
+	cancel()
+	select {
+	case <-pushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocked push did not return after cancel")
+	}
+	select {
+	case <-ts.doneC:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not stopped after cancel")
+	}
+	collect(t, s)
+	<-s.Done()
+	if err := s.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("got Err %v, want context.Canceled", err)
+	}
+}
+
+func TestWsFuturesBookTickerChan(t *testing.T) {
+	newWsTestServer(t, func(uri string, conn *websocket.Conn) {
+		for _, bid := range []string{"100", "101"} {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"e":"bookTicker","u":1,"s":"BTCUSDT","b":"`+bid+`","B":"1","a":"102","A":"1"}`))
+		}
+		drain(conn)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := WsFuturesBookTickerChan(ctx, "BTCUSDT", ChanConfig[*WsBookTickerEvent]{}, WithStreamReconnect(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"100", "101"} {
+		select {
+		case e := <-s.C:
+			if e.Symbol != "BTCUSDT" || e.BestBidPrice != want {
+				t.Errorf("got event %+v, want BTCUSDT bid %s", e, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+		}
+	}
+}
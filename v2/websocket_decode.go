@@ -0,0 +1,473 @@
+package aster
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"unsafe"
+
+	"github.com/json-iterator/go"
+)
+
+// ErrCombinedStreamNoData is reported for combined stream messages without
+// data
+var ErrCombinedStreamNoData = errors.New("aster: combined stream message without data")
+
+// maxInternedNames bounds the interned event types and symbols
+const maxInternedNames = 4096
+
+// internedNames holds the event types and symbols seen in messages, which
+// repeat in every message of a stream
+var internedNames = struct {
+	sync.RWMutex
+	m map[string]string
+}{m: map[string]string{}}
+
+// wsDecoder is an event decoded by hand from an iterator, without
+// reflection. Its strings are appended to strs, returned with them.
+type wsDecoder interface {
+	decodeJSON(iter *jsoniter.Iterator, strs []byte) []byte
+}
+
+// wsEvent is a pointer to an event with a hand-written decoder
+type wsEvent[T any] interface {
+	*T
+	wsDecoder
+}
+
+// wsEventPool recycles the events of a type for the streams started with
+// WithPooledEvents
+type wsEventPool[T any] struct {
+	pool sync.Pool
+}
+
+var (
+	depthEvents           wsEventPool[WsDepthEvent]
+	bookTickerEvents      wsEventPool[WsBookTickerEvent]
+	spotAggTradeEvents    wsEventPool[WsSpotAggTradeEvent]
+	futuresAggTradeEvents wsEventPool[WsFuturesAggTradeEvent]
+	markPriceEvents       wsEventPool[WsFuturesMarkPriceEvent]
+)
+
+// get returns a recycled event when pooled, a new one otherwise
+func (p *wsEventPool[T]) get(pooled bool) *T {
+	if pooled {
+		if e, ok := p.pool.Get().(*T); ok {
+			return e
+		}
+	}
+	return new(T)
+}
+
+// put recycles an event when pooled
+func (p *wsEventPool[T]) put(e *T, pooled bool) {
+	if pooled {
+		p.pool.Put(e)
+	}
+}
+
+// decodeWsEvent returns a WsHandler decoding messages into events of pool,
+// reused once handler returns when cfg has PooledEvents set. Messages of
+// combined streams are unwrapped first.
+func decodeWsEvent[T any, P wsEvent[T]](pool *wsEventPool[T], cfg *WsConfig, combined bool, handler func(P), errHandler ErrHandler) WsHandler {
+	pooled := cfg.PooledEvents
+	return func(message []byte) {
+		event := P(pool.get(pooled))
+		var err error
+		if combined {
+			err = decodeCombined(message, event)
+		} else {
+			err = decodeWs(message, event)
+		}
+		if err != nil {
+			pool.put(event, pooled)
+			if errHandler != nil {
+				errHandler(err)
+			}
+			return
+		}
+		handler(event)
+		pool.put(event, pooled)
+	}
+}
+
+// decodeWsEvents returns a WsHandler decoding the events of an array stream
+// like decodeWsEvent, calling handler once all of them are decoded
+func decodeWsEvents[T any, P wsEvent[T]](pool *wsEventPool[T], cfg *WsConfig, handler func(P), errHandler ErrHandler) WsHandler {
+	pooled := cfg.PooledEvents
+	var events []P // the handler runs on the reader goroutine only
+	return func(message []byte) {
+		iter := JSON.BorrowIterator(message)
+		defer JSON.ReturnIterator(iter)
+		events = events[:0]
+		strs := make([]byte, 0, len(message))
+		iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+			event := P(pool.get(pooled))
+			strs = event.decodeJSON(iter, strs)
+			events = append(events, event)
+			return iter.Error == nil
+		})
+		err := decodeError(iter)
+		for i, event := range events {
+			if err == nil {
+				handler(event)
+			}
+			pool.put(event, pooled)
+			events[i] = nil
+		}
+		if err != nil && errHandler != nil {
+			errHandler(err)
+		}
+	}
+}
+
+// decodeWs decodes a message into event
+func decodeWs(data []byte, event wsDecoder) error {
+	iter := JSON.BorrowIterator(data)
+	defer JSON.ReturnIterator(iter)
+	event.decodeJSON(iter, make([]byte, 0, len(data)))
+	return decodeError(iter)
+}
+
+// decodeCombined decodes the data of a combined stream message into event
+func decodeCombined(data []byte, event wsDecoder) error {
+	iter := JSON.BorrowIterator(data)
+	defer JSON.ReturnIterator(iter)
+	found := false
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, field string) bool {
+		if field == "data" && !iter.ReadNil() {
+			event.decodeJSON(iter, make([]byte, 0, len(data)))
+			found = true
+		} else {
+			iter.Skip()
+		}
+		return iter.Error == nil
+	})
+	if err := decodeError(iter); err != nil {
+		return err
+	}
+	if !found {
+		return ErrCombinedStreamNoData
+	}
+	return nil
+}
+
+// decodeError returns the error of a decoding iterator. The decoders stop
+// at the end of the value, so reaching the end of the input means it was
+// truncated.
+func decodeError(iter *jsoniter.Iterator) error {
+	if iter.Error == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return iter.Error
+}
+
+// readName reads an event type or symbol, interned so that its string is
+// allocated once. strs is only used as scratch space.
+func readName(iter *jsoniter.Iterator, strs *[]byte) string {
+	if iter.WhatIsNext() != jsoniter.StringValue {
+		return iter.ReadString()
+	}
+	start := len(*strs)
+	*strs = iter.SkipAndAppendBytes(*strs)
+	raw := (*strs)[start:]
+	*strs = (*strs)[:start]
+	if len(raw) <= 2 || bytes.IndexByte(raw, '\\') >= 0 {
+		return unquote(iter, raw)
+	}
+	name := raw[1 : len(raw)-1]
+	internedNames.RLock()
+	s, ok := internedNames.m[string(name)]
+	internedNames.RUnlock()
+	if ok {
+		return s
+	}
+	s = string(name)
+	internedNames.Lock()
+	if len(internedNames.m) < maxInternedNames {
+		internedNames.m[s] = s
+	}
+	internedNames.Unlock()
+	return s
+}
+
+// readInt64 reads an integer, 0 for null
+func readInt64(iter *jsoniter.Iterator) int64 {
+	if iter.ReadNil() {
+		return 0
+	}
+	return iter.ReadInt64()
+}
+
+// readBool reads a boolean, false for null
+func readBool(iter *jsoniter.Iterator) bool {
+	if iter.ReadNil() {
+		return false
+	}
+	return iter.ReadBool()
+}
+
+// readString reads a string into strs. The strings of a message share its
+// buffer, so that decoding allocates once however many prices it has.
+func readString(iter *jsoniter.Iterator, strs *[]byte) string {
+	if iter.WhatIsNext() != jsoniter.StringValue {
+		return iter.ReadString()
+	}
+	start := len(*strs)
+	*strs = iter.SkipAndAppendBytes(*strs)
+	raw := (*strs)[start:]
+	if len(raw) <= 2 || bytes.IndexByte(raw, '\\') >= 0 {
+		*strs = (*strs)[:start]
+		return unquote(iter, raw)
+	}
+	return unsafe.String(&raw[1], len(raw)-2)
+}
+
+// unquote decodes an empty or escaped string the slow way
+func unquote(iter *jsoniter.Iterator, raw []byte) string {
+	var s string
+	if len(raw) > 2 {
+		if err := JSON.Unmarshal(raw, &s); err != nil {
+			iter.ReportError("unquote", err.Error())
+		}
+	}
+	return s
+}
+
+// readLevel reads a [price, quantity] pair of a depth event
+func readLevel(iter *jsoniter.Iterator, strs *[]byte) (price, quantity string) {
+	i := 0
+	iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+		switch i {
+		case 0:
+			price = readString(iter, strs)
+		case 1:
+			quantity = readString(iter, strs)
+		default:
+			iter.Skip()
+		}
+		i++
+		return iter.Error == nil
+	})
+	return price, quantity
+}
+
+// readLevels reads the price levels of a depth event into levels, reusing
+// its capacity
+func readLevels[L ~struct {
+	Price    string
+	Quantity string
+}](iter *jsoniter.Iterator, levels []L, strs *[]byte) []L {
+	if iter.ReadNil() {
+		return nil
+	}
+	levels = levels[:0]
+	iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+		price, quantity := readLevel(iter, strs)
+		levels = append(levels, L{Price: price, Quantity: quantity})
+		return iter.Error == nil
+	})
+	if levels == nil {
+		levels = []L{}
+	}
+	return levels
+}
+
+// UnmarshalJSON decodes a [price, quantity] pair
+func (b *Bid) UnmarshalJSON(data []byte) error {
+	iter := JSON.BorrowIterator(data)
+	defer JSON.ReturnIterator(iter)
+	strs := make([]byte, 0, len(data))
+	b.Price, b.Quantity = readLevel(iter, &strs)
+	return decodeError(iter)
+}
+
+// UnmarshalJSON decodes a [price, quantity] pair
+func (a *Ask) UnmarshalJSON(data []byte) error {
+	iter := JSON.BorrowIterator(data)
+	defer JSON.ReturnIterator(iter)
+	strs := make([]byte, 0, len(data))
+	a.Price, a.Quantity = readLevel(iter, &strs)
+	return decodeError(iter)
+}
+
+// UnmarshalJSON decodes a depth event without reflection
+func (e *WsDepthEvent) UnmarshalJSON(data []byte) error {
+	return decodeWs(data, e)
+}
+
+func (e *WsDepthEvent) decodeJSON(iter *jsoniter.Iterator, strs []byte) []byte {
+	bids, asks := e.Bids, e.Asks
+	*e = WsDepthEvent{}
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, field string) bool {
+		switch field {
+		case "e":
+			e.Event = readName(iter, &strs)
+		case "E":
+			e.Time = readInt64(iter)
+		case "T":
+			e.TransactionTime = readInt64(iter)
+		case "s":
+			e.Symbol = readName(iter, &strs)
+		case "U":
+			e.FirstUpdateID = readInt64(iter)
+		case "u":
+			e.LastUpdateID = readInt64(iter)
+		case "pu":
+			e.PrevLastUpdateID = readInt64(iter)
+		case "b":
+			e.Bids = readLevels(iter, bids, &strs)
+		case "a":
+			e.Asks = readLevels(iter, asks, &strs)
+		default:
+			iter.Skip()
+		}
+		return iter.Error == nil
+	})
+	return strs
+}
+
+// UnmarshalJSON decodes a book ticker event without reflection
+func (e *WsBookTickerEvent) UnmarshalJSON(data []byte) error {
+	return decodeWs(data, e)
+}
+
+func (e *WsBookTickerEvent) decodeJSON(iter *jsoniter.Iterator, strs []byte) []byte {
+	*e = WsBookTickerEvent{}
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, field string) bool {
+		switch field {
+		case "e":
+			e.Event = readName(iter, &strs)
+		case "u":
+			e.UpdateID = readInt64(iter)
+		case "E":
+			e.Time = readInt64(iter)
+		case "T":
+			e.TransactionTime = readInt64(iter)
+		case "s":
+			e.Symbol = readName(iter, &strs)
+		case "b":
+			e.BestBidPrice = readString(iter, &strs)
+		case "B":
+			e.BestBidQty = readString(iter, &strs)
+		case "a":
+			e.BestAskPrice = readString(iter, &strs)
+		case "A":
+			e.BestAskQty = readString(iter, &strs)
+		default:
+			iter.Skip()
+		}
+		return iter.Error == nil
+	})
+	return strs
+}
+
+// UnmarshalJSON decodes an aggregate trade event without reflection
+func (e *WsSpotAggTradeEvent) UnmarshalJSON(data []byte) error {
+	return decodeWs(data, e)
+}
+
+func (e *WsSpotAggTradeEvent) decodeJSON(iter *jsoniter.Iterator, strs []byte) []byte {
+	*e = WsSpotAggTradeEvent{}
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, field string) bool {
+		switch field {
+		case "e":
+			e.Event = readName(iter, &strs)
+		case "E":
+			e.Time = readInt64(iter)
+		case "s":
+			e.Symbol = readName(iter, &strs)
+		case "a":
+			e.AggregateTradeID = readInt64(iter)
+		case "p":
+			e.Price = readString(iter, &strs)
+		case "q":
+			e.Quantity = readString(iter, &strs)
+		case "f":
+			e.FirstBreakdownTradeID = readInt64(iter)
+		case "l":
+			e.LastBreakdownTradeID = readInt64(iter)
+		case "T":
+			e.TradeTime = readInt64(iter)
+		case "m":
+			e.IsBuyerMaker = readBool(iter)
+		case "M":
+			e.Ignore = readBool(iter)
+		default:
+			iter.Skip()
+		}
+		return iter.Error == nil
+	})
+	return strs
+}
+
+// UnmarshalJSON decodes an aggregate trade event without reflection
+func (e *WsFuturesAggTradeEvent) UnmarshalJSON(data []byte) error {
+	return decodeWs(data, e)
+}
+
+func (e *WsFuturesAggTradeEvent) decodeJSON(iter *jsoniter.Iterator, strs []byte) []byte {
+	*e = WsFuturesAggTradeEvent{}
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, field string) bool {
+		switch field {
+		case "e":
+			e.Event = readName(iter, &strs)
+		case "E":
+			e.Time = readInt64(iter)
+		case "s":
+			e.Symbol = readName(iter, &strs)
+		case "a":
+			e.AggregateTradeID = readInt64(iter)
+		case "p":
+			e.Price = readString(iter, &strs)
+		case "q":
+			e.Quantity = readString(iter, &strs)
+		case "f":
+			e.FirstBreakdownTradeID = readInt64(iter)
+		case "l":
+			e.LastBreakdownTradeID = readInt64(iter)
+		case "T":
+			e.TradeTime = readInt64(iter)
+		case "m":
+			e.IsBuyerMaker = readBool(iter)
+		default:
+			iter.Skip()
+		}
+		return iter.Error == nil
+	})
+	return strs
+}
+
+// UnmarshalJSON decodes a mark price event without reflection
+func (e *WsFuturesMarkPriceEvent) UnmarshalJSON(data []byte) error {
+	return decodeWs(data, e)
+}
+
+func (e *WsFuturesMarkPriceEvent) decodeJSON(iter *jsoniter.Iterator, strs []byte) []byte {
+	*e = WsFuturesMarkPriceEvent{}
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, field string) bool {
+		switch field {
+		case "e":
+			e.Event = readName(iter, &strs)
+		case "E":
+			e.Time = readInt64(iter)
+		case "s":
+			e.Symbol = readName(iter, &strs)
+		case "p":
+			e.MarkPrice = readString(iter, &strs)
+		case "i":
+			e.IndexPrice = readString(iter, &strs)
+		case "P":
+			e.EstimatedSettlePrice = readString(iter, &strs)
+		case "r":
+			e.FundingRate = readString(iter, &strs)
+		case "T":
+			e.NextFundingTime = readInt64(iter)
+		default:
+			iter.Skip()
+		}
+		return iter.Error == nil
+	})
+	return strs
+}
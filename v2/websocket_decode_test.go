@@ -0,0 +1,238 @@
+package aster
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// The shadow types drop the methods of the events, so that JSON.Unmarshal
+// decodes them with reflection
+type (
+	reflectDepthEvent           WsDepthEvent
+	reflectBookTickerEvent      WsBookTickerEvent
+	reflectSpotAggTradeEvent    WsSpotAggTradeEvent
+	reflectFuturesAggTradeEvent WsFuturesAggTradeEvent
+	reflectMarkPriceEvent       WsFuturesMarkPriceEvent
+	reflectLevels               struct {
+		Bids [][2]string `json:"b"`
+		Asks [][2]string `json:"a"`
+	}
+)
+
+var (
+	depthMessage           = []byte(`{"e":"depthUpdate","E":1700000000123,"T":1700000000120,"s":"BTCUSDT","U":1001,"u":1010,"pu":1000,"b":[["42000.10","1.500"],["41999.90","0.020"],["41999.50","3.000"]],"a":[["42000.20","0.700"],["42001.00","12.000"]]}`)
+	bookTickerMessage      = []byte(`{"e":"bookTicker","u":400900217,"E":1700000000123,"T":1700000000120,"s":"BTCUSDT","b":"42000.10","B":"1.500","a":"42000.20","A":"0.700"}`)
+	spotAggTradeMessage    = []byte(`{"e":"aggTrade","E":1700000000123,"s":"BTCUSDT","a":26129,"p":"42000.10","q":"0.010","f":100,"l":105,"T":1700000000120,"m":true,"M":true}`)
+	futuresAggTradeMessage = []byte(`{"e":"aggTrade","E":1700000000123,"s":"BTCUSDT","a":26129,"p":"42000.10","q":"0.010","f":100,"l":105,"T":1700000000120,"m":false}`)
+	markPriceMessage       = []byte(`{"e":"markPriceUpdate","E":1700000000123,"s":"BTCUSDT","p":"42000.11","i":"42000.05","P":"42010.00","r":"0.00010000","T":1700006400000}`)
+)
+
+func TestDecodeWsMatchesReflection(t *testing.T) {
+	tests := []struct {
+		name    string
+		message []byte
+		decoded wsDecoder
+		want    any
+	}{
+		{"depth", depthMessage, &WsDepthEvent{}, &reflectDepthEvent{}},
+		{"spot depth", []byte(`{"e":"depthUpdate","E":1,"s":"ETHUSDT","U":5,"u":6,"b":[],"a":[["1.0","2.0"]]}`), &WsDepthEvent{}, &reflectDepthEvent{}},
+		{"bookTicker", bookTickerMessage, &WsBookTickerEvent{}, &reflectBookTickerEvent{}},
+		{"spot aggTrade", spotAggTradeMessage, &WsSpotAggTradeEvent{}, &reflectSpotAggTradeEvent{}},
+		{"futures aggTrade", futuresAggTradeMessage, &WsFuturesAggTradeEvent{}, &reflectFuturesAggTradeEvent{}},
+		{"markPrice", markPriceMessage, &WsFuturesMarkPriceEvent{}, &reflectMarkPriceEvent{}},
+		{"escaped strings", []byte(`{"e":"bookTicker","s":"BTC\"USDT","b":"4\\2","B":"1","a":"2","A":"3","u":1}`), &WsBookTickerEvent{}, &reflectBookTickerEvent{}},
+		{"unknown fields and nulls", []byte(`{"x":{"y":[1,{"z":null}]},"e":"aggTrade","s":null,"a":7,"p":"1","q":"2","f":1,"l":1,"T":3,"m":null,"E":4}`), &WsFuturesAggTradeEvent{}, &reflectFuturesAggTradeEvent{}},
+		{"reordered fields", []byte(`{"T":1700006400000,"r":"0.0001","P":"1","i":"2","p":"3","s":"BTCUSDT","E":5,"e":"markPriceUpdate"}`), &WsFuturesMarkPriceEvent{}, &reflectMarkPriceEvent{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := decodeWs(test.message, test.decoded); err != nil {
+				t.Fatalf("decodeWs: %v", err)
+			}
+			if err := JSON.Unmarshal(test.message, test.want); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			got := reflect.ValueOf(test.decoded).Elem().Convert(reflect.TypeOf(test.want).Elem()).Interface()
+			if want := reflect.ValueOf(test.want).Elem().Interface(); !reflect.DeepEqual(got, want) {
+				t.Errorf("decoded %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeWsLevels(t *testing.T) {
+	var event WsDepthEvent
+	if err := decodeWs(depthMessage, &event); err != nil {
+		t.Fatal(err)
+	}
+	var want reflectLevels
+	if err := JSON.Unmarshal(depthMessage, &want); err != nil {
+		t.Fatal(err)
+	}
+	if len(event.Bids) != len(want.Bids) || len(event.Asks) != len(want.Asks) {
+		t.Fatalf("got %d bids and %d asks, want %d and %d", len(event.Bids), len(event.Asks), len(want.Bids), len(want.Asks))
+	}
+	for i, bid := range event.Bids {
+		if bid.Price != want.Bids[i][0] || bid.Quantity != want.Bids[i][1] {
+			t.Errorf("bid %d = %v, want %v", i, bid, want.Bids[i])
+		}
+	}
+	for i, ask := range event.Asks {
+		if ask.Price != want.Asks[i][0] || ask.Quantity != want.Asks[i][1] {
+			t.Errorf("ask %d = %v, want %v", i, ask, want.Asks[i])
+		}
+	}
+}
+
+func TestDecodeWsErrors(t *testing.T) {
+	for _, message := range []string{
+		``,
+		`{"e":"depthUpdate","E":1`,
+		`{"e":"depthUpdate","b":[["1","2"],}`,
+		`{"E":"not a number"}`,
+		`[1,2]`,
+	} {
+		var event WsDepthEvent
+		if err := decodeWs([]byte(message), &event); err == nil {
+			t.Errorf("decodeWs(%q) succeeded", message)
+		}
+		var shadow reflectDepthEvent
+		if err := JSON.Unmarshal([]byte(message), &shadow); err == nil {
+			t.Errorf("Unmarshal(%q) succeeded", message)
+		}
+	}
+}
+
+func TestDecodeCombined(t *testing.T) {
+	message := append(append([]byte(`{"stream":"btcusdt@bookTicker","data":`), bookTickerMessage...), '}')
+	var got, want WsBookTickerEvent
+	if err := decodeCombined(message, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := decodeWs(bookTickerMessage, &want); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("decoded %+v, want %+v", got, want)
+	}
+	if err := decodeCombined([]byte(`{"stream":"btcusdt@bookTicker"}`), &got); !errors.Is(err, ErrCombinedStreamNoData) {
+		t.Errorf("got %v, want ErrCombinedStreamNoData", err)
+	}
+}
+
+func TestDecodeWsEventPooled(t *testing.T) {
+	var pool wsEventPool[WsDepthEvent]
+	var kept []WsDepthEvent
+	handler := decodeWsEvent(&pool, &WsConfig{PooledEvents: true}, false, func(e *WsDepthEvent) {
+		cp := *e
+		cp.Bids = append([]Bid(nil), e.Bids...)
+		cp.Asks = append([]Ask(nil), e.Asks...)
+		kept = append(kept, cp)
+	}, func(err error) { t.Error(err) })
+	handler(depthMessage)
+	handler([]byte(`{"e":"depthUpdate","s":"ETHUSDT","U":1,"u":2,"b":[["1","2"]],"a":[]}`))
+	if len(kept) != 2 {
+		t.Fatalf("got %d events, want 2", len(kept))
+	}
+	if kept[0].Symbol != "BTCUSDT" || len(kept[0].Bids) != 3 || kept[0].Bids[0].Price != "42000.10" {
+		t.Errorf("first event changed after reuse: %+v", kept[0])
+	}
+	if kept[1].Symbol != "ETHUSDT" || kept[1].PrevLastUpdateID != 0 || len(kept[1].Bids) != 1 || len(kept[1].Asks) != 0 {
+		t.Errorf("second event keeps fields of the first: %+v", kept[1])
+	}
+}
+
+// benchmarkDecode compares decoding a message with reflection into S with
+// the hand-written decoder of T, with and without pooled events
+func benchmarkDecode[S, T any, P wsEvent[T]](b *testing.B, message []byte) {
+	b.Run("reflect", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(message)))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var event S
+			if err := JSON.Unmarshal(message, &event); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("handwritten", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(message)))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := decodeWs(message, P(new(T))); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		var pool wsEventPool[T]
+		handler := decodeWsEvent(&pool, &WsConfig{PooledEvents: true}, false, func(P) {}, func(err error) { b.Fatal(err) })
+		b.ReportAllocs()
+		b.SetBytes(int64(len(message)))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			handler(message)
+		}
+	})
+}
+
+func BenchmarkDecodeDepth(b *testing.B) {
+	benchmarkDecode[reflectDepthEvent, WsDepthEvent](b, depthMessage)
+}
+
+func BenchmarkDecodeBookTicker(b *testing.B) {
+	benchmarkDecode[reflectBookTickerEvent, WsBookTickerEvent](b, bookTickerMessage)
+}
+
+func BenchmarkDecodeSpotAggTrade(b *testing.B) {
+	benchmarkDecode[reflectSpotAggTradeEvent, WsSpotAggTradeEvent](b, spotAggTradeMessage)
+}
+
+func BenchmarkDecodeFuturesAggTrade(b *testing.B) {
+	benchmarkDecode[reflectFuturesAggTradeEvent, WsFuturesAggTradeEvent](b, futuresAggTradeMessage)
+}
+
+func BenchmarkDecodeMarkPrice(b *testing.B) {
+	benchmarkDecode[reflectMarkPriceEvent, WsFuturesMarkPriceEvent](b, markPriceMessage)
+}
+
+func TestUnmarshalUserData(t *testing.T) {
+	var spot WsSpotUserDataEvent
+	if err := JSON.Unmarshal([]byte(`{"e":"executionReport","E":1700000000123,"s":"BTCUSDT","c":"fixture-1","S":"BUY","o":"LIMIT","q":"0.010","p":"41000"}`), &spot); err != nil {
+		t.Fatal(err)
+	}
+	if spot.Event != UserDataEventTypeExecutionReport || spot.Time != 1700000000123 || spot.OrderUpdate == nil ||
+		spot.OrderUpdate.ClientOrderID != "fixture-1" || spot.OrderUpdate.OriginalPrice != "41000" {
+		t.Errorf("got %+v", spot)
+	}
+
+	// the event type is found when it is not the first field
+	if err := JSON.Unmarshal([]byte(`{"E":1700000000123,"listenKey":"k","e":"listenKeyExpired"}`), &spot); err != nil {
+		t.Fatal(err)
+	}
+	if spot.Event != UserDataEventTypeListenKeyExpired || spot.ListenKey != "k" || spot.Time != 1700000000123 {
+		t.Errorf("got %+v", spot)
+	}
+
+	var futures WsFuturesUserDataEvent
+	if err := JSON.Unmarshal([]byte(`{"e":"ORDER_TRADE_UPDATE","E":1700000000123,"T":1700000000120,"o":{"s":"BTCUSDT","c":"fixture-2"}}`), &futures); err != nil {
+		t.Fatal(err)
+	}
+	if futures.Event != UserDataEventTypeOrderTradeUpdate || futures.TransactionTime != 1700000000120 ||
+		futures.OrderUpdate == nil || futures.OrderUpdate.ClientOrderID != "fixture-2" {
+		t.Errorf("got %+v", futures)
+	}
+
+	// unknown events keep the message and its times
+	raw := `{"e":"STRATEGY_UPDATE","E":1700000000123,"T":1700000000120,"su":{"T":"ignored"}}`
+	if err := JSON.Unmarshal([]byte(raw), &futures); err != nil {
+		t.Fatal(err)
+	}
+	if futures.Event != "STRATEGY_UPDATE" || futures.Time != 1700000000123 || futures.TransactionTime != 1700000000120 ||
+		string(futures.Raw) != raw || futures.OrderUpdate != nil {
+		t.Errorf("got %+v", futures)
+	}
+}
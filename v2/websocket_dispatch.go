@@ -0,0 +1,169 @@
+package aster
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// DispatcherConfig configures a Dispatcher
+type DispatcherConfig[T any] struct {
+	Workers   int // worker goroutines, runtime.NumCPU() by default
+	QueueSize int // queued events per worker, 1024 by default
+
+	// Key returns the ordering key of an event, e.g. its symbol. Events
+	// with the same key are handled in order by the same worker.
+	Key func(event T) string
+}
+
+// DispatcherStats are the counters of a Dispatcher
+type DispatcherStats struct {
+	Dispatched uint64
+	Handled    uint64
+	// QueueDepths are the events queued per worker, MaxQueueDepths the
+	// highest depths seen
+	QueueDepths    []int
+	MaxQueueDepths []int
+}
+
+// Dispatcher spreads the events of a stream over worker goroutines by key,
+// so that a slow key does not hold up the others while the events of each
+// key keep their order. Dispatch blocks while the queue of the worker is
+// full, stalling the websocket reader like a slow handler would.
+//
+//	d := aster.NewDispatcher(aster.DispatcherConfig[*aster.WsBookTickerEvent]{
+//		Key: func(e *aster.WsBookTickerEvent) string { return e.Symbol },
+//	}, onBookTicker)
+//	defer d.Close()
+//	doneC, stopC, err := aster.WsFuturesAllBookTickerServe(d.Dispatch, errHandler)
+type Dispatcher[T any] struct {
+	cfg     DispatcherConfig[T]
+	handler func(event T)
+
+	queues     []chan T
+	maxDepths  []atomic.Int64
+	dispatched atomic.Uint64
+	handled    atomic.Uint64
+	wg         sync.WaitGroup
+
+	done      chan struct{} // closed by Close
+	closeOnce sync.Once
+}
+
+// NewDispatcher creates a dispatcher calling handler on its workers
+func NewDispatcher[T any](cfg DispatcherConfig[T], handler func(event T)) *Dispatcher[T] {
+	if cfg.Workers <= 0 {
+		cfg.Workers = runtime.NumCPU()
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+	d := &Dispatcher[T]{
+		cfg:       cfg,
+		handler:   handler,
+		queues:    make([]chan T, cfg.Workers),
+		maxDepths: make([]atomic.Int64, cfg.Workers),
+		done:      make(chan struct{}),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan T, cfg.QueueSize)
+		d.wg.Add(1)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+// Dispatch queues an event for the worker of its key. Events dispatched
+// after Close are dropped, and a Dispatch blocked on a full queue returns
+// when Close is called.
+func (d *Dispatcher[T]) Dispatch(event T) {
+	select {
+	case <-d.done:
+		return
+	default:
+	}
+	i := d.worker(event)
+	select {
+	case d.queues[i] <- event:
+	case <-d.done:
+		return
+	}
+	d.dispatched.Add(1)
+	depth := int64(len(d.queues[i]))
+	for max := d.maxDepths[i].Load(); depth > max; max = d.maxDepths[i].Load() {
+		if d.maxDepths[i].CompareAndSwap(max, depth) {
+			break
+		}
+	}
+}
+
+// DispatchAll dispatches the events of an array stream, e.g. all market
+// statistics
+func (d *Dispatcher[T]) DispatchAll(events []T) {
+	for _, event := range events {
+		d.Dispatch(event)
+	}
+}
+
+// Close stops accepting events and waits until the queued ones are handled.
+// Events dispatched concurrently with Close may be dropped.
+func (d *Dispatcher[T]) Close() {
+	d.closeOnce.Do(func() { close(d.done) })
+	d.wg.Wait()
+}
+
+// Stats returns the counters and queue depths
+func (d *Dispatcher[T]) Stats() DispatcherStats {
+	stats := DispatcherStats{
+		Dispatched:     d.dispatched.Load(),
+		Handled:        d.handled.Load(),
+		QueueDepths:    make([]int, len(d.queues)),
+		MaxQueueDepths: make([]int, len(d.queues)),
+	}
+	for i, q := range d.queues {
+		stats.QueueDepths[i] = len(q)
+		stats.MaxQueueDepths[i] = int(d.maxDepths[i].Load())
+	}
+	return stats
+}
+
+// work handles the events of a queue until the dispatcher is closed and
+// the queue is drained
+func (d *Dispatcher[T]) work(q chan T) {
+	defer d.wg.Done()
+	for {
+		select {
+		case event := <-q:
+			d.handle(event)
+		case <-d.done:
+			for {
+				select {
+				case event := <-q:
+					d.handle(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *Dispatcher[T]) handle(event T) {
+	d.handler(event)
+	d.handled.Add(1)
+}
+
+// worker returns the worker of the key of an event
+func (d *Dispatcher[T]) worker(event T) int {
+	if len(d.queues) == 1 || d.cfg.Key == nil {
+		return 0
+	}
+	// FNV-1a, without allocating a hasher per event
+	key := d.cfg.Key(event)
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return int(h % uint32(len(d.queues)))
+}
@@ -0,0 +1,89 @@
+package aster
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatcherOrder(t *testing.T) {
+	var mu sync.Mutex
+	got := map[string][]int{}
+	d := NewDispatcher(DispatcherConfig[[2]int]{
+		Workers: 4,
+		Key:     func(e [2]int) string { return strconv.Itoa(e[0]) },
+	}, func(e [2]int) {
+		mu.Lock()
+		got[strconv.Itoa(e[0])] = append(got[strconv.Itoa(e[0])], e[1])
+		mu.Unlock()
+	})
+	for i := 0; i < 100; i++ {
+		d.Dispatch([2]int{i % 10, i})
+	}
+	d.Close()
+
+	if stats := d.Stats(); stats.Dispatched != 100 || stats.Handled != 100 {
+		t.Errorf("got stats %+v, want 100 events dispatched and handled", stats)
+	}
+	for key, events := range got {
+		for i := 1; i < len(events); i++ {
+			if events[i] < events[i-1] {
+				t.Errorf("key %s handled out of order: %v", key, events)
+				break
+			}
+		}
+	}
+}
+
+func TestDispatcherCloseUnblocksDispatch(t *testing.T) {
+	release := make(chan struct{})
+	d := NewDispatcher(DispatcherConfig[int]{Workers: 1, QueueSize: 1}, func(int) { <-release })
+	d.Dispatch(1) // handled, blocking the worker
+	d.Dispatch(2) // queued
+
+	blocked := make(chan struct{})
+	go func() {
+		d.Dispatch(3) // the queue is full
+		close(blocked)
+	}()
+	closed := make(chan struct{})
+	go func() {
+		d.Close()
+		close(closed)
+	}()
+	select {
+	case <-blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Dispatch blocked after Close")
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+	if stats := d.Stats(); stats.Handled != stats.Dispatched || stats.Handled < 2 {
+		t.Errorf("got stats %+v, want the queued events handled", stats)
+	}
+	d.Dispatch(4)
+	if stats := d.Stats(); stats.Dispatched > 3 {
+		t.Errorf("dispatched an event after Close: %+v", stats)
+	}
+}
+
+func TestDispatcherWorker(t *testing.T) {
+	d := NewDispatcher(DispatcherConfig[string]{
+		Workers: 7,
+		Key:     func(e string) string { return e },
+	}, func(string) {})
+	defer d.Close()
+	for _, key := range []string{"", "BTCUSDT", "ETHUSDT", "1000SHIBUSDT"} {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		if got, want := d.worker(key), int(h.Sum32()%7); got != want {
+			t.Errorf("worker(%q) = %d, want %d", key, got, want)
+		}
+	}
+}
@@ -4,50 +4,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2/common"
 )
 
 // Futures WebSocket services
 
 // WsFuturesDepthServe serves websocket depth stream for futures
-func WsFuturesDepthServe(symbol string, handler WsDepthHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsFuturesDepthServe(symbol string, handler WsDepthHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/%s@depth", getWsEndpoint(true, false), strings.ToLower(symbol))
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		event := new(WsDepthEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+	cfg := newMarketWsConfig(true, endpoint, opts...)
+	wsHandler := decodeWsEvent(&depthEvents, cfg, false, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // WsFuturesPartialDepthServe serves websocket partial depth stream for futures
-func WsFuturesPartialDepthServe(symbol string, levels int, handler WsDepthHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsFuturesPartialDepthServe(symbol string, levels int, handler WsDepthHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/%s@depth%d@100ms", getWsEndpoint(true, false), strings.ToLower(symbol), levels)
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		event := new(WsDepthEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+	cfg := newMarketWsConfig(true, endpoint, opts...)
+	wsHandler := decodeWsEvent(&depthEvents, cfg, false, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // WsFuturesKlineServe serves websocket kline stream for futures
-func WsFuturesKlineServe(symbol string, interval string, handler WsFuturesKlineHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsFuturesKlineServe(symbol string, interval string, handler WsFuturesKlineHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/%s@kline_%s", getWsEndpoint(true, false), strings.ToLower(symbol), interval)
-	cfg := newWsConfig(endpoint)
+	cfg := newMarketWsConfig(true, endpoint, opts...)
 	wsHandler := func(message []byte) {
 		event := new(WsFuturesKlineEvent)
 		err := json.Unmarshal(message, event)
@@ -63,505 +46,242 @@ func WsFuturesKlineServe(symbol string, interval string, handler WsFuturesKlineH
 }
 
 // WsFuturesAggTradeServe serves websocket aggregate trade stream for futures
-func WsFuturesAggTradeServe(symbol string, handler WsFuturesAggTradeHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsFuturesAggTradeServe(symbol string, handler WsFuturesAggTradeHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/%s@aggTrade", getWsEndpoint(true, false), strings.ToLower(symbol))
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		event := new(WsFuturesAggTradeEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+	cfg := newMarketWsConfig(true, endpoint, opts...)
+	wsHandler := decodeWsEvent(&futuresAggTradeEvents, cfg, false, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // WsFuturesMarkPriceServe serves websocket mark price stream for futures
-func WsFuturesMarkPriceServe(symbol string, handler WsFuturesMarkPriceHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsFuturesMarkPriceServe(symbol string, handler WsFuturesMarkPriceHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/%s@markPrice", getWsEndpoint(true, false), strings.ToLower(symbol))
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		event := new(WsFuturesMarkPriceEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+	cfg := newMarketWsConfig(true, endpoint, opts...)
+	wsHandler := decodeWsEvent(&markPriceEvents, cfg, false, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // WsFuturesAllMarkPriceServe serves websocket all mark price stream for futures
-func WsFuturesAllMarkPriceServe(handler WsFuturesMarkPriceHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsFuturesAllMarkPriceServe(handler WsFuturesMarkPriceHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/!markPrice@arr", getWsEndpoint(true, false))
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		var events []*WsFuturesMarkPriceEvent
-		err := json.Unmarshal(message, &events)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		for _, event := range events {
-			handler(event)
-		}
-	}
+	cfg := newMarketWsConfig(true, endpoint, opts...)
+	wsHandler := decodeWsEvents(&markPriceEvents, cfg, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // WsFuturesBookTickerServe serves websocket book ticker stream for futures
-func WsFuturesBookTickerServe(symbol string, handler WsBookTickerHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsFuturesBookTickerServe(symbol string, handler WsBookTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/%s@bookTicker", getWsEndpoint(true, false), strings.ToLower(symbol))
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		event := new(WsBookTickerEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+	cfg := newMarketWsConfig(true, endpoint, opts...)
+	wsHandler := decodeWsEvent(&bookTickerEvents, cfg, false, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // WsFuturesAllBookTickerServe serves websocket all book tickers stream for futures
-func WsFuturesAllBookTickerServe(handler WsBookTickerHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsFuturesAllBookTickerServe(handler WsBookTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/!bookTicker", getWsEndpoint(true, false))
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		event := new(WsBookTickerEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+	cfg := newMarketWsConfig(true, endpoint, opts...)
+	wsHandler := decodeWsEvent(&bookTickerEvents, cfg, false, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // WsFuturesUserDataServe serves websocket user data stream for futures
-func WsFuturesUserDataServe(listenKey string, handler WsFuturesUserDataHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsFuturesUserDataServe(listenKey string, handler WsFuturesUserDataHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/%s", getWsEndpoint(true, false), listenKey)
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		// First check the event type using a map
-		var rawMap map[string]interface{}
-		err := json.Unmarshal(message, &rawMap)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		
-		eventTypeStr, ok := rawMap["e"].(string)
-		if !ok {
-			if errHandler != nil {
-				errHandler(fmt.Errorf("event type 'e' is not a string"))
-			}
-			return
-		}
-
-		event := new(WsFuturesUserDataEvent)
-		event.Event = eventTypeStr
-
-		switch eventTypeStr {
-		case "ACCOUNT_UPDATE":
-			var accountUpdate WsFuturesAccountUpdate
-			err = json.Unmarshal(message, &accountUpdate)
-			if err == nil {
-				event.Time = accountUpdate.Time
-				event.TransactionTime = accountUpdate.TransactionTime
-				event.AccountUpdate = &accountUpdate
-			}
-		case "ORDER_TRADE_UPDATE":
-			var orderData struct {
-				Event           string              `json:"e"`
-				Time            int64               `json:"E"`
-				TransactionTime int64               `json:"T"`
-				Order           WsFuturesOrderUpdate `json:"o"`
-			}
-			err = json.Unmarshal(message, &orderData)
-			if err == nil {
-				event.Time = orderData.Time
-				event.TransactionTime = orderData.TransactionTime
-				event.OrderUpdate = &orderData.Order
-			}
-		case "ACCOUNT_CONFIG_UPDATE":
-			var configData struct {
-				Event                string                       `json:"e"`
-				Time                 int64                        `json:"E"`
-				TransactionTime      int64                        `json:"T"`
-				AccountConfigUpdate  WsFuturesAccountConfigUpdate `json:"ac"`
-			}
-			err = json.Unmarshal(message, &configData)
-			if err == nil {
-				event.Time = configData.Time
-				event.TransactionTime = configData.TransactionTime
-				event.AccountConfigUpdate = &configData.AccountConfigUpdate
-			}
-		case "MARGIN_CALL":
-			var marginCall WsFuturesMarginCall
-			err = json.Unmarshal(message, &marginCall)
-			if err == nil {
-				event.MarginCall = &marginCall
-			}
-		}
-
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
+	cfg := newMarketWsConfig(true, endpoint, opts...)
+	cfg.userData = true
+	return wsServe(cfg, futuresUserDataHandler(handler, errHandler), errHandler)
+}
 
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+// futuresUserDataHandler decodes futures user data events
+func futuresUserDataHandler(handler WsFuturesUserDataHandler, errHandler ErrHandler) WsHandler {
+	return decodeStream(handler, errHandler)
 }
 
 // Combined streams for futures
 
 // WsCombinedFuturesDepthServe serves websocket combined depth stream for futures
-func WsCombinedFuturesDepthServe(symbols []string, handler WsDepthHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsCombinedFuturesDepthServe(symbols []string, handler WsDepthHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	var streams []string
 	for _, s := range symbols {
 		streams = append(streams, fmt.Sprintf("%s@depth", strings.ToLower(s)))
 	}
-	endpoint := fmt.Sprintf("%s?streams=%s", combinedFuturesBaseURL, strings.Join(streams, "/"))
-	return wsCombinedFuturesDepthServe(endpoint, handler, errHandler)
+	endpoint := fmt.Sprintf("%s?streams=%s", getCombinedEndpoint(true), strings.Join(streams, "/"))
+	return wsCombinedFuturesDepthServe(endpoint, handler, errHandler, opts...)
 }
 
 // WsCombinedFuturesBookTickerServe serves websocket combined book ticker stream for futures
-func WsCombinedFuturesBookTickerServe(symbols []string, handler WsBookTickerHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsCombinedFuturesBookTickerServe(symbols []string, handler WsBookTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	var streams []string
 	for _, s := range symbols {
 		streams = append(streams, fmt.Sprintf("%s@bookTicker", strings.ToLower(s)))
 	}
-	endpoint := fmt.Sprintf("%s?streams=%s", combinedFuturesBaseURL, strings.Join(streams, "/"))
-	return wsCombinedFuturesBookTickerServe(endpoint, handler, errHandler)
+	endpoint := fmt.Sprintf("%s?streams=%s", getCombinedEndpoint(true), strings.Join(streams, "/"))
+	return wsCombinedFuturesBookTickerServe(endpoint, handler, errHandler, opts...)
 }
 
 // Internal function for combined futures depth
-func wsCombinedFuturesDepthServe(endpoint string, handler WsDepthHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		var combinedEvent struct {
-			Stream string          `json:"stream"`
-			Data   json.RawMessage `json:"data"`
-		}
-		err := json.Unmarshal(message, &combinedEvent)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-
-		event := new(WsDepthEvent)
-		err = json.Unmarshal(combinedEvent.Data, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+func wsCombinedFuturesDepthServe(endpoint string, handler WsDepthHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	cfg := newMarketWsConfig(true, endpoint, opts...)
+	wsHandler := decodeWsEvent(&depthEvents, cfg, true, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // Internal function for combined futures book ticker
-func wsCombinedFuturesBookTickerServe(endpoint string, handler WsBookTickerHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		var combinedEvent struct {
-			Stream string          `json:"stream"`
-			Data   json.RawMessage `json:"data"`
-		}
-		err := json.Unmarshal(message, &combinedEvent)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-
-		event := new(WsBookTickerEvent)
-		err = json.Unmarshal(combinedEvent.Data, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+func wsCombinedFuturesBookTickerServe(endpoint string, handler WsBookTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	cfg := newMarketWsConfig(true, endpoint, opts...)
+	wsHandler := decodeWsEvent(&bookTickerEvents, cfg, true, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // Futures WebSocket functions with LocalAddress support
 
 // WsFuturesDepthServeWithLocalAddr serves websocket depth stream for futures with local address binding
+//
+// Deprecated: use WsFuturesDepthServe with WithStreamLocalAddress.
 func WsFuturesDepthServeWithLocalAddr(symbol string, handler WsDepthHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	endpoint := fmt.Sprintf("%s/ws/%s@depth", getWsEndpoint(true, false), strings.ToLower(symbol))
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
-	}
-	wsHandler := func(message []byte) {
-		event := new(WsDepthEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+	return WsFuturesDepthServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
 // WsFuturesKlineServeWithLocalAddr serves websocket kline stream for futures with local address binding
+//
+// Deprecated: use WsFuturesKlineServe with WithStreamLocalAddress.
 func WsFuturesKlineServeWithLocalAddr(symbol string, interval string, handler WsFuturesKlineHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	endpoint := fmt.Sprintf("%s/ws/%s@kline_%s", getWsEndpoint(true, false), strings.ToLower(symbol), interval)
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
-	}
-	wsHandler := func(message []byte) {
-		event := new(WsFuturesKlineEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+	return WsFuturesKlineServe(symbol, interval, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
 // WsFuturesAggTradeServeWithLocalAddr serves websocket aggregate trade stream for futures with local address binding
+//
+// Deprecated: use WsFuturesAggTradeServe with WithStreamLocalAddress.
 func WsFuturesAggTradeServeWithLocalAddr(symbol string, handler WsFuturesAggTradeHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	endpoint := fmt.Sprintf("%s/ws/%s@aggTrade", getWsEndpoint(true, false), strings.ToLower(symbol))
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
-	}
-	wsHandler := func(message []byte) {
-		event := new(WsFuturesAggTradeEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+	return WsFuturesAggTradeServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
 // WsFuturesBookTickerServeWithLocalAddr serves websocket book ticker stream for futures with local address binding
+//
+// Deprecated: use WsFuturesBookTickerServe with WithStreamLocalAddress.
 func WsFuturesBookTickerServeWithLocalAddr(symbol string, handler WsBookTickerHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	endpoint := fmt.Sprintf("%s/ws/%s@bookTicker", getWsEndpoint(true, false), strings.ToLower(symbol))
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
-	}
-	wsHandler := func(message []byte) {
-		event := new(WsBookTickerEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+	return WsFuturesBookTickerServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
 // WsFuturesMarkPriceServeWithLocalAddr serves websocket mark price stream for futures with local address binding
+//
+// Deprecated: use WsFuturesMarkPriceServe with WithStreamLocalAddress.
 func WsFuturesMarkPriceServeWithLocalAddr(symbol string, handler WsFuturesMarkPriceHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	endpoint := fmt.Sprintf("%s/ws/%s@markPrice", getWsEndpoint(true, false), strings.ToLower(symbol))
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
-	}
-	wsHandler := func(message []byte) {
-		event := new(WsFuturesMarkPriceEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+	return WsFuturesMarkPriceServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
 // WsFuturesUserDataServeWithLocalAddr serves websocket user data stream for futures with local address binding
+//
+// Deprecated: use WsFuturesUserDataServe with WithStreamLocalAddress.
 func WsFuturesUserDataServeWithLocalAddr(listenKey string, handler WsFuturesUserDataHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	endpoint := fmt.Sprintf("%s/ws/%s", getWsEndpoint(true, false), listenKey)
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
-	}
-	wsHandler := func(message []byte) {
-		// First check the event type using a map
-		var rawMap map[string]interface{}
-		err := json.Unmarshal(message, &rawMap)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		
-		eventTypeStr, ok := rawMap["e"].(string)
-		if !ok {
-			if errHandler != nil {
-				errHandler(fmt.Errorf("event type 'e' is not a string"))
-			}
-			return
-		}
-		
-		// Parse based on event type
-		switch eventTypeStr {
-		case "ACCOUNT_UPDATE":
-			event := new(WsFuturesAccountUpdate)
-			err := json.Unmarshal(message, event)
-			if err != nil {
-				if errHandler != nil {
-					errHandler(err)
-				}
-				return
-			}
-			userDataEvent := &WsFuturesUserDataEvent{
-				Event:         eventTypeStr,
-				AccountUpdate: event,
-			}
-			handler(userDataEvent)
-		case "ORDER_TRADE_UPDATE":
-			event := new(WsFuturesOrderUpdate)
-			err := json.Unmarshal(message, event)
-			if err != nil {
-				if errHandler != nil {
-					errHandler(err)
-				}
-				return
-			}
-			userDataEvent := &WsFuturesUserDataEvent{
-				Event:       eventTypeStr,
-				OrderUpdate: event,
-			}
-			handler(userDataEvent)
-		case "ACCOUNT_CONFIG_UPDATE":
-			event := new(WsFuturesAccountConfigUpdate)
-			err := json.Unmarshal(message, event)
-			if err != nil {
-				if errHandler != nil {
-					errHandler(err)
-				}
-				return
-			}
-			userDataEvent := &WsFuturesUserDataEvent{
-				Event:               eventTypeStr,
-				AccountConfigUpdate: event,
-			}
-			handler(userDataEvent)
-		case "MARGIN_CALL":
-			event := new(WsFuturesMarginCall)
-			err := json.Unmarshal(message, event)
-			if err != nil {
-				if errHandler != nil {
-					errHandler(err)
-				}
-				return
-			}
-			userDataEvent := &WsFuturesUserDataEvent{
-				Event:      eventTypeStr,
-				MarginCall: event,
-			}
-			handler(userDataEvent)
-		default:
-			if errHandler != nil {
-				errHandler(fmt.Errorf("unknown event type: %s", eventTypeStr))
-			}
-		}
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+	return WsFuturesUserDataServe(listenKey, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
 // WsCombinedFuturesBookTickerServeWithLocalAddr serves websocket combined book ticker stream for futures with local address binding
+//
+// Deprecated: use WsCombinedFuturesBookTickerServe with WithStreamLocalAddress.
 func WsCombinedFuturesBookTickerServeWithLocalAddr(symbols []string, handler WsBookTickerHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	var streams []string
-	for _, s := range symbols {
-		streams = append(streams, fmt.Sprintf("%s@bookTicker", strings.ToLower(s)))
+	return WsCombinedFuturesBookTickerServe(symbols, handler, errHandler, WithStreamLocalAddress(localAddr))
+}
+
+// Futures market streams
+
+// wsFuturesStreamServe serves a raw futures stream with the options
+func wsFuturesStreamServe(stream string, handler WsHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	endpoint := fmt.Sprintf("%s/ws/%s", getWsEndpoint(true, false), stream)
+	cfg := newMarketWsConfig(true, endpoint, opts...)
+	return wsServe(cfg, handler, errHandler)
+}
+
+// updateRateSuffix returns the stream suffix of an update rate of 1s or the
+// default 3s
+func updateRateSuffix(rate time.Duration) (string, error) {
+	switch rate {
+	case 3 * time.Second:
+		return "", nil
+	case time.Second:
+		return "@1s", nil
 	}
-	endpoint := fmt.Sprintf("%s?streams=%s", combinedFuturesBaseURL, strings.Join(streams, "/"))
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
+	return "", fmt.Errorf("aster: invalid update rate %s, must be 1s or 3s", rate)
+}
+
+// WsFuturesLiquidationOrderServe serves websocket liquidation order stream
+// of a symbol
+func WsFuturesLiquidationOrderServe(symbol string, handler WsFuturesLiquidationOrderHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	stream := fmt.Sprintf("%s@forceOrder", strings.ToLower(symbol))
+	return wsFuturesStreamServe(stream, decodeStream(handler, errHandler), errHandler, opts...)
+}
+
+// WsFuturesAllLiquidationOrderServe serves websocket liquidation order
+// stream of all symbols
+func WsFuturesAllLiquidationOrderServe(handler WsFuturesLiquidationOrderHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return wsFuturesStreamServe("!forceOrder@arr", decodeStream(handler, errHandler), errHandler, opts...)
+}
+
+// WsFuturesMiniMarketTickerServe serves websocket 24h mini ticker stream of
+// a symbol
+func WsFuturesMiniMarketTickerServe(symbol string, handler WsFuturesMiniMarketTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	stream := fmt.Sprintf("%s@miniTicker", strings.ToLower(symbol))
+	return wsFuturesStreamServe(stream, decodeStream(handler, errHandler), errHandler, opts...)
+}
+
+// WsFuturesAllMiniMarketTickerServe serves websocket 24h mini ticker stream
+// of all symbols
+func WsFuturesAllMiniMarketTickerServe(handler WsFuturesAllMiniMarketTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return wsFuturesStreamServe("!miniTicker@arr", decodeStreamValue(handler, errHandler), errHandler, opts...)
+}
+
+// WsFuturesMarketTickerServe serves websocket 24h ticker stream of a symbol
+func WsFuturesMarketTickerServe(symbol string, handler WsFuturesMarketTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	stream := fmt.Sprintf("%s@ticker", strings.ToLower(symbol))
+	return wsFuturesStreamServe(stream, decodeStream(handler, errHandler), errHandler, opts...)
+}
+
+// WsFuturesAllMarketTickerServe serves websocket 24h ticker stream of all
+// symbols
+func WsFuturesAllMarketTickerServe(handler WsFuturesAllMarketTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return wsFuturesStreamServe("!ticker@arr", decodeStreamValue(handler, errHandler), errHandler, opts...)
+}
+
+// WsFuturesContinuousKlineServe serves websocket continuous contract kline
+// stream of a pair, e.g. "BTCUSDT" and "PERPETUAL"
+func WsFuturesContinuousKlineServe(pair, contractType string, interval common.Interval, handler WsFuturesContinuousKlineHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	stream := fmt.Sprintf("%s_%s@continuousKline_%s", strings.ToLower(pair), strings.ToLower(contractType), interval)
+	return wsFuturesStreamServe(stream, decodeStream(handler, errHandler), errHandler, opts...)
+}
+
+// WsFuturesIndexPriceServe serves websocket index price stream of a pair,
+// updated every 3s or 1s
+func WsFuturesIndexPriceServe(pair string, rate time.Duration, handler WsFuturesIndexPriceHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	suffix, err := updateRateSuffix(rate)
+	if err != nil {
+		return nil, nil, err
 	}
-	wsHandler := func(message []byte) {
-		var combinedEvent struct {
-			Stream string          `json:"stream"`
-			Data   json.RawMessage `json:"data"`
-		}
-		err := json.Unmarshal(message, &combinedEvent)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
+	stream := fmt.Sprintf("%s@indexPrice%s", strings.ToLower(pair), suffix)
+	return wsFuturesStreamServe(stream, decodeStream(handler, errHandler), errHandler, opts...)
+}
 
-		event := new(WsBookTickerEvent)
-		err = json.Unmarshal(combinedEvent.Data, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
+// WsFuturesMarkPriceServeWithRate serves websocket mark price stream of a
+// symbol, updated every 3s or 1s
+func WsFuturesMarkPriceServeWithRate(symbol string, rate time.Duration, handler WsFuturesMarkPriceHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	suffix, err := updateRateSuffix(rate)
+	if err != nil {
+		return nil, nil, err
 	}
-	return wsServe(cfg, wsHandler, errHandler)
-}
\ No newline at end of file
+	endpoint := fmt.Sprintf("%s/ws/%s@markPrice%s", getWsEndpoint(true, false), strings.ToLower(symbol), suffix)
+	cfg := newMarketWsConfig(true, endpoint, opts...)
+	return wsServe(cfg, decodeWsEvent(&markPriceEvents, cfg, false, handler, errHandler), errHandler)
+}
+
+// WsFuturesAllMarkPriceServeWithRate serves websocket mark price stream of
+// all symbols, updated every 3s or 1s
+func WsFuturesAllMarkPriceServeWithRate(rate time.Duration, handler WsFuturesMarkPriceHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	suffix, err := updateRateSuffix(rate)
+	if err != nil {
+		return nil, nil, err
+	}
+	endpoint := fmt.Sprintf("%s/ws/!markPrice@arr%s", getWsEndpoint(true, false), suffix)
+	cfg := newMarketWsConfig(true, endpoint, opts...)
+	return wsServe(cfg, decodeWsEvents(&markPriceEvents, cfg, handler, errHandler), errHandler)
+}
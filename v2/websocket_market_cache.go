@@ -0,0 +1,367 @@
+package aster
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrMarketCacheConnected is returned by Connect on a connected cache
+var ErrMarketCacheConnected = errors.New("aster: market cache already connected")
+
+// MarketField selects groups of values of a MarketState
+type MarketField int
+
+const (
+	// MarketBook is the best bid and ask, from the bookTicker stream
+	MarketBook MarketField = 1 << iota
+	// MarketMark is the mark price and funding, from the markPrice stream
+	MarketMark
+	// MarketTicker is the last price and 24hr statistics, from the ticker
+	// stream
+	MarketTicker
+
+	MarketAll = MarketBook | MarketMark | MarketTicker
+)
+
+// MarketState is the latest market data of a symbol. Each group of values
+// has the time it was received, zero until then.
+type MarketState struct {
+	Symbol string
+
+	BidPrice float64
+	BidQty   float64
+	AskPrice float64
+	AskQty   float64
+	BookTime time.Time
+
+	MarkPrice       float64
+	IndexPrice      float64
+	FundingRate     float64
+	NextFundingTime int64 // ms
+	MarkTime        time.Time
+
+	LastPrice          float64
+	PriceChangePercent float64
+	BaseVolume         float64
+	QuoteVolume        float64
+	TickerTime         time.Time
+}
+
+// Received returns the oldest receive time of the fields, zero when one of
+// them was never received
+func (s *MarketState) Received(fields MarketField) time.Time {
+	var oldest time.Time
+	for _, f := range []struct {
+		field MarketField
+		t     time.Time
+	}{{MarketBook, s.BookTime}, {MarketMark, s.MarkTime}, {MarketTicker, s.TickerTime}} {
+		if fields&f.field == 0 {
+			continue
+		}
+		if f.t.IsZero() {
+			return time.Time{}
+		}
+		if oldest.IsZero() || f.t.Before(oldest) {
+			oldest = f.t
+		}
+	}
+	return oldest
+}
+
+// MarketCache keeps the latest best bid and ask, mark price, funding rate
+// and last price of a set of symbols from one stream connection, so that
+// components share it instead of opening their own streams. Reads are lock
+// free. All methods are safe for concurrent use.
+type MarketCache struct {
+	// LocalAddress binds the connection to a local IP address
+	LocalAddress string
+	// Options configure the connection, e.g. WithStreamProxy
+	Options []StreamOption
+
+	isFutures  bool
+	errHandler ErrHandler
+	symbols    []string
+	entries    map[string]*marketEntry // fixed at creation
+
+	mu        sync.Mutex
+	connected bool // Connect was called
+	closed    bool
+	client    *StreamClient // set once connected
+
+	subsMu sync.Mutex
+	subs   atomic.Pointer[[]*marketSub]
+}
+
+// marketEntry holds the state of a symbol. Updates copy the state under mu
+// and publish the copy.
+type marketEntry struct {
+	mu    sync.Mutex
+	state atomic.Pointer[MarketState]
+}
+
+// marketSub is a change subscription
+type marketSub struct {
+	fields MarketField
+	fn     func(state MarketState, changed MarketField)
+}
+
+// NewSpotMarketCache creates a market cache of spot symbols, fed by the
+// bookTicker and ticker streams
+func NewSpotMarketCache(symbols []string, errHandler ErrHandler) *MarketCache {
+	return newMarketCache(false, symbols, errHandler)
+}
+
+// NewFuturesMarketCache creates a market cache of futures symbols, fed by
+// the bookTicker, markPrice and ticker streams
+func NewFuturesMarketCache(symbols []string, errHandler ErrHandler) *MarketCache {
+	return newMarketCache(true, symbols, errHandler)
+}
+
+func newMarketCache(isFutures bool, symbols []string, errHandler ErrHandler) *MarketCache {
+	c := &MarketCache{
+		isFutures:  isFutures,
+		errHandler: errHandler,
+		entries:    make(map[string]*marketEntry, len(symbols)),
+	}
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		if c.entries[symbol] != nil {
+			continue
+		}
+		e := &marketEntry{}
+		e.state.Store(&MarketState{Symbol: symbol})
+		c.entries[symbol] = e
+		c.symbols = append(c.symbols, symbol)
+	}
+	c.subs.Store(&[]*marketSub{})
+	return c
+}
+
+// Connect opens the stream connection and subscribes to the streams of the
+// symbols. With WsReconnect set, it reconnects until Close. A cache
+// connects once, later calls return ErrMarketCacheConnected.
+func (c *MarketCache) Connect(ctx context.Context) error {
+	client := newStreamClient(c.isFutures, c.errHandler)
+	client.LocalAddress = c.LocalAddress
+	client.Options = c.Options
+	c.mu.Lock()
+	if c.connected {
+		c.mu.Unlock()
+		return ErrMarketCacheConnected
+	}
+	c.connected = true
+	c.mu.Unlock()
+
+	if err := client.Connect(); err != nil {
+		c.reset()
+		return err
+	}
+	err := client.SubscribeBookTicker(ctx, c.HandleBookTicker, c.symbols...)
+	if err == nil && c.isFutures {
+		err = client.SubscribeMarkPrice(ctx, c.HandleMarkPrice, c.symbols...)
+	}
+	if err == nil {
+		if c.isFutures {
+			err = client.SubscribeFuturesTicker(ctx, c.HandleFuturesTicker, c.symbols...)
+		} else {
+			err = client.SubscribeSpotTicker(ctx, c.HandleSpotTicker, c.symbols...)
+		}
+	}
+	if err != nil {
+		client.Close()
+		c.reset()
+		return err
+	}
+	c.mu.Lock()
+	closed := c.closed
+	if !closed {
+		c.client = client
+	}
+	c.mu.Unlock()
+	if closed {
+		client.Close()
+		return ErrStreamClientClosed
+	}
+	return nil
+}
+
+// reset allows Connect again after a failure
+func (c *MarketCache) reset() {
+	c.mu.Lock()
+	c.connected = false
+	c.mu.Unlock()
+}
+
+// Close closes the stream connection, including one Connect is opening
+func (c *MarketCache) Close() {
+	c.mu.Lock()
+	c.closed = true
+	client := c.client
+	c.mu.Unlock()
+	if client != nil {
+		client.Close()
+	}
+}
+
+// Done returns a channel closed once the stream connection stops, nil
+// before Connect
+func (c *MarketCache) Done() <-chan struct{} {
+	c.mu.Lock()
+	client := c.client
+	c.mu.Unlock()
+	if client == nil {
+		return nil
+	}
+	return client.Done()
+}
+
+// Get returns the state of a symbol
+func (c *MarketCache) Get(symbol string) (MarketState, bool) {
+	e := c.entries[strings.ToUpper(symbol)]
+	if e == nil {
+		return MarketState{}, false
+	}
+	return *e.state.Load(), true
+}
+
+// Snapshot returns the states of all symbols, in the order of the symbols
+func (c *MarketCache) Snapshot() []MarketState {
+	states := make([]MarketState, len(c.symbols))
+	for i, symbol := range c.symbols {
+		states[i] = *c.entries[symbol].state.Load()
+	}
+	return states
+}
+
+// Stale reports whether any of the fields of a symbol was never received or
+// is older than maxAge. Unknown symbols are stale.
+func (c *MarketCache) Stale(symbol string, fields MarketField, maxAge time.Duration) bool {
+	state, ok := c.Get(symbol)
+	if !ok {
+		return true
+	}
+	received := state.Received(fields)
+	return received.IsZero() || time.Since(received) > maxAge
+}
+
+// StaleSymbols returns the symbols for which Stale is true
+func (c *MarketCache) StaleSymbols(fields MarketField, maxAge time.Duration) []string {
+	var stale []string
+	for _, symbol := range c.symbols {
+		if c.Stale(symbol, fields, maxAge) {
+			stale = append(stale, symbol)
+		}
+	}
+	return stale
+}
+
+// Subscribe calls fn with the new state whenever one of the fields of a
+// symbol changes. Calls for one symbol are serialized and run on the stream
+// goroutine, so fn must not block. The returned function cancels the
+// subscription.
+func (c *MarketCache) Subscribe(fields MarketField, fn func(state MarketState, changed MarketField)) (cancel func()) {
+	sub := &marketSub{fields: fields, fn: fn}
+	c.subsMu.Lock()
+	subs := append(append([]*marketSub(nil), *c.subs.Load()...), sub)
+	c.subs.Store(&subs)
+	c.subsMu.Unlock()
+	return func() {
+		c.subsMu.Lock()
+		defer c.subsMu.Unlock()
+		old := *c.subs.Load()
+		subs := make([]*marketSub, 0, len(old))
+		for _, s := range old {
+			if s != sub {
+				subs = append(subs, s)
+			}
+		}
+		c.subs.Store(&subs)
+	}
+}
+
+// HandleBookTicker updates the best bid and ask, e.g. from another stream
+func (c *MarketCache) HandleBookTicker(ev *WsBookTickerEvent) {
+	c.update(ev.Symbol, MarketBook, func(s *MarketState) {
+		s.BidPrice = c.parse(ev.BestBidPrice)
+		s.BidQty = c.parse(ev.BestBidQty)
+		s.AskPrice = c.parse(ev.BestAskPrice)
+		s.AskQty = c.parse(ev.BestAskQty)
+	})
+}
+
+// HandleMarkPrice updates the mark price and funding
+func (c *MarketCache) HandleMarkPrice(ev *WsFuturesMarkPriceEvent) {
+	c.update(ev.Symbol, MarketMark, func(s *MarketState) {
+		s.MarkPrice = c.parse(ev.MarkPrice)
+		s.IndexPrice = c.parse(ev.IndexPrice)
+		s.FundingRate = c.parse(ev.FundingRate)
+		s.NextFundingTime = ev.NextFundingTime
+	})
+}
+
+// HandleFuturesTicker updates the last price and 24hr statistics
+func (c *MarketCache) HandleFuturesTicker(ev *WsFuturesMarketTickerEvent) {
+	c.update(ev.Symbol, MarketTicker, func(s *MarketState) {
+		s.LastPrice = c.parse(ev.ClosePrice)
+		s.PriceChangePercent = c.parse(ev.PriceChangePercent)
+		s.BaseVolume = c.parse(ev.BaseVolume)
+		s.QuoteVolume = c.parse(ev.QuoteVolume)
+	})
+}
+
+// HandleSpotTicker updates the last price and 24hr statistics
+func (c *MarketCache) HandleSpotTicker(ev *WsSpotMarketStatEvent) {
+	c.update(ev.Symbol, MarketTicker, func(s *MarketState) {
+		s.LastPrice = c.parse(ev.LastPrice)
+		s.PriceChangePercent = c.parse(ev.PriceChangePercent)
+		s.BaseVolume = c.parse(ev.BaseVolume)
+		s.QuoteVolume = c.parse(ev.QuoteVolume)
+	})
+}
+
+// update publishes a copy of the state of symbol changed by set and
+// notifies the subscribers of field when the values differ
+func (c *MarketCache) update(symbol string, field MarketField, set func(s *MarketState)) {
+	e := c.entries[strings.ToUpper(symbol)]
+	if e == nil {
+		return
+	}
+	now := time.Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	old := e.state.Load()
+	next := *old
+	set(&next)
+	changed := next != *old
+	switch field {
+	case MarketBook:
+		next.BookTime = now
+	case MarketMark:
+		next.MarkTime = now
+	case MarketTicker:
+		next.TickerTime = now
+	}
+	e.state.Store(&next)
+	if !changed {
+		return
+	}
+	for _, sub := range *c.subs.Load() {
+		if sub.fields&field != 0 {
+			sub.fn(next, field)
+		}
+	}
+}
+
+// parse parses a decimal value, reporting malformed values as 0
+func (c *MarketCache) parse(s string) float64 {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && s != "" && c.errHandler != nil {
+		c.errHandler(err)
+	}
+	return f
+}
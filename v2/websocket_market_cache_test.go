@@ -0,0 +1,58 @@
+package aster
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// serveSubscriptions answers the subscription requests of a stream client,
+// sends message after the first one and closes the connection after n
+func serveSubscriptions(conn *websocket.Conn, n int, message []byte) {
+	for i := 0; i < n; i++ {
+		var req wsStreamRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		if err := conn.WriteJSON(map[string]interface{}{"result": nil, "id": req.ID}); err != nil {
+			return
+		}
+		if i == 0 {
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestMarketCacheConnect(t *testing.T) {
+	message := append(append([]byte(`{"stream":"btcusdt@bookTicker","data":`), bookTickerMessage...), '}')
+	newWsTestServer(t, func(uri string, conn *websocket.Conn) {
+		serveSubscriptions(conn, 2, message)
+	})
+
+	cache := NewSpotMarketCache([]string{"btcusdt"}, func(err error) {})
+	updated := make(chan MarketState, 1)
+	cache.Subscribe(MarketBook, func(state MarketState, changed MarketField) { updated <- state })
+	// without reconnects the cache stops when the server closes
+	cache.Options = []StreamOption{WithStreamReconnect(nil)}
+	if err := cache.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	if err := cache.Connect(context.Background()); !errors.Is(err, ErrMarketCacheConnected) {
+		t.Errorf("second Connect returned %v", err)
+	}
+
+	if state := waitC(t, updated, 1)[0]; state.Symbol != "BTCUSDT" || state.BidPrice != 42000.10 {
+		t.Errorf("got state %+v", state)
+	}
+	select {
+	case <-cache.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("cache reconnected with WsReconnect disabled")
+	}
+}
@@ -0,0 +1,151 @@
+package aster
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// StreamOption configures the connection of a stream. Options are accepted
+// by every Ws*Serve function and apply to its reconnects too.
+type StreamOption func(cfg *WsConfig)
+
+// WithStreamLocalAddress binds the connection to a local IP address
+func WithStreamLocalAddress(ip string) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.IP = ip
+	}
+}
+
+// WithStreamEndpoint connects to baseURL instead of BaseWsMainURL or
+// BaseWsFuturesURL, e.g. an intranet endpoint or an astertest server.
+// The stream path is kept.
+func WithStreamEndpoint(baseURL string) StreamOption {
+	return func(cfg *WsConfig) {
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil {
+			// wsDial reports the malformed endpoint
+			return
+		}
+		cfg.Endpoint = strings.TrimSuffix(baseURL, "/") + u.RequestURI()
+	}
+}
+
+// WithStreamResolver resolves the endpoint host with resolver
+func WithStreamResolver(resolver *net.Resolver) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.Resolver = resolver
+	}
+}
+
+// WithStreamProxy connects through an HTTP, HTTPS or SOCKS5 proxy, e.g.
+// socks5://127.0.0.1:1080, instead of the proxy of the environment. A nil
+// URL connects directly.
+func WithStreamProxy(proxyURL *url.URL) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.Proxy = http.ProxyURL(proxyURL)
+	}
+}
+
+// WithStreamTLSConfig sets the TLS configuration of wss endpoints
+func WithStreamTLSConfig(tlsConfig *tls.Config) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.TLSConfig = tlsConfig
+	}
+}
+
+// WithStreamHeader adds headers to the handshake request
+func WithStreamHeader(header http.Header) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.Header = header
+	}
+}
+
+// WithStreamDialTimeout bounds the TCP connect
+func WithStreamDialTimeout(timeout time.Duration) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.DialTimeout = timeout
+	}
+}
+
+// WithStreamHandshakeTimeout bounds the websocket handshake, 45s by default
+func WithStreamHandshakeTimeout(timeout time.Duration) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.HandshakeTimeout = timeout
+	}
+}
+
+// WithStreamBufferSizes sets the read and write buffer sizes of the
+// connection, 0 keeping the default of 4096 bytes
+func WithStreamBufferSizes(read, write int) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.ReadBufferSize = read
+		cfg.WriteBufferSize = write
+	}
+}
+
+// WithStreamReadLimit closes the connection when a message exceeds limit
+// bytes
+func WithStreamReadLimit(limit int64) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.ReadLimit = limit
+	}
+}
+
+// WithStreamCompression negotiates permessage-deflate compression
+func WithStreamCompression(enabled bool) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.Compression = enabled
+	}
+}
+
+// WithStreamReconnect manages the stream with rc instead of WsReconnect, nil
+// disabling reconnects
+func WithStreamReconnect(rc *ReconnectConfig) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.Reconnect = rc
+	}
+}
+
+// WithStreamHeartbeat sets the heartbeat of the stream instead of WsHeartbeat
+func WithStreamHeartbeat(hc *HeartbeatConfig) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.Heartbeat = hc
+	}
+}
+
+// WithStreamRecorder records the stream with rec instead of WsRecorder, nil
+// disabling recording
+func WithStreamRecorder(rec *StreamRecorder) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.Recorder = rec
+	}
+}
+
+// WithStreamReplayer serves the stream from rp instead of WsReplayer, nil
+// connecting to the exchange
+func WithStreamReplayer(rp *StreamReplayer) StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.Replayer = rp
+	}
+}
+
+// WithPooledEvents makes a depth, bookTicker, aggTrade or markPrice stream
+// reuse its events once the handler returns, sparing an allocation per
+// message. The handler must then copy what it keeps of an event, including
+// its Bids and Asks, and must not hand events to other goroutines, e.g.
+// through ServeChan or a Dispatcher. Other streams ignore it.
+func WithPooledEvents() StreamOption {
+	return func(cfg *WsConfig) {
+		cfg.PooledEvents = true
+	}
+}
+
+// streamOptions returns the options of a client stream, binding the local
+// address of the client before opts
+func (c *BaseClient) streamOptions(opts []StreamOption) []StreamOption {
+	return append([]StreamOption{WithStreamLocalAddress(c.LocalAddress)}, opts...)
+}
@@ -0,0 +1,396 @@
+package aster
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WsRecorder records the frames of every stream started after it is set
+var WsRecorder *StreamRecorder
+
+// WsReplayer serves every Ws*Serve call started after it is set from a
+// recording instead of the exchange. StreamClient is not replayed.
+var WsReplayer *StreamReplayer
+
+// userDataStream is the stream name recorded for user data streams, whose
+// endpoint holds a listen key that changes between runs
+const userDataStream = "userData"
+
+// RecordedFrame is one frame of a stream recording
+type RecordedFrame struct {
+	Time   int64           `json:"t"` // local receive time in nanoseconds
+	Market string          `json:"m"` // "spot" or "futures"
+	Stream string          `json:"s"` // stream name, "userData" for user data streams
+	Data   json.RawMessage `json:"d"` // raw frame, unwrapped from combined stream envelopes
+}
+
+// RecorderConfig configures a StreamRecorder. Files are named
+// <Prefix>-<time of the first frame>.ndjson.gz, so their names sort in time
+// order.
+type RecorderConfig struct {
+	Dir     string
+	Prefix  string        // file name prefix, "stream" by default
+	MaxSize int64         // uncompressed bytes after which a file is rotated, 0 never
+	MaxAge  time.Duration // age after which a file is rotated, 0 never
+
+	// ErrHandler receives the errors of frames recorded from streams
+	ErrHandler ErrHandler
+}
+
+// StreamRecorder writes stream frames to gzip compressed NDJSON files
+type StreamRecorder struct {
+	cfg RecorderConfig
+
+	mu     sync.Mutex
+	file   *os.File
+	gz     *gzip.Writer
+	w      *bufio.Writer
+	size   int64
+	opened time.Time
+	closed bool
+}
+
+// NewStreamRecorder creates a recorder writing to cfg.Dir
+func NewStreamRecorder(cfg RecorderConfig) (*StreamRecorder, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "stream"
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &StreamRecorder{cfg: cfg}, nil
+}
+
+// Write appends a frame to the current file, rotating it first if needed
+func (r *StreamRecorder) Write(frame *RecordedFrame) error {
+	line, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	t := time.Unix(0, frame.Time)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return errors.New("aster: recorder closed")
+	}
+	if r.file != nil && r.rotationDue(t) {
+		if err = r.closeFile(); err != nil {
+			return err
+		}
+	}
+	if r.file == nil {
+		if err = r.openFile(t); err != nil {
+			return err
+		}
+	}
+	if _, err = r.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	r.size += int64(len(line)) + 1
+	return nil
+}
+
+// Flush writes the buffered frames of the current file
+func (r *StreamRecorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	return r.gz.Flush()
+}
+
+// Close flushes and closes the current file. Frames recorded afterwards
+// are dropped.
+func (r *StreamRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.file == nil {
+		return nil
+	}
+	return r.closeFile()
+}
+
+// record writes a frame received on the stream of cfg
+func (r *StreamRecorder) record(cfg *WsConfig, message []byte, t time.Time) {
+	market, stream, data := recordedStream(cfg, message)
+	if stream == "" {
+		return
+	}
+	err := r.Write(&RecordedFrame{Time: t.UnixNano(), Market: market, Stream: stream, Data: data})
+	if err != nil && r.cfg.ErrHandler != nil {
+		r.cfg.ErrHandler(err)
+	}
+}
+
+// rotationDue reports whether the current file must be rotated before a
+// frame received at t. r.mu must be held.
+func (r *StreamRecorder) rotationDue(t time.Time) bool {
+	if r.cfg.MaxSize > 0 && r.size >= r.cfg.MaxSize {
+		return true
+	}
+	return r.cfg.MaxAge > 0 && t.Sub(r.opened) >= r.cfg.MaxAge
+}
+
+// openFile starts a new file. r.mu must be held.
+func (r *StreamRecorder) openFile(t time.Time) error {
+	name := fmt.Sprintf("%s-%s.ndjson.gz", r.cfg.Prefix, t.UTC().Format("20060102T150405.000000000"))
+	f, err := os.OpenFile(filepath.Join(r.cfg.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	r.file = f
+	r.gz = gzip.NewWriter(f)
+	r.w = bufio.NewWriter(r.gz)
+	r.size = 0
+	r.opened = t
+	return nil
+}
+
+// closeFile flushes and closes the current file. r.mu must be held.
+func (r *StreamRecorder) closeFile() error {
+	err := r.w.Flush()
+	if e := r.gz.Close(); err == nil {
+		err = e
+	}
+	if e := r.file.Close(); err == nil {
+		err = e
+	}
+	r.file, r.gz, r.w = nil, nil, nil
+	return err
+}
+
+// recordedStream returns the market, stream name and data of a frame
+// received on the stream of cfg. The stream is empty for frames that are
+// not stream data, like the responses to SUBSCRIBE requests.
+func recordedStream(cfg *WsConfig, message []byte) (market, stream string, data json.RawMessage) {
+	market = cfg.market
+	if cfg.userData {
+		return market, userDataStream, message
+	}
+	if i := strings.LastIndex(cfg.Endpoint, "/ws/"); i >= 0 {
+		return market, cfg.Endpoint[i+len("/ws/"):], message
+	}
+	var envelope struct {
+		Stream string          `json:"stream"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(message, &envelope); err != nil {
+		return market, "", nil
+	}
+	return market, envelope.Stream, envelope.Data
+}
+
+// RecordingFiles returns the files of a recording in time order
+func RecordingFiles(dir, prefix string) ([]string, error) {
+	if prefix == "" {
+		prefix = "stream"
+	}
+	files, err := filepath.Glob(filepath.Join(dir, prefix+"-*.ndjson.gz"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ReplayConfig configures a StreamReplayer
+type ReplayConfig struct {
+	Files []string // recording files in time order, see RecordingFiles
+	Speed float64  // 1 replays in real time, 10 ten times faster, 0 as fast as possible
+}
+
+// StreamReplayer feeds recorded frames to the streams served while it is
+// set as WsReplayer, through the same handlers as live frames. Streams
+// started before Run receive every frame, and all of them end with it.
+type StreamReplayer struct {
+	cfg ReplayConfig
+
+	mu   sync.Mutex
+	subs []*replaySub
+}
+
+// replaySub is a stream served by a replayer
+type replaySub struct {
+	market   string
+	streams  map[string]bool
+	combined bool // whether frames are wrapped in combined stream envelopes
+	handler  WsHandler
+
+	mu    sync.Mutex
+	done  bool
+	doneC chan struct{}
+}
+
+// NewStreamReplayer creates a replayer
+func NewStreamReplayer(cfg ReplayConfig) *StreamReplayer {
+	return &StreamReplayer{cfg: cfg}
+}
+
+// Run replays the recording and ends the streams. It returns early with the
+// error of ctx or of a file.
+func (r *StreamReplayer) Run(ctx context.Context) error {
+	defer func() {
+		r.mu.Lock()
+		subs := r.subs
+		r.subs = nil
+		r.mu.Unlock()
+		for _, sub := range subs {
+			sub.stop()
+		}
+	}()
+
+	var first int64
+	start := time.Now()
+	for _, path := range r.cfg.Files {
+		err := readRecording(path, func(frame *RecordedFrame) error {
+			if first == 0 {
+				first = frame.Time
+			}
+			if r.cfg.Speed > 0 {
+				delay := time.Until(start.Add(time.Duration(float64(frame.Time-first) / r.cfg.Speed)))
+				if delay > 0 {
+					timer := time.NewTimer(delay)
+					select {
+					case <-timer.C:
+					case <-ctx.Done():
+						timer.Stop()
+					}
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			r.dispatch(frame)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatch delivers a frame to the streams subscribed to it
+func (r *StreamReplayer) dispatch(frame *RecordedFrame) {
+	r.mu.Lock()
+	subs := r.subs
+	r.mu.Unlock()
+	for _, sub := range subs {
+		if sub.market != frame.Market || !sub.streams[frame.Stream] {
+			continue
+		}
+		message := []byte(frame.Data)
+		if sub.combined {
+			message, _ = json.Marshal(struct {
+				Stream string          `json:"stream"`
+				Data   json.RawMessage `json:"data"`
+			}{frame.Stream, frame.Data})
+		}
+		sub.mu.Lock()
+		if !sub.done {
+			sub.handler(message)
+		}
+		sub.mu.Unlock()
+	}
+}
+
+// serve registers a stream in place of connecting to cfg.Endpoint
+func (r *StreamReplayer) serve(cfg *WsConfig, handler WsHandler) (doneC, stopC chan struct{}, err error) {
+	market, _, _ := recordedStream(cfg, nil)
+	sub := &replaySub{market: market, streams: map[string]bool{}, handler: handler, doneC: make(chan struct{})}
+	if i := strings.Index(cfg.Endpoint, "/stream?streams="); i >= 0 {
+		sub.combined = true
+		for _, s := range strings.Split(cfg.Endpoint[i+len("/stream?streams="):], "/") {
+			sub.streams[s] = true
+		}
+	} else if _, stream, _ := recordedStream(cfg, nil); stream != "" {
+		sub.streams[stream] = true
+	} else {
+		return nil, nil, fmt.Errorf("aster: cannot replay endpoint %s", cfg.Endpoint)
+	}
+
+	r.mu.Lock()
+	r.subs = append(r.subs, sub)
+	r.mu.Unlock()
+
+	stopC = make(chan struct{})
+	go func() {
+		select {
+		case <-stopC:
+			r.remove(sub)
+			sub.stop()
+		case <-sub.doneC:
+		}
+	}()
+	return sub.doneC, stopC, nil
+}
+
+// remove unregisters a stream
+func (r *StreamReplayer) remove(sub *replaySub) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, s := range r.subs {
+		if s == sub {
+			r.subs = append(r.subs[:i:i], r.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// stop ends the stream once its handler has returned
+func (sub *replaySub) stop() {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if !sub.done {
+		sub.done = true
+		close(sub.doneC)
+	}
+}
+
+// readRecording calls fn with every frame of a recording file
+func readRecording(path string, fn func(frame *RecordedFrame) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("aster: invalid recording %s: %w", path, err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		frame := new(RecordedFrame)
+		if err := json.Unmarshal(scanner.Bytes(), frame); err != nil {
+			return fmt.Errorf("aster: invalid recording %s: %w", path, err)
+		}
+		if err := fn(frame); err != nil {
+			return err
+		}
+	}
+	// a recorder that did not close its file leaves it truncated
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package aster
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newWsTestServer starts a websocket server calling serve with the request
+// URI of each connection, and points the spot and futures endpoints to it
+// until the test ends
+func newWsTestServer(t *testing.T, serve func(uri string, conn *websocket.Conn)) *httptest.Server {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	var wg sync.WaitGroup
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Count the connection before the upgrade hijacks it, while
+		// srv.Close still waits for the request
+		wg.Add(1)
+		defer wg.Done()
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serve(r.URL.RequestURI(), conn)
+	}))
+	mainURL, futuresURL := BaseWsMainURL, BaseWsFuturesURL
+	BaseWsMainURL = "ws" + strings.TrimPrefix(srv.URL, "http")
+	BaseWsFuturesURL = BaseWsMainURL
+	t.Cleanup(func() {
+		BaseWsMainURL, BaseWsFuturesURL = mainURL, futuresURL
+		srv.CloseClientConnections()
+		srv.Close()
+		wg.Wait()
+	})
+	return srv
+}
+
+// drain reads a connection until it closes, answering pings
+func drain(conn *websocket.Conn) {
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// waitC waits for n values on ch
+func waitC[T any](t *testing.T, ch <-chan T, n int) []T {
+	t.Helper()
+	var got []T
+	timeout := time.After(5 * time.Second)
+	for len(got) < n {
+		select {
+		case v := <-ch:
+			got = append(got, v)
+		case <-timeout:
+			t.Fatalf("got %d values, want %d", len(got), n)
+		}
+	}
+	return got
+}
+
+func TestStreamRecorderRoundTrip(t *testing.T) {
+	newWsTestServer(t, func(uri string, conn *websocket.Conn) {
+		var message []byte
+		switch {
+		case strings.HasSuffix(uri, "@depth"):
+			message = depthMessage
+		case strings.HasSuffix(uri, "/!bookTicker"):
+			message = bookTickerMessage
+		default: // listen key
+			message = []byte(`{"e":"listenKeyExpired","E":1700000000123}`)
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			return
+		}
+		drain(conn)
+	})
+
+	dir := t.TempDir()
+	rec, err := NewStreamRecorder(RecorderConfig{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := []StreamOption{WithStreamRecorder(rec), WithStreamReconnect(nil)}
+	errHandler := func(err error) {}
+	depthC := make(chan *WsDepthEvent, 1)
+	bookTickerC := make(chan *WsBookTickerEvent, 1)
+	userDataC := make(chan *WsFuturesUserDataEvent, 1)
+	var stops []chan struct{}
+	for _, serve := range []func() (chan struct{}, chan struct{}, error){
+		func() (chan struct{}, chan struct{}, error) {
+			return WsSpotDepthServe("BTCUSDT", func(e *WsDepthEvent) { depthC <- e }, errHandler, opts...)
+		},
+		func() (chan struct{}, chan struct{}, error) {
+			return WsSpotAllBookTickerServe(func(e *WsBookTickerEvent) { bookTickerC <- e }, errHandler, opts...)
+		},
+		func() (chan struct{}, chan struct{}, error) {
+			return WsFuturesUserDataServe("pqia91ma19a5s61cv6a81va65sdf19v8a65a1a5s61cv6a81va65sdf19v8a65a1", func(e *WsFuturesUserDataEvent) { userDataC <- e }, errHandler, opts...)
+		},
+	} {
+		doneC, stopC, err := serve()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stops = append(stops, stopC)
+		defer func() { <-doneC }()
+	}
+	depth := waitC(t, depthC, 1)[0]
+	bookTicker := waitC(t, bookTickerC, 1)[0]
+	waitC(t, userDataC, 1)
+	for _, stopC := range stops {
+		close(stopC)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := RecordingFiles(dir, "")
+	if err != nil || len(files) != 1 {
+		t.Fatalf("got files %v, %v", files, err)
+	}
+	got := map[string]string{}
+	err = readRecording(files[0], func(frame *RecordedFrame) error {
+		got[frame.Stream] = frame.Market
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"btcusdt@depth": "spot", "!bookTicker": "spot", userDataStream: "futures"}
+	if len(got) != len(want) {
+		t.Errorf("recorded streams %v, want %v", got, want)
+	}
+	for stream, market := range want {
+		if got[stream] != market {
+			t.Errorf("stream %s recorded as %q, want %q", stream, got[stream], market)
+		}
+	}
+
+	rp := NewStreamReplayer(ReplayConfig{Files: files})
+	opts = []StreamOption{WithStreamReplayer(rp)}
+	if _, _, err := WsSpotDepthServe("BTCUSDT", func(e *WsDepthEvent) { depthC <- e }, errHandler, opts...); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := WsCombinedSpotBookTickerServe([]string{"BTCUSDT"}, func(e *WsBookTickerEvent) { bookTickerC <- e }, errHandler, opts...); err != nil {
+		t.Fatal(err)
+	}
+	// recorded as !bookTicker, not btcusdt@bookTicker
+	if err := rp.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	replayed := waitC(t, depthC, 1)[0]
+	if replayed.LastUpdateID != depth.LastUpdateID || len(replayed.Bids) != len(depth.Bids) {
+		t.Errorf("replayed %+v, want %+v", replayed, depth)
+	}
+	select {
+	case e := <-bookTickerC:
+		t.Errorf("replayed %+v of another stream, recorded %+v", e, bookTicker)
+	default:
+	}
+}
@@ -0,0 +1,363 @@
+package aster
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/json-iterator/go"
+)
+
+// StreamPath is one connection of a RedundantStreamClient
+type StreamPath struct {
+	Endpoint     string // websocket base URL, the default of the market when empty
+	LocalAddress string
+}
+
+// StreamPathStats are the statistics of one path of a RedundantStreamClient
+type StreamPathStats struct {
+	StreamPath
+	Connected   bool
+	Messages    uint64        // events received
+	First       uint64        // events delivered from this path because it was first
+	Latency     time.Duration // moving average of receive time minus event time
+	LastMessage time.Time
+}
+
+// RedundantStreamClient subscribes to the same streams over several
+// independent connections and delivers each event once, from whichever
+// connection receives it first. The events of each stream and symbol are
+// matched by their update, trade or aggregate trade id, or by event time
+// and content. The client keeps
+// working while at least one path is up. Handlers are never called
+// concurrently. Set the exported fields before calling Connect.
+type RedundantStreamClient struct {
+	// MaxMessagesPerSecond, RequestTimeout and Options configure each
+	// connection, see StreamClient
+	MaxMessagesPerSecond int
+	RequestTimeout       time.Duration
+	Options              []StreamOption
+
+	streamSubscriptions
+
+	paths []*redundantPath
+
+	mu     sync.Mutex // serializes deduplication and handlers
+	latest map[dedupeKey]*dedupeState
+
+	statsMu sync.Mutex
+}
+
+// redundantPath is a path and its statistics, guarded by statsMu
+type redundantPath struct {
+	StreamPath
+	client *StreamClient
+	stats  StreamPathStats
+}
+
+// dedupeKey identifies the events ordered by one id: those of a stream and
+// symbol, since the symbols of all-market streams like !bookTicker each
+// have their own update ids
+type dedupeKey struct {
+	stream string
+	symbol string
+}
+
+// dedupeState tracks the events of a stream delivered so far
+type dedupeState struct {
+	id    int64
+	first []byte          // the first event with id, for events without one
+	seen  map[uint64]bool // content hashes of the later events with id
+}
+
+// NewSpotRedundantStreamClient creates a redundant stream client for spot
+// streams
+func NewSpotRedundantStreamClient(paths []StreamPath, errHandler ErrHandler) *RedundantStreamClient {
+	return newRedundantStreamClient(false, paths, errHandler)
+}
+
+// NewFuturesRedundantStreamClient creates a redundant stream client for
+// futures streams, e.g. over the public and an intranet endpoint
+func NewFuturesRedundantStreamClient(paths []StreamPath, errHandler ErrHandler) *RedundantStreamClient {
+	return newRedundantStreamClient(true, paths, errHandler)
+}
+
+func newRedundantStreamClient(isFutures bool, paths []StreamPath, errHandler ErrHandler) *RedundantStreamClient {
+	c := &RedundantStreamClient{latest: map[dedupeKey]*dedupeState{}}
+	c.streamSubscriptions = streamSubscriptions{subscribe: c.subscribe, isFutures: isFutures, errHandler: errHandler}
+	for _, p := range paths {
+		c.paths = append(c.paths, &redundantPath{StreamPath: p, stats: StreamPathStats{StreamPath: p}})
+	}
+	return c
+}
+
+// Connect opens every path. It fails only when none connects, returning the
+// errors of all paths; otherwise the errors go to the error handler.
+func (c *RedundantStreamClient) Connect() error {
+	if len(c.paths) == 0 {
+		return errors.New("aster: no stream paths")
+	}
+	var connected int
+	err := c.eachPath(func(p *redundantPath) error {
+		client := newStreamClient(c.isFutures, c.errHandler)
+		client.MaxMessagesPerSecond = c.MaxMessagesPerSecond
+		client.RequestTimeout = c.RequestTimeout
+		client.LocalAddress = p.LocalAddress
+		client.Options = c.Options
+		client.Endpoint = p.Endpoint
+		if err := client.Connect(); err != nil {
+			return err
+		}
+		c.statsMu.Lock()
+		p.client = client
+		connected++
+		c.statsMu.Unlock()
+		return nil
+	})
+	if connected == 0 {
+		return err
+	}
+	return nil
+}
+
+// Close closes every path
+func (c *RedundantStreamClient) Close() {
+	for _, p := range c.paths {
+		if client := c.client(p); client != nil {
+			client.Close()
+		}
+	}
+}
+
+// Subscribe subscribes every connected path to streams, e.g.
+// "btcusdt@depth", and routes the first copy of each event to handler. It
+// fails only when no path subscribes; the errors of the other paths go to
+// the error handler.
+func (c *RedundantStreamClient) Subscribe(ctx context.Context, handler WsHandler, streams ...string) error {
+	var subscribed bool
+	var mu sync.Mutex
+	err := c.eachPath(func(p *redundantPath) error {
+		client := c.client(p)
+		if client == nil || isClosed(client.doneC) {
+			return ErrStreamClientClosed
+		}
+		err := client.subscribeEach(ctx, func(stream string) streamHandler {
+			return streamHandler{raw: func(data []byte) {
+				c.receive(p, stream, data, handler)
+			}}
+		}, streams...)
+		if err == nil {
+			mu.Lock()
+			subscribed = true
+			mu.Unlock()
+		}
+		return err
+	})
+	if !subscribed {
+		return err
+	}
+	return nil
+}
+
+// subscribe subscribes to streams routed to the raw handler, which
+// deduplicates events before they are decoded
+func (c *RedundantStreamClient) subscribe(ctx context.Context, handler streamHandler, streams ...string) error {
+	return c.Subscribe(ctx, handler.raw, streams...)
+}
+
+// Unsubscribe unsubscribes every path from streams
+func (c *RedundantStreamClient) Unsubscribe(ctx context.Context, streams ...string) error {
+	err := c.eachPath(func(p *redundantPath) error {
+		if client := c.client(p); client != nil && !isClosed(client.doneC) {
+			return client.Unsubscribe(ctx, streams...)
+		}
+		return nil
+	})
+	unsubscribed := map[string]bool{}
+	for _, stream := range streams {
+		unsubscribed[stream] = true
+	}
+	c.mu.Lock()
+	for k := range c.latest {
+		if unsubscribed[k.stream] {
+			delete(c.latest, k)
+		}
+	}
+	c.mu.Unlock()
+	return err
+}
+
+// Stats returns the statistics of each path, in the order of the paths
+func (c *RedundantStreamClient) Stats() []StreamPathStats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	stats := make([]StreamPathStats, len(c.paths))
+	for i, p := range c.paths {
+		stats[i] = p.stats
+		stats[i].Connected = p.client != nil && !isClosed(p.client.doneC)
+	}
+	return stats
+}
+
+// receive delivers an event received on path p unless another path
+// delivered it already
+func (c *RedundantStreamClient) receive(p *redundantPath, stream string, data []byte, handler WsHandler) {
+	now := time.Now()
+	symbol, id, eventTime, unique := eventIdentity(data)
+	c.statsMu.Lock()
+	p.stats.Messages++
+	p.stats.LastMessage = now
+	if eventTime > 0 {
+		latency := now.Sub(time.UnixMilli(eventTime))
+		if p.stats.Latency == 0 {
+			p.stats.Latency = latency
+		} else {
+			p.stats.Latency += (latency - p.stats.Latency) / 8
+		}
+	}
+	c.statsMu.Unlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := dedupeKey{stream: stream, symbol: symbol}
+	st := c.latest[key]
+	switch {
+	case st == nil:
+		st = &dedupeState{id: id - 1}
+		c.latest[key] = st
+	case id < st.id:
+		return
+	case id == st.id && (unique || st.delivered(data)):
+		return
+	}
+	if id > st.id {
+		st.id, st.first, st.seen = id, nil, nil
+		if !unique {
+			st.first = data
+		}
+	}
+	c.statsMu.Lock()
+	p.stats.First++
+	c.statsMu.Unlock()
+	handler(data)
+}
+
+// delivered reports whether an event without id was delivered already,
+// recording it otherwise. Copies from other paths are equal to the first
+// event with the same event time, so only distinct events sharing an event
+// time are hashed.
+func (st *dedupeState) delivered(data []byte) bool {
+	if bytes.Equal(data, st.first) {
+		return true
+	}
+	sum := fnv64a(data)
+	if st.seen[sum] {
+		return true
+	}
+	if st.seen == nil || len(st.seen) >= 1024 {
+		st.seen = map[uint64]bool{}
+	}
+	st.seen[sum] = true
+	return false
+}
+
+// fnv64a returns the FNV-1a hash of data
+func fnv64a(data []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, b := range data {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+	return h
+}
+
+// eachPath runs fn for every path concurrently, reporting the errors to the
+// error handler and returning them joined
+func (c *RedundantStreamClient) eachPath(fn func(p *redundantPath) error) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, p := range c.paths {
+		wg.Add(1)
+		go func(p *redundantPath) {
+			defer wg.Done()
+			if err := fn(p); err != nil {
+				if c.errHandler != nil {
+					c.errHandler(err)
+				}
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(p)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// client returns the stream client of a path, nil until it connects
+func (c *RedundantStreamClient) client(p *redundantPath) *StreamClient {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return p.client
+}
+
+// eventIdentity returns the symbol of an event and the id events of a
+// stream and symbol are ordered by: the final update id, the aggregate
+// trade id of aggTrade or the trade id of trade events, or else the event
+// time. It also returns the event time in milliseconds and whether the id
+// identifies a single event. The event is read in one pass.
+func eventIdentity(data []byte) (symbol string, id, eventTime int64, unique bool) {
+	var (
+		event string
+		// u, lastUpdateId, a and t
+		ids   [4]int64
+		found [4]bool
+	)
+	iter := JSON.BorrowIterator(data)
+	defer JSON.ReturnIterator(iter)
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, key string) bool {
+		next := iter.WhatIsNext()
+		i := -1
+		switch {
+		case key == "e" && next == jsoniter.StringValue:
+			event = iter.ReadString()
+			return true
+		case key == "s" && next == jsoniter.StringValue:
+			symbol = iter.ReadString()
+			return true
+		case key == "E" && next == jsoniter.NumberValue:
+			eventTime = iter.ReadInt64()
+			return true
+		case key == "u":
+			i = 0
+		case key == "lastUpdateId":
+			i = 1
+		case key == "a": // also the asks of depth and the seller order of trade events
+			i = 2
+		case key == "t":
+			i = 3
+		}
+		if i < 0 || next != jsoniter.NumberValue {
+			iter.Skip()
+			return true
+		}
+		ids[i], found[i] = iter.ReadInt64(), true
+		return true
+	})
+	switch {
+	case found[0]:
+		return symbol, ids[0], eventTime, true
+	case found[1]:
+		return symbol, ids[1], eventTime, true
+	case found[2] && event == "aggTrade":
+		return symbol, ids[2], eventTime, true
+	case found[3] && event == "trade":
+		return symbol, ids[3], eventTime, true
+	}
+	return symbol, eventTime, eventTime, false
+}
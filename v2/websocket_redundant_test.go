@@ -0,0 +1,170 @@
+package aster_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/astertest"
+)
+
+func TestRedundantConnectJoinsErrors(t *testing.T) {
+	c := aster.NewSpotRedundantStreamClient([]aster.StreamPath{
+		{Endpoint: "ws://127.0.0.1:1"},
+		{Endpoint: "ws://127.0.0.1:2"},
+	}, func(err error) {})
+	err := c.Connect()
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 2 {
+		t.Errorf("got %v, want the errors of both paths", err)
+	}
+}
+
+// newTestRedundantClient connects a client with two paths to url
+func newTestRedundantClient(t *testing.T, futures bool, url string) *aster.RedundantStreamClient {
+	paths := []aster.StreamPath{{Endpoint: url}, {Endpoint: url}}
+	errHandler := func(err error) { t.Error(err) }
+	c := aster.NewSpotRedundantStreamClient(paths, errHandler)
+	if futures {
+		c = aster.NewFuturesRedundantStreamClient(paths, errHandler)
+	}
+	c.Options = []aster.StreamOption{aster.WithStreamReconnect(nil)}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// checkStats checks that both paths received every event and that each
+// event was delivered once
+func checkStats(t *testing.T, c *aster.RedundantStreamClient, events int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stats := c.Stats()
+		if stats[0].Messages == uint64(events) && stats[1].Messages == uint64(events) {
+			if first := stats[0].First + stats[1].First; first != uint64(events) {
+				t.Errorf("delivered %d events from the paths, want %d", first, events)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("paths received %d and %d events, want %d", stats[0].Messages, stats[1].Messages, events)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRedundantDeliversOnce(t *testing.T) {
+	srv := astertest.NewServer()
+	defer srv.Close()
+	c := newTestRedundantClient(t, false, srv.SpotWsURL())
+
+	depths := make(chan *aster.WsDepthEvent, 100)
+	ctx := context.Background()
+	if err := c.SubscribeDepth(ctx, func(e *aster.WsDepthEvent) { depths <- e }, "BTCUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	for _, price := range []string{"100", "101", "102"} {
+		srv.SetDepth(astertest.MarketSpot, "BTCUSDT", [][]string{{price, "1"}}, [][]string{{"200", "1"}})
+	}
+	got := receive(t, depths, 3)
+	for i := 1; i < len(got); i++ {
+		if got[i].LastUpdateID <= got[i-1].LastUpdateID {
+			t.Errorf("got update ids %d after %d", got[i].LastUpdateID, got[i-1].LastUpdateID)
+		}
+	}
+	checkStats(t, c, 3)
+	select {
+	case e := <-depths:
+		t.Errorf("got a duplicate of update %d", e.LastUpdateID)
+	default:
+	}
+}
+
+func TestRedundantDeliversEventsWithoutID(t *testing.T) {
+	srv := astertest.NewServer()
+	defer srv.Close()
+	c := newTestRedundantClient(t, true, srv.FuturesWsURL())
+
+	marks := make(chan string, 100)
+	ctx := context.Background()
+	if err := c.SubscribeMarkPrice(ctx, func(e *aster.WsFuturesMarkPriceEvent) { marks <- e.MarkPrice }, "BTCUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	// mark price events have no id, and may share an event time
+	want := []string{"100", "101", "102"}
+	for _, price := range want {
+		srv.SetMarkPrice("BTCUSDT", price)
+	}
+	got := receive(t, marks, 3)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got mark prices %v, want %v", got, want)
+			break
+		}
+	}
+	checkStats(t, c, 3)
+	select {
+	case price := <-marks:
+		t.Errorf("got a duplicate mark price %s", price)
+	default:
+	}
+}
+
+// subscribeRaw subscribes a client with two paths to a stream of a
+// subscription server, returning the delivered events
+func subscribeRaw(t *testing.T, stream string) (*subscriptionServer, *aster.RedundantStreamClient, <-chan string) {
+	s := newSubscriptionServer(t)
+	c := newTestRedundantClient(t, false, s.url)
+	events := make(chan string, 100)
+	if err := c.Subscribe(context.Background(), func(data []byte) { events <- string(data) }, stream); err != nil {
+		t.Fatal(err)
+	}
+	return s, c, events
+}
+
+// expectEvents publishes events on stream and checks that each is delivered
+// once
+func expectEvents(t *testing.T, s *subscriptionServer, c *aster.RedundantStreamClient, events <-chan string, stream string, want []string) {
+	t.Helper()
+	for _, e := range want {
+		s.publishData(stream, e)
+	}
+	got := receive(t, events, len(want))
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got events %v, want %v", got, want)
+			break
+		}
+	}
+	checkStats(t, c, len(want))
+	select {
+	case e := <-events:
+		t.Errorf("got a duplicate event %s", e)
+	default:
+	}
+}
+
+func TestRedundantDeliversTrades(t *testing.T) {
+	s, c, events := subscribeRaw(t, "btcusdt@trade")
+	// a is the seller order id, shared by trades filling the same order
+	expectEvents(t, s, c, events, "btcusdt@trade", []string{
+		`{"e":"trade","E":1700000000001,"s":"BTCUSDT","t":100,"p":"42000","q":"1","b":11,"a":7,"T":1700000000000,"m":false}`,
+		`{"e":"trade","E":1700000000001,"s":"BTCUSDT","t":101,"p":"42000","q":"2","b":12,"a":7,"T":1700000000000,"m":false}`,
+		`{"e":"trade","E":1700000000002,"s":"BTCUSDT","t":102,"p":"41999","q":"1","b":13,"a":5,"T":1700000000001,"m":false}`,
+	})
+}
+
+func TestRedundantDeliversAllMarketStreams(t *testing.T) {
+	s, c, events := subscribeRaw(t, "!bookTicker")
+	// each symbol has its own update ids
+	expectEvents(t, s, c, events, "!bookTicker", []string{
+		`{"u":400900217,"s":"BTCUSDT","b":"42000.10","B":"1","a":"42000.20","A":"1"}`,
+		`{"u":1200,"s":"ETHUSDT","b":"2200.10","B":"1","a":"2200.20","A":"1"}`,
+		`{"u":400900218,"s":"BTCUSDT","b":"42000.00","B":"1","a":"42000.20","A":"1"}`,
+		`{"u":1201,"s":"ETHUSDT","b":"2200.00","B":"1","a":"2200.20","A":"1"}`,
+	})
+}
@@ -1,28 +1,39 @@
 package aster
 
 import (
+	"encoding/json"
+
+	"github.com/json-iterator/go"
+
 	"github.com/drinkthere/go-aster/v2/common"
 )
 
-// Base WebSocket endpoints
-const (
-	baseWsMainnetURL        = "wss://sstream.asterdex.com"
-	baseWsFuturesMainnetURL = "wss://fstream.asterdex.com"
-	baseWsTestnetURL        = "wss://testnet.asterdex.com"
-	
-	combinedBaseURL        = "wss://sstream.asterdex.com/stream"
-	combinedFuturesBaseURL = "wss://fstream.asterdex.com/stream"
+// Base WebSocket endpoints. They are variables so that streams can be
+// pointed at another server, e.g. astertest in integration tests.
+var (
+	BaseWsMainURL    = "wss://sstream.asterdex.com"
+	BaseWsFuturesURL = "wss://fstream.asterdex.com"
+	BaseWsTestnetURL = "wss://testnet.asterdex.com"
+
+	// Websocket API endpoints used by WsAPIClient
+	BaseWsAPIMainURL    = "wss://sstream.asterdex.com/ws-api/v3"
+	BaseWsAPIFuturesURL = "wss://fstream.asterdex.com/ws-fapi/v1"
 )
 
 // getWsEndpoint returns the websocket endpoint
 func getWsEndpoint(isFutures, isTestnet bool) string {
 	if isTestnet {
-		return baseWsTestnetURL
+		return BaseWsTestnetURL
 	}
 	if isFutures {
-		return baseWsFuturesMainnetURL
+		return BaseWsFuturesURL
 	}
-	return baseWsMainnetURL
+	return BaseWsMainURL
+}
+
+// getCombinedEndpoint returns the combined streams endpoint
+func getCombinedEndpoint(isFutures bool) string {
+	return getWsEndpoint(isFutures, false) + "/stream"
 }
 
 // Depth handlers
@@ -42,40 +53,15 @@ type Ask struct {
 
 // WsDepthEvent define websocket depth event
 type WsDepthEvent struct {
-	Event         string `json:"e"`
-	Time          int64  `json:"E"`
-	TransactionTime int64  `json:"T"`
-	Symbol        string `json:"s"`
-	FirstUpdateID int64  `json:"U"`
-	LastUpdateID  int64  `json:"u"`
-	Bids          []Bid  `json:"b"`
-	Asks          []Ask  `json:"a"`
-}
-
-// UnmarshalJSON custom unmarshal for Bid
-func (b *Bid) UnmarshalJSON(data []byte) error {
-	var items []string
-	if err := JSON.Unmarshal(data, &items); err != nil {
-		return err
-	}
-	if len(items) >= 2 {
-		b.Price = items[0]
-		b.Quantity = items[1]
-	}
-	return nil
-}
-
-// UnmarshalJSON custom unmarshal for Ask
-func (a *Ask) UnmarshalJSON(data []byte) error {
-	var items []string
-	if err := JSON.Unmarshal(data, &items); err != nil {
-		return err
-	}
-	if len(items) >= 2 {
-		a.Price = items[0]
-		a.Quantity = items[1]
-	}
-	return nil
+	Event            string `json:"e"`
+	Time             int64  `json:"E"`
+	TransactionTime  int64  `json:"T"`
+	Symbol           string `json:"s"`
+	FirstUpdateID    int64  `json:"U"`
+	LastUpdateID     int64  `json:"u"`
+	PrevLastUpdateID int64  `json:"pu"` // futures only: u of the previous event
+	Bids             []Bid  `json:"b"`
+	Asks             []Ask  `json:"a"`
 }
 
 // Kline handlers
@@ -156,6 +142,8 @@ type WsSpotAggTradeEvent struct {
 	TradeTime             int64  `json:"T"`
 	IsBuyerMaker          bool   `json:"m"`
 	Ignore                bool   `json:"M"`
+	// Backfilled is set on trades recovered through REST after a gap
+	Backfilled bool `json:"-"`
 }
 
 // WsFuturesAggTradeEvent define websocket aggregate trade event for futures
@@ -170,6 +158,8 @@ type WsFuturesAggTradeEvent struct {
 	LastBreakdownTradeID  int64  `json:"l"`
 	TradeTime             int64  `json:"T"`
 	IsBuyerMaker          bool   `json:"m"`
+	// Backfilled is set on trades recovered through REST after a gap
+	Backfilled bool `json:"-"`
 }
 
 // Book ticker handler
@@ -221,6 +211,60 @@ type WsSpotMarketStatEvent struct {
 	Count              int64  `json:"n"`
 }
 
+// Market statistics handler of a symbol for spot
+type WsSpotMarketStatHandler func(event *WsSpotMarketStatEvent)
+
+// Partial depth handler for spot
+type WsSpotPartialDepthHandler func(event *WsSpotPartialDepthEvent)
+
+// WsSpotPartialDepthEvent define websocket partial book depth event for
+// spot. The payload has no symbol, it is set from the stream.
+type WsSpotPartialDepthEvent struct {
+	Symbol       string `json:"-"`
+	LastUpdateID int64  `json:"lastUpdateId"`
+	Bids         []Bid  `json:"bids"`
+	Asks         []Ask  `json:"asks"`
+}
+
+// Trade handler for spot
+type WsSpotTradeHandler func(event *WsSpotTradeEvent)
+
+// WsSpotTradeEvent define websocket trade event for spot
+type WsSpotTradeEvent struct {
+	Event         string `json:"e"`
+	Time          int64  `json:"E"`
+	Symbol        string `json:"s"`
+	TradeID       int64  `json:"t"`
+	Price         string `json:"p"`
+	Quantity      string `json:"q"`
+	BuyerOrderID  int64  `json:"b"`
+	SellerOrderID int64  `json:"a"`
+	TradeTime     int64  `json:"T"`
+	IsBuyerMaker  bool   `json:"m"`
+	Ignore        bool   `json:"M"`
+}
+
+// Mini ticker handlers for spot
+type WsSpotMiniMarketTickerHandler func(event *WsSpotMiniMarketTickerEvent)
+type WsSpotAllMiniMarketTickerHandler func(event WsSpotAllMiniMarketTickerEvent)
+
+// WsSpotAllMiniMarketTickerEvent define websocket all mini tickers event for
+// spot
+type WsSpotAllMiniMarketTickerEvent []*WsSpotMiniMarketTickerEvent
+
+// WsSpotMiniMarketTickerEvent define websocket 24h mini ticker event for spot
+type WsSpotMiniMarketTickerEvent struct {
+	Event       string `json:"e"`
+	Time        int64  `json:"E"`
+	Symbol      string `json:"s"`
+	ClosePrice  string `json:"c"`
+	OpenPrice   string `json:"o"`
+	HighPrice   string `json:"h"`
+	LowPrice    string `json:"l"`
+	Volume      string `json:"v"`
+	QuoteVolume string `json:"q"`
+}
+
 // Mark price handler for futures
 type WsFuturesMarkPriceHandler func(event *WsFuturesMarkPriceEvent)
 
@@ -236,44 +280,377 @@ type WsFuturesMarkPriceEvent struct {
 	NextFundingTime      int64  `json:"T"`
 }
 
+// Liquidation order handler for futures
+type WsFuturesLiquidationOrderHandler func(event *WsFuturesLiquidationOrderEvent)
+
+// WsFuturesLiquidationOrderEvent define websocket liquidation order event
+type WsFuturesLiquidationOrderEvent struct {
+	Event            string                    `json:"e"`
+	Time             int64                     `json:"E"`
+	LiquidationOrder WsFuturesLiquidationOrder `json:"o"`
+}
+
+// WsFuturesLiquidationOrder define websocket liquidation order
+type WsFuturesLiquidationOrder struct {
+	Symbol               string                 `json:"s"`
+	Side                 common.SideType        `json:"S"`
+	OrderType            common.OrderType       `json:"o"`
+	TimeInForce          common.TimeInForceType `json:"f"`
+	OrigQuantity         string                 `json:"q"`
+	Price                string                 `json:"p"`
+	AvgPrice             string                 `json:"ap"`
+	OrderStatus          common.OrderStatusType `json:"X"`
+	LastFilledQty        string                 `json:"l"`
+	AccumulatedFilledQty string                 `json:"z"`
+	TradeTime            int64                  `json:"T"`
+}
+
+// Mini ticker handlers for futures
+type WsFuturesMiniMarketTickerHandler func(event *WsFuturesMiniMarketTickerEvent)
+type WsFuturesAllMiniMarketTickerHandler func(event WsFuturesAllMiniMarketTickerEvent)
+
+// WsFuturesAllMiniMarketTickerEvent define websocket all mini tickers event
+type WsFuturesAllMiniMarketTickerEvent []*WsFuturesMiniMarketTickerEvent
+
+// WsFuturesMiniMarketTickerEvent define websocket 24h mini ticker event
+type WsFuturesMiniMarketTickerEvent struct {
+	Event       string `json:"e"`
+	Time        int64  `json:"E"`
+	Symbol      string `json:"s"`
+	ClosePrice  string `json:"c"`
+	OpenPrice   string `json:"o"`
+	HighPrice   string `json:"h"`
+	LowPrice    string `json:"l"`
+	Volume      string `json:"v"`
+	QuoteVolume string `json:"q"`
+}
+
+// Ticker handlers for futures
+type WsFuturesMarketTickerHandler func(event *WsFuturesMarketTickerEvent)
+type WsFuturesAllMarketTickerHandler func(event WsFuturesAllMarketTickerEvent)
+
+// WsFuturesAllMarketTickerEvent define websocket all tickers event
+type WsFuturesAllMarketTickerEvent []*WsFuturesMarketTickerEvent
+
+// WsFuturesMarketTickerEvent define websocket 24h ticker event
+type WsFuturesMarketTickerEvent struct {
+	Event              string `json:"e"`
+	Time               int64  `json:"E"`
+	Symbol             string `json:"s"`
+	PriceChange        string `json:"p"`
+	PriceChangePercent string `json:"P"`
+	WeightedAvgPrice   string `json:"w"`
+	ClosePrice         string `json:"c"`
+	CloseQty           string `json:"Q"`
+	OpenPrice          string `json:"o"`
+	HighPrice          string `json:"h"`
+	LowPrice           string `json:"l"`
+	BaseVolume         string `json:"v"`
+	QuoteVolume        string `json:"q"`
+	OpenTime           int64  `json:"O"`
+	CloseTime          int64  `json:"C"`
+	FirstID            int64  `json:"F"`
+	LastID             int64  `json:"L"`
+	TradeCount         int64  `json:"n"`
+}
+
+// Continuous contract kline handler for futures
+type WsFuturesContinuousKlineHandler func(event *WsFuturesContinuousKlineEvent)
+
+// WsFuturesContinuousKlineEvent define websocket continuous contract kline
+// event. The kline has no symbol.
+type WsFuturesContinuousKlineEvent struct {
+	Event        string         `json:"e"`
+	Time         int64          `json:"E"`
+	PairSymbol   string         `json:"ps"`
+	ContractType string         `json:"ct"`
+	Kline        WsFuturesKline `json:"k"`
+}
+
+// Index price handler for futures
+type WsFuturesIndexPriceHandler func(event *WsFuturesIndexPriceEvent)
+
+// WsFuturesIndexPriceEvent define websocket index price event
+type WsFuturesIndexPriceEvent struct {
+	Event      string `json:"e"`
+	Time       int64  `json:"E"`
+	Pair       string `json:"i"`
+	IndexPrice string `json:"p"`
+}
+
 // User data handlers
 type WsSpotUserDataHandler func(event *WsSpotUserDataEvent)
 type WsFuturesUserDataHandler func(event *WsFuturesUserDataEvent)
 
-// WsSpotUserDataEvent represents a spot user data event
+// UserDataEventType is the "e" field of a user data event
+type UserDataEventType string
+
+const (
+	// Futures events
+	UserDataEventTypeAccountUpdate       UserDataEventType = "ACCOUNT_UPDATE"
+	UserDataEventTypeOrderTradeUpdate    UserDataEventType = "ORDER_TRADE_UPDATE"
+	UserDataEventTypeAccountConfigUpdate UserDataEventType = "ACCOUNT_CONFIG_UPDATE"
+	UserDataEventTypeMarginCall          UserDataEventType = "MARGIN_CALL"
+	UserDataEventTypeTradeLite           UserDataEventType = "TRADE_LITE"
+
+	// Spot events
+	UserDataEventTypeOutboundAccountPosition UserDataEventType = "outboundAccountPosition"
+	UserDataEventTypeBalanceUpdate           UserDataEventType = "balanceUpdate"
+	UserDataEventTypeExecutionReport         UserDataEventType = "executionReport"
+	UserDataEventTypeListStatus              UserDataEventType = "listStatus"
+
+	// Both markets
+	UserDataEventTypeListenKeyExpired UserDataEventType = "listenKeyExpired"
+)
+
+// AccountUpdateReason is the reason of a futures ACCOUNT_UPDATE
+type AccountUpdateReason string
+
+const (
+	AccountUpdateReasonDeposit          AccountUpdateReason = "DEPOSIT"
+	AccountUpdateReasonWithdraw         AccountUpdateReason = "WITHDRAW"
+	AccountUpdateReasonOrder            AccountUpdateReason = "ORDER"
+	AccountUpdateReasonFundingFee       AccountUpdateReason = "FUNDING_FEE"
+	AccountUpdateReasonWithdrawReject   AccountUpdateReason = "WITHDRAW_REJECT"
+	AccountUpdateReasonAdjustment       AccountUpdateReason = "ADJUSTMENT"
+	AccountUpdateReasonInsuranceClear   AccountUpdateReason = "INSURANCE_CLEAR"
+	AccountUpdateReasonAdminDeposit     AccountUpdateReason = "ADMIN_DEPOSIT"
+	AccountUpdateReasonAdminWithdraw    AccountUpdateReason = "ADMIN_WITHDRAW"
+	AccountUpdateReasonMarginTransfer   AccountUpdateReason = "MARGIN_TRANSFER"
+	AccountUpdateReasonMarginTypeChange AccountUpdateReason = "MARGIN_TYPE_CHANGE"
+	AccountUpdateReasonAssetTransfer    AccountUpdateReason = "ASSET_TRANSFER"
+	AccountUpdateReasonAutoExchange     AccountUpdateReason = "AUTO_EXCHANGE"
+)
+
+// WsSpotUserDataEvent represents a spot user data event. One field is set
+// depending on Event, Raw for events without a typed field.
 type WsSpotUserDataEvent struct {
-	Event            string                 `json:"e"`
-	Time             int64                  `json:"E"`
-	
-	// Account update event
-	AccountUpdate    *WsSpotAccountUpdate   `json:"accountUpdate,omitempty"`
-	
-	// Order update event
-	OrderUpdate      *WsSpotOrderUpdate     `json:"orderUpdate,omitempty"`
+	Event UserDataEventType `json:"e"`
+	Time  int64             `json:"E"`
+
+	// outboundAccountPosition
+	AccountUpdate *WsSpotAccountUpdate `json:"accountUpdate,omitempty"`
+
+	// balanceUpdate
+	BalanceUpdate *WsSpotBalanceUpdate `json:"balanceUpdate,omitempty"`
+
+	// executionReport
+	OrderUpdate *WsSpotOrderUpdate `json:"orderUpdate,omitempty"`
+
+	// listStatus
+	ListStatus *WsSpotListStatus `json:"listStatus,omitempty"`
+
+	// Expired listen key, set on listenKeyExpired
+	ListenKey string `json:"listenKey,omitempty"`
+
+	// Message of an unrecognized event
+	Raw json.RawMessage `json:"raw,omitempty"`
 }
 
-// WsFuturesUserDataEvent represents a futures user data event
+// UnmarshalJSON decodes a spot user data message. The event type is read
+// from the first field, then the message is decoded once into the matching
+// type.
+func (e *WsSpotUserDataEvent) UnmarshalJSON(data []byte) error {
+	*e = WsSpotUserDataEvent{Event: userDataEventType(data)}
+	switch e.Event {
+	case UserDataEventTypeOutboundAccountPosition:
+		var v struct {
+			Event string `json:"e"`
+			Time  int64  `json:"E"`
+			WsSpotAccountUpdate
+		}
+		if err := JSON.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		e.Time, e.AccountUpdate = v.Time, &v.WsSpotAccountUpdate
+	case UserDataEventTypeBalanceUpdate:
+		var v struct {
+			Event string `json:"e"`
+			Time  int64  `json:"E"`
+			WsSpotBalanceUpdate
+		}
+		if err := JSON.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		e.Time, e.BalanceUpdate = v.Time, &v.WsSpotBalanceUpdate
+	case UserDataEventTypeExecutionReport:
+		var v struct {
+			Event string `json:"e"`
+			Time  int64  `json:"E"`
+			WsSpotOrderUpdate
+		}
+		if err := JSON.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		e.Time, e.OrderUpdate = v.Time, &v.WsSpotOrderUpdate
+	case UserDataEventTypeListStatus:
+		var v struct {
+			Event string `json:"e"`
+			Time  int64  `json:"E"`
+			WsSpotListStatus
+		}
+		if err := JSON.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		e.Time, e.ListStatus = v.Time, &v.WsSpotListStatus
+	case UserDataEventTypeListenKeyExpired:
+		var v struct {
+			Event     string `json:"e"`
+			Time      int64  `json:"E"`
+			ListenKey string `json:"listenKey"`
+		}
+		if err := JSON.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		e.Time, e.ListenKey = v.Time, v.ListenKey
+	default:
+		e.Time, _ = userDataEventTimes(data)
+		e.Raw = append(json.RawMessage(nil), data...)
+	}
+	return nil
+}
+
+// WsFuturesUserDataEvent represents a futures user data event. One field is
+// set depending on Event, Raw for events without a typed field.
 type WsFuturesUserDataEvent struct {
-	Event            string                   `json:"e"`
-	Time             int64                    `json:"E"`
-	TransactionTime  int64                    `json:"T"`
-	
-	// Account update event
-	AccountUpdate    *WsFuturesAccountUpdate  `json:"a,omitempty"`
-	
-	// Order update event
-	OrderUpdate      *WsFuturesOrderUpdate    `json:"o,omitempty"`
-	
-	// Account config update event
+	Event           UserDataEventType `json:"e"`
+	Time            int64             `json:"E"`
+	TransactionTime int64             `json:"T"`
+
+	// ACCOUNT_UPDATE
+	AccountUpdate *WsFuturesAccountUpdate `json:"a,omitempty"`
+
+	// ORDER_TRADE_UPDATE
+	OrderUpdate *WsFuturesOrderUpdate `json:"o,omitempty"`
+
+	// ACCOUNT_CONFIG_UPDATE, leverage or multi-assets mode
 	AccountConfigUpdate *WsFuturesAccountConfigUpdate `json:"ac,omitempty"`
-	
-	// Margin call event
-	MarginCall       *WsFuturesMarginCall     `json:"margin_call,omitempty"`
+	AccountInfoUpdate   *WsFuturesAccountInfoUpdate   `json:"ai,omitempty"`
+
+	// MARGIN_CALL
+	MarginCall *WsFuturesMarginCall `json:"margin_call,omitempty"`
+
+	// TRADE_LITE
+	TradeLite *WsFuturesTradeLite `json:"tradeLite,omitempty"`
+
+	// Expired listen key, set on listenKeyExpired
+	ListenKey string `json:"listenKey,omitempty"`
+
+	// Message of an unrecognized event
+	Raw json.RawMessage `json:"raw,omitempty"`
+}
+
+// UnmarshalJSON decodes a futures user data message. The event type is read
+// from the first field, then the message is decoded once into the matching
+// type.
+func (e *WsFuturesUserDataEvent) UnmarshalJSON(data []byte) error {
+	*e = WsFuturesUserDataEvent{Event: userDataEventType(data)}
+	switch e.Event {
+	case UserDataEventTypeAccountUpdate:
+		v := new(WsFuturesAccountUpdate)
+		if err := JSON.Unmarshal(data, v); err != nil {
+			return err
+		}
+		e.Time, e.TransactionTime, e.AccountUpdate = v.Time, v.TransactionTime, v
+	case UserDataEventTypeOrderTradeUpdate:
+		var v struct {
+			Event           string               `json:"e"`
+			Time            int64                `json:"E"`
+			TransactionTime int64                `json:"T"`
+			Order           WsFuturesOrderUpdate `json:"o"`
+		}
+		if err := JSON.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		e.Time, e.TransactionTime, e.OrderUpdate = v.Time, v.TransactionTime, &v.Order
+	case UserDataEventTypeAccountConfigUpdate:
+		var v struct {
+			Event           string                        `json:"e"`
+			Time            int64                         `json:"E"`
+			TransactionTime int64                         `json:"T"`
+			Config          *WsFuturesAccountConfigUpdate `json:"ac"`
+			Info            *WsFuturesAccountInfoUpdate   `json:"ai"`
+		}
+		if err := JSON.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		e.Time, e.TransactionTime = v.Time, v.TransactionTime
+		e.AccountConfigUpdate, e.AccountInfoUpdate = v.Config, v.Info
+	case UserDataEventTypeMarginCall:
+		var v struct {
+			Event string `json:"e"`
+			Time  int64  `json:"E"`
+			WsFuturesMarginCall
+		}
+		if err := JSON.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		e.Time, e.MarginCall = v.Time, &v.WsFuturesMarginCall
+	case UserDataEventTypeTradeLite:
+		var v struct {
+			Event           string `json:"e"`
+			Time            int64  `json:"E"`
+			TransactionTime int64  `json:"T"`
+			WsFuturesTradeLite
+		}
+		if err := JSON.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		e.Time, e.TransactionTime, e.TradeLite = v.Time, v.TransactionTime, &v.WsFuturesTradeLite
+	case UserDataEventTypeListenKeyExpired:
+		var v struct {
+			Event     string `json:"e"`
+			Time      int64  `json:"E"`
+			ListenKey string `json:"listenKey"`
+		}
+		if err := JSON.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		e.Time, e.ListenKey = v.Time, v.ListenKey
+	default:
+		e.Time, e.TransactionTime = userDataEventTimes(data)
+		e.Raw = append(json.RawMessage(nil), data...)
+	}
+	return nil
+}
+
+// userDataEventType returns the event type of a user data message. The
+// exchange sends it as the first field, so only that field is read unless
+// the message has another order.
+func userDataEventType(data []byte) UserDataEventType {
+	iter := JSON.BorrowIterator(data)
+	defer JSON.ReturnIterator(iter)
+	if iter.ReadObject() == "e" && iter.WhatIsNext() == jsoniter.StringValue {
+		return UserDataEventType(iter.ReadString())
+	}
+	return UserDataEventType(JSON.Get(data, "e").ToString())
+}
+
+// userDataEventTimes reads the event and transaction times of a message
+// without a typed field in one pass
+func userDataEventTimes(data []byte) (eventTime, transactionTime int64) {
+	iter := JSON.BorrowIterator(data)
+	defer JSON.ReturnIterator(iter)
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, key string) bool {
+		switch {
+		case iter.WhatIsNext() != jsoniter.NumberValue:
+			iter.Skip()
+		case key == "E":
+			eventTime = iter.ReadInt64()
+		case key == "T":
+			transactionTime = iter.ReadInt64()
+		default:
+			iter.Skip()
+		}
+		return true
+	})
+	return eventTime, transactionTime
 }
 
 // WsSpotAccountUpdate represents spot account update
 type WsSpotAccountUpdate struct {
-	Balances []WsSpotBalance `json:"B"`
+	LastUpdateTime int64           `json:"u"`
+	Balances       []WsSpotBalance `json:"B"`
 }
 
 // WsSpotBalance represents spot balance
@@ -283,45 +660,82 @@ type WsSpotBalance struct {
 	Locked string `json:"l"`
 }
 
+// WsSpotBalanceUpdate represents a spot deposit, withdrawal or transfer
+type WsSpotBalanceUpdate struct {
+	Asset     string `json:"a"`
+	Change    string `json:"d"`
+	ClearTime int64  `json:"T"`
+}
+
 // WsSpotOrderUpdate represents spot order update
 type WsSpotOrderUpdate struct {
-	Symbol                   string                    `json:"s"`
-	ClientOrderID            string                    `json:"c"`
-	Side                     common.SideType           `json:"S"`
-	OrderType                common.OrderType          `json:"o"`
-	TimeInForce              common.TimeInForceType    `json:"f"`
-	OriginalQuantity         string                    `json:"q"`
-	OriginalPrice            string                    `json:"p"`
-	AveragePrice             string                    `json:"ap"`
-	StopPrice                string                    `json:"sp"`
-	ExecutionType            string                    `json:"x"`
-	OrderStatus              common.OrderStatusType    `json:"X"`
-	OrderID                  int64                     `json:"i"`
-	LastFilledQuantity       string                    `json:"l"`
-	CumulativeFilledQuantity string                    `json:"z"`
-	LastFilledPrice          string                    `json:"L"`
-	Commission               string                    `json:"n"`
-	CommissionAsset          string                    `json:"N"`
-	TransactionTime          int64                     `json:"T"`
-	TradeID                  int64                     `json:"t"`
-	OrderCreatedTime         int64                     `json:"O"`
-	CumulativeQuoteQty       string                    `json:"Z"`
-	LastQuoteQty             string                    `json:"Y"`
+	Symbol                   string                 `json:"s"`
+	ClientOrderID            string                 `json:"c"`
+	Side                     common.SideType        `json:"S"`
+	OrderType                common.OrderType       `json:"o"`
+	TimeInForce              common.TimeInForceType `json:"f"`
+	OriginalQuantity         string                 `json:"q"`
+	OriginalPrice            string                 `json:"p"`
+	AveragePrice             string                 `json:"ap"`
+	StopPrice                string                 `json:"P"`
+	IcebergQuantity          string                 `json:"F"`
+	ExecutionType            common.ExecutionType   `json:"x"`
+	OrderStatus              common.OrderStatusType `json:"X"`
+	RejectReason             string                 `json:"r"`
+	OrderID                  int64                  `json:"i"`
+	OrderListID              int64                  `json:"g"`
+	OrigClientOrderID        string                 `json:"C"`
+	LastFilledQuantity       string                 `json:"l"`
+	CumulativeFilledQuantity string                 `json:"z"`
+	LastFilledPrice          string                 `json:"L"`
+	Commission               string                 `json:"n"`
+	CommissionAsset          string                 `json:"N"`
+	TransactionTime          int64                  `json:"T"`
+	TradeID                  int64                  `json:"t"`
+	ExecutionID              int64                  `json:"I"`
+	IsWorking                bool                   `json:"w"`
+	IsMaker                  bool                   `json:"m"`
+	Ignore                   bool                   `json:"M"`
+	OrderCreatedTime         int64                  `json:"O"`
+	WorkingTime              int64                  `json:"W"`
+	CumulativeQuoteQty       string                 `json:"Z"`
+	LastQuoteQty             string                 `json:"Y"`
+	QuoteOrderQty            string                 `json:"Q"`
+}
+
+// WsSpotListStatus represents a spot order list update
+type WsSpotListStatus struct {
+	Symbol            string                  `json:"s"`
+	OrderListID       int64                   `json:"g"`
+	ContingencyType   string                  `json:"c"`
+	ListStatusType    string                  `json:"l"`
+	ListOrderStatus   string                  `json:"L"`
+	ListRejectReason  string                  `json:"r"`
+	ListClientOrderID string                  `json:"C"`
+	TransactionTime   int64                   `json:"T"`
+	Orders            []WsSpotListStatusOrder `json:"O"`
+}
+
+// WsSpotListStatusOrder represents an order of a spot order list
+type WsSpotListStatusOrder struct {
+	Symbol        string `json:"s"`
+	OrderID       int64  `json:"i"`
+	ClientOrderID string `json:"c"`
 }
 
 // WsFuturesAccountUpdate represents futures account update
 type WsFuturesAccountUpdate struct {
-	Event           string                    `json:"e"`
-	Time            int64                     `json:"E"`
-	TransactionTime int64                     `json:"T"`
+	Event           string                     `json:"e"`
+	Time            int64                      `json:"E"`
+	TransactionTime int64                      `json:"T"`
 	UpdateData      WsFuturesAccountUpdateData `json:"a"`
 }
 
 // WsFuturesAccountUpdateData represents futures account update data
 type WsFuturesAccountUpdateData struct {
-	Reason    string                `json:"m"`
-	Balances  []WsFuturesBalance    `json:"B"`
-	Positions []WsFuturesPosition   `json:"P"`
+	Reason    AccountUpdateReason `json:"m"`
+	Balances  []WsFuturesBalance  `json:"B"`
+	Positions []WsFuturesPosition `json:"P"`
 }
 
 // WsFuturesBalance represents futures balance
@@ -334,48 +748,50 @@ type WsFuturesBalance struct {
 
 // WsFuturesPosition represents futures position
 type WsFuturesPosition struct {
-	Symbol         string `json:"s"`   // 交易对
-	Side           string `json:"ps"`  // 持仓方向
-	Amount         string `json:"pa"`  // 持仓数量
-	MarginType     string `json:"mt"`  // 保证金模式
-	IsolatedWallet string `json:"iw"`  // 若为逐仓，仓位保证金
-	MarkPrice      string `json:"mp"`  // 标记价格
-	UnrealizedPnL  string `json:"up"`  // 持仓未实现盈亏
-	EntryPrice     string `json:"ep"`  // 持仓成本价
+	Symbol              string `json:"s"`   // 交易对
+	Side                string `json:"ps"`  // 持仓方向
+	Amount              string `json:"pa"`  // 持仓数量
+	MarginType          string `json:"mt"`  // 保证金模式
+	IsolatedWallet      string `json:"iw"`  // 若为逐仓，仓位保证金
+	MarkPrice           string `json:"mp"`  // 标记价格
+	UnrealizedPnL       string `json:"up"`  // 持仓未实现盈亏
+	EntryPrice          string `json:"ep"`  // 持仓成本价
+	AccumulatedRealized string `json:"cr"`  // 累计实现损益
+	BreakEvenPrice      string `json:"bep"` // 盈亏平衡价
 }
 
 // WsFuturesOrderUpdate represents futures order update
 type WsFuturesOrderUpdate struct {
-	Symbol                   string                    `json:"s"`
-	ClientOrderID            string                    `json:"c"`
-	Side                     common.SideType           `json:"S"`
-	OrderType                common.OrderType          `json:"o"`
-	TimeInForce              common.TimeInForceType    `json:"f"`
-	OriginalQuantity         string                    `json:"q"`
-	OriginalPrice            string                    `json:"p"`
-	AveragePrice             string                    `json:"ap"`
-	StopPrice                string                    `json:"sp"`
-	ExecutionType            string                    `json:"x"`
-	OrderStatus              common.OrderStatusType    `json:"X"`
-	OrderID                  int64                     `json:"i"`
-	LastFilledQuantity       string                    `json:"l"`
-	CumulativeFilledQuantity string                    `json:"z"`
-	LastFilledPrice          string                    `json:"L"`
-	CommissionAsset          string                    `json:"N"`
-	Commission               string                    `json:"n"`
-	OrderTradeTime           int64                     `json:"T"`
-	TradeID                  int64                     `json:"t"`
-	BidsNotional             string                    `json:"b"`
-	AsksNotional             string                    `json:"a"`
-	IsMaker                  bool                      `json:"m"`
-	IsReduceOnly             bool                      `json:"R"`
-	WorkingType              string                    `json:"wt"`
-	OriginalType             common.OrderType          `json:"ot"`
-	PositionSide             string                    `json:"ps"`
-	IsClosingPosition        bool                      `json:"cp"`
-	ActivationPrice          string                    `json:"AP"`
-	CallbackRate             string                    `json:"cr"`
-	RealizedProfit           string                    `json:"rp"`
+	Symbol                   string                 `json:"s"`
+	ClientOrderID            string                 `json:"c"`
+	Side                     common.SideType        `json:"S"`
+	OrderType                common.OrderType       `json:"o"`
+	TimeInForce              common.TimeInForceType `json:"f"`
+	OriginalQuantity         string                 `json:"q"`
+	OriginalPrice            string                 `json:"p"`
+	AveragePrice             string                 `json:"ap"`
+	StopPrice                string                 `json:"sp"`
+	ExecutionType            common.ExecutionType   `json:"x"`
+	OrderStatus              common.OrderStatusType `json:"X"`
+	OrderID                  int64                  `json:"i"`
+	LastFilledQuantity       string                 `json:"l"`
+	CumulativeFilledQuantity string                 `json:"z"`
+	LastFilledPrice          string                 `json:"L"`
+	CommissionAsset          string                 `json:"N"`
+	Commission               string                 `json:"n"`
+	OrderTradeTime           int64                  `json:"T"`
+	TradeID                  int64                  `json:"t"`
+	BidsNotional             string                 `json:"b"`
+	AsksNotional             string                 `json:"a"`
+	IsMaker                  bool                   `json:"m"`
+	IsReduceOnly             bool                   `json:"R"`
+	WorkingType              string                 `json:"wt"`
+	OriginalType             common.OrderType       `json:"ot"`
+	PositionSide             string                 `json:"ps"`
+	IsClosingPosition        bool                   `json:"cp"`
+	ActivationPrice          string                 `json:"AP"`
+	CallbackRate             string                 `json:"cr"`
+	RealizedProfit           string                 `json:"rp"`
 }
 
 // WsFuturesAccountConfigUpdate represents futures account configuration update
@@ -385,19 +801,39 @@ type WsFuturesAccountConfigUpdate struct {
 	MarginType string `json:"mt"`
 }
 
+// WsFuturesAccountInfoUpdate represents a futures multi-assets mode change
+type WsFuturesAccountInfoUpdate struct {
+	MultiAssetsMode bool `json:"j"`
+}
+
 // WsFuturesMarginCall represents futures margin call
 type WsFuturesMarginCall struct {
-	Positions []WsFuturesMarginCallPosition `json:"p"`
+	CrossWalletBalance string                        `json:"cw"`
+	Positions          []WsFuturesMarginCallPosition `json:"p"`
 }
 
 // WsFuturesMarginCallPosition represents futures margin call position
 type WsFuturesMarginCallPosition struct {
-	Symbol      string            `json:"s"`
-	Side        string            `json:"ps"`
-	Amount      string            `json:"pa"`
-	MarginType  string            `json:"mt"`
-	IsolatedWallet string         `json:"iw"`
-	MarkPrice   string            `json:"mp"`
-	UnrealizedPnL string          `json:"up"`
+	Symbol                    string `json:"s"`
+	Side                      string `json:"ps"`
+	Amount                    string `json:"pa"`
+	MarginType                string `json:"mt"`
+	IsolatedWallet            string `json:"iw"`
+	MarkPrice                 string `json:"mp"`
+	UnrealizedPnL             string `json:"up"`
 	MaintenanceMarginRequired string `json:"mm"`
-}
\ No newline at end of file
+}
+
+// WsFuturesTradeLite represents a futures TRADE_LITE fill
+type WsFuturesTradeLite struct {
+	Symbol             string          `json:"s"`
+	OriginalQuantity   string          `json:"q"`
+	OriginalPrice      string          `json:"p"`
+	IsMaker            bool            `json:"m"`
+	ClientOrderID      string          `json:"c"`
+	Side               common.SideType `json:"S"`
+	LastFilledPrice    string          `json:"L"`
+	LastFilledQuantity string          `json:"l"`
+	TradeID            int64           `json:"t"`
+	OrderID            int64           `json:"i"`
+}
@@ -0,0 +1,451 @@
+package aster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ErrShardLimit is returned when streams do not fit in MaxConnections
+// connections
+var ErrShardLimit = errors.New("aster: stream connection limit reached")
+
+// ShardedStreamClient spreads streams over as many StreamClient connections
+// as the per-connection stream limit requires. New streams go to the least
+// loaded connection, and when streams are removed, connections that are no
+// longer needed are merged into the others. Each connection calls its
+// handlers from its own goroutine, unless SerializeHandlers is set. Set the
+// exported fields before the first Subscribe.
+type ShardedStreamClient struct {
+	// MaxStreamsPerConnection caps the streams of one connection, 200 by
+	// default
+	MaxStreamsPerConnection int
+	// MaxConnections caps the connections, 0 means no limit
+	MaxConnections int
+	// SerializeHandlers calls the handlers of all connections one at a
+	// time, so that they form one feed at the cost of a shared lock
+	SerializeHandlers bool
+
+	// MaxMessagesPerSecond, RequestTimeout, LocalAddress and Options
+	// configure each connection, see StreamClient
+	MaxMessagesPerSecond int
+	RequestTimeout       time.Duration
+	LocalAddress         string
+	Options              []StreamOption
+
+	streamSubscriptions
+
+	opMu       sync.Mutex // serializes subscription changes
+	dispatchMu sync.Mutex // serializes handlers with SerializeHandlers
+
+	mu       sync.Mutex
+	shards   []*streamShard
+	location map[string]*streamShard
+	handlers map[string]*streamHandler // shared by the streams of one Subscribe
+	closed   bool
+}
+
+// streamShard is one connection of a ShardedStreamClient
+type streamShard struct {
+	client  *StreamClient
+	streams map[string]bool
+}
+
+// NewSpotShardedStreamClient creates a sharded stream client for spot
+// streams
+func NewSpotShardedStreamClient(errHandler ErrHandler) *ShardedStreamClient {
+	return newShardedStreamClient(false, errHandler)
+}
+
+// NewFuturesShardedStreamClient creates a sharded stream client for
+// futures streams
+func NewFuturesShardedStreamClient(errHandler ErrHandler) *ShardedStreamClient {
+	return newShardedStreamClient(true, errHandler)
+}
+
+func newShardedStreamClient(isFutures bool, errHandler ErrHandler) *ShardedStreamClient {
+	c := &ShardedStreamClient{
+		location: map[string]*streamShard{},
+		handlers: map[string]*streamHandler{},
+	}
+	c.streamSubscriptions = streamSubscriptions{subscribe: c.subscribe, isFutures: isFutures, errHandler: errHandler}
+	return c
+}
+
+// Subscribe subscribes to streams, e.g. "btcusdt@depth", opening
+// connections as needed, and routes their raw event data to handler.
+// Streams already subscribed only get the new handler.
+func (c *ShardedStreamClient) Subscribe(ctx context.Context, handler WsHandler, streams ...string) error {
+	return c.subscribe(ctx, streamHandler{raw: handler}, streams...)
+}
+
+// subscribe subscribes to streams routed to handler. A failed call is
+// undone: the streams it added are unsubscribed and the others keep their
+// previous handler.
+func (c *ShardedStreamClient) subscribe(ctx context.Context, handler streamHandler, streams ...string) error {
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+	if c.isClosed() {
+		return ErrStreamClientClosed
+	}
+	if c.SerializeHandlers {
+		handler = c.serialize(handler)
+	}
+
+	// assign the streams to connections, filling the least loaded first
+	assigned := map[*streamShard][]string{}
+	var order []*streamShard
+	previous := map[string]*streamHandler{}
+	load := map[*streamShard]int{}
+	c.mu.Lock()
+	shards := append([]*streamShard(nil), c.shards...)
+	for _, sh := range shards {
+		load[sh] = len(sh.streams)
+	}
+	var added []*streamShard
+	for _, stream := range dedupe(streams) {
+		sh := c.location[stream]
+		if sh == nil {
+			if sh = leastLoaded(shards, load, c.maxStreams()); sh == nil {
+				if c.MaxConnections > 0 && len(shards) >= c.MaxConnections {
+					c.mu.Unlock()
+					c.closeShards(added)
+					return fmt.Errorf("%w: %d connections of %d streams", ErrShardLimit, c.MaxConnections, c.maxStreams())
+				}
+				sh = &streamShard{streams: map[string]bool{}}
+				shards = append(shards, sh)
+				added = append(added, sh)
+			}
+			load[sh]++
+		} else {
+			previous[stream] = c.handlers[stream]
+		}
+		if assigned[sh] == nil {
+			order = append(order, sh)
+		}
+		assigned[sh] = append(assigned[sh], stream)
+	}
+	c.mu.Unlock()
+
+	for _, sh := range added {
+		if err := c.connect(sh); err != nil {
+			c.closeShards(added)
+			return err
+		}
+	}
+	c.mu.Lock()
+	c.shards = append(c.shards, added...)
+	c.mu.Unlock()
+
+	for i, sh := range order {
+		if err := sh.client.subscribe(ctx, handler, assigned[sh]...); err != nil {
+			err = fmt.Errorf("aster: subscribe %v: %w", assigned[sh], err)
+			err = errors.Join(err, c.undoSubscribe(ctx, order[:i+1], added, assigned, previous))
+			c.dropEmpty()
+			return err
+		}
+		c.mu.Lock()
+		for _, stream := range assigned[sh] {
+			sh.streams[stream] = true
+			c.location[stream] = sh
+			c.handlers[stream] = &handler
+		}
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// undoSubscribe reverts the streams a failed subscribe assigned to shards.
+// Streams it added are unsubscribed, the others get their previous handler
+// back. The connections it opened are left to dropEmpty.
+func (c *ShardedStreamClient) undoSubscribe(ctx context.Context, shards, opened []*streamShard, assigned map[*streamShard][]string, previous map[string]*streamHandler) error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	for _, sh := range shards {
+		var added []string
+		for _, stream := range assigned[sh] {
+			if handler := previous[stream]; handler != nil {
+				// only swaps the handler, no request is sent
+				sh.client.subscribe(ctx, *handler, stream)
+				c.mu.Lock()
+				c.handlers[stream] = handler
+				c.mu.Unlock()
+			} else {
+				added = append(added, stream)
+			}
+		}
+		if len(added) == 0 {
+			continue
+		}
+		c.mu.Lock()
+		for _, stream := range added {
+			delete(sh.streams, stream)
+			delete(c.location, stream)
+			delete(c.handlers, stream)
+		}
+		c.mu.Unlock()
+		if containsShard(opened, sh) {
+			continue
+		}
+		if err := sh.client.Unsubscribe(ctx, added...); err != nil {
+			errs = append(errs, fmt.Errorf("aster: unsubscribe %v: %w", added, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Unsubscribe unsubscribes from streams and merges connections that are no
+// longer needed. Streams moved by a merge are subscribed on their new
+// connection before the old one closes, so they may deliver a few events
+// twice. Streams whose request fails stay subscribed.
+func (c *ShardedStreamClient) Unsubscribe(ctx context.Context, streams ...string) error {
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+	if c.isClosed() {
+		return ErrStreamClientClosed
+	}
+
+	byShard := map[*streamShard][]string{}
+	c.mu.Lock()
+	for _, stream := range dedupe(streams) {
+		if sh := c.location[stream]; sh != nil {
+			byShard[sh] = append(byShard[sh], stream)
+		}
+	}
+	c.mu.Unlock()
+
+	// Streams of a failed connection stay subscribed, the others are
+	// still removed and their connections merged
+	var errs []error
+	for sh, shardStreams := range byShard {
+		if err := sh.client.Unsubscribe(ctx, shardStreams...); err != nil {
+			errs = append(errs, fmt.Errorf("aster: unsubscribe %v: %w", shardStreams, err))
+			continue
+		}
+		c.mu.Lock()
+		for _, stream := range shardStreams {
+			delete(sh.streams, stream)
+			delete(c.location, stream)
+			delete(c.handlers, stream)
+		}
+		c.mu.Unlock()
+	}
+	c.dropEmpty()
+	return errors.Join(append(errs, c.rebalance(ctx))...)
+}
+
+// Streams returns the subscribed streams, in no particular order
+func (c *ShardedStreamClient) Streams() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	streams := make([]string, 0, len(c.location))
+	for stream := range c.location {
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+// Connections returns the number of streams of each connection
+func (c *ShardedStreamClient) Connections() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make([]int, len(c.shards))
+	for i, sh := range c.shards {
+		counts[i] = len(sh.streams)
+	}
+	return counts
+}
+
+// Close closes every connection
+func (c *ShardedStreamClient) Close() {
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+	c.mu.Lock()
+	c.closed = true
+	shards := c.shards
+	c.shards = nil
+	c.location = map[string]*streamShard{}
+	c.handlers = map[string]*streamHandler{}
+	c.mu.Unlock()
+	c.closeShards(shards)
+}
+
+// rebalance merges the least loaded connection into the others while the
+// streams fit in fewer connections
+func (c *ShardedStreamClient) rebalance(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		total := len(c.location)
+		needed := (total + c.maxStreams() - 1) / c.maxStreams()
+		if len(c.shards) <= needed || len(c.shards) < 2 {
+			c.mu.Unlock()
+			return nil
+		}
+		sort.Slice(c.shards, func(i, j int) bool { return len(c.shards[i].streams) < len(c.shards[j].streams) })
+		src := c.shards[0]
+		targets := c.shards[1:]
+		load := map[*streamShard]int{}
+		for _, sh := range targets {
+			load[sh] = len(sh.streams)
+		}
+		moves := map[*streamShard]map[*streamHandler][]string{}
+		for stream := range src.streams {
+			sh := leastLoaded(targets, load, c.maxStreams())
+			load[sh]++
+			if moves[sh] == nil {
+				moves[sh] = map[*streamHandler][]string{}
+			}
+			handler := c.handlers[stream]
+			moves[sh][handler] = append(moves[sh][handler], stream)
+		}
+		c.mu.Unlock()
+
+		var moved []string
+		for sh, byHandler := range moves {
+			for handler, streams := range byHandler {
+				if err := sh.client.subscribe(ctx, *handler, streams...); err != nil {
+					err = fmt.Errorf("aster: move %v: %w", streams, err)
+					return errors.Join(err, c.undoMove(ctx, src, sh, streams, moved))
+				}
+				c.mu.Lock()
+				for _, stream := range streams {
+					delete(src.streams, stream)
+					sh.streams[stream] = true
+					c.location[stream] = sh
+				}
+				c.mu.Unlock()
+				moved = append(moved, streams...)
+			}
+		}
+		c.dropEmpty()
+	}
+}
+
+// undoMove settles a merge that failed moving streams from src to dst, so
+// that every stream is on one connection: the failed streams stay on src
+// and the moved ones leave it
+func (c *ShardedStreamClient) undoMove(ctx context.Context, src, dst *streamShard, failed, moved []string) error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	// the request may have reached the server before failing
+	if err := dst.client.Unsubscribe(ctx, failed...); err != nil {
+		errs = append(errs, fmt.Errorf("aster: unsubscribe %v: %w", failed, err))
+	}
+	if len(moved) > 0 {
+		if err := src.client.Unsubscribe(ctx, moved...); err != nil {
+			errs = append(errs, fmt.Errorf("aster: unsubscribe %v: %w", moved, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// connect opens the connection of a new shard
+func (c *ShardedStreamClient) connect(sh *streamShard) error {
+	client := newStreamClient(c.isFutures, c.errHandler)
+	client.MaxMessagesPerSecond = c.MaxMessagesPerSecond
+	client.RequestTimeout = c.RequestTimeout
+	client.LocalAddress = c.LocalAddress
+	client.Options = c.Options
+	if err := client.Connect(); err != nil {
+		return err
+	}
+	sh.client = client
+	return nil
+}
+
+// dropEmpty closes the connections without streams
+func (c *ShardedStreamClient) dropEmpty() {
+	var empty []*streamShard
+	c.mu.Lock()
+	kept := c.shards[:0]
+	for _, sh := range c.shards {
+		if len(sh.streams) == 0 {
+			empty = append(empty, sh)
+		} else {
+			kept = append(kept, sh)
+		}
+	}
+	c.shards = kept
+	c.mu.Unlock()
+	c.closeShards(empty)
+}
+
+// closeShards closes the connections of shards
+func (c *ShardedStreamClient) closeShards(shards []*streamShard) {
+	for _, sh := range shards {
+		if sh.client != nil {
+			sh.client.Close()
+		}
+	}
+}
+
+// serialize wraps handler so that it runs under the dispatch lock
+func (c *ShardedStreamClient) serialize(handler streamHandler) streamHandler {
+	serialized := streamHandler{raw: func(data []byte) {
+		c.dispatchMu.Lock()
+		defer c.dispatchMu.Unlock()
+		handler.raw(data)
+	}}
+	if handler.decode != nil {
+		serialized.decode = func(iter *jsoniter.Iterator, size int) {
+			c.dispatchMu.Lock()
+			defer c.dispatchMu.Unlock()
+			handler.decode(iter, size)
+		}
+	}
+	return serialized
+}
+
+// isClosed reports whether Close has been called
+func (c *ShardedStreamClient) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// maxStreams returns the stream limit of a connection
+func (c *ShardedStreamClient) maxStreams() int {
+	if c.MaxStreamsPerConnection > 0 {
+		return c.MaxStreamsPerConnection
+	}
+	return 200
+}
+
+// leastLoaded returns the shard with the fewest streams below max, or nil
+func leastLoaded(shards []*streamShard, load map[*streamShard]int, max int) *streamShard {
+	var best *streamShard
+	for _, sh := range shards {
+		if load[sh] < max && (best == nil || load[sh] < load[best]) {
+			best = sh
+		}
+	}
+	return best
+}
+
+// containsShard reports whether shards contains sh
+func containsShard(shards []*streamShard, sh *streamShard) bool {
+	for _, s := range shards {
+		if s == sh {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupe returns streams without duplicates, in their original order
+func dedupe(streams []string) []string {
+	seen := make(map[string]bool, len(streams))
+	out := streams[:0:0]
+	for _, stream := range streams {
+		if !seen[stream] {
+			seen[stream] = true
+			out = append(out, stream)
+		}
+	}
+	return out
+}
@@ -0,0 +1,376 @@
+package aster_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/astertest"
+)
+
+// receive waits for n values on ch
+func receive[T any](t *testing.T, ch <-chan T, n int) []T {
+	t.Helper()
+	var got []T
+	timeout := time.After(5 * time.Second)
+	for len(got) < n {
+		select {
+		case v := <-ch:
+			got = append(got, v)
+		case <-timeout:
+			t.Fatalf("got %d values, want %d", len(got), n)
+		}
+	}
+	return got
+}
+
+func sum(counts []int) int {
+	n := 0
+	for _, c := range counts {
+		n += c
+	}
+	return n
+}
+
+func TestShardedStreamClient(t *testing.T) {
+	srv := astertest.NewServer()
+	defer srv.Close()
+
+	c := aster.NewSpotShardedStreamClient(func(err error) { t.Error(err) })
+	c.MaxStreamsPerConnection = 2
+	c.SerializeHandlers = true
+	c.Options = []aster.StreamOption{aster.WithStreamEndpoint(srv.SpotWsURL()), aster.WithStreamReconnect(nil)}
+	defer c.Close()
+
+	var running, overlaps int32
+	tickers := make(chan string, 100)
+	handler := func(e *aster.WsBookTickerEvent) {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		tickers <- e.Symbol
+	}
+	symbols := []string{"AUSDT", "BUSDT", "CUSDT", "DUSDT", "EUSDT"}
+	ctx := context.Background()
+	if err := c.SubscribeBookTicker(ctx, handler, symbols...); err != nil {
+		t.Fatal(err)
+	}
+	if counts := c.Connections(); len(counts) != 3 || sum(counts) != 5 {
+		t.Fatalf("got connections %v, want 3 for 5 streams", counts)
+	}
+
+	publish := func(price string, symbols ...string) {
+		for _, symbol := range symbols {
+			srv.SetDepth(astertest.MarketSpot, symbol, [][]string{{price, "1"}}, [][]string{{"200", "1"}})
+		}
+	}
+	publish("100", symbols...)
+	got := receive(t, tickers, 5)
+	sort.Strings(got)
+	if strings.Join(got, ",") != strings.Join(symbols, ",") {
+		t.Errorf("got book tickers of %v", got)
+	}
+	if n := atomic.LoadInt32(&overlaps); n > 0 {
+		t.Errorf("handlers overlapped %d times with SerializeHandlers", n)
+	}
+
+	// the remaining streams fit in one connection
+	if err := c.Unsubscribe(ctx, "ausdt@bookTicker", "busdt@bookTicker", "cusdt@bookTicker"); err != nil {
+		t.Fatal(err)
+	}
+	if counts := c.Connections(); len(counts) != 1 || counts[0] != 2 {
+		t.Fatalf("got connections %v after unsubscribing, want [2]", counts)
+	}
+	publish("101", symbols...)
+	got = receive(t, tickers, 2)
+	sort.Strings(got)
+	if strings.Join(got, ",") != "DUSDT,EUSDT" {
+		t.Errorf("got book tickers of %v after merging", got)
+	}
+	select {
+	case symbol := <-tickers:
+		t.Errorf("got a book ticker of %s", symbol)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// subscriptionServer is a combined stream server whose SUBSCRIBE requests
+// fail for streams named fail@... and, once failAll is set, for every
+// stream. Failed streams are subscribed anyway, as if the response was lost.
+// UNSUBSCRIBE requests fail without effect once failUnsubscribe is set.
+type subscriptionServer struct {
+	url             string
+	failAll         atomic.Bool
+	failUnsubscribe atomic.Bool
+
+	mu      sync.Mutex
+	conns   map[*websocket.Conn]map[string]bool
+	writeMu map[*websocket.Conn]*sync.Mutex
+}
+
+func newSubscriptionServer(t *testing.T) *subscriptionServer {
+	s := &subscriptionServer{conns: map[*websocket.Conn]map[string]bool{}, writeMu: map[*websocket.Conn]*sync.Mutex{}}
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.mu.Lock()
+		s.conns[conn] = map[string]bool{}
+		s.writeMu[conn] = &sync.Mutex{}
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+		for {
+			var req struct {
+				Method string   `json:"method"`
+				Params []string `json:"params"`
+				ID     int64    `json:"id"`
+			}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			resp := map[string]interface{}{"result": nil, "id": req.ID}
+			s.mu.Lock()
+			for _, stream := range req.Params {
+				if req.Method == "UNSUBSCRIBE" && s.failUnsubscribe.Load() {
+					resp = map[string]interface{}{"code": 2, "msg": "Invalid request", "id": req.ID}
+					break
+				}
+				if req.Method == "UNSUBSCRIBE" {
+					delete(s.conns[conn], stream)
+					continue
+				}
+				s.conns[conn][stream] = true
+				if s.failAll.Load() || strings.HasPrefix(stream, "fail@") {
+					resp = map[string]interface{}{"code": 2, "msg": "Invalid request", "id": req.ID}
+				}
+			}
+			s.mu.Unlock()
+			if s.write(conn, resp) != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(func() {
+		srv.CloseClientConnections()
+		srv.Close()
+	})
+	s.url = "ws" + strings.TrimPrefix(srv.URL, "http")
+	return s
+}
+
+func (s *subscriptionServer) write(conn *websocket.Conn, v interface{}) error {
+	s.mu.Lock()
+	mu := s.writeMu[conn]
+	s.mu.Unlock()
+	mu.Lock()
+	defer mu.Unlock()
+	return conn.WriteJSON(v)
+}
+
+// publish sends an event of stream to the connections subscribed to it
+func (s *subscriptionServer) publish(stream string) {
+	s.publishData(stream, `{"s":"`+stream+`"}`)
+}
+
+// publishData sends the event data of stream to the connections subscribed
+// to it
+func (s *subscriptionServer) publishData(stream, data string) {
+	s.mu.Lock()
+	var conns []*websocket.Conn
+	for conn, streams := range s.conns {
+		if streams[stream] {
+			conns = append(conns, conn)
+		}
+	}
+	s.mu.Unlock()
+	for _, conn := range conns {
+		s.write(conn, map[string]interface{}{"stream": stream, "data": json.RawMessage(data)})
+	}
+}
+
+// subscribers returns the number of connections subscribed to each stream
+func (s *subscriptionServer) subscribers() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := map[string]int{}
+	for _, streams := range s.conns {
+		for stream := range streams {
+			n[stream]++
+		}
+	}
+	return n
+}
+
+func newTestShardedClient(t *testing.T, s *subscriptionServer) *aster.ShardedStreamClient {
+	c := aster.NewSpotShardedStreamClient(func(err error) {})
+	c.MaxStreamsPerConnection = 2
+	c.Options = []aster.StreamOption{aster.WithStreamEndpoint(s.url), aster.WithStreamReconnect(nil)}
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestShardedSubscribeRollsBack(t *testing.T) {
+	s := newSubscriptionServer(t)
+	c := newTestShardedClient(t, s)
+	ctx := context.Background()
+	first, second := make(chan string, 10), make(chan string, 10)
+	if err := c.Subscribe(ctx, func(data []byte) { first <- string(data) }, "a@depth"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a and b go to the first connection, fail@depth to a new one
+	err := c.Subscribe(ctx, func(data []byte) { second <- string(data) }, "a@depth", "b@depth", "fail@depth")
+	if err == nil || !strings.Contains(err.Error(), "fail@depth") {
+		t.Fatalf("got error %v, want the failed stream", err)
+	}
+	if streams := c.Streams(); len(streams) != 1 || streams[0] != "a@depth" {
+		t.Errorf("got streams %v after a failed Subscribe, want [a@depth]", streams)
+	}
+	if counts := c.Connections(); len(counts) != 1 || counts[0] != 1 {
+		t.Errorf("got connections %v, want [1]", counts)
+	}
+	if n := s.subscribers(); n["b@depth"] != 0 {
+		t.Errorf("b@depth still subscribed on the server: %v", n)
+	}
+	s.publish("a@depth")
+	receive(t, first, 1)
+	select {
+	case <-second:
+		t.Errorf("a@depth kept the handler of the failed Subscribe")
+	default:
+	}
+}
+
+func TestShardedMergeFailure(t *testing.T) {
+	s := newSubscriptionServer(t)
+	c := newTestShardedClient(t, s)
+	ctx := context.Background()
+	events := make(chan string, 10)
+	handler := func(data []byte) { events <- string(data) }
+	if err := c.Subscribe(ctx, handler, "a@depth", "b@depth"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Subscribe(ctx, handler, "c@depth"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the merge of the two connections fails
+	s.failAll.Store(true)
+	if err := c.Unsubscribe(ctx, "b@depth"); err == nil {
+		t.Fatal("Unsubscribe succeeded with a failed merge")
+	}
+	if counts := c.Connections(); len(counts) != 2 || sum(counts) != 2 {
+		t.Errorf("got connections %v, want [1 1]", counts)
+	}
+	n := s.subscribers()
+	if n["a@depth"] != 1 || n["c@depth"] != 1 || n["b@depth"] != 0 {
+		t.Errorf("got server subscriptions %v, want a and c on one connection each", n)
+	}
+	s.publish("a@depth")
+	s.publish("c@depth")
+	receive(t, events, 2)
+	select {
+	case e := <-events:
+		t.Errorf("got a duplicate event %s", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the merge succeeds once subscriptions work again
+	s.failAll.Store(false)
+	if err := c.Unsubscribe(ctx, "b@depth"); err != nil {
+		t.Fatal(err)
+	}
+	if counts := c.Connections(); len(counts) != 1 || counts[0] != 2 {
+		t.Errorf("got connections %v after merging, want [2]", counts)
+	}
+}
+
+func TestStreamClientUnsubscribeFailureKeepsHandlers(t *testing.T) {
+	s := newSubscriptionServer(t)
+	c := aster.NewSpotStreamClient(func(err error) {})
+	c.Options = []aster.StreamOption{aster.WithStreamEndpoint(s.url), aster.WithStreamReconnect(nil)}
+	done := c.Done()
+	if done == nil {
+		t.Fatal("Done returned nil before Connect")
+	}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	events := make(chan string, 10)
+	if err := c.Subscribe(ctx, func(data []byte) { events <- string(data) }, "a@depth"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.failUnsubscribe.Store(true)
+	if err := c.Unsubscribe(ctx, "a@depth"); err == nil {
+		t.Fatal("Unsubscribe succeeded with a failed request")
+	}
+	if streams := c.Streams(); len(streams) != 1 || streams[0] != "a@depth" {
+		t.Errorf("got streams %v after a failed Unsubscribe, want [a@depth]", streams)
+	}
+	s.publish("a@depth")
+	receive(t, events, 1)
+
+	s.failUnsubscribe.Store(false)
+	if err := c.Unsubscribe(ctx, "a@depth"); err != nil {
+		t.Fatal(err)
+	}
+	if streams := c.Streams(); len(streams) != 0 {
+		t.Errorf("got streams %v after Unsubscribe", streams)
+	}
+
+	c.Close()
+	select {
+	case <-done:
+	default:
+		t.Error("the channel returned by Done before Connect was not closed by Close")
+	}
+}
+
+func TestShardedUnsubscribeFailureKeepsStreams(t *testing.T) {
+	s := newSubscriptionServer(t)
+	c := newTestShardedClient(t, s)
+	ctx := context.Background()
+	events := make(chan string, 10)
+	if err := c.Subscribe(ctx, func(data []byte) { events <- string(data) }, "a@depth", "b@depth"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.failUnsubscribe.Store(true)
+	if err := c.Unsubscribe(ctx, "a@depth"); err == nil {
+		t.Fatal("Unsubscribe succeeded with a failed request")
+	}
+	if streams := c.Streams(); len(streams) != 2 {
+		t.Errorf("got streams %v after a failed Unsubscribe, want a and b", streams)
+	}
+	s.publish("a@depth")
+	receive(t, events, 1)
+
+	s.failUnsubscribe.Store(false)
+	if err := c.Unsubscribe(ctx, "a@depth"); err != nil {
+		t.Fatal(err)
+	}
+	if streams := c.Streams(); len(streams) != 1 || streams[0] != "b@depth" {
+		t.Errorf("got streams %v after Unsubscribe, want [b@depth]", streams)
+	}
+}
@@ -4,50 +4,51 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // Spot WebSocket services
 
 // WsSpotDepthServe serves websocket depth stream
-func WsSpotDepthServe(symbol string, handler WsDepthHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsSpotDepthServe(symbol string, handler WsDepthHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/%s@depth", getWsEndpoint(false, false), strings.ToLower(symbol))
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		event := new(WsDepthEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+	cfg := newMarketWsConfig(false, endpoint, opts...)
+	wsHandler := decodeWsEvent(&depthEvents, cfg, false, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
-// WsSpotPartialDepthServe serves websocket partial depth stream
-func WsSpotPartialDepthServe(symbol string, levels int, handler WsDepthHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	endpoint := fmt.Sprintf("%s/ws/%s@depth%d", getWsEndpoint(false, false), strings.ToLower(symbol), levels)
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		event := new(WsDepthEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
+// WsSpotPartialDepthServe serves websocket partial depth stream of 5, 10 or
+// 20 levels, updated every second
+func WsSpotPartialDepthServe(symbol string, levels int, handler WsSpotPartialDepthHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return WsSpotPartialDepthServeWithSpeed(symbol, levels, time.Second, handler, errHandler, opts...)
+}
+
+// WsSpotPartialDepthServeWithSpeed serves websocket partial depth stream of
+// 5, 10 or 20 levels, updated every 1000ms or 100ms
+func WsSpotPartialDepthServeWithSpeed(symbol string, levels int, speed time.Duration, handler WsSpotPartialDepthHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	if levels != 5 && levels != 10 && levels != 20 {
+		return nil, nil, fmt.Errorf("aster: invalid depth levels %d, must be 5, 10 or 20", levels)
 	}
-	return wsServe(cfg, wsHandler, errHandler)
+	stream := fmt.Sprintf("%s@depth%d", strings.ToLower(symbol), levels)
+	switch speed {
+	case time.Second:
+	case 100 * time.Millisecond:
+		stream += "@100ms"
+	default:
+		return nil, nil, fmt.Errorf("aster: invalid depth speed %s, must be 1s or 100ms", speed)
+	}
+	symbol = strings.ToUpper(symbol)
+	wsHandler := decodeStream(func(event *WsSpotPartialDepthEvent) {
+		event.Symbol = symbol
+		handler(event)
+	}, errHandler)
+	return wsSpotStreamServe(stream, wsHandler, errHandler, opts...)
 }
 
 // WsSpotKlineServe serves websocket kline stream
-func WsSpotKlineServe(symbol string, interval string, handler WsSpotKlineHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsSpotKlineServe(symbol string, interval string, handler WsSpotKlineHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/%s@kline_%s", getWsEndpoint(false, false), strings.ToLower(symbol), interval)
-	cfg := newWsConfig(endpoint)
+	cfg := newMarketWsConfig(false, endpoint, opts...)
 	wsHandler := func(message []byte) {
 		event := new(WsSpotKlineEvent)
 		err := json.Unmarshal(message, event)
@@ -63,63 +64,33 @@ func WsSpotKlineServe(symbol string, interval string, handler WsSpotKlineHandler
 }
 
 // WsSpotAggTradeServe serves websocket aggregate trade stream
-func WsSpotAggTradeServe(symbol string, handler WsSpotAggTradeHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsSpotAggTradeServe(symbol string, handler WsSpotAggTradeHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/%s@aggTrade", getWsEndpoint(false, false), strings.ToLower(symbol))
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		event := new(WsSpotAggTradeEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+	cfg := newMarketWsConfig(false, endpoint, opts...)
+	wsHandler := decodeWsEvent(&spotAggTradeEvents, cfg, false, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // WsSpotBookTickerServe serves websocket book ticker stream
-func WsSpotBookTickerServe(symbol string, handler WsBookTickerHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsSpotBookTickerServe(symbol string, handler WsBookTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/%s@bookTicker", getWsEndpoint(false, false), strings.ToLower(symbol))
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		event := new(WsBookTickerEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+	cfg := newMarketWsConfig(false, endpoint, opts...)
+	wsHandler := decodeWsEvent(&bookTickerEvents, cfg, false, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // WsSpotAllBookTickerServe serves websocket all book tickers stream
-func WsSpotAllBookTickerServe(handler WsBookTickerHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsSpotAllBookTickerServe(handler WsBookTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/!bookTicker", getWsEndpoint(false, false))
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		event := new(WsBookTickerEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+	cfg := newMarketWsConfig(false, endpoint, opts...)
+	wsHandler := decodeWsEvent(&bookTickerEvents, cfg, false, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // WsSpotAllMarketsStatServe serves websocket 24hr statistics stream for all markets
-func WsSpotAllMarketsStatServe(handler WsSpotAllMarketsStatHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsSpotAllMarketsStatServe(handler WsSpotAllMarketsStatHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/!ticker@arr", getWsEndpoint(false, false))
-	cfg := newWsConfig(endpoint)
+	cfg := newMarketWsConfig(false, endpoint, opts...)
 	wsHandler := func(message []byte) {
 		var event WsSpotAllMarketsStatEvent
 		err := json.Unmarshal(message, &event)
@@ -135,312 +106,135 @@ func WsSpotAllMarketsStatServe(handler WsSpotAllMarketsStatHandler, errHandler E
 }
 
 // WsSpotUserDataServe serves websocket user data stream
-func WsSpotUserDataServe(listenKey string, handler WsSpotUserDataHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsSpotUserDataServe(listenKey string, handler WsSpotUserDataHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	endpoint := fmt.Sprintf("%s/ws/%s", getWsEndpoint(false, false), listenKey)
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		// First check the event type
-		var eventType struct {
-			Event string `json:"e"`
-		}
-		err := json.Unmarshal(message, &eventType)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-
-		event := new(WsSpotUserDataEvent)
-		event.Event = eventType.Event
-		
-		switch eventType.Event {
-		case "outboundAccountPosition":
-			var accountUpdate struct {
-				Event         string          `json:"e"`
-				Time          int64           `json:"E"`
-				AccountUpdate WsSpotAccountUpdate `json:"u"`
-			}
-			err = json.Unmarshal(message, &accountUpdate)
-			if err == nil {
-				event.Time = accountUpdate.Time
-				event.AccountUpdate = &accountUpdate.AccountUpdate
-			}
-		case "executionReport":
-			var orderUpdate WsSpotOrderUpdate
-			err = json.Unmarshal(message, &orderUpdate)
-			if err == nil {
-				event.OrderUpdate = &orderUpdate
-			}
-		}
-
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
+	cfg := newMarketWsConfig(false, endpoint, opts...)
+	cfg.userData = true
+	return wsServe(cfg, spotUserDataHandler(handler, errHandler), errHandler)
+}
 
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+// spotUserDataHandler decodes spot user data events
+func spotUserDataHandler(handler WsSpotUserDataHandler, errHandler ErrHandler) WsHandler {
+	return decodeStream(handler, errHandler)
 }
 
 // Combined streams
 
 // WsCombinedSpotDepthServe serves websocket combined depth stream
-func WsCombinedSpotDepthServe(symbols []string, handler WsDepthHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsCombinedSpotDepthServe(symbols []string, handler WsDepthHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	var streams []string
 	for _, s := range symbols {
 		streams = append(streams, fmt.Sprintf("%s@depth", strings.ToLower(s)))
 	}
-	endpoint := fmt.Sprintf("%s?streams=%s", combinedBaseURL, strings.Join(streams, "/"))
-	return wsCombinedSpotDepthServe(endpoint, handler, errHandler)
+	endpoint := fmt.Sprintf("%s?streams=%s", getCombinedEndpoint(false), strings.Join(streams, "/"))
+	return wsCombinedSpotDepthServe(endpoint, handler, errHandler, opts...)
 }
 
 // WsCombinedSpotBookTickerServe serves websocket combined book ticker stream
-func WsCombinedSpotBookTickerServe(symbols []string, handler WsBookTickerHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
+func WsCombinedSpotBookTickerServe(symbols []string, handler WsBookTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
 	var streams []string
 	for _, s := range symbols {
 		streams = append(streams, fmt.Sprintf("%s@bookTicker", strings.ToLower(s)))
 	}
-	endpoint := fmt.Sprintf("%s?streams=%s", combinedBaseURL, strings.Join(streams, "/"))
-	return wsCombinedSpotBookTickerServe(endpoint, handler, errHandler)
+	endpoint := fmt.Sprintf("%s?streams=%s", getCombinedEndpoint(false), strings.Join(streams, "/"))
+	return wsCombinedSpotBookTickerServe(endpoint, handler, errHandler, opts...)
 }
 
 // Internal function for combined depth
-func wsCombinedSpotDepthServe(endpoint string, handler WsDepthHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		var combinedEvent struct {
-			Stream string          `json:"stream"`
-			Data   json.RawMessage `json:"data"`
-		}
-		err := json.Unmarshal(message, &combinedEvent)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-
-		event := new(WsDepthEvent)
-		err = json.Unmarshal(combinedEvent.Data, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+func wsCombinedSpotDepthServe(endpoint string, handler WsDepthHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	cfg := newMarketWsConfig(false, endpoint, opts...)
+	wsHandler := decodeWsEvent(&depthEvents, cfg, true, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // Internal function for combined book ticker
-func wsCombinedSpotBookTickerServe(endpoint string, handler WsBookTickerHandler, errHandler ErrHandler) (doneC, stopC chan struct{}, err error) {
-	cfg := newWsConfig(endpoint)
-	wsHandler := func(message []byte) {
-		var combinedEvent struct {
-			Stream string          `json:"stream"`
-			Data   json.RawMessage `json:"data"`
-		}
-		err := json.Unmarshal(message, &combinedEvent)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-
-		event := new(WsBookTickerEvent)
-		err = json.Unmarshal(combinedEvent.Data, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
+func wsCombinedSpotBookTickerServe(endpoint string, handler WsBookTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	cfg := newMarketWsConfig(false, endpoint, opts...)
+	wsHandler := decodeWsEvent(&bookTickerEvents, cfg, true, handler, errHandler)
 	return wsServe(cfg, wsHandler, errHandler)
 }
 
 // WebSocket functions with LocalAddress support
 
 // WsSpotDepthServeWithLocalAddr serves websocket depth stream with local address binding
+//
+// Deprecated: use WsSpotDepthServe with WithStreamLocalAddress.
 func WsSpotDepthServeWithLocalAddr(symbol string, handler WsDepthHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	endpoint := fmt.Sprintf("%s/ws/%s@depth", getWsEndpoint(false, false), strings.ToLower(symbol))
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
-	}
-	wsHandler := func(message []byte) {
-		event := new(WsDepthEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+	return WsSpotDepthServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
 // WsSpotKlineServeWithLocalAddr serves websocket kline stream with local address binding
+//
+// Deprecated: use WsSpotKlineServe with WithStreamLocalAddress.
 func WsSpotKlineServeWithLocalAddr(symbol string, interval string, handler WsSpotKlineHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	endpoint := fmt.Sprintf("%s/ws/%s@kline_%s", getWsEndpoint(false, false), strings.ToLower(symbol), interval)
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
-	}
-	wsHandler := func(message []byte) {
-		event := new(WsSpotKlineEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+	return WsSpotKlineServe(symbol, interval, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
 // WsSpotAggTradeServeWithLocalAddr serves websocket aggregate trade stream with local address binding
+//
+// Deprecated: use WsSpotAggTradeServe with WithStreamLocalAddress.
 func WsSpotAggTradeServeWithLocalAddr(symbol string, handler WsSpotAggTradeHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	endpoint := fmt.Sprintf("%s/ws/%s@aggTrade", getWsEndpoint(false, false), strings.ToLower(symbol))
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
-	}
-	wsHandler := func(message []byte) {
-		event := new(WsSpotAggTradeEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+	return WsSpotAggTradeServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
 // WsSpotBookTickerServeWithLocalAddr serves websocket book ticker stream with local address binding
+//
+// Deprecated: use WsSpotBookTickerServe with WithStreamLocalAddress.
 func WsSpotBookTickerServeWithLocalAddr(symbol string, handler WsBookTickerHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	endpoint := fmt.Sprintf("%s/ws/%s@bookTicker", getWsEndpoint(false, false), strings.ToLower(symbol))
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
-	}
-	wsHandler := func(message []byte) {
-		event := new(WsBookTickerEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+	return WsSpotBookTickerServe(symbol, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
 // WsSpotAllMarketsStatServeWithLocalAddr serves websocket all markets statistics stream with local address binding
+//
+// Deprecated: use WsSpotAllMarketsStatServe with WithStreamLocalAddress.
 func WsSpotAllMarketsStatServeWithLocalAddr(handler WsSpotAllMarketsStatHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	endpoint := fmt.Sprintf("%s/ws/!ticker@arr", getWsEndpoint(false, false))
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
-	}
-	wsHandler := func(message []byte) {
-		var event WsSpotAllMarketsStatEvent
-		err := json.Unmarshal(message, &event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+	return WsSpotAllMarketsStatServe(handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
 // WsSpotUserDataServeWithLocalAddr serves websocket user data stream with local address binding
+//
+// Deprecated: use WsSpotUserDataServe with WithStreamLocalAddress.
 func WsSpotUserDataServeWithLocalAddr(listenKey string, handler WsSpotUserDataHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	endpoint := fmt.Sprintf("%s/ws/%s", getWsEndpoint(false, false), listenKey)
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
-	}
-	wsHandler := func(message []byte) {
-		event := new(WsSpotUserDataEvent)
-		err := json.Unmarshal(message, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
+	return WsSpotUserDataServe(listenKey, handler, errHandler, WithStreamLocalAddress(localAddr))
 }
 
 // WsCombinedSpotBookTickerServeWithLocalAddr serves websocket combined book ticker stream with local address binding
+//
+// Deprecated: use WsCombinedSpotBookTickerServe with WithStreamLocalAddress.
 func WsCombinedSpotBookTickerServeWithLocalAddr(symbols []string, handler WsBookTickerHandler, errHandler ErrHandler, localAddr string) (doneC, stopC chan struct{}, err error) {
-	var streams []string
-	for _, s := range symbols {
-		streams = append(streams, fmt.Sprintf("%s@bookTicker", strings.ToLower(s)))
-	}
-	endpoint := fmt.Sprintf("%s?streams=%s", combinedBaseURL, strings.Join(streams, "/"))
-	var cfg *WsConfig
-	if localAddr != "" {
-		cfg = newWsConfigWithIP(endpoint, localAddr)
-	} else {
-		cfg = newWsConfig(endpoint)
-	}
-	wsHandler := func(message []byte) {
-		var combinedEvent struct {
-			Stream string          `json:"stream"`
-			Data   json.RawMessage `json:"data"`
-		}
-		err := json.Unmarshal(message, &combinedEvent)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
+	return WsCombinedSpotBookTickerServe(symbols, handler, errHandler, WithStreamLocalAddress(localAddr))
+}
 
-		event := new(WsBookTickerEvent)
-		err = json.Unmarshal(combinedEvent.Data, event)
-		if err != nil {
-			if errHandler != nil {
-				errHandler(err)
-			}
-			return
-		}
-		handler(event)
-	}
-	return wsServe(cfg, wsHandler, errHandler)
-}
\ No newline at end of file
+// Spot market streams
+
+// wsSpotStreamServe serves a raw spot stream with the options
+func wsSpotStreamServe(stream string, handler WsHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	endpoint := fmt.Sprintf("%s/ws/%s", getWsEndpoint(false, false), stream)
+	cfg := newMarketWsConfig(false, endpoint, opts...)
+	return wsServe(cfg, handler, errHandler)
+}
+
+// WsSpotTradeServe serves websocket raw trade stream of a symbol
+func WsSpotTradeServe(symbol string, handler WsSpotTradeHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	stream := fmt.Sprintf("%s@trade", strings.ToLower(symbol))
+	return wsSpotStreamServe(stream, decodeStream(handler, errHandler), errHandler, opts...)
+}
+
+// WsSpotMiniMarketTickerServe serves websocket 24h mini ticker stream of a
+// symbol
+func WsSpotMiniMarketTickerServe(symbol string, handler WsSpotMiniMarketTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	stream := fmt.Sprintf("%s@miniTicker", strings.ToLower(symbol))
+	return wsSpotStreamServe(stream, decodeStream(handler, errHandler), errHandler, opts...)
+}
+
+// WsSpotAllMiniMarketTickerServe serves websocket 24h mini ticker stream of
+// all symbols
+func WsSpotAllMiniMarketTickerServe(handler WsSpotAllMiniMarketTickerHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	return wsSpotStreamServe("!miniTicker@arr", decodeStreamValue(handler, errHandler), errHandler, opts...)
+}
+
+// WsSpotMarketStatServe serves websocket 24hr statistics stream of a symbol
+func WsSpotMarketStatServe(symbol string, handler WsSpotMarketStatHandler, errHandler ErrHandler, opts ...StreamOption) (doneC, stopC chan struct{}, err error) {
+	stream := fmt.Sprintf("%s@ticker", strings.ToLower(symbol))
+	return wsSpotStreamServe(stream, decodeStream(handler, errHandler), errHandler, opts...)
+}
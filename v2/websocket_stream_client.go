@@ -0,0 +1,595 @@
+package aster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// ErrStreamClientClosed is returned by requests on a closed StreamClient
+var ErrStreamClientClosed = errors.New("stream client closed")
+
+// StreamClient multiplexes streams over one combined stream connection and
+// changes its subscriptions live with SUBSCRIBE and UNSUBSCRIBE requests.
+// Set the exported fields before calling Connect.
+type StreamClient struct {
+	// MaxMessagesPerSecond caps the requests sent on the connection,
+	// 5 for spot and 10 for futures by default
+	MaxMessagesPerSecond int
+	// RequestTimeout bounds requests whose context has no deadline,
+	// 10s by default
+	RequestTimeout time.Duration
+	// LocalAddress binds the connection to a local IP address
+	LocalAddress string
+	// Endpoint overrides the websocket base URL of the market, e.g. an
+	// intranet endpoint
+	Endpoint string
+	// Options configure the connection, e.g. WithStreamProxy
+	Options []StreamOption
+
+	streamSubscriptions
+
+	writeMu  sync.Mutex
+	lastSend time.Time
+
+	mu       sync.Mutex
+	conn     *websocket.Conn
+	handlers map[string]streamHandler
+	pending  map[int64]chan *wsStreamResponse
+	nextID   int64
+	stopC    chan struct{}
+	doneC    chan struct{}
+}
+
+// wsStreamRequest is a subscription management request
+type wsStreamRequest struct {
+	Method string   `json:"method"`
+	Params []string `json:"params,omitempty"`
+	ID     int64    `json:"id"`
+}
+
+// streamHandler handles the data of a subscribed stream. decode, when set,
+// decodes the data in place from the iterator of its frame, otherwise raw
+// gets a copy of its bytes.
+type streamHandler struct {
+	raw    WsHandler
+	decode func(iter *jsoniter.Iterator, size int)
+}
+
+// wsStreamResponse is the response to a request, or the error ending it
+type wsStreamResponse struct {
+	result json.RawMessage
+	err    error
+}
+
+// NewSpotStreamClient creates a stream client for spot streams
+func NewSpotStreamClient(errHandler ErrHandler) *StreamClient {
+	return newStreamClient(false, errHandler)
+}
+
+// NewFuturesStreamClient creates a stream client for futures streams
+func NewFuturesStreamClient(errHandler ErrHandler) *StreamClient {
+	return newStreamClient(true, errHandler)
+}
+
+func newStreamClient(isFutures bool, errHandler ErrHandler) *StreamClient {
+	c := &StreamClient{
+		handlers: map[string]streamHandler{},
+		pending:  map[int64]chan *wsStreamResponse{},
+		doneC:    make(chan struct{}),
+	}
+	c.streamSubscriptions = streamSubscriptions{subscribe: c.subscribe, isFutures: isFutures, errHandler: errHandler}
+	return c
+}
+
+// Connect opens the connection. With WsReconnect set, the client
+// reconnects and resubscribes its streams until Close.
+func (c *StreamClient) Connect() error {
+	endpoint := getCombinedEndpoint(c.isFutures)
+	if c.Endpoint != "" {
+		endpoint = c.Endpoint + "/stream"
+	}
+	cfg := newWsConfigWithIP(endpoint, c.LocalAddress, c.Options...)
+	cfg.market = marketName(c.isFutures)
+	conn, err := wsDial(cfg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.conn = conn
+	c.stopC = make(chan struct{})
+	if isClosed(c.doneC) {
+		c.doneC = make(chan struct{})
+	}
+	doneC := c.doneC
+	c.mu.Unlock()
+
+	s := &wsStream{
+		cfg:        cfg,
+		handler:    c.route,
+		errHandler: c.errHandler,
+		stopC:      c.stopC,
+		onConnect:  c.resubscribe,
+	}
+	go func() {
+		defer close(doneC)
+		s.run(conn)
+		c.failPending(ErrStreamClientClosed)
+	}()
+	return nil
+}
+
+// Close closes the connection and waits for the client to stop
+func (c *StreamClient) Close() {
+	c.mu.Lock()
+	stopC, doneC := c.stopC, c.doneC
+	c.mu.Unlock()
+	if stopC == nil {
+		return
+	}
+	select {
+	case <-stopC:
+	default:
+		close(stopC)
+	}
+	<-doneC
+}
+
+// Done returns a channel closed once the client stops, after Close or when
+// the connection is lost for good. Before Connect it returns the channel of
+// the first connection, and after a stop the one of the next Connect.
+func (c *StreamClient) Done() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.doneC
+}
+
+// Subscribe subscribes to streams, e.g. "btcusdt@depth", and routes their
+// raw event data to handler. Streams already subscribed only get the new
+// handler.
+func (c *StreamClient) Subscribe(ctx context.Context, handler WsHandler, streams ...string) error {
+	return c.subscribe(ctx, streamHandler{raw: handler}, streams...)
+}
+
+// subscribe subscribes to streams routed to handler
+func (c *StreamClient) subscribe(ctx context.Context, handler streamHandler, streams ...string) error {
+	return c.subscribeEach(ctx, func(string) streamHandler { return handler }, streams...)
+}
+
+// subscribeEach subscribes to streams with one request, routing each stream
+// to the handler returned for it
+func (c *StreamClient) subscribeEach(ctx context.Context, handlerOf func(stream string) streamHandler, streams ...string) error {
+	var added []string
+	c.mu.Lock()
+	for _, stream := range streams {
+		if _, ok := c.handlers[stream]; !ok {
+			added = append(added, stream)
+		}
+		c.handlers[stream] = handlerOf(stream)
+	}
+	c.mu.Unlock()
+	if len(added) == 0 {
+		return nil
+	}
+	if _, err := c.call(ctx, "SUBSCRIBE", added); err != nil {
+		c.mu.Lock()
+		for _, stream := range added {
+			delete(c.handlers, stream)
+		}
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Unsubscribe unsubscribes from streams. Their handlers are kept when the
+// request fails.
+func (c *StreamClient) Unsubscribe(ctx context.Context, streams ...string) error {
+	if _, err := c.call(ctx, "UNSUBSCRIBE", streams); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	for _, stream := range streams {
+		delete(c.handlers, stream)
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// ListSubscriptions returns the streams subscribed on the server
+func (c *StreamClient) ListSubscriptions(ctx context.Context) ([]string, error) {
+	result, err := c.call(ctx, "LIST_SUBSCRIPTIONS", nil)
+	if err != nil {
+		return nil, err
+	}
+	var streams []string
+	if err := json.Unmarshal(result, &streams); err != nil {
+		return nil, err
+	}
+	return streams, nil
+}
+
+// Streams returns the streams the client routes, in no particular order
+func (c *StreamClient) Streams() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	streams := make([]string, 0, len(c.handlers))
+	for stream := range c.handlers {
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+// call sends a request and waits for its response
+func (c *StreamClient) call(ctx context.Context, method string, params []string) (json.RawMessage, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := c.RequestTimeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	c.mu.Lock()
+	if c.conn == nil || isClosed(c.doneC) {
+		c.mu.Unlock()
+		return nil, ErrStreamClientClosed
+	}
+	c.nextID++
+	id := c.nextID
+	respC := make(chan *wsStreamResponse, 1)
+	c.pending[id] = respC
+	conn := c.conn
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(ctx, conn, &wsStreamRequest{Method: method, Params: params, ID: id}); err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-respC:
+		return resp.result, resp.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// send writes a request, waiting as needed to respect the message rate
+// limit of the connection
+func (c *StreamClient) send(ctx context.Context, conn *websocket.Conn, req *wsStreamRequest) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	rate := c.MaxMessagesPerSecond
+	if rate <= 0 {
+		rate = 5
+		if c.isFutures {
+			rate = 10
+		}
+	}
+	if wait := time.Until(c.lastSend.Add(time.Second / time.Duration(rate))); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	c.lastSend = time.Now()
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// resubscribe switches to a new connection and subscribes it to every
+// routed stream
+func (c *StreamClient) resubscribe(conn *websocket.Conn) {
+	c.mu.Lock()
+	c.conn = conn
+	streams := make([]string, 0, len(c.handlers))
+	for stream := range c.handlers {
+		streams = append(streams, stream)
+	}
+	c.nextID++
+	id := c.nextID
+	c.mu.Unlock()
+	if len(streams) == 0 {
+		return
+	}
+	// The response has no waiter and is dropped by route
+	err := c.send(context.Background(), conn, &wsStreamRequest{Method: "SUBSCRIBE", Params: streams, ID: id})
+	if err != nil && c.errHandler != nil {
+		c.errHandler(err)
+	}
+}
+
+// route dispatches a frame to the handler of its stream or the waiter of
+// its request. Stream data is decoded in place when its handler can.
+func (c *StreamClient) route(message []byte) {
+	iter := JSON.BorrowIterator(message)
+	defer JSON.ReturnIterator(iter)
+	var (
+		stream  string
+		data    []byte
+		decoded bool
+		id      *int64
+		result  json.RawMessage
+		failed  bool
+		code    int
+		msg     string
+	)
+	iter.ReadObjectCB(func(iter *jsoniter.Iterator, field string) bool {
+		switch field {
+		case "stream":
+			stream = iter.ReadString()
+		case "data":
+			if handler := c.handler(stream); handler.decode != nil {
+				handler.decode(iter, len(message))
+				decoded = true
+			} else {
+				data = iter.SkipAndReturnBytes()
+			}
+		case "id":
+			if !iter.ReadNil() {
+				v := iter.ReadInt64()
+				id = &v
+			}
+		case "result":
+			result = iter.SkipAndReturnBytes()
+		case "code":
+			code = iter.ReadInt()
+		case "msg":
+			failed = true
+			msg = iter.ReadString()
+		case "error":
+			if iter.ReadNil() {
+				break
+			}
+			failed = true
+			iter.ReadObjectCB(func(iter *jsoniter.Iterator, field string) bool {
+				switch field {
+				case "code":
+					code = iter.ReadInt()
+				case "msg":
+					msg = iter.ReadString()
+				default:
+					iter.Skip()
+				}
+				return iter.Error == nil
+			})
+		default:
+			iter.Skip()
+		}
+		return iter.Error == nil
+	})
+	if err := decodeError(iter); err != nil {
+		if c.errHandler != nil {
+			c.errHandler(err)
+		}
+		return
+	}
+	if stream != "" {
+		// data seen before the stream name is handled raw
+		if !decoded && data != nil {
+			if handler := c.handler(stream); handler.raw != nil {
+				handler.raw(data)
+			}
+		}
+		return
+	}
+	if id == nil {
+		return
+	}
+	// errors come as an error object, or a code and msg next to the id
+	resp := &wsStreamResponse{result: result}
+	if failed || code != 0 {
+		resp.err = &common.APIError{Code: code, Message: msg}
+	}
+	c.mu.Lock()
+	respC := c.pending[*id]
+	c.mu.Unlock()
+	if respC != nil {
+		select {
+		case respC <- resp:
+		default:
+		}
+	}
+}
+
+// handler returns the handler of a stream
+func (c *StreamClient) handler(stream string) streamHandler {
+	if stream == "" {
+		return streamHandler{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.handlers[stream]
+}
+
+// failPending ends the pending requests with err
+func (c *StreamClient) failPending(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, respC := range c.pending {
+		select {
+		case respC <- &wsStreamResponse{err: err}:
+		default:
+		}
+		delete(c.pending, id)
+	}
+}
+
+// isClosed reports whether ch is closed
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// decodeStream returns a raw handler decoding events into new(T) values,
+// with the hand-written decoder of T when it has one
+func decodeStream[T any](handler func(*T), errHandler ErrHandler) WsHandler {
+	return func(data []byte) {
+		event := new(T)
+		var err error
+		if d, ok := any(event).(wsDecoder); ok {
+			err = decodeWs(data, d)
+		} else {
+			err = json.Unmarshal(data, event)
+		}
+		if err != nil {
+			if errHandler != nil {
+				errHandler(err)
+			}
+			return
+		}
+		handler(event)
+	}
+}
+
+// typedStream returns the handler of a stream of T events, decoded in place
+// from combined frames
+func typedStream[T any](handler func(*T), errHandler ErrHandler) streamHandler {
+	return streamHandler{
+		raw: decodeStream(handler, errHandler),
+		decode: func(iter *jsoniter.Iterator, size int) {
+			event := new(T)
+			if d, ok := any(event).(wsDecoder); ok {
+				d.decodeJSON(iter, make([]byte, 0, size))
+			} else {
+				iter.ReadVal(event)
+			}
+			if iter.Error == nil {
+				handler(event)
+			}
+		},
+	}
+}
+
+// decodeStreamValue returns a WsHandler decoding messages into a T passed
+// by value, e.g. the slice of an array stream
+func decodeStreamValue[T any](handler func(T), errHandler ErrHandler) WsHandler {
+	return func(data []byte) {
+		var event T
+		if err := json.Unmarshal(data, &event); err != nil {
+			if errHandler != nil {
+				errHandler(err)
+			}
+			return
+		}
+		handler(event)
+	}
+}
+
+// symbolStreams returns the stream names of symbols with a suffix
+func symbolStreams(suffix string, symbols []string) []string {
+	streams := make([]string, 0, len(symbols))
+	for _, s := range symbols {
+		streams = append(streams, fmt.Sprintf("%s@%s", strings.ToLower(s), suffix))
+	}
+	return streams
+}
+
+// streamSubscriptions implements the typed Subscribe methods on top of the
+// raw Subscribe of a client
+type streamSubscriptions struct {
+	subscribe  func(ctx context.Context, handler streamHandler, streams ...string) error
+	isFutures  bool
+	errHandler ErrHandler
+}
+
+// SubscribeDepth subscribes to the diff depth streams of symbols
+func (c *streamSubscriptions) SubscribeDepth(ctx context.Context, handler WsDepthHandler, symbols ...string) error {
+	return c.subscribe(ctx, typedStream(handler, c.errHandler), symbolStreams("depth", symbols)...)
+}
+
+// SubscribePartialDepth subscribes to the futures partial depth streams of
+// symbols. Spot partial depth has its own payload, see
+// SubscribeSpotPartialDepth.
+func (c *streamSubscriptions) SubscribePartialDepth(ctx context.Context, levels int, handler WsDepthHandler, symbols ...string) error {
+	suffix := fmt.Sprintf("depth%d", levels)
+	if c.isFutures {
+		suffix += "@100ms"
+	}
+	return c.subscribe(ctx, typedStream(handler, c.errHandler), symbolStreams(suffix, symbols)...)
+}
+
+// SubscribeSpotPartialDepth subscribes to the spot partial depth streams of
+// symbols, updated every second
+func (c *streamSubscriptions) SubscribeSpotPartialDepth(ctx context.Context, levels int, handler WsSpotPartialDepthHandler, symbols ...string) error {
+	for _, symbol := range symbols {
+		symbol := strings.ToUpper(symbol)
+		wsHandler := typedStream(func(event *WsSpotPartialDepthEvent) {
+			event.Symbol = symbol
+			handler(event)
+		}, c.errHandler)
+		if err := c.subscribe(ctx, wsHandler, symbolStreams(fmt.Sprintf("depth%d", levels), []string{symbol})...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubscribeBookTicker subscribes to the book ticker streams of symbols
+func (c *streamSubscriptions) SubscribeBookTicker(ctx context.Context, handler WsBookTickerHandler, symbols ...string) error {
+	return c.subscribe(ctx, typedStream(handler, c.errHandler), symbolStreams("bookTicker", symbols)...)
+}
+
+// SubscribeSpotAggTrade subscribes to the spot aggregate trade streams of
+// symbols
+func (c *streamSubscriptions) SubscribeSpotAggTrade(ctx context.Context, handler WsSpotAggTradeHandler, symbols ...string) error {
+	return c.subscribe(ctx, typedStream(handler, c.errHandler), symbolStreams("aggTrade", symbols)...)
+}
+
+// SubscribeFuturesAggTrade subscribes to the futures aggregate trade
+// streams of symbols
+func (c *streamSubscriptions) SubscribeFuturesAggTrade(ctx context.Context, handler WsFuturesAggTradeHandler, symbols ...string) error {
+	return c.subscribe(ctx, typedStream(handler, c.errHandler), symbolStreams("aggTrade", symbols)...)
+}
+
+// SubscribeSpotKline subscribes to the spot kline streams of symbols
+func (c *streamSubscriptions) SubscribeSpotKline(ctx context.Context, interval string, handler WsSpotKlineHandler, symbols ...string) error {
+	return c.subscribe(ctx, typedStream(handler, c.errHandler), symbolStreams("kline_"+interval, symbols)...)
+}
+
+// SubscribeFuturesKline subscribes to the futures kline streams of symbols
+func (c *streamSubscriptions) SubscribeFuturesKline(ctx context.Context, interval string, handler WsFuturesKlineHandler, symbols ...string) error {
+	return c.subscribe(ctx, typedStream(handler, c.errHandler), symbolStreams("kline_"+interval, symbols)...)
+}
+
+// SubscribeMarkPrice subscribes to the futures mark price streams of symbols
+func (c *streamSubscriptions) SubscribeMarkPrice(ctx context.Context, handler WsFuturesMarkPriceHandler, symbols ...string) error {
+	return c.subscribe(ctx, typedStream(handler, c.errHandler), symbolStreams("markPrice", symbols)...)
+}
+
+// SubscribeSpotTicker subscribes to the spot 24hr ticker streams of symbols
+func (c *streamSubscriptions) SubscribeSpotTicker(ctx context.Context, handler WsSpotMarketStatHandler, symbols ...string) error {
+	return c.subscribe(ctx, typedStream(handler, c.errHandler), symbolStreams("ticker", symbols)...)
+}
+
+// SubscribeFuturesTicker subscribes to the futures 24hr ticker streams of
+// symbols
+func (c *streamSubscriptions) SubscribeFuturesTicker(ctx context.Context, handler WsFuturesMarketTickerHandler, symbols ...string) error {
+	return c.subscribe(ctx, typedStream(handler, c.errHandler), symbolStreams("ticker", symbols)...)
+}
@@ -0,0 +1,65 @@
+package aster
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+func TestStreamClientRoute(t *testing.T) {
+	c := newStreamClient(true, func(err error) { t.Error(err) })
+	var decoded []*WsBookTickerEvent
+	var raw [][]byte
+	c.handlers["btcusdt@bookTicker"] = typedStream(func(e *WsBookTickerEvent) { decoded = append(decoded, e) }, nil)
+	c.handlers["btcusdt@raw"] = streamHandler{raw: func(data []byte) { raw = append(raw, data) }}
+	respC := make(chan *wsStreamResponse, 1)
+	c.pending[3] = respC
+
+	c.route(append(append([]byte(`{"stream":"btcusdt@bookTicker","data":`), bookTickerMessage...), '}'))
+	c.route(append(append([]byte(`{"data":`), bookTickerMessage...), []byte(`,"stream":"btcusdt@bookTicker"}`)...))
+	c.route([]byte(`{"stream":"btcusdt@raw","data":{"a":[1,2]}}`))
+	c.route([]byte(`{"stream":"ethusdt@unknown","data":{"a":1}}`))
+	c.route([]byte(`{"id":3,"code":2,"msg":"Invalid request"}`))
+
+	if len(decoded) != 2 || decoded[0].Symbol != "BTCUSDT" || *decoded[0] != *decoded[1] {
+		t.Errorf("decoded %v", decoded)
+	}
+	if len(raw) != 1 || string(raw[0]) != `{"a":[1,2]}` {
+		t.Errorf("raw %q", raw)
+	}
+	resp := <-respC
+	var apiErr *common.APIError
+	if !errors.As(resp.err, &apiErr) || apiErr.Code != 2 || apiErr.Message != "Invalid request" {
+		t.Errorf("response error %v", resp.err)
+	}
+}
+
+func TestStreamClientRouteResponses(t *testing.T) {
+	tests := []struct {
+		frame string
+		err   *common.APIError
+	}{
+		{`{"result":null,"id":1}`, nil},
+		{`{"result":["btcusdt@depth"],"id":1}`, nil},
+		{`{"error":{"code":2,"msg":"Invalid request: unknown method"},"id":1}`, &common.APIError{Code: 2, Message: "Invalid request: unknown method"}},
+		{`{"id":1,"error":{"msg":"Invalid request"}}`, &common.APIError{Message: "Invalid request"}},
+		{`{"code":-1121,"msg":"Invalid symbol.","id":1}`, &common.APIError{Code: -1121, Message: "Invalid symbol."}},
+		{`{"msg":"Too many requests","id":1}`, &common.APIError{Message: "Too many requests"}},
+		{`{"error":null,"result":null,"id":1}`, nil},
+	}
+	for _, test := range tests {
+		c := newStreamClient(false, func(err error) { t.Error(err) })
+		respC := make(chan *wsStreamResponse, 1)
+		c.pending[1] = respC
+		c.route([]byte(test.frame))
+		resp := <-respC
+		var apiErr *common.APIError
+		switch {
+		case test.err == nil && resp.err != nil:
+			t.Errorf("%s: got error %v", test.frame, resp.err)
+		case test.err != nil && (!errors.As(resp.err, &apiErr) || *apiErr != *test.err):
+			t.Errorf("%s: got error %v, want %v", test.frame, resp.err, test.err)
+		}
+	}
+}
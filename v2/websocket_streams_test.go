@@ -0,0 +1,241 @@
+package aster
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// streamServe starts a stream sending its decoded events to got
+type streamServe func(got chan<- any, errHandler ErrHandler) (doneC, stopC chan struct{}, err error)
+
+// TestStreamFixtures serves each fixture in testdata/streams as the only
+// frame of a connection and checks the requested stream and the decoded
+// events
+func TestStreamFixtures(t *testing.T) {
+	opt := WithStreamReconnect(nil)
+	futuresTicker := &WsFuturesMarketTickerEvent{
+		Event: "24hrTicker", Time: 1700000000123, Symbol: "BTCUSDT",
+		PriceChange: "1000.10", PriceChangePercent: "2.439", WeightedAvgPrice: "41500.00",
+		ClosePrice: "42000.10", CloseQty: "0.010", OpenPrice: "41000.00", HighPrice: "42100.00", LowPrice: "40900.00",
+		BaseVolume: "10000.5", QuoteVolume: "415000000.25",
+		OpenTime: 1699913600000, CloseTime: 1700000000000, FirstID: 100, LastID: 18150, TradeCount: 18051,
+	}
+	futuresMiniTicker := &WsFuturesMiniMarketTickerEvent{
+		Event: "24hrMiniTicker", Time: 1700000000123, Symbol: "BTCUSDT",
+		ClosePrice: "42000.10", OpenPrice: "41000.00", HighPrice: "42100.00", LowPrice: "40900.00",
+		Volume: "10000.5", QuoteVolume: "415000000.25",
+	}
+	spotMiniTicker := &WsSpotMiniMarketTickerEvent{
+		Event: "24hrMiniTicker", Time: 1700000000123, Symbol: "BNBUSDT",
+		ClosePrice: "300.10", OpenPrice: "295.00", HighPrice: "302.00", LowPrice: "294.50",
+		Volume: "12000", QuoteVolume: "3600000",
+	}
+
+	tests := []struct {
+		fixture string
+		uri     string
+		serve   streamServe
+		want    []any
+	}{
+		{
+			"futures_force_order.json", "/ws/btcusdt@forceOrder",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsFuturesLiquidationOrderServe("BTCUSDT", func(e *WsFuturesLiquidationOrderEvent) { got <- e }, eh, opt)
+			},
+			[]any{&WsFuturesLiquidationOrderEvent{
+				Event: "forceOrder", Time: 1568014460893,
+				LiquidationOrder: WsFuturesLiquidationOrder{
+					Symbol: "BTCUSDT", Side: common.SideTypeSell, OrderType: common.OrderTypeLimit,
+					TimeInForce: common.TimeInForceTypeIOC, OrigQuantity: "0.014", Price: "9910", AvgPrice: "9910",
+					OrderStatus: common.OrderStatusTypeFilled, LastFilledQty: "0.014", AccumulatedFilledQty: "0.014",
+					TradeTime: 1568014460893,
+				},
+			}},
+		},
+		{
+			"futures_all_force_order.json", "/ws/!forceOrder@arr",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsFuturesAllLiquidationOrderServe(func(e *WsFuturesLiquidationOrderEvent) { got <- e }, eh, opt)
+			},
+			[]any{&WsFuturesLiquidationOrderEvent{
+				Event: "forceOrder", Time: 1568014460900,
+				LiquidationOrder: WsFuturesLiquidationOrder{
+					Symbol: "ETHUSDT", Side: common.SideTypeBuy, OrderType: common.OrderTypeLimit,
+					TimeInForce: common.TimeInForceTypeIOC, OrigQuantity: "1.5", Price: "181.2", AvgPrice: "181.1",
+					OrderStatus: common.OrderStatusTypeFilled, LastFilledQty: "1.5", AccumulatedFilledQty: "1.5",
+					TradeTime: 1568014460899,
+				},
+			}},
+		},
+		{
+			"futures_mini_ticker.json", "/ws/btcusdt@miniTicker",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsFuturesMiniMarketTickerServe("BTCUSDT", func(e *WsFuturesMiniMarketTickerEvent) { got <- e }, eh, opt)
+			},
+			[]any{futuresMiniTicker},
+		},
+		{
+			"futures_all_mini_ticker.json", "/ws/!miniTicker@arr",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsFuturesAllMiniMarketTickerServe(func(e WsFuturesAllMiniMarketTickerEvent) { got <- e }, eh, opt)
+			},
+			[]any{WsFuturesAllMiniMarketTickerEvent{futuresMiniTicker, {
+				Event: "24hrMiniTicker", Time: 1700000000123, Symbol: "ETHUSDT",
+				ClosePrice: "2200.10", OpenPrice: "2150.00", HighPrice: "2210.00", LowPrice: "2140.00",
+				Volume: "50000", QuoteVolume: "109000000",
+			}}},
+		},
+		{
+			"futures_ticker.json", "/ws/btcusdt@ticker",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsFuturesMarketTickerServe("BTCUSDT", func(e *WsFuturesMarketTickerEvent) { got <- e }, eh, opt)
+			},
+			[]any{futuresTicker},
+		},
+		{
+			"futures_all_ticker.json", "/ws/!ticker@arr",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsFuturesAllMarketTickerServe(func(e WsFuturesAllMarketTickerEvent) { got <- e }, eh, opt)
+			},
+			[]any{WsFuturesAllMarketTickerEvent{futuresTicker, {
+				Event: "24hrTicker", Time: 1700000000123, Symbol: "ETHUSDT",
+				PriceChange: "50.10", PriceChangePercent: "2.330", WeightedAvgPrice: "2180.00",
+				ClosePrice: "2200.10", CloseQty: "0.5", OpenPrice: "2150.00", HighPrice: "2210.00", LowPrice: "2140.00",
+				BaseVolume: "50000", QuoteVolume: "109000000",
+				OpenTime: 1699913600000, CloseTime: 1700000000000, FirstID: 7, LastID: 9007, TradeCount: 9001,
+			}}},
+		},
+		{
+			"futures_continuous_kline.json", "/ws/btcusdt_perpetual@continuousKline_1m",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsFuturesContinuousKlineServe("BTCUSDT", "PERPETUAL", common.Interval1m, func(e *WsFuturesContinuousKlineEvent) { got <- e }, eh, opt)
+			},
+			[]any{&WsFuturesContinuousKlineEvent{
+				Event: "continuous_kline", Time: 1607443058651, PairSymbol: "BTCUSDT", ContractType: "PERPETUAL",
+				Kline: WsFuturesKline{
+					StartTime: 1607443020000, EndTime: 1607443079999, Interval: "1m",
+					FirstTradeID: 116467658886, LastTradeID: 116468012423,
+					Open: "18787.00", Close: "18804.04", High: "18804.04", Low: "18786.54", Volume: "197.664",
+					TradeNum: 543, QuoteVolume: "3715253.19494", ActiveBuyVolume: "184.769", ActiveBuyQuoteVolume: "3472925.84746",
+				},
+			}},
+		},
+		{
+			"futures_index_price.json", "/ws/btcusdt@indexPrice@1s",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsFuturesIndexPriceServe("BTCUSDT", time.Second, func(e *WsFuturesIndexPriceEvent) { got <- e }, eh, opt)
+			},
+			[]any{&WsFuturesIndexPriceEvent{Event: "indexPriceUpdate", Time: 1591261236000, Pair: "BTCUSDT", IndexPrice: "9636.57860000"}},
+		},
+		{
+			"futures_all_mark_price_1s.json", "/ws/!markPrice@arr@1s",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsFuturesAllMarkPriceServeWithRate(time.Second, func(e *WsFuturesMarkPriceEvent) { got <- e }, eh, opt)
+			},
+			[]any{
+				&WsFuturesMarkPriceEvent{
+					Event: "markPriceUpdate", Time: 1562305380000, Symbol: "BTCUSDT", MarkPrice: "11794.15000000",
+					IndexPrice: "11784.62659091", EstimatedSettlePrice: "11784.25641265", FundingRate: "0.00038167", NextFundingTime: 1562306400000,
+				},
+				&WsFuturesMarkPriceEvent{
+					Event: "markPriceUpdate", Time: 1562305380000, Symbol: "ETHUSDT", MarkPrice: "310.12000000",
+					IndexPrice: "310.05000000", EstimatedSettlePrice: "310.01000000", FundingRate: "-0.00010000", NextFundingTime: 1562306400000,
+				},
+			},
+		},
+		{
+			"spot_trade.json", "/ws/bnbusdt@trade",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsSpotTradeServe("BNBUSDT", func(e *WsSpotTradeEvent) { got <- e }, eh, opt)
+			},
+			[]any{&WsSpotTradeEvent{
+				Event: "trade", Time: 1700000000123, Symbol: "BNBUSDT", TradeID: 12345, Price: "300.10", Quantity: "2.5",
+				BuyerOrderID: 88, SellerOrderID: 50, TradeTime: 1700000000120, IsBuyerMaker: true, Ignore: true,
+			}},
+		},
+		{
+			"spot_mini_ticker.json", "/ws/bnbusdt@miniTicker",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsSpotMiniMarketTickerServe("BNBUSDT", func(e *WsSpotMiniMarketTickerEvent) { got <- e }, eh, opt)
+			},
+			[]any{spotMiniTicker},
+		},
+		{
+			"spot_all_mini_ticker.json", "/ws/!miniTicker@arr",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsSpotAllMiniMarketTickerServe(func(e WsSpotAllMiniMarketTickerEvent) { got <- e }, eh, opt)
+			},
+			[]any{WsSpotAllMiniMarketTickerEvent{spotMiniTicker, {
+				Event: "24hrMiniTicker", Time: 1700000000123, Symbol: "ASTERUSDT",
+				ClosePrice: "1.20", OpenPrice: "1.10", HighPrice: "1.25", LowPrice: "1.05",
+				Volume: "900000", QuoteVolume: "1050000",
+			}}},
+		},
+		{
+			"spot_ticker.json", "/ws/bnbusdt@ticker",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsSpotMarketStatServe("BNBUSDT", func(e *WsSpotMarketStatEvent) { got <- e }, eh, opt)
+			},
+			[]any{&WsSpotMarketStatEvent{
+				Event: "24hrTicker", Time: 1700000000123, Symbol: "BNBUSDT",
+				PriceChange: "5.10", PriceChangePercent: "1.729", WeightedAvgPrice: "298.00", PrevClosePrice: "295.00",
+				LastPrice: "300.10", CloseQty: "2.5", BidPrice: "300.00", BidQty: "10", AskPrice: "300.20", AskQty: "7",
+				OpenPrice: "295.00", HighPrice: "302.00", LowPrice: "294.50", BaseVolume: "12000", QuoteVolume: "3600000",
+				OpenTime: 1699913600000, CloseTime: 1700000000000, FirstID: 0, LastID: 18150, Count: 18151,
+			}},
+		},
+		{
+			"spot_partial_depth.json", "/ws/bnbusdt@depth10@100ms",
+			func(got chan<- any, eh ErrHandler) (chan struct{}, chan struct{}, error) {
+				return WsSpotPartialDepthServeWithSpeed("bnbusdt", 10, 100*time.Millisecond, func(e *WsSpotPartialDepthEvent) { got <- e }, eh, opt)
+			},
+			[]any{&WsSpotPartialDepthEvent{
+				Symbol:       "BNBUSDT",
+				LastUpdateID: 160,
+				Bids:         []Bid{{Price: "300.00", Quantity: "10"}, {Price: "299.90", Quantity: "4.5"}},
+				Asks:         []Ask{{Price: "300.20", Quantity: "7"}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			frame, err := os.ReadFile(filepath.Join("testdata", "streams", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			uris := make(chan string, 1)
+			newWsTestServer(t, func(uri string, conn *websocket.Conn) {
+				uris <- uri
+				conn.WriteMessage(websocket.TextMessage, bytes.TrimSpace(frame))
+				drain(conn)
+			})
+
+			got := make(chan any, len(tt.want)+1)
+			doneC, stopC, err := tt.serve(got, func(err error) { t.Error(err) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				close(stopC)
+				<-doneC
+			}()
+
+			if uri := waitC(t, uris, 1)[0]; uri != tt.uri {
+				t.Errorf("requested %s, want %s", uri, tt.uri)
+			}
+			for i, event := range waitC(t, got, len(tt.want)) {
+				if !reflect.DeepEqual(event, tt.want[i]) {
+					t.Errorf("event %d = %+v, want %+v", i, event, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package aster
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	rc := &ReconnectConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: -1}
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, w := range want {
+		if got := rc.backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, w*time.Millisecond)
+		}
+	}
+
+	defaults := &ReconnectConfig{}
+	for attempt, base := range map[int]time.Duration{1: 500 * time.Millisecond, 3: 2 * time.Second, 10: 30 * time.Second} {
+		for i := 0; i < 100; i++ {
+			if got := defaults.backoff(attempt); got < base*8/10 || got > base*12/10 {
+				t.Fatalf("backoff(%d) = %s, want %s ±20%%", attempt, got, base)
+			}
+		}
+	}
+}
+
+func TestServeReportsReadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		close func(conn *websocket.Conn)
+		code  int
+	}{
+		{"going away", func(conn *websocket.Conn) {
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+		}, websocket.CloseGoingAway},
+		{"normal closure", func(conn *websocket.Conn) {
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		}, websocket.CloseNormalClosure},
+		{"dropped", func(conn *websocket.Conn) {
+			conn.UnderlyingConn().Close()
+		}, websocket.CloseAbnormalClosure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newWsTestServer(t, func(uri string, conn *websocket.Conn) {
+				tt.close(conn)
+				drain(conn)
+			})
+			errs := make(chan error, 10)
+			cfg := newWsConfig(BaseWsMainURL+"/ws/x", WithStreamReconnect(nil))
+			doneC, _, err := wsServe(cfg, func([]byte) {}, func(err error) { errs <- err })
+			if err != nil {
+				t.Fatal(err)
+			}
+			<-doneC
+			got := waitC(t, errs, 1)[0]
+			if !websocket.IsCloseError(got, tt.code) {
+				t.Errorf("got error %v, want close code %d", got, tt.code)
+			}
+		})
+	}
+}
+
+func TestServeStopDoesNotReport(t *testing.T) {
+	newWsTestServer(t, func(uri string, conn *websocket.Conn) { drain(conn) })
+	cfg := newWsConfig(BaseWsMainURL+"/ws/x", WithStreamReconnect(&ReconnectConfig{}))
+	doneC, stopC, err := wsServe(cfg, func([]byte) {}, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(stopC)
+	<-doneC
+}
+
+func TestReconnectLifecycle(t *testing.T) {
+	var conns atomic.Int32
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conns.Add(1) > 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte(`{}`))
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+		drain(conn)
+	}))
+	defer srv.Close()
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(format string, args ...interface{}) {
+		mu.Lock()
+		events = append(events, fmt.Sprintf(format, args...))
+		mu.Unlock()
+	}
+	endpoint := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/x"
+	rc := &ReconnectConfig{
+		InitialBackoff: time.Millisecond,
+		Jitter:         -1,
+		MaxAttempts:    2,
+		OnConnected:    func(e string) { record("connected %v", e == endpoint) },
+		OnDisconnected: func(e string, err error) {
+			record("disconnected %v", websocket.IsCloseError(err, websocket.CloseGoingAway))
+		},
+		OnReconnecting: func(e string, attempt int, delay time.Duration) { record("reconnecting %d %s", attempt, delay) },
+		OnGaveUp:       func(e string, err error) { record("gave up %v", errors.Is(err, websocket.ErrBadHandshake)) },
+	}
+	cfg := newWsConfig(endpoint, WithStreamReconnect(rc))
+	doneC, _, err := wsServe(cfg, func(message []byte) { record("message %s", message) }, func(err error) { record("error") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-doneC:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not give up")
+	}
+
+	want := []string{
+		"connected true",
+		"message {}",
+		"error",
+		"disconnected true",
+		"reconnecting 1 1ms",
+		"error",
+		"reconnecting 2 2ms",
+		"error",
+		"gave up true",
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got events\n%s\nwant\n%s", strings.Join(events, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestReconnectMaxConnectionAge(t *testing.T) {
+	var conns atomic.Int32
+	newWsTestServer(t, func(uri string, conn *websocket.Conn) {
+		n := conns.Add(1)
+		go drain(conn)
+		for {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(strconv.Itoa(int(n)))); err != nil {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+
+	disconnects := make(chan error, 10)
+	messages := make(chan string, 1000)
+	rc := &ReconnectConfig{
+		MaxConnectionAge: 100 * time.Millisecond,
+		OnDisconnected:   func(endpoint string, err error) { disconnects <- err },
+	}
+	cfg := newWsConfig(BaseWsMainURL+"/ws/x", WithStreamReconnect(rc))
+	doneC, stopC, err := wsServe(cfg, func(message []byte) {
+		select {
+		case messages <- string(message):
+		default:
+		}
+	}, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		close(stopC)
+		<-doneC
+	}()
+
+	if err := waitC(t, disconnects, 1)[0]; !errors.Is(err, ErrMaxConnectionAge) {
+		t.Errorf("got disconnect %v, want ErrMaxConnectionAge", err)
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m := <-messages:
+			if m != "1" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("no message from the replacement connection")
+		}
+	}
+}
+
+func TestHeartbeatPongTimeout(t *testing.T) {
+	done := make(chan struct{})
+	newWsTestServer(t, func(uri string, conn *websocket.Conn) {
+		// Never read, so pings go unanswered
+		<-done
+	})
+	t.Cleanup(func() { close(done) })
+
+	errs := make(chan error, 10)
+	cfg := newWsConfig(BaseWsMainURL+"/ws/x", WithStreamReconnect(nil),
+		WithStreamHeartbeat(&HeartbeatConfig{PingInterval: 20 * time.Millisecond, PongTimeout: 20 * time.Millisecond}))
+	doneC, _, err := wsServe(cfg, func([]byte) {}, func(err error) { errs <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := waitC(t, errs, 1)[0]; !errors.Is(err, ErrPongTimeout) {
+		t.Errorf("got error %v, want ErrPongTimeout", err)
+	}
+	select {
+	case <-doneC:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not end after the pong timeout")
+	}
+}
+
+func TestHeartbeatStaleStreamReconnects(t *testing.T) {
+	conns := make(chan struct{}, 10)
+	newWsTestServer(t, func(uri string, conn *websocket.Conn) {
+		// Answer pings but never send data
+		conns <- struct{}{}
+		drain(conn)
+	})
+
+	errs := make(chan error, 10)
+	cfg := newWsConfig(BaseWsMainURL+"/ws/x", WithStreamReconnect(nil),
+		WithStreamHeartbeat(&HeartbeatConfig{PingInterval: 10 * time.Millisecond, MaxSilence: 50 * time.Millisecond}))
+	doneC, stopC, err := wsServe(cfg, func([]byte) {}, func(err error) { errs <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := waitC(t, errs, 1)[0]; !errors.Is(err, ErrStreamStale) {
+		t.Errorf("got error %v, want ErrStreamStale", err)
+	}
+	waitC(t, conns, 2)
+	select {
+	case <-doneC:
+		t.Fatal("stream ended instead of reconnecting")
+	default:
+	}
+	close(stopC)
+	<-doneC
+}
+
+func TestWithStreamEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint, base, want string
+	}{
+		{"wss://fstream.asterdex.com/ws/btcusdt@depth", "ws://127.0.0.1:8080/futures", "ws://127.0.0.1:8080/futures/ws/btcusdt@depth"},
+		{"wss://sstream.asterdex.com/stream?streams=a@depth/b@depth", "wss://intranet.example/", "wss://intranet.example/stream?streams=a@depth/b@depth"},
+	}
+	for _, tt := range tests {
+		if got := newWsConfig(tt.endpoint, WithStreamEndpoint(tt.base)).Endpoint; got != tt.want {
+			t.Errorf("endpoint %s on %s = %s, want %s", tt.endpoint, tt.base, got, tt.want)
+		}
+	}
+}
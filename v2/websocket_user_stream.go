@@ -0,0 +1,358 @@
+package aster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+// Errors passed to UserStream.OnGap
+var (
+	ErrListenKeyExpired       = errors.New("aster: listen key expired")
+	ErrUserStreamDisconnected = errors.New("aster: user data stream disconnected")
+)
+
+// ErrUserStreamStarted is returned by Start on a running or stopped stream
+var ErrUserStreamStarted = errors.New("aster: user stream already started")
+
+// UserStream keeps a user data stream open. It creates the listen key, keeps
+// it alive, reconnects after disconnects and replaces the key when it
+// expires. Events received while the stream was down are lost, so OnGap
+// tells consumers to reconcile orders and balances through REST.
+type UserStream struct {
+	KeepaliveInterval time.Duration    // 30m by default
+	Reconnect         *ReconnectConfig // backoff between attempts, the defaults when nil
+	LocalAddress      string
+	Options           []StreamOption // configure the connection, e.g. WithStreamProxy
+
+	// OnGap is called once the stream is back after events may have been
+	// missed, with ErrListenKeyExpired or ErrUserStreamDisconnected
+	OnGap func(reason error)
+
+	keys       listenKeys
+	futures    bool
+	handler    WsHandler
+	errHandler ErrHandler
+
+	mu        sync.Mutex
+	listenKey string
+	started   bool
+	expiredC  chan string
+	ctx       context.Context
+	cancel    context.CancelFunc
+	doneC     chan struct{}
+}
+
+// NewSpotUserStream creates a spot user stream. It starts with Start.
+func NewSpotUserStream(c *BaseClient, handler WsSpotUserDataHandler, errHandler ErrHandler) *UserStream {
+	s := newUserStream(c, spotListenKeys{&SpotClient{BaseClient: c}}, false, errHandler)
+	s.handler = spotUserDataHandler(func(event *WsSpotUserDataEvent) {
+		if event.Event == UserDataEventTypeListenKeyExpired {
+			s.expired(event.ListenKey)
+		}
+		handler(event)
+	}, errHandler)
+	return s
+}
+
+// NewFuturesUserStream creates a futures user stream. It starts with Start.
+func NewFuturesUserStream(c *BaseClient, handler WsFuturesUserDataHandler, errHandler ErrHandler) *UserStream {
+	s := newUserStream(c, futuresListenKeys{&FuturesClient{BaseClient: c}}, true, errHandler)
+	s.handler = futuresUserDataHandler(func(event *WsFuturesUserDataEvent) {
+		if event.Event == UserDataEventTypeListenKeyExpired {
+			s.expired(event.ListenKey)
+		}
+		handler(event)
+	}, errHandler)
+	return s
+}
+
+func newUserStream(c *BaseClient, keys listenKeys, futures bool, errHandler ErrHandler) *UserStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &UserStream{
+		KeepaliveInterval: 30 * time.Minute,
+		LocalAddress:      c.LocalAddress,
+		keys:              keys,
+		futures:           futures,
+		errHandler:        errHandler,
+		expiredC:          make(chan string, 1),
+		ctx:               ctx,
+		cancel:            cancel,
+		doneC:             make(chan struct{}),
+	}
+}
+
+// NewUserStream creates a spot user stream for the client
+func (c *SpotClient) NewUserStream(handler WsSpotUserDataHandler, errHandler ErrHandler) *UserStream {
+	return NewSpotUserStream(c.BaseClient, handler, errHandler)
+}
+
+// NewUserStream creates a futures user stream for the client
+func (c *FuturesClient) NewUserStream(handler WsFuturesUserDataHandler, errHandler ErrHandler) *UserStream {
+	return NewFuturesUserStream(c.BaseClient, handler, errHandler)
+}
+
+// Start creates the listen key and connects. Failures after Start are
+// retried in the background and reported to the error handler.
+func (s *UserStream) Start() error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return ErrUserStreamStarted
+	}
+	s.started = true
+	s.mu.Unlock()
+
+	key, err := s.startKey()
+	if err != nil {
+		close(s.doneC)
+		return err
+	}
+	wsDone, wsStop, err := s.serve(key)
+	if err != nil {
+		s.closeKey(key)
+		close(s.doneC)
+		return err
+	}
+	go s.run(key, wsDone, wsStop)
+	return nil
+}
+
+// Stop disconnects and closes the listen key
+func (s *UserStream) Stop() {
+	s.cancel()
+	s.mu.Lock()
+	if !s.started {
+		s.started = true
+		close(s.doneC)
+	}
+	s.mu.Unlock()
+	<-s.doneC
+}
+
+// Done is closed once the stream has stopped
+func (s *UserStream) Done() <-chan struct{} {
+	return s.doneC
+}
+
+// ListenKey returns the listen key in use
+func (s *UserStream) ListenKey() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listenKey
+}
+
+// run keeps the key alive and replaces the connection or the key until the
+// stream stops or gives up
+func (s *UserStream) run(key string, wsDone, wsStop chan struct{}) {
+	defer close(s.doneC)
+	keepalive := time.NewTicker(s.KeepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		var reason error
+		select {
+		case <-s.ctx.Done():
+			stopWs(wsDone, wsStop)
+			s.closeKey(key)
+			return
+		case <-keepalive.C:
+			err := s.keepaliveKey(key)
+			if err == nil {
+				continue
+			}
+			if !isListenKeyGone(err) {
+				s.error(err)
+				continue
+			}
+			reason = ErrListenKeyExpired
+		case expired := <-s.expiredC:
+			if expired != "" && expired != key {
+				continue
+			}
+			reason = ErrListenKeyExpired
+		case <-wsDone:
+			reason = ErrUserStreamDisconnected
+		}
+		stopWs(wsDone, wsStop)
+
+		var ok bool
+		if key, wsDone, wsStop, ok = s.reconnect(key, reason); !ok {
+			if key != "" {
+				s.closeKey(key)
+			}
+			return
+		}
+		keepalive.Reset(s.KeepaliveInterval)
+		if s.OnGap != nil {
+			s.OnGap(reason)
+		}
+	}
+}
+
+// reconnect connects again with backoff, keeping the listen key unless it
+// is gone. It returns false once the stream stops or the attempts run out.
+func (s *UserStream) reconnect(key string, reason error) (string, chan struct{}, chan struct{}, bool) {
+	rc := s.Reconnect
+	if rc == nil {
+		rc = &ReconnectConfig{}
+	}
+	if errors.Is(reason, ErrListenKeyExpired) {
+		key = ""
+	}
+	for attempt := 1; ; attempt++ {
+		if rc.MaxAttempts > 0 && attempt > rc.MaxAttempts {
+			s.error(fmt.Errorf("aster: user stream gave up after %d attempts", rc.MaxAttempts))
+			return key, nil, nil, false
+		}
+		// a replaced key reconnects at once, a dropped connection waits
+		if key != "" || attempt > 1 {
+			timer := time.NewTimer(rc.backoff(attempt))
+			select {
+			case <-timer.C:
+			case <-s.ctx.Done():
+				timer.Stop()
+				return key, nil, nil, false
+			}
+		}
+
+		var err error
+		if key != "" {
+			if err = s.keepaliveKey(key); err != nil && isListenKeyGone(err) {
+				key = ""
+			}
+		}
+		if key == "" {
+			if key, err = s.startKey(); err != nil {
+				s.error(err)
+				continue
+			}
+		}
+		wsDone, wsStop, err := s.serve(key)
+		if err != nil {
+			s.error(err)
+			continue
+		}
+		// a listenKeyExpired of the previous key is no longer relevant
+		select {
+		case <-s.expiredC:
+		default:
+		}
+		return key, wsDone, wsStop, true
+	}
+}
+
+// serve connects to the stream of a listen key. Reconnects are handled by
+// run so that every one of them is reported as a gap.
+func (s *UserStream) serve(key string) (doneC, stopC chan struct{}, err error) {
+	endpoint := fmt.Sprintf("%s/ws/%s", getWsEndpoint(s.futures, false), key)
+	cfg := newWsConfigWithIP(endpoint, s.LocalAddress, s.Options...)
+	cfg.market, cfg.userData = marketName(s.futures), true
+	cfg.Reconnect = nil
+	// user data is event driven, silence does not mean the stream is stale
+	cfg.Heartbeat = cfg.Heartbeat.withoutWatchdog()
+	return wsServe(cfg, s.handler, s.errHandler)
+}
+
+// expired signals a listenKeyExpired event to run
+func (s *UserStream) expired(key string) {
+	select {
+	case s.expiredC <- key:
+	default:
+	}
+}
+
+// listenKeys creates, keeps alive and closes the listen keys of a market
+// through its user stream services
+type listenKeys interface {
+	start(ctx context.Context) (string, error)
+	keepalive(ctx context.Context, key string) error
+	close(ctx context.Context, key string) error
+}
+
+type spotListenKeys struct{ c *SpotClient }
+
+func (k spotListenKeys) start(ctx context.Context) (string, error) {
+	return k.c.NewStartUserStreamService().Do(ctx)
+}
+
+func (k spotListenKeys) keepalive(ctx context.Context, key string) error {
+	return k.c.NewKeepaliveUserStreamService().ListenKey(key).Do(ctx)
+}
+
+func (k spotListenKeys) close(ctx context.Context, key string) error {
+	return k.c.NewCloseUserStreamService().ListenKey(key).Do(ctx)
+}
+
+type futuresListenKeys struct{ c *FuturesClient }
+
+func (k futuresListenKeys) start(ctx context.Context) (string, error) {
+	return k.c.NewStartUserStreamService().Do(ctx)
+}
+
+func (k futuresListenKeys) keepalive(ctx context.Context, key string) error {
+	return k.c.NewKeepaliveUserStreamService().ListenKey(key).Do(ctx)
+}
+
+func (k futuresListenKeys) close(ctx context.Context, key string) error {
+	return k.c.NewCloseUserStreamService().ListenKey(key).Do(ctx)
+}
+
+// startKey creates a listen key
+func (s *UserStream) startKey() (string, error) {
+	key, err := s.keys.start(s.ctx)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.listenKey = key
+	s.mu.Unlock()
+	return key, nil
+}
+
+// keepaliveKey extends the validity of a listen key
+func (s *UserStream) keepaliveKey(key string) error {
+	return s.keys.keepalive(s.ctx, key)
+}
+
+// closeKey closes a listen key on shutdown. It does not use s.ctx, which is
+// already cancelled.
+func (s *UserStream) closeKey(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.keys.close(ctx, key); err != nil {
+		s.error(err)
+	}
+}
+
+// error reports an error to the error handler
+func (s *UserStream) error(err error) {
+	if s.errHandler != nil {
+		s.errHandler(err)
+	}
+}
+
+// stopWs stops a stream unless it has already ended
+func stopWs(doneC, stopC chan struct{}) {
+	select {
+	case <-doneC:
+		return
+	default:
+	}
+	close(stopC)
+	<-doneC
+}
+
+// isListenKeyGone reports whether a listen key request failed because the
+// key does not exist anymore, by its error code or a 404 status
+func isListenKeyGone(err error) bool {
+	var apiErr *common.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == -1125 || apiErr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,49 @@
+package aster_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/drinkthere/go-aster/v2"
+	"github.com/drinkthere/go-aster/v2/astertest"
+	"github.com/drinkthere/go-aster/v2/common"
+)
+
+func TestUserStreamReplacesExpiredKey(t *testing.T) {
+	srv := astertest.NewServer()
+	defer srv.Close()
+	srv.AddAccount("key", "secret")
+	client := aster.NewFutures("key", "secret", aster.WithBaseURL(srv.URL()))
+
+	gaps := make(chan error, 10)
+	s := client.NewUserStream(func(e *aster.WsFuturesUserDataEvent) {}, func(err error) { t.Error(err) })
+	s.Reconnect = &aster.ReconnectConfig{InitialBackoff: time.Millisecond}
+	s.Options = []aster.StreamOption{aster.WithStreamEndpoint(srv.FuturesWsURL())}
+	s.OnGap = func(reason error) { gaps <- reason }
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	first := s.ListenKey()
+	if len(first) != 64 {
+		t.Fatalf("got listen key %q", first)
+	}
+
+	srv.ExpireListenKey(first)
+	if reason := receive(t, gaps, 1)[0]; !errors.Is(reason, aster.ErrListenKeyExpired) {
+		t.Errorf("got gap %v, want ErrListenKeyExpired", reason)
+	}
+	second := s.ListenKey()
+	if second == "" || second == first {
+		t.Errorf("got listen key %q after %q expired", second, first)
+	}
+
+	// Stop closes the key, so it cannot be kept alive anymore
+	s.Stop()
+	err := client.NewKeepaliveUserStreamService().ListenKey(second).Do(context.Background())
+	var apiErr *common.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != -1125 {
+		t.Errorf("keepalive of a stopped stream returned %v, want API error -1125", err)
+	}
+}